@@ -16,17 +16,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/brurbanko/mercury/internal/scrapper"
 
 	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
 
 	"github.com/brurbanko/mercury/config"
 	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/database/memory"
 	"github.com/brurbanko/mercury/server"
+	"github.com/brurbanko/mercury/service/bot"
 
 	"github.com/brurbanko/mercury/service/hearings"
 
@@ -35,6 +41,9 @@ import (
 )
 
 func main() {
+	ephemeral := flag.Bool("ephemeral", false, "keep hearings in memory instead of database. Data is lost on exit")
+	flag.Parse()
+
 	l := log.Logger.With().
 		Str("service", "crawler").
 		Logger()
@@ -55,25 +64,41 @@ func main() {
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 
-	err = run(ctx, cancel, cfg, &l)
+	if flag.Arg(0) == "migrate" {
+		err = migrate(ctx, cfg, flag.Args()[1:], &l)
+		cancel()
+		if err != nil {
+			l.Fatal().Err(err).Msg("migration failed")
+		}
+		return
+	}
+
+	err = run(ctx, cancel, cfg, *ephemeral, &l)
 	if err != nil {
 		l.Fatal().Err(err).Msg("service failed start")
 	}
 	l.Info().Msg("stopped")
 }
 
-func run(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, logger *zerolog.Logger) error {
+func run(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, ephemeral bool, logger *zerolog.Logger) error {
 	defer cancel()
 
-	db, err := database.New(cfg.Database.DSN, logger)
-	if err != nil {
-		return fmt.Errorf("failed connect to database: %w", err)
-	}
-	defer func() {
-		if cerr := db.Close(); cerr != nil {
-			logger.Error().Err(cerr).Msg("failed close database")
+	var db database.Repository
+	if ephemeral {
+		logger.Warn().Msg("ephemeral mode. hearings are kept in memory and will be lost on exit")
+		db = memory.New()
+	} else {
+		client, err := database.New(cfg.Database.DSN, logger)
+		if err != nil {
+			return fmt.Errorf("failed connect to database: %w", err)
 		}
-	}()
+		defer func() {
+			if cerr := client.Close(); cerr != nil {
+				logger.Error().Err(cerr).Msg("failed close database")
+			}
+		}()
+		db = client
+	}
 
 	s := scrapper.New(&scrapper.Options{
 		Logger:      logger,
@@ -81,22 +106,153 @@ func run(ctx context.Context, cancel context.CancelFunc, cfg *config.Config, log
 		MaxBodySize: 1 << 20, // 1MB
 		CacheDir:    "./cache",
 	})
-	p, err := publisher.New(&publisher.Options{
+	p, err := publisher.NewTelegram(&publisher.TelegramOptions{
 		Logger: logger,
 		Token:  cfg.Publish.Token,
 		ChatID: cfg.Publish.ChatID,
+		APIURL: cfg.Publish.APIURL,
+		Format: cfg.Publish.Format,
 	})
 	if err != nil {
 		return fmt.Errorf("failed create publisher: %w", err)
 	}
+	schedule, err := publisher.ParseSchedule(cfg.Publish.Windows, cfg.Publish.Spacing, cfg.Publish.Silent)
+	if err != nil {
+		return fmt.Errorf("invalid publishing schedule: %w", err)
+	}
+	var targets []publisher.Target
+	if cfg.Publish.Targets != "" {
+		if targets, err = publisher.ReadTargets(cfg.Publish.Targets, logger); err != nil {
+			return fmt.Errorf("failed load publishing targets: %w", err)
+		}
+	}
+
+	renderer := render.New()
+	if cfg.Publish.Templates != "" {
+		if renderer, err = render.Load(cfg.Publish.Templates); err != nil {
+			return fmt.Errorf("failed load templates: %w", err)
+		}
+	}
+
+	var reminders []hearings.Reminder
+	if cfg.Reminders.Enabled {
+		reminders = hearings.DefaultReminders()
+		if cfg.Reminders.File != "" {
+			if reminders, err = hearings.ReadReminders(cfg.Reminders.File); err != nil {
+				return fmt.Errorf("failed load reminders: %w", err)
+			}
+		}
+	}
+
+	// Subscribed chats are notified only when bot is enabled
+	var chats hearings.ChatPublisher
+	var botSender *publisher.Telegram
+	botToken := cfg.Bot.Token
+	if botToken == "" {
+		botToken = cfg.Publish.Token
+	}
+	if cfg.Bot.Enabled {
+		// Private chats allow more frequent messages than channels
+		botSender, err = publisher.NewTelegram(&publisher.TelegramOptions{
+			Logger:       logger,
+			Token:        botToken,
+			APIURL:       cfg.Bot.APIURL,
+			ChatInterval: time.Second,
+		})
+		if err != nil {
+			return fmt.Errorf("failed create bot publisher: %w", err)
+		}
+		chats = botSender
+	}
+
+	var moderation *hearings.ModerationConfig
+	moderationChat := ""
+	if cfg.Moderation.Enabled {
+		moderation = &hearings.ModerationConfig{AutoApprove: cfg.Moderation.AutoApprove}
+		// Previews are sent by bot, so it handles their buttons
+		if cfg.Moderation.Chat != "" {
+			if botSender == nil {
+				return errors.New("moderation chat requires enabled bot")
+			}
+			moderationChat = cfg.Moderation.Chat
+			moderation.Chat, moderation.Sender = moderationChat, botSender
+		}
+	}
+
+	var digest *hearings.DigestConfig
+	if cfg.Digest.Period != "" {
+		mailer, err := publisher.NewEmail(&publisher.EmailOptions{
+			Logger:   logger,
+			Host:     cfg.Digest.SMTPHost,
+			Port:     cfg.Digest.SMTPPort,
+			Username: cfg.Digest.SMTPUsername,
+			Password: cfg.Digest.SMTPPassword,
+			From:     cfg.Digest.From,
+			To:       cfg.Digest.Recipients,
+		})
+		if err != nil {
+			return fmt.Errorf("failed create digest mailer: %w", err)
+		}
+		digest = &hearings.DigestConfig{
+			Period:     cfg.Digest.Period,
+			Hour:       cfg.Digest.Hour,
+			Recipients: cfg.Digest.Recipients,
+			Secret:     cfg.Digest.Secret,
+			URL:        cfg.Digest.URL,
+			Mailer:     mailer,
+		}
+		if err = digest.Validate(); err != nil {
+			return fmt.Errorf("invalid digest: %w", err)
+		}
+	}
 
 	srv := hearings.New(&hearings.Config{
-		Database:  db,
-		Scrapper:  s,
-		Publisher: p,
-		Logger:    logger,
+		Database:   db,
+		Scrapper:   s,
+		Publisher:  p,
+		Format:     cfg.Publish.Format,
+		Schedule:   schedule,
+		Targets:    targets,
+		Digest:     digest,
+		Bot:        chats,
+		Reminders:  reminders,
+		Renderer:   renderer,
+		Moderation: moderation,
+		Logger:     logger,
 	})
 
+	if cfg.Crawler.Rules != "" {
+		if err = srv.LoadRules(cfg.Crawler.Rules); err != nil {
+			return fmt.Errorf("failed load parsing rules: %w", err)
+		}
+		go srv.WatchRules(ctx, cfg.Crawler.Rules, cfg.Crawler.RulesInterval)
+	}
+
+	go srv.RunOutbox(ctx, cfg.Publish.Interval)
+	if len(reminders) > 0 {
+		go srv.RunReminders(ctx, cfg.Reminders.Interval)
+	}
+	if chats != nil {
+		b, err := bot.New(&bot.Options{
+			Logger:   logger,
+			Token:    botToken,
+			APIURL:   cfg.Bot.APIURL,
+			Timeout:  cfg.Bot.Timeout,
+			Hearings: srv,
+			Sender:   chats,
+
+			ModerationChat: moderationChat,
+			Callbacks:      botSender,
+		})
+		if err != nil {
+			return fmt.Errorf("failed create bot: %w", err)
+		}
+		go b.Run(ctx)
+	}
+	if digest != nil {
+		go srv.RunDigest(ctx, cfg.Digest.Interval)
+	}
+
 	http := server.New(server.Config{
 		Host:     cfg.Server.Host,
 		Port:     cfg.Server.Port,
@@ -0,0 +1,87 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/brurbanko/mercury/config"
+	"github.com/brurbanko/mercury/database"
+
+	"github.com/rs/zerolog"
+)
+
+const migrateUsage = "usage: crawler migrate status|up [steps]|down [steps]"
+
+// migrate manages schema of database: migrate status|up [steps]|down [steps].
+// Up applies all migrations by default, down reverts only the last one.
+func migrate(ctx context.Context, cfg *config.Config, args []string, logger *zerolog.Logger) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	steps := 0
+	if args[0] == "down" {
+		steps = 1
+	}
+	if len(args) == 2 {
+		var err error
+		steps, err = strconv.Atoi(args[1])
+		if err != nil || steps < 1 {
+			return fmt.Errorf("invalid steps %q. %s", args[1], migrateUsage)
+		}
+	}
+
+	db, err := database.Open(cfg.Database.DSN, logger)
+	if err != nil {
+		return fmt.Errorf("failed connect to database: %w", err)
+	}
+	defer func() {
+		if cerr := db.Close(); cerr != nil {
+			logger.Error().Err(cerr).Msg("failed close database")
+		}
+	}()
+
+	switch args[0] {
+	case "status":
+		list, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT\tMODIFIED")
+		for _, m := range list {
+			appliedAt := "pending"
+			if m.Applied {
+				appliedAt = m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%t\n", m.Version, m.Name, appliedAt, m.Modified)
+		}
+		return w.Flush()
+	case "up":
+		n, err := db.MigrateUp(ctx, steps)
+		logger.Info().Msgf("applied %d migrations", n)
+		return err
+	case "down":
+		n, err := db.MigrateDown(ctx, steps)
+		logger.Info().Msgf("reverted %d migrations", n)
+		return err
+	}
+	return fmt.Errorf("unknown command %q. %s", args[0], migrateUsage)
+}
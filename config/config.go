@@ -15,6 +15,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/cristalhq/aconfig"
 )
 
@@ -25,6 +27,9 @@ type Config struct {
 	Crawler struct {
 		Domain    string `env:"DOMAIN" default:"bga32.ru"`
 		UserAgent string `env:"USERAGENT" default:"urbanist-public-hearings (https://t.me/public_bryansk_bot)"`
+		// Rules is path to YAML file with parsing rules. Built-in rules are used if empty.
+		Rules         string        `env:"RULES"`
+		RulesInterval time.Duration `env:"RULES_INTERVAL" default:"1m"`
 	}
 	Server struct {
 		Host  string `env:"HOST"`
@@ -32,11 +37,74 @@ type Config struct {
 		Token string `env:"TOKEN"`
 	}
 	Database struct {
+		// DSN is path to SQLite file (optionally with sqlite:// scheme) or postgres:// URL
 		DSN string `env:"DSN" default:"database"`
 	}
 	Publish struct {
 		Token  string `env:"TOKEN"`
 		ChatID string `env:"CHAT"`
+		// APIURL is base URL of Telegram Bot API, for example of local Bot API server
+		APIURL string `env:"API_URL" default:"https://api.telegram.org"`
+		// Interval of delivering messages from outbox
+		Interval time.Duration `env:"INTERVAL" default:"1m"`
+		// Format of messages of telegram channel: markdown, telegram_html, entities or text
+		Format string `env:"FORMAT" default:"markdown"`
+		// Targets is path to YAML file with additional publishing channels
+		Targets string `env:"TARGETS"`
+		// Templates is directory with templates of messages overriding built-in ones
+		Templates string `env:"TEMPLATES"`
+		// Windows of publishing to telegram channel in local time like 09:00-21:00, comma separated
+		Windows []string `env:"WINDOWS"`
+		// Spacing is minimal interval between messages to telegram channel
+		Spacing time.Duration `env:"SPACING"`
+		// Silent publishes messages outside of windows at once without notification instead of waiting
+		Silent bool `env:"SILENT"`
+	}
+	Reminders struct {
+		// Enabled posts reminders before hearings and deadlines of proposals
+		Enabled bool `env:"ENABLED"`
+		// File is path to YAML file with schedule and templates of reminders. Built-in reminders are used if empty.
+		File string `env:"FILE"`
+		// Interval of checking due reminders
+		Interval time.Duration `env:"INTERVAL" default:"1m"`
+	}
+	Bot struct {
+		// Enabled starts interactive bot receiving commands of users by long polling
+		Enabled bool `env:"ENABLED"`
+		// Token of bot. Token of publishing is used if empty.
+		Token string `env:"TOKEN"`
+		// APIURL is base URL of Telegram Bot API
+		APIURL string `env:"API_URL" default:"https://api.telegram.org"`
+		// Timeout of long polling
+		Timeout time.Duration `env:"TIMEOUT" default:"30s"`
+	}
+	Moderation struct {
+		// Enabled holds new hearings for approval of moderators before publishing
+		Enabled bool `env:"ENABLED"`
+		// AutoApprove approves hearings without problems of parsing at once
+		AutoApprove bool `env:"AUTO_APPROVE"`
+		// Chat of moderators which previews with buttons are sent to. Buttons are handled by bot.
+		Chat string `env:"CHAT"`
+	}
+	Digest struct {
+		// Period of email digest: daily or weekly. Digest is disabled if empty.
+		Period string `env:"PERIOD"`
+		// Hour of local time after which digest is sent
+		Hour int `env:"HOUR" default:"9"`
+		// Recipients is comma separated list of emails
+		Recipients []string `env:"RECIPIENTS"`
+		// Secret signs unsubscribe links
+		Secret string `env:"SECRET"`
+		// URL is public base URL of service for unsubscribe links
+		URL string `env:"URL"`
+		// Interval of checking whether digest is due
+		Interval time.Duration `env:"INTERVAL" default:"10m"`
+
+		SMTPHost     string `env:"SMTP_HOST"`
+		SMTPPort     string `env:"SMTP_PORT" default:"25"`
+		SMTPUsername string `env:"SMTP_USERNAME"`
+		SMTPPassword string `env:"SMTP_PASSWORD"`
+		From         string `env:"FROM"`
 	}
 }
 
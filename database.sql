@@ -2,12 +2,130 @@ create table hearings
 (
     id         INTEGER primary key,
     link       TEXT    default '' not null unique,
-    topics     TEXT    default '',
-    proposals  TEXT    default '',
     place      TEXT    default '',
     date       TEXT    default '1970-01-01 00:00:00',
     published  BOOLEAN default false,
-    raw        TEXT    default '',
-    created_at TEXT    default '1970-01-01 00:00:00'
+    created_at TEXT    default '1970-01-01 00:00:00',
+    status     TEXT    default '',
+    category   TEXT    default '',
+    district   TEXT    default '',
+    deleted_at TEXT
 );
 
+create index hearings_date on hearings (date);
+create index hearings_category on hearings (category);
+create index hearings_district on hearings (district);
+
+create table hearing_topics
+(
+    hearing_id INTEGER not null references hearings (id) on delete cascade,
+    position   INTEGER not null,
+    topic      TEXT    default '' not null,
+    primary key (hearing_id, position)
+);
+
+create table hearing_proposals
+(
+    hearing_id INTEGER not null references hearings (id) on delete cascade,
+    position   INTEGER not null,
+    proposal   TEXT    default '' not null,
+    primary key (hearing_id, position)
+);
+
+create table hearing_paragraphs
+(
+    hearing_id INTEGER not null references hearings (id) on delete cascade,
+    position   INTEGER not null,
+    paragraph  TEXT    default '' not null,
+    primary key (hearing_id, position)
+);
+
+create table ignore_rules
+(
+    id         INTEGER not null primary key,
+    kind       TEXT    not null,
+    pattern    TEXT    not null,
+    reason     TEXT    default '' not null,
+    created_at TEXT    not null,
+    unique (kind, pattern)
+);
+
+create table outbox
+(
+    id              INTEGER not null primary key,
+    hearing_id      INTEGER not null references hearings (id) on delete cascade,
+    channel         TEXT    not null,
+    event           TEXT    not null,
+    message         TEXT    not null,
+    status          TEXT    default 'pending' not null,
+    attempts        INTEGER default 0 not null,
+    next_attempt_at TEXT    not null,
+    last_error      TEXT    default '' not null,
+    created_at      TEXT    not null,
+    sent_at         TEXT,
+    unique (hearing_id, channel, event)
+);
+
+create index outbox_due on outbox (status, next_attempt_at);
+
+create index outbox_channel on outbox (channel);
+
+create table webhooks
+(
+    id         INTEGER not null primary key,
+    url        TEXT    not null,
+    secret     TEXT    not null,
+    events     TEXT    not null,
+    created_at TEXT    not null
+);
+
+create table digests
+(
+    id              INTEGER not null primary key,
+    period          TEXT    not null,
+    last_hearing_id INTEGER not null,
+    recipients      INTEGER not null,
+    sent_at         TEXT    not null
+);
+
+create index digests_period on digests (period, sent_at);
+
+create table digest_unsubscribes
+(
+    email      TEXT not null primary key,
+    created_at TEXT not null
+);
+
+create table subscriptions
+(
+    id         INTEGER not null primary key,
+    chat_id    TEXT    not null,
+    kind       TEXT    not null,
+    value      TEXT    not null,
+    created_at TEXT    not null
+);
+
+create unique index subscriptions_chat on subscriptions (chat_id, kind, value);
+
+create table reviews
+(
+    hearing_id INTEGER not null primary key references hearings (id) on delete cascade,
+    state      TEXT    not null,
+    moderator  TEXT    default '' not null,
+    comment    TEXT    default '' not null,
+    created_at TEXT    not null,
+    updated_at TEXT    not null
+);
+
+create index reviews_state on reviews (state);
+
+
+-- full-text index rebuilt when hearing is saved, see database/migrations/sqlite/0005_search_hearings.up.sql
+create virtual table hearings_search using fts5
+(
+    topics,
+    proposals,
+    place,
+    raw,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
@@ -16,10 +16,13 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,50 +32,158 @@ import (
 
 	_ "github.com/glebarez/go-sqlite" // pure go SQLite driver
 	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
-const (
-	sliceDelimeter = "||"
-	timeFormat     = "2006-01-02 15:04:05"
+const timeFormat = "2006-01-02 15:04:05"
+
+// formatDate returns wall clock of time of hearing in local time zone.
+// Dates of hearings are stored in local time unlike other timestamps which are stored in UTC.
+func formatDate(t time.Time) string {
+	return t.In(time.Local).Format(timeFormat)
+}
+
+// parseDate returns time of hearing stored by formatDate
+func parseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(timeFormat, s, time.Local)
+}
+
+// child tables of hearings with ordered lists of strings
+var childTables = []struct {
+	table  string
+	column string
+}{
+	{table: "hearing_topics", column: "topic"},
+	{table: "hearing_proposals", column: "proposal"},
+	{table: "hearing_paragraphs", column: "paragraph"},
+}
+
+// dialect of SQL database
+type dialect struct {
+	// driver is name of database/sql driver
+	driver string
+	// migrations is directory with migrations of dialect
+	migrations string
+	// tableExists is query which returns count of tables with name $1
+	tableExists string
+	// legacy is true if schema could be upgraded by inline queries before migrations
+	legacy bool
+	// search is full-text search query with match expression $1 and limit $2
+	search string
+	// searchMatch converts user query to match expression of search query
+	searchMatch func(query string) string
+	// textFilter is condition of hearings h matched by expression of searchMatch
+	textFilter string
+	// reindex are queries which rebuild full-text index of hearing $1
+	reindex []string
+}
+
+var (
+	sqliteDialect = dialect{
+		driver:      "sqlite",
+		migrations:  "migrations/sqlite",
+		tableExists: "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = $1",
+		legacy:      true,
+		search: `SELECT h.id, h.link, h.place, h.date, h.published, h.status, h.category, h.district, h.deleted_at,
+			snippet(hearings_search, -1, '` + snippetStart + `', '` + snippetEnd + `', '…', 16) AS snippet,
+			-bm25(hearings_search, 10.0, 2.0, 5.0, 1.0) AS rank
+			FROM hearings_search JOIN hearings h ON h.id = hearings_search.rowid
+			WHERE hearings_search MATCH $1 AND h.deleted_at IS NULL AND h.` + approvedOrUnmoderated + ` ORDER BY rank DESC, h.date DESC LIMIT $2`,
+		searchMatch: sqliteMatch,
+		textFilter:  "h.id IN (SELECT rowid FROM hearings_search WHERE hearings_search MATCH ?)",
+		reindex: []string{
+			"DELETE FROM hearings_search WHERE rowid = $1",
+			"INSERT INTO hearings_search(rowid, topics, proposals, place, raw) " +
+				"SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = $1",
+		},
+	}
+	postgresDialect = dialect{
+		driver:      "postgres",
+		migrations:  "migrations/postgres",
+		tableExists: "SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1",
+		search: `SELECT h.id, h.link, h.place, h.date, h.published, h.status, h.category, h.district, h.deleted_at,
+			ts_headline('russian', concat_ws(E'\n', s.topics, s.place, s.proposals, s.raw), q,
+				'StartSel=` + snippetStart + `, StopSel=` + snippetEnd + `, FragmentDelimiter=…, MaxFragments=1, MaxWords=16, MinWords=5') AS snippet,
+			ts_rank(s.document, q) AS rank
+			FROM hearings_search s JOIN hearings h ON h.id = s.hearing_id, plainto_tsquery('russian', $1) q
+			WHERE s.document @@ q AND h.deleted_at IS NULL AND h.` + approvedOrUnmoderated + ` ORDER BY rank DESC, h.date DESC LIMIT $2`,
+		searchMatch: postgresMatch,
+		textFilter:  "h.id IN (SELECT hearing_id FROM hearings_search WHERE document @@ plainto_tsquery('russian', ?))",
+		reindex:     []string{"SELECT hearings_search_refresh($1)"},
+	}
 )
 
 // Client to database
 type Client struct {
-	db     *sqlx.DB
-	logger *zerolog.Logger
+	db      *sqlx.DB
+	dialect dialect
+	logger  *zerolog.Logger
 }
 
 type hearing struct {
 	ID        int    `json:"id" db:"id"`
 	Link      string `json:"link" db:"link"`
-	Topics    string `json:"topics" db:"topics"`
 	Place     string `json:"place" db:"place"`
 	Date      string `json:"date" db:"date"`
-	Proposals string `json:"proposals" db:"proposals"`
 	Published bool   `json:"published" db:"published"`
-	Raw       string `json:"raw" db:"raw"`
+	Status    string `json:"status" db:"status"`
+	Category  string `json:"category" db:"category"`
+	District  string `json:"district" db:"district"`
+	CreatedAt string `json:"created_at" db:"created_at"`
+	// DeletedAt is not null for soft deleted hearing
+	DeletedAt sql.NullString `json:"deleted_at" db:"deleted_at"`
 }
 
-// New connection to database
+type hearingChild struct {
+	HearingID int    `db:"hearing_id"`
+	Value     string `db:"value"`
+}
+
+// New connection to database. Schema of database is migrated to the latest version.
 func New(dsn string, logger *zerolog.Logger) (*Client, error) {
+	client, err := Open(dsn, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = client.MigrateUp(context.Background(), 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not migrate schema: %w", err)
+	}
+	client.logger.Info().Msg("database schema is up to date")
+
+	return client, nil
+}
+
+// Open connection to database without migration of schema.
+// Database is selected by scheme of DSN: postgres:// and postgresql:// are PostgreSQL,
+// others are path to SQLite file with optional scheme sqlite://.
+func Open(dsn string, logger *zerolog.Logger) (*Client, error) {
 	if dsn == "" {
 		return nil, fmt.Errorf("dsn is empty")
 	}
-	if !strings.HasSuffix(dsn, ".sqlite3") {
-		dsn += ".sqlite3"
-	}
 
 	l := logger.With().Str("component", "database").Logger()
 	client := &Client{
-		logger: &l,
+		dialect: sqliteDialect,
+		logger:  &l,
 	}
 
-	err := client.prepareDBFile(dsn)
-	if err != nil {
-		return nil, fmt.Errorf("could not prepare database file: %w", err)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		client.dialect = postgresDialect
+	} else {
+		dsn = strings.TrimPrefix(dsn, "sqlite://")
+		if !strings.HasSuffix(dsn, ".sqlite3") {
+			dsn += ".sqlite3"
+		}
+
+		err := client.prepareDBFile(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("could not prepare database file: %w", err)
+		}
 	}
 
-	db, err := sqlx.Open("sqlite", dsn)
+	db, err := sqlx.Open(client.dialect.driver, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("could not open database: %w", err)
 	}
@@ -82,11 +193,6 @@ func New(dsn string, logger *zerolog.Logger) (*Client, error) {
 	}
 	client.db = db
 
-	err = client.prepareSchema()
-	if err != nil {
-		return nil, fmt.Errorf("could not prepare schema: %w", err)
-	}
-
 	return client, nil
 }
 
@@ -120,51 +226,6 @@ func (c Client) cleanQuery(q string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(q, "\n", " "), "\t", "")
 }
 
-func (c Client) prepareSchema() error {
-	version, err := c.getSchemaVersion()
-	if err != nil {
-		return fmt.Errorf("could not get schema version: %w", err)
-	}
-
-	c.logger.Info().Msgf("database current schema version: %d", version)
-
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS hearings(
-			id INTEGER PRIMARY KEY,
-			link TEXT DEFAULT '' NOT NULL UNIQUE,
-			topics TEXT DEFAULT '',
-			proposals TEXT DEFAULT '',
-			place TEXT DEFAULT '',
-			date TEXT DEFAULT '1970-01-01 00:00:00',
-			published BOOLEAN DEFAULT false,
-			raw TEXT DEFAULT ''
-		)`,
-		`ALTER TABLE hearings ADD COLUMN created_at TEXT DEFAULT '1970-01-01 00:00:00'`,
-	}
-
-	if version == len(queries) {
-		c.logger.Info().Msg("database schema is up to date")
-		return nil
-	}
-
-	c.logger.Info().Msg("upgrading database schema...")
-
-	for i := version; i < len(queries); i++ {
-		c.logger.Debug().Msgf("database executing query: %s", c.cleanQuery(queries[i]))
-		_, err = c.db.Exec(queries[i])
-		if err != nil {
-			return fmt.Errorf("could not execute query: %w", err)
-		}
-		err = c.setSchemaVersion(i + 1)
-		if err != nil {
-			return fmt.Errorf("could not set schema version: %w", err)
-		}
-		c.logger.Debug().Msgf("database schema version: %d", i+1)
-	}
-	c.logger.Info().Msg("database schema is up to date")
-	return nil
-}
-
 func (c Client) getSchemaVersion() (int, error) {
 	row := c.db.QueryRow("PRAGMA user_version")
 	if row == nil {
@@ -177,26 +238,45 @@ func (c Client) getSchemaVersion() (int, error) {
 	return version, nil
 }
 
-func (c Client) setSchemaVersion(version int) error {
-	_, err := c.db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version))
-	return err
-}
-
 // Create new hearing in database
 func (c Client) Create(ctx context.Context, publicHearing domain.Hearing) error {
-	query := "INSERT INTO hearings(link,topics,proposals,place,date,raw,created_at) VALUES($1, $2, $3, $4, $5, $6, $7)"
-	_, err := c.db.ExecContext(
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	if _, err = c.create(ctx, tx, publicHearing); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// create inserts hearing with its children in transaction and returns identifier of hearing
+func (c Client) create(ctx context.Context, tx *sqlx.Tx, publicHearing domain.Hearing) (int, error) {
+	query := "INSERT INTO hearings(link,place,date,created_at,status,category,district) " +
+		"VALUES($1, $2, $3, $4, $5, $6, $7) RETURNING id"
+	var id int
+	err := tx.QueryRowxContext(
 		ctx,
 		query,
 		publicHearing.URL,
-		strings.Join(publicHearing.Topic, sliceDelimeter),
-		strings.Join(publicHearing.Proposals, sliceDelimeter),
 		publicHearing.Place,
-		publicHearing.Time.Format(timeFormat),
-		strings.Join(publicHearing.Raw, sliceDelimeter),
-		time.Now(),
-	)
-	return err
+		formatDate(publicHearing.Time),
+		time.Now().UTC().Format(timeFormat),
+		publicHearing.CurrentStatus(time.Now()),
+		publicHearing.DetectCategory(),
+		publicHearing.DetectDistrict(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	err = c.saveChildren(ctx, tx, id, publicHearing.Topic, publicHearing.Proposals, publicHearing.Raw)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
 // Update hearing in database
@@ -228,7 +308,7 @@ func (c Client) Update(ctx context.Context, publicHearing domain.Hearing) error
 	if date.IsZero() {
 		date = currentHearing.Time
 	}
-	dateStr := date.Format(timeFormat)
+	dateStr := formatDate(date)
 
 	if !published {
 		published = currentHearing.Published
@@ -242,60 +322,114 @@ func (c Client) Update(ctx context.Context, publicHearing domain.Hearing) error
 		raw = currentHearing.Raw
 	}
 
-	query := "UPDATE hearings SET topics = $2, place = $3, date = $4, published = $5, proposals = $6, raw = $7 WHERE link = $1"
-	_, err = c.db.ExecContext(ctx, query, publicHearing.URL, topic, place, dateStr, published, proposals, raw)
+	status := publicHearing.Status
+	if status == "" {
+		status = currentHearing.Status
+	}
+	updated := domain.Hearing{Topic: topic, Place: place, Time: date, Published: published, Status: status}
 
-	return err
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	query := "UPDATE hearings SET place = $2, date = $3, published = $4, status = $5, category = $6, district = $7 " +
+		"WHERE link = $1 RETURNING id"
+	var id int
+	err = tx.QueryRowxContext(ctx, query, publicHearing.URL, place, dateStr, published,
+		updated.CurrentStatus(time.Now()), updated.DetectCategory(), updated.DetectDistrict()).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range childTables {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE hearing_id = $1", child.table), id)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.saveChildren(ctx, tx, id, topic, proposals, raw)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Find one hearing in database
 func (c Client) Find(ctx context.Context, link string) (domain.Hearing, error) {
-	tempHearing := &hearing{}
-	hp := domain.Hearing{}
-	query := "SELECT id, link, topics, proposals, place, date, published, raw FROM hearings WHERE link = $1"
-	err := c.db.QueryRowxContext(ctx, query, link).StructScan(tempHearing)
+	return c.findBy(ctx, "link", link)
+}
+
+// Get one hearing by identifier from database
+func (c Client) Get(ctx context.Context, id string) (domain.Hearing, error) {
+	n, err := strconv.Atoi(id)
 	if err != nil {
-		return hp, err
+		return domain.Hearing{}, ErrNotFound
 	}
+	return c.findBy(ctx, "id", n)
+}
 
-	hp.URL = tempHearing.Link
-	hp.Time, _ = time.Parse(timeFormat, tempHearing.Date)
-	hp.Place = tempHearing.Place
-	hp.Topic = strings.Split(tempHearing.Topics, sliceDelimeter)
-	hp.Proposals = strings.Split(tempHearing.Proposals, sliceDelimeter)
-	hp.Published = tempHearing.Published
-	hp.Raw = strings.Split(tempHearing.Raw, sliceDelimeter)
+// findBy returns one hearing with value of column
+func (c Client) findBy(ctx context.Context, column string, value interface{}) (domain.Hearing, error) {
+	tempHearing := hearing{}
+	query := "SELECT id, link, place, date, published, status, category, district, deleted_at " +
+		"FROM hearings WHERE " + column + " = $1"
+	err := c.db.QueryRowxContext(ctx, query, value).StructScan(&tempHearing)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Hearing{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Hearing{}, err
+	}
 
-	return hp, nil
+	res, err := c.castToHearing(ctx, c.db, []hearing{tempHearing})
+	if err != nil {
+		return domain.Hearing{}, err
+	}
+	return res[0], nil
 }
 
 // List all hearings in database
 func (c Client) List(ctx context.Context) ([]domain.Hearing, error) {
 	tempHearings := make([]hearing, 0)
-	res := make([]domain.Hearing, 0)
-	query := "SELECT id, link, topics, proposals, place, date as date, published, raw FROM hearings ORDER BY date"
+	query := "SELECT id, link, place, date as date, published, status, category, district, deleted_at " +
+		"FROM hearings ORDER BY date"
 	err := c.db.SelectContext(ctx, &tempHearings, query)
 	if err != nil {
-		return res, err
+		return make([]domain.Hearing, 0), err
 	}
-	res = c.castToHearing(tempHearings)
-	return res, err
+	return c.castToHearing(ctx, c.db, tempHearings)
 }
 
-// Unpublished hearings in database
+// approvedOrUnmoderated is condition of hearings which are approved or not moderated at all
+const approvedOrUnmoderated = "id NOT IN (SELECT hearing_id FROM reviews WHERE state <> 'approved')"
+
+// Unpublished hearings in database except ones which are pending review or rejected
 func (c Client) Unpublished(ctx context.Context, mark bool) ([]domain.Hearing, error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return make([]domain.Hearing, 0), err
+	}
+	defer c.rollback(tx)
+
 	tempHearings := make([]hearing, 0)
-	res := make([]domain.Hearing, 0)
-	query := "SELECT id, link, topics, proposals, place, date AS date, published, raw FROM hearings WHERE published IS NOT TRUE ORDER BY date"
+	query := "SELECT id, link, place, date AS date, published, status, category, district, deleted_at " +
+		"FROM hearings WHERE published IS NOT TRUE AND deleted_at IS NULL AND " + approvedOrUnmoderated + " ORDER BY date"
 	if mark {
-		query = "UPDATE hearings SET published = TRUE WHERE published IS NOT TRUE RETURNING id, link, topics, proposals, place, date AS date, published, raw"
+		query = "UPDATE hearings SET published = TRUE WHERE published IS NOT TRUE AND deleted_at IS NULL AND " + approvedOrUnmoderated +
+			" RETURNING id, link, place, date AS date, published, status, category, district, deleted_at"
 	}
-	err := c.db.SelectContext(ctx, &tempHearings, query)
+	err = tx.SelectContext(ctx, &tempHearings, query)
+	if err != nil {
+		return make([]domain.Hearing, 0), err
+	}
+	res, err := c.castToHearing(ctx, tx, tempHearings)
 	if err != nil {
 		return res, err
 	}
-	res = c.castToHearing(tempHearings)
-	return res, err
+	return res, tx.Commit()
 }
 
 // MarkPublished marks published hearings in database
@@ -305,22 +439,110 @@ func (c Client) MarkPublished(ctx context.Context, link string) error {
 	return err
 }
 
-func (c Client) castToHearing(h []hearing) []domain.Hearing {
-	res := make([]domain.Hearing, 0)
-	var err error
+// Delete hearing softly. Deleted hearing is kept to not process its link again,
+// but it is hidden from queries, search and publishing.
+func (c Client) Delete(ctx context.Context, id string) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	query := "UPDATE hearings SET deleted_at = coalesce(deleted_at, $2) WHERE id = $1"
+	res, err := c.db.ExecContext(ctx, query, n, time.Now().UTC().Format(timeFormat))
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// rollback transaction if it is not committed
+func (c Client) rollback(tx *sqlx.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		c.logger.Error().Err(err).Msg("failed rollback transaction")
+	}
+}
+
+// saveChildren saves ordered topics, proposals and paragraphs of hearing and rebuilds its full-text index.
+// Index is rebuilt once after all children are saved.
+func (c Client) saveChildren(ctx context.Context, tx *sqlx.Tx, id int, lists ...[]string) error {
+	for i, child := range childTables {
+		query := fmt.Sprintf("INSERT INTO %s(hearing_id, position, %s) VALUES($1, $2, $3)", child.table, child.column)
+		for position, value := range lists[i] {
+			_, err := tx.ExecContext(ctx, query, id, position, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, query := range c.dialect.reindex {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadChildren returns ordered values of child table grouped by hearing id
+func (c Client) loadChildren(ctx context.Context, q sqlx.QueryerContext, table, column string, ids []int) (map[int][]string, error) {
+	res := make(map[int][]string)
+	if len(ids) == 0 {
+		return res, nil
+	}
+	query, args, err := sqlx.In(
+		fmt.Sprintf("SELECT hearing_id, %s AS value FROM %s WHERE hearing_id IN (?) ORDER BY hearing_id, position", column, table),
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]hearingChild, 0)
+	err = sqlx.SelectContext(ctx, q, &children, c.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		res[child.HearingID] = append(res[child.HearingID], child.Value)
+	}
+	return res, nil
+}
+
+func (c Client) castToHearing(ctx context.Context, q sqlx.QueryerContext, h []hearing) ([]domain.Hearing, error) {
+	res := make([]domain.Hearing, 0, len(h))
+	ids := make([]int, 0, len(h))
 	for _, th := range h {
-		hp := domain.Hearing{}
-		hp.URL = th.Link
-		hp.Time, err = time.Parse(timeFormat, th.Date)
+		ids = append(ids, th.ID)
+	}
+
+	lists := make([]map[int][]string, len(childTables))
+	for i, child := range childTables {
+		values, err := c.loadChildren(ctx, q, child.table, child.column, ids)
 		if err != nil {
-			break
+			return res, err
 		}
+		lists[i] = values
+	}
+
+	for _, th := range h {
+		hp := domain.Hearing{}
+		hp.ID = strconv.Itoa(th.ID)
+		hp.URL = th.Link
+		hp.Time, _ = parseDate(th.Date)
 		hp.Place = th.Place
-		hp.Topic = strings.Split(th.Topics, sliceDelimeter)
-		hp.Proposals = strings.Split(th.Proposals, sliceDelimeter)
+		hp.Topic = lists[0][th.ID]
+		hp.Proposals = lists[1][th.ID]
 		hp.Published = th.Published
-		hp.Raw = strings.Split(th.Raw, sliceDelimeter)
+		hp.Status = domain.Status(th.Status)
+		hp.Category = domain.Category(th.Category)
+		hp.District = domain.District(th.District)
+		hp.Deleted = th.DeletedAt.Valid
+		hp.Raw = lists[2][th.ID]
 		res = append(res, hp)
 	}
-	return res
+	return res, nil
 }
@@ -0,0 +1,960 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package databasetest is conformance test suite for implementations of repositories of package database
+package databasetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// Factory returns empty repository for one test
+type Factory func(t *testing.T) database.HearingRepository
+
+func testHearing(n int, date time.Time) domain.Hearing {
+	return domain.Hearing{
+		URL:       "https://bga32.ru/hearing-" + string(rune('a'+n)) + "/",
+		Topic:     []string{"по проекту планировки || межевания", "по проекту планировки территории"},
+		Proposals: []string{"Приём предложений", "Приём заявлений"},
+		Place:     "ГДК Советского района (ул. Калинина, д. 66)",
+		Time:      date,
+		Raw:       []string{"первый абзац", "второй абзац", "третий абзац"},
+	}
+}
+
+// Moscow is time zone of hearings which is ahead of UTC
+var Moscow = time.FixedZone("MSK", 3*60*60)
+
+// SetLocal sets local time zone for one test. Tests which call it must not be parallel.
+func SetLocal(t *testing.T, loc *time.Location) {
+	t.Helper()
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+}
+
+// TestHearingRepository runs conformance tests against repositories created by factory
+func TestHearingRepository(t *testing.T, newRepo Factory) {
+	ctx := context.Background()
+	future := time.Now().AddDate(0, 1, 0).Truncate(time.Second)
+	future = time.Date(future.Year(), future.Month(), future.Day(), 11, 0, 0, 0, time.UTC)
+
+	t.Run("create and find", func(t *testing.T) {
+		repo := newRepo(t)
+		h := testHearing(0, future)
+		require.NoError(t, repo.Create(ctx, h))
+
+		got, err := repo.Find(ctx, h.URL)
+		require.NoError(t, err)
+		require.Equal(t, h.URL, got.URL)
+		require.Equal(t, h.Topic, got.Topic)
+		require.Equal(t, h.Proposals, got.Proposals)
+		require.Equal(t, h.Raw, got.Raw)
+		require.Equal(t, h.Place, got.Place)
+		require.True(t, h.Time.Equal(got.Time), "time %s != %s", h.Time, got.Time)
+		require.False(t, got.Published)
+		require.Equal(t, domain.StatusAnnounced, got.Status)
+	})
+
+	t.Run("time in local zone", func(t *testing.T) {
+		SetLocal(t, Moscow)
+		repo := newRepo(t)
+		local := time.Date(future.Year(), future.Month(), future.Day(), 11, 0, 0, 0, time.Local)
+		h := testHearing(0, local)
+		require.NoError(t, repo.Create(ctx, h))
+
+		got, err := repo.Find(ctx, h.URL)
+		require.NoError(t, err)
+		require.True(t, local.Equal(got.Time), "time %s != %s", local, got.Time)
+		require.Equal(t, 11, got.Time.In(time.Local).Hour())
+
+		require.NoError(t, repo.Update(ctx, domain.Hearing{URL: h.URL, Place: "ДК Железнодорожников"}))
+		got, err = repo.Find(ctx, h.URL)
+		require.NoError(t, err)
+		require.True(t, local.Equal(got.Time), "time must not be shifted by update: %s != %s", local, got.Time)
+	})
+
+	t.Run("create duplicate", func(t *testing.T) {
+		repo := newRepo(t)
+		h := testHearing(0, future)
+		require.NoError(t, repo.Create(ctx, h))
+		require.Error(t, repo.Create(ctx, h))
+	})
+
+	t.Run("stable identifiers", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, repo.Create(ctx, testHearing(i, future)))
+		}
+		found, err := repo.Find(ctx, testHearing(1, future).URL)
+		require.NoError(t, err)
+		require.NotEmpty(t, found.ID)
+
+		got, err := repo.Get(ctx, found.ID)
+		require.NoError(t, err)
+		require.Equal(t, found.URL, got.URL)
+		require.Equal(t, found.ID, got.ID)
+
+		require.NoError(t, repo.Update(ctx, domain.Hearing{URL: found.URL, Place: "ДК Железнодорожников"}))
+		list, err := repo.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		require.NotEmpty(t, list[0].ID)
+		require.NotEqual(t, list[0].ID, list[1].ID)
+		require.Equal(t, found.ID, list[1].ID, "identifier must not change on update")
+
+		_, err = repo.Get(ctx, "999999")
+		require.ErrorIs(t, err, database.ErrNotFound)
+		_, err = repo.Get(ctx, "not a number")
+		require.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 2; i++ {
+			require.NoError(t, repo.Create(ctx, testHearing(i, future)))
+		}
+		deleted, err := repo.Find(ctx, testHearing(0, future).URL)
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, deleted.ID))
+		require.NoError(t, repo.Delete(ctx, deleted.ID), "delete must be idempotent")
+		require.ErrorIs(t, repo.Delete(ctx, "999999"), database.ErrNotFound)
+
+		got, err := repo.Get(ctx, deleted.ID)
+		require.NoError(t, err)
+		require.True(t, got.Deleted)
+
+		list, err := repo.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 2, "deleted hearings must be listed to not process them again")
+
+		page, err := repo.Query(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 1, page.Total)
+		require.Equal(t, testHearing(1, future).URL, page.Hearings[0].URL)
+		require.False(t, page.Hearings[0].Deleted)
+
+		unpublished, err := repo.Unpublished(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, unpublished, 1)
+		got, err = repo.Get(ctx, deleted.ID)
+		require.NoError(t, err)
+		require.False(t, got.Published, "deleted hearing must not be marked as published")
+
+		found, err := repo.Search(ctx, "планировки", 10)
+		require.NoError(t, err)
+		require.Len(t, found, 1)
+	})
+
+	t.Run("find missing", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Find(ctx, "https://bga32.ru/missing/")
+		require.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		repo := newRepo(t)
+		h := testHearing(0, future)
+		require.NoError(t, repo.Create(ctx, h))
+
+		require.NoError(t, repo.Update(ctx, domain.Hearing{
+			URL:       h.URL,
+			Proposals: []string{"Новый приём предложений"},
+			Status:    domain.StatusCancelled,
+		}))
+		got, err := repo.Find(ctx, h.URL)
+		require.NoError(t, err)
+		require.Equal(t, h.Topic, got.Topic)
+		require.Equal(t, []string{"Новый приём предложений"}, got.Proposals)
+		require.Equal(t, h.Raw, got.Raw)
+		require.Equal(t, h.Place, got.Place)
+		require.True(t, h.Time.Equal(got.Time))
+		require.Equal(t, domain.StatusCancelled, got.Status)
+
+		later := future.AddDate(0, 0, 7)
+		require.NoError(t, repo.Update(ctx, domain.Hearing{URL: h.URL, Time: later, Place: "Новое место", Published: true}))
+		got, err = repo.Find(ctx, h.URL)
+		require.NoError(t, err)
+		require.True(t, later.Equal(got.Time))
+		require.Equal(t, "Новое место", got.Place)
+		require.True(t, got.Published)
+	})
+
+	t.Run("update missing", func(t *testing.T) {
+		repo := newRepo(t)
+		require.ErrorIs(t, repo.Update(ctx, domain.Hearing{URL: "https://bga32.ru/missing/"}), database.ErrNotFound)
+		require.Error(t, repo.Update(ctx, domain.Hearing{}))
+	})
+
+	t.Run("list ordered by date", func(t *testing.T) {
+		repo := newRepo(t)
+		list, err := repo.List(ctx)
+		require.NoError(t, err)
+		require.Empty(t, list)
+
+		for i, days := range []int{3, 1, 2} {
+			require.NoError(t, repo.Create(ctx, testHearing(i, future.AddDate(0, 0, days))))
+		}
+		list, err = repo.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+		require.Equal(t, testHearing(1, future).URL, list[0].URL)
+		require.Equal(t, testHearing(2, future).URL, list[1].URL)
+		require.Equal(t, testHearing(0, future).URL, list[2].URL)
+		require.Equal(t, testHearing(0, future).Topic, list[2].Topic)
+	})
+
+	t.Run("unpublished", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.Create(ctx, testHearing(i, future.AddDate(0, 0, i))))
+		}
+		require.NoError(t, repo.MarkPublished(ctx, testHearing(0, future).URL))
+
+		list, err := repo.Unpublished(ctx, false)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		require.Equal(t, testHearing(1, future).Topic, list[0].Topic)
+
+		list, err = repo.Unpublished(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+
+		list, err = repo.Unpublished(ctx, false)
+		require.NoError(t, err)
+		require.Empty(t, list)
+
+		got, err := repo.Find(ctx, testHearing(2, future).URL)
+		require.NoError(t, err)
+		require.True(t, got.Published)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		repo := newRepo(t)
+		past := future.AddDate(0, -2, 0)
+		hearings := []domain.Hearing{
+			testHearing(0, past),
+			testHearing(1, future),
+			testHearing(2, future.AddDate(0, 0, 1)),
+			testHearing(3, future.AddDate(0, 0, 2)),
+			testHearing(4, future),
+		}
+		hearings[1].Place = "ДК Бежицкого района"
+		hearings[2].Topic = []string{"о внесении изменений в Правила землепользования и застройки"}
+		hearings[3].Topic = []string{"по проекту благоустройства сквера на ул. Фосфоритной"}
+		for _, h := range hearings {
+			require.NoError(t, repo.Create(ctx, h))
+		}
+		require.NoError(t, repo.MarkPublished(ctx, hearings[2].URL))
+
+		urls := func(page database.Page) []string {
+			res := make([]string, 0, len(page.Hearings))
+			for _, h := range page.Hearings {
+				res = append(res, h.URL)
+			}
+			return res
+		}
+
+		page, err := repo.Query(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 5, page.Total)
+		require.Empty(t, page.Next)
+		require.Equal(t, []string{hearings[0].URL, hearings[1].URL, hearings[4].URL, hearings[2].URL, hearings[3].URL}, urls(page))
+		require.Equal(t, domain.CategoryPlanning, page.Hearings[0].Category)
+		require.Equal(t, domain.DistrictSovetsky, page.Hearings[0].District)
+
+		published := true
+		page, err = repo.Query(ctx, database.ListOptions{Published: &published})
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[2].URL}, urls(page))
+
+		page, err = repo.Query(ctx, database.ListOptions{Statuses: []domain.Status{domain.StatusHeld, domain.StatusUpcoming}})
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[0].URL, hearings[2].URL}, urls(page))
+
+		page, err = repo.Query(ctx, database.ListOptions{Category: domain.CategoryZoning})
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[2].URL}, urls(page))
+
+		page, err = repo.Query(ctx, database.ListOptions{District: domain.DistrictBezhitsky})
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[1].URL}, urls(page))
+
+		page, err = repo.Query(ctx, database.ListOptions{From: future, To: future.AddDate(0, 0, 2)})
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[1].URL, hearings[4].URL, hearings[2].URL}, urls(page))
+
+		page, err = repo.Query(ctx, database.ListOptions{Text: "Фосфоритная"})
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[3].URL}, urls(page))
+
+		// Walk through pages in descending order of dates
+		opts := database.ListOptions{Desc: true, Limit: 2}
+		all := make([]string, 0)
+		for i := 0; i < 3; i++ {
+			page, err = repo.Query(ctx, opts)
+			require.NoError(t, err)
+			require.Equal(t, 5, page.Total)
+			all = append(all, urls(page)...)
+			opts.Cursor = page.Next
+		}
+		require.Empty(t, page.Next)
+		require.Equal(t, []string{hearings[3].URL, hearings[2].URL, hearings[4].URL, hearings[1].URL, hearings[0].URL}, all)
+
+		opts = database.ListOptions{Sort: database.SortCreated, Limit: 3}
+		page, err = repo.Query(ctx, opts)
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[0].URL, hearings[1].URL, hearings[2].URL}, urls(page))
+		opts.Cursor = page.Next
+		page, err = repo.Query(ctx, opts)
+		require.NoError(t, err)
+		require.Equal(t, []string{hearings[3].URL, hearings[4].URL}, urls(page))
+
+		_, err = repo.Query(ctx, database.ListOptions{Sort: database.SortID, Cursor: opts.Cursor})
+		require.ErrorIs(t, err, database.ErrInvalidCursor)
+		_, err = repo.Query(ctx, database.ListOptions{Cursor: "garbage"})
+		require.ErrorIs(t, err, database.ErrInvalidCursor)
+		_, err = repo.Query(ctx, database.ListOptions{Sort: "place"})
+		require.Error(t, err)
+	})
+
+	t.Run("query statuses near midnight", func(t *testing.T) {
+		SetLocal(t, Moscow)
+		repo := newRepo(t)
+		y, m, d := future.Date()
+		now := time.Date(y, m, d, 12, 0, 0, 0, time.Local)
+		hearings := []domain.Hearing{
+			testHearing(0, time.Date(y, m, d-1, 23, 30, 0, 0, time.Local)),
+			testHearing(1, time.Date(y, m, d, 23, 30, 0, 0, time.Local)),
+			testHearing(2, time.Date(y, m, d+1, 0, 30, 0, 0, time.Local)),
+		}
+		for _, h := range hearings {
+			require.NoError(t, repo.Create(ctx, h))
+		}
+
+		for i, status := range []domain.Status{domain.StatusHeld, domain.StatusToday, domain.StatusAnnounced} {
+			page, err := repo.Query(ctx, database.ListOptions{Statuses: []domain.Status{status}, Now: now})
+			require.NoError(t, err)
+			require.Len(t, page.Hearings, 1, status)
+			require.Equal(t, hearings[i].URL, page.Hearings[0].URL, status)
+			require.Equal(t, status, page.Hearings[0].CurrentStatus(now))
+		}
+
+		page, err := repo.Query(ctx, database.ListOptions{From: time.Date(y, m, d, 0, 0, 0, 0, time.Local), To: time.Date(y, m, d+1, 0, 0, 0, 0, time.Local)})
+		require.NoError(t, err)
+		require.Len(t, page.Hearings, 1)
+		require.Equal(t, hearings[1].URL, page.Hearings[0].URL)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		repo := newRepo(t)
+		street := testHearing(0, future)
+		street.Topic = []string{"по проекту планировки территории по ул. Фосфоритной"}
+		mention := testHearing(1, future)
+		mention.Raw = []string{"рядом с улицей <Фосфоритной> & набережной"}
+		other := testHearing(2, future)
+		other.Topic = []string{"по проекту межевания территории по ул. Речной"}
+		for _, h := range []domain.Hearing{street, mention, other} {
+			require.NoError(t, repo.Create(ctx, h))
+		}
+
+		res, err := repo.Search(ctx, "Фосфоритная", 10)
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		require.Equal(t, street.URL, res[0].Hearing.URL, "match in topic must be ranked higher")
+		require.Equal(t, street.Topic, res[0].Hearing.Topic)
+		require.Contains(t, res[0].Snippet, database.SnippetStart+"Фосфоритной"+database.SnippetEnd)
+		require.Greater(t, res[0].Rank, res[1].Rank)
+		require.Equal(t, mention.URL, res[1].Hearing.URL)
+		require.Contains(t, res[1].Snippet, "&lt;"+database.SnippetStart+"Фосфоритной"+database.SnippetEnd+"&gt; &amp;",
+			"text of snippet must be escaped")
+
+		res, err = repo.Search(ctx, "Фосфоритная", 1)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+
+		res, err = repo.Search(ctx, "межевание речной", 10)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, other.URL, res[0].Hearing.URL)
+
+		res, err = repo.Search(ctx, "", 10)
+		require.NoError(t, err)
+		require.Empty(t, res)
+
+		other.Topic = []string{"по проекту планировки территории по ул. Ново-Советской"}
+		require.NoError(t, repo.Update(ctx, other))
+		res, err = repo.Search(ctx, "речной", 10)
+		require.NoError(t, err)
+		require.Empty(t, res, "index must be updated with hearing")
+		res, err = repo.Search(ctx, "Ново-Советская", 10)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+	})
+}
+
+// IgnoreRuleFactory returns empty repository of ignore rules for one test
+type IgnoreRuleFactory func(t *testing.T) database.IgnoreRuleRepository
+
+// TestIgnoreRuleRepository runs conformance tests against repositories of ignore rules created by factory
+func TestIgnoreRuleRepository(t *testing.T, newRepo IgnoreRuleFactory) {
+	ctx := context.Background()
+
+	t.Run("add, list and delete", func(t *testing.T) {
+		repo := newRepo(t)
+		rules, err := repo.IgnoreRules(ctx)
+		require.NoError(t, err)
+		require.Empty(t, rules)
+
+		first, err := repo.AddIgnoreRule(ctx, database.IgnoreRule{
+			Kind:    database.IgnoreURL,
+			Pattern: "https://bga32.ru/itogi/",
+			Reason:  "results of hearings",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, first.ID)
+		require.False(t, first.CreatedAt.IsZero())
+
+		second, err := repo.AddIgnoreRule(ctx, database.IgnoreRule{Kind: database.IgnorePattern, Pattern: `\.pdf$`})
+		require.NoError(t, err)
+		require.NotEqual(t, first.ID, second.ID)
+
+		_, err = repo.AddIgnoreRule(ctx, database.IgnoreRule{Kind: database.IgnoreURL, Pattern: "https://bga32.ru/itogi/"})
+		require.ErrorIs(t, err, database.ErrAlreadyExists)
+
+		rules, err = repo.IgnoreRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 2)
+		require.Equal(t, first.ID, rules[0].ID)
+		require.Equal(t, "results of hearings", rules[0].Reason)
+		require.True(t, first.CreatedAt.Equal(rules[0].CreatedAt), "time %s != %s", first.CreatedAt, rules[0].CreatedAt)
+		require.Equal(t, `\.pdf$`, rules[1].Pattern)
+
+		require.NoError(t, repo.DeleteIgnoreRule(ctx, first.ID))
+		require.ErrorIs(t, repo.DeleteIgnoreRule(ctx, first.ID), database.ErrNotFound)
+		rules, err = repo.IgnoreRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		require.Equal(t, second.ID, rules[0].ID)
+	})
+}
+
+// WebhookFactory returns empty repository of webhooks for one test
+type WebhookFactory func(t *testing.T) database.WebhookRepository
+
+// TestWebhookRepository runs conformance tests against repositories of webhooks created by factory
+func TestWebhookRepository(t *testing.T, newRepo WebhookFactory) {
+	ctx := context.Background()
+
+	t.Run("add, get, list and delete", func(t *testing.T) {
+		repo := newRepo(t)
+		hooks, err := repo.Webhooks(ctx)
+		require.NoError(t, err)
+		require.Empty(t, hooks)
+
+		first, err := repo.AddWebhook(ctx, database.Webhook{
+			URL:    "https://example.com/hook",
+			Secret: "secret",
+			Events: []string{"hearing.created", "hearing.published"},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, first.ID)
+		require.False(t, first.CreatedAt.IsZero())
+
+		second, err := repo.AddWebhook(ctx, database.Webhook{URL: "https://example.com/other", Secret: "other", Events: []string{"hearing.cancelled"}})
+		require.NoError(t, err)
+		require.NotEqual(t, first.ID, second.ID)
+
+		got, err := repo.Webhook(ctx, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/hook", got.URL)
+		require.Equal(t, "secret", got.Secret)
+		require.Equal(t, []string{"hearing.created", "hearing.published"}, got.Events)
+		require.True(t, first.CreatedAt.Equal(got.CreatedAt), "time %s != %s", first.CreatedAt, got.CreatedAt)
+		_, err = repo.Webhook(ctx, "999999")
+		require.ErrorIs(t, err, database.ErrNotFound)
+
+		hooks, err = repo.Webhooks(ctx)
+		require.NoError(t, err)
+		require.Len(t, hooks, 2)
+		require.Equal(t, first.ID, hooks[0].ID)
+		require.Equal(t, []string{"hearing.cancelled"}, hooks[1].Events)
+
+		require.NoError(t, repo.DeleteWebhook(ctx, first.ID))
+		require.ErrorIs(t, repo.DeleteWebhook(ctx, first.ID), database.ErrNotFound)
+		hooks, err = repo.Webhooks(ctx)
+		require.NoError(t, err)
+		require.Len(t, hooks, 1)
+		require.Equal(t, second.ID, hooks[0].ID)
+	})
+}
+
+// DigestFactory returns empty repository of digests for one test
+type DigestFactory func(t *testing.T) database.DigestRepository
+
+// TestDigestRepository runs conformance tests against repositories of digests created by factory
+func TestDigestRepository(t *testing.T, newRepo DigestFactory) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("last digest of period", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.LastDigest(ctx, "daily", "a@example.com")
+		require.ErrorIs(t, err, database.ErrNotFound)
+
+		first, err := repo.AddDigest(ctx, database.Digest{Period: "daily", LastHearingID: "3", Recipients: 2, SentAt: now.Add(-24 * time.Hour)})
+		require.NoError(t, err)
+		require.NotEmpty(t, first.ID)
+		second, err := repo.AddDigest(ctx, database.Digest{Period: "daily", Recipient: "A@example.com", LastHearingID: "5", Recipients: 1, SentAt: now})
+		require.NoError(t, err)
+		require.NotEqual(t, first.ID, second.ID)
+		require.Equal(t, "a@example.com", second.Recipient)
+		_, err = repo.AddDigest(ctx, database.Digest{Period: "weekly", Recipient: "a@example.com", LastHearingID: "7", Recipients: 1, SentAt: now.Add(-time.Hour)})
+		require.NoError(t, err)
+
+		got, err := repo.LastDigest(ctx, "daily", "a@Example.com")
+		require.NoError(t, err)
+		require.Equal(t, second.ID, got.ID)
+		require.Equal(t, "a@example.com", got.Recipient)
+		require.Equal(t, "5", got.LastHearingID)
+		require.Equal(t, 1, got.Recipients)
+		require.True(t, now.Equal(got.SentAt), "time %s != %s", now, got.SentAt)
+
+		got, err = repo.LastDigest(ctx, "daily", "b@example.com")
+		require.NoError(t, err)
+		require.Equal(t, first.ID, got.ID, "digest without recipient applies to all recipients")
+		require.Empty(t, got.Recipient)
+
+		got, err = repo.LastDigest(ctx, "weekly", "a@example.com")
+		require.NoError(t, err)
+		require.Equal(t, "7", got.LastHearingID)
+		_, err = repo.LastDigest(ctx, "weekly", "b@example.com")
+		require.ErrorIs(t, err, database.ErrNotFound)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		repo := newRepo(t)
+		emails, err := repo.Unsubscribed(ctx)
+		require.NoError(t, err)
+		require.Empty(t, emails)
+
+		require.NoError(t, repo.Unsubscribe(ctx, "User@Example.com"))
+		require.NoError(t, repo.Unsubscribe(ctx, "user@example.com"))
+		require.NoError(t, repo.Unsubscribe(ctx, "another@example.com"))
+
+		emails, err = repo.Unsubscribed(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"another@example.com", "user@example.com"}, emails)
+	})
+}
+
+// SubscriptionFactory returns empty repository of subscriptions for one test
+type SubscriptionFactory func(t *testing.T) database.SubscriptionRepository
+
+// TestSubscriptionRepository runs conformance tests against repositories of subscriptions created by factory
+func TestSubscriptionRepository(t *testing.T, newRepo SubscriptionFactory) {
+	ctx := context.Background()
+
+	t.Run("add, list and delete", func(t *testing.T) {
+		repo := newRepo(t)
+		subs, err := repo.Subscriptions(ctx, "")
+		require.NoError(t, err)
+		require.Empty(t, subs)
+
+		district, err := repo.AddSubscription(ctx, database.Subscription{ChatID: "1", Kind: database.SubscribeDistrict, Value: "sovetsky"})
+		require.NoError(t, err)
+		require.NotEmpty(t, district.ID)
+		require.False(t, district.CreatedAt.IsZero())
+		_, err = repo.AddSubscription(ctx, database.Subscription{ChatID: "1", Kind: database.SubscribeDistrict, Value: "sovetsky"})
+		require.ErrorIs(t, err, database.ErrAlreadyExists)
+		keyword, err := repo.AddSubscription(ctx, database.Subscription{ChatID: "1", Kind: database.SubscribeKeyword, Value: "речная"})
+		require.NoError(t, err)
+		other, err := repo.AddSubscription(ctx, database.Subscription{ChatID: "2", Kind: database.SubscribeDistrict, Value: "sovetsky"})
+		require.NoError(t, err)
+
+		subs, err = repo.Subscriptions(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, subs, 2)
+		require.Equal(t, district.ID, subs[0].ID)
+		require.Equal(t, "речная", subs[1].Value)
+		subs, err = repo.Subscriptions(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, subs, 3)
+
+		n, err := repo.DeleteSubscriptions(ctx, "1", database.SubscribeKeyword, "речная")
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		n, err = repo.DeleteSubscriptions(ctx, "1", database.SubscribeKeyword, "речная")
+		require.NoError(t, err)
+		require.Zero(t, n)
+		subs, err = repo.Subscriptions(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		require.NotEqual(t, keyword.ID, subs[0].ID)
+
+		n, err = repo.DeleteSubscriptions(ctx, "1", "", "")
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		subs, err = repo.Subscriptions(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		require.Equal(t, other.ID, subs[0].ID)
+	})
+}
+
+// OutboxFactory returns empty repository of hearings and deliveries for one test
+type OutboxFactory func(t *testing.T) database.Repository
+
+// TestOutboxRepository runs conformance tests against outboxes of repositories created by factory
+func TestOutboxRepository(t *testing.T, newRepo OutboxFactory) {
+	ctx := context.Background()
+	future := time.Now().AddDate(0, 1, 0).Truncate(time.Second)
+	now := time.Now().Truncate(time.Second)
+
+	create := func(t *testing.T, repo database.Repository, n int) domain.Hearing {
+		require.NoError(t, repo.Create(ctx, testHearing(n, future)))
+		h, err := repo.Find(ctx, testHearing(n, future).URL)
+		require.NoError(t, err)
+		return h
+	}
+
+	t.Run("enqueue idempotently", func(t *testing.T) {
+		repo := newRepo(t)
+		h := create(t, repo, 0)
+		other := create(t, repo, 1)
+
+		deliveries := []database.Delivery{
+			{HearingID: h.ID, Channel: "telegram", Event: "published", Message: "первое сообщение"},
+			{HearingID: h.ID, Channel: "webhook", Event: "published", Message: "первое сообщение"},
+		}
+		saved, err := repo.Enqueue(ctx, deliveries, true)
+		require.NoError(t, err)
+		require.Equal(t, 2, saved)
+		saved, err = repo.Enqueue(ctx, deliveries, true)
+		require.NoError(t, err)
+		require.Zero(t, saved, "the same deliveries must not be enqueued twice")
+
+		got, err := repo.Get(ctx, h.ID)
+		require.NoError(t, err)
+		require.True(t, got.Published)
+		unpublished, err := repo.Unpublished(ctx, false)
+		require.NoError(t, err)
+		require.Len(t, unpublished, 1)
+		require.Equal(t, other.URL, unpublished[0].URL)
+
+		saved, err = repo.Enqueue(ctx, []database.Delivery{{HearingID: other.ID, Channel: "telegram", Event: "cancelled"}}, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, saved)
+		got, err = repo.Get(ctx, other.ID)
+		require.NoError(t, err)
+		require.False(t, got.Published)
+
+		_, err = repo.Enqueue(ctx, []database.Delivery{{HearingID: "999999", Channel: "telegram", Event: "published"}}, true)
+		require.ErrorIs(t, err, database.ErrNotFound)
+
+		list, err := repo.Deliveries(ctx, h.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		require.Equal(t, "telegram", list[0].Channel)
+		require.Equal(t, "первое сообщение", list[0].Message)
+		require.Equal(t, database.DeliveryPending, list[0].Status)
+		require.Zero(t, list[0].Attempts)
+		require.False(t, list[0].CreatedAt.IsZero())
+		require.True(t, list[0].SentAt.IsZero())
+
+		list, err = repo.Deliveries(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, list, 3)
+
+		list, err = repo.ChannelDeliveries(ctx, "webhook")
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, h.ID, list[0].HearingID)
+	})
+
+	t.Run("claim, retry and complete", func(t *testing.T) {
+		repo := newRepo(t)
+		h := create(t, repo, 0)
+		deleted := create(t, repo, 1)
+		_, err := repo.Enqueue(ctx, []database.Delivery{
+			{HearingID: h.ID, Channel: "telegram", Event: "published", Message: "now", NextAttemptAt: now},
+			{HearingID: h.ID, Channel: "telegram", Event: "reminder", Message: "later", NextAttemptAt: now.Add(time.Hour)},
+			{HearingID: deleted.ID, Channel: "telegram", Event: "published", Message: "deleted", NextAttemptAt: now},
+		}, false)
+		require.NoError(t, err)
+		require.NoError(t, repo.Delete(ctx, deleted.ID))
+
+		claimed, err := repo.ClaimDeliveries(ctx, now, time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1, "only due deliveries of not deleted hearings must be claimed")
+		require.Equal(t, "now", claimed[0].Message)
+		require.Equal(t, database.DeliverySending, claimed[0].Status)
+		require.Equal(t, 1, claimed[0].Attempts)
+
+		claimed2, err := repo.ClaimDeliveries(ctx, now, time.Minute, 10)
+		require.NoError(t, err)
+		require.Empty(t, claimed2, "claimed delivery must not be claimed again during lease")
+
+		// Worker stopped without result, delivery is claimed again after lease
+		claimed2, err = repo.ClaimDeliveries(ctx, now.Add(2*time.Minute), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed2, 1)
+		require.Equal(t, claimed[0].ID, claimed2[0].ID)
+		require.Equal(t, 2, claimed2[0].Attempts)
+
+		// Result of the first worker is not saved over the claim of the second one
+		require.ErrorIs(t, repo.FailDelivery(ctx, claimed[0].ID, claimed[0].Attempts, "timeout", now), database.ErrNotClaimed)
+		require.ErrorIs(t, repo.CompleteDelivery(ctx, claimed[0].ID, claimed[0].Attempts, now), database.ErrNotClaimed)
+		require.NoError(t, repo.FailDelivery(ctx, claimed2[0].ID, claimed2[0].Attempts, "timeout", now.Add(30*time.Minute)))
+		require.ErrorIs(t, repo.FailDelivery(ctx, claimed2[0].ID, claimed2[0].Attempts, "timeout", now), database.ErrNotClaimed,
+			"delivery is not sending after result")
+		claimed, err = repo.ClaimDeliveries(ctx, now.Add(10*time.Minute), time.Minute, 10)
+		require.NoError(t, err)
+		require.Empty(t, claimed)
+
+		claimed, err = repo.ClaimDeliveries(ctx, now.Add(2*time.Hour), time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1, "claim must be limited")
+		require.Equal(t, "now", claimed[0].Message, "earlier deliveries must be claimed first")
+		require.Equal(t, "timeout", claimed[0].LastError)
+		require.Equal(t, 3, claimed[0].Attempts)
+
+		require.NoError(t, repo.CompleteDelivery(ctx, claimed[0].ID, claimed[0].Attempts, now.Add(2*time.Hour)))
+		require.ErrorIs(t, repo.CompleteDelivery(ctx, "999999", 1, now), database.ErrNotFound)
+
+		claimed, err = repo.ClaimDeliveries(ctx, now.Add(2*time.Hour), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.Equal(t, "later", claimed[0].Message)
+		require.NoError(t, repo.FailDelivery(ctx, claimed[0].ID, claimed[0].Attempts, "bad request", time.Time{}))
+
+		claimed, err = repo.ClaimDeliveries(ctx, now.Add(24*time.Hour), time.Minute, 10)
+		require.NoError(t, err)
+		require.Empty(t, claimed, "sent and failed deliveries must not be claimed")
+
+		list, err := repo.Deliveries(ctx, h.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		require.Equal(t, database.DeliverySent, list[0].Status)
+		require.Empty(t, list[0].LastError)
+		require.True(t, now.Add(2*time.Hour).Equal(list[0].SentAt), "time %s", list[0].SentAt)
+		require.Equal(t, database.DeliveryFailed, list[1].Status)
+		require.Equal(t, "bad request", list[1].LastError)
+	})
+
+	t.Run("progress of delivery", func(t *testing.T) {
+		repo := newRepo(t)
+		h := create(t, repo, 0)
+		_, err := repo.Enqueue(ctx, []database.Delivery{{HearingID: h.ID, Channel: "telegram", Event: "published", Message: "long", NextAttemptAt: now}}, false)
+		require.NoError(t, err)
+		claimed, err := repo.ClaimDeliveries(ctx, now, time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.Zero(t, claimed[0].SentParts)
+		require.Zero(t, claimed[0].LastMessageID)
+
+		require.NoError(t, repo.SetDeliveryProgress(ctx, claimed[0].ID, claimed[0].Attempts, 2, 1234567))
+		require.ErrorIs(t, repo.SetDeliveryProgress(ctx, claimed[0].ID, claimed[0].Attempts+1, 3, 1), database.ErrNotClaimed)
+		require.ErrorIs(t, repo.SetDeliveryProgress(ctx, "999999", 1, 1, 1), database.ErrNotFound)
+		require.NoError(t, repo.FailDelivery(ctx, claimed[0].ID, claimed[0].Attempts, "timeout", now.Add(time.Minute)))
+
+		claimed, err = repo.ClaimDeliveries(ctx, now.Add(time.Hour), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.Equal(t, 2, claimed[0].SentParts, "progress must survive retry")
+		require.Equal(t, 1234567, claimed[0].LastMessageID)
+
+		list, err := repo.Deliveries(ctx, h.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		require.Equal(t, 2, list[0].SentParts)
+		require.Equal(t, 1234567, list[0].LastMessageID)
+	})
+
+	t.Run("last slot of channel", func(t *testing.T) {
+		repo := newRepo(t)
+		h := create(t, repo, 0)
+		last, err := repo.LastSlot(ctx, "telegram")
+		require.NoError(t, err)
+		require.True(t, last.IsZero())
+
+		_, err = repo.Enqueue(ctx, []database.Delivery{
+			{HearingID: h.ID, Channel: "telegram", Event: "published", Message: "sent", NextAttemptAt: now.Add(-time.Hour)},
+			{HearingID: h.ID, Channel: "telegram", Event: "changed", Message: "planned", NextAttemptAt: now.Add(time.Hour)},
+			{HearingID: h.ID, Channel: "telegram", Event: "cancelled", Message: "failed", NextAttemptAt: now.Add(-30 * time.Minute)},
+			{HearingID: h.ID, Channel: "vk", Event: "published", Message: "other", NextAttemptAt: now.Add(5 * time.Hour)},
+		}, false)
+		require.NoError(t, err)
+		last, err = repo.LastSlot(ctx, "telegram")
+		require.NoError(t, err)
+		require.True(t, now.Add(time.Hour).Equal(last), "time %s", last)
+
+		// Lease moves the next attempt of claimed deliveries after the planned one
+		claimed, err := repo.ClaimDeliveries(ctx, now, 3*time.Hour, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 2)
+		require.Equal(t, "sent", claimed[0].Message)
+		require.NoError(t, repo.CompleteDelivery(ctx, claimed[0].ID, claimed[0].Attempts, now.Add(2*time.Hour)))
+		require.NoError(t, repo.FailDelivery(ctx, claimed[1].ID, claimed[1].Attempts, "bad request", time.Time{}))
+		last, err = repo.LastSlot(ctx, "telegram")
+		require.NoError(t, err)
+		require.True(t, now.Add(2*time.Hour).Equal(last), "time of sending must be used for sent delivery, got %s", last)
+	})
+}
+
+// ReviewFactory returns empty repository of hearings and reviews for one test
+type ReviewFactory func(t *testing.T) database.Repository
+
+// TestReviewRepository runs conformance tests against moderation reviews of repositories created by factory
+func TestReviewRepository(t *testing.T, newRepo ReviewFactory) {
+	ctx := context.Background()
+	date := time.Date(2030, time.March, 17, 11, 0, 0, 0, time.UTC)
+
+	t.Run("set, get and list", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Create(ctx, testHearing(0, date)))
+		first, err := repo.Find(ctx, testHearing(0, date).URL)
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(ctx, testHearing(1, date)))
+		second, err := repo.Find(ctx, testHearing(1, date).URL)
+		require.NoError(t, err)
+
+		_, err = repo.Review(ctx, first.ID)
+		require.ErrorIs(t, err, database.ErrNotFound)
+		require.ErrorIs(t, repo.SetReview(ctx, database.Review{HearingID: "100500", State: database.ReviewPending}), database.ErrNotFound)
+
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: first.ID, State: database.ReviewPending}))
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: second.ID, State: database.ReviewPending}))
+		review, err := repo.Review(ctx, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, database.ReviewPending, review.State)
+		require.False(t, review.CreatedAt.IsZero())
+		created := review.CreatedAt
+
+		require.NoError(t, repo.SetReview(ctx, database.Review{
+			HearingID: first.ID, State: database.ReviewRejected, Moderator: "admin", Comment: "дубликат",
+		}))
+		review, err = repo.Review(ctx, first.ID)
+		require.NoError(t, err)
+		require.Equal(t, database.ReviewRejected, review.State)
+		require.Equal(t, "admin", review.Moderator)
+		require.Equal(t, "дубликат", review.Comment)
+		require.True(t, created.Equal(review.CreatedAt), "time of creation must be kept")
+
+		reviews, err := repo.Reviews(ctx, database.ReviewPending)
+		require.NoError(t, err)
+		require.Len(t, reviews, 1)
+		require.Equal(t, second.ID, reviews[0].HearingID)
+		reviews, err = repo.Reviews(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, reviews, 2)
+		require.Equal(t, first.ID, reviews[0].HearingID)
+	})
+
+	t.Run("create with review", func(t *testing.T) {
+		repo := newRepo(t)
+		h := testHearing(0, date)
+		require.NoError(t, repo.CreateWithReview(ctx, h, database.Review{HearingID: "100500", State: database.ReviewPending}))
+		require.Error(t, repo.CreateWithReview(ctx, h, database.Review{State: database.ReviewApproved}))
+
+		created, err := repo.Find(ctx, h.URL)
+		require.NoError(t, err)
+		review, err := repo.Review(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, database.ReviewPending, review.State)
+		require.False(t, review.CreatedAt.IsZero())
+		_, err = repo.Review(ctx, "100500")
+		require.ErrorIs(t, err, database.ErrNotFound)
+
+		unpublished, err := repo.Unpublished(ctx, false)
+		require.NoError(t, err)
+		require.Empty(t, unpublished, "hearing pending review must not be published")
+		reviews, err := repo.Reviews(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, reviews, 1, "failed creation must not save review")
+	})
+
+	t.Run("update pending review", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.CreateWithReview(ctx, testHearing(0, date), database.Review{State: database.ReviewPending}))
+		h, err := repo.Find(ctx, testHearing(0, date).URL)
+		require.NoError(t, err)
+		require.NoError(t, repo.Create(ctx, testHearing(1, date)))
+		other, err := repo.Find(ctx, testHearing(1, date).URL)
+		require.NoError(t, err)
+
+		require.ErrorIs(t, repo.UpdatePendingReview(ctx, database.Review{HearingID: other.ID, State: database.ReviewApproved}), database.ErrNotFound,
+			"not moderated hearing has no review")
+		require.ErrorIs(t, repo.UpdatePendingReview(ctx, database.Review{HearingID: "100500", State: database.ReviewApproved}), database.ErrNotFound)
+
+		require.NoError(t, repo.UpdatePendingReview(ctx, database.Review{HearingID: h.ID, State: database.ReviewPending, Moderator: "editor", Comment: "исправлено"}))
+		require.NoError(t, repo.UpdatePendingReview(ctx, database.Review{HearingID: h.ID, State: database.ReviewApproved, Moderator: "first"}))
+		// The second moderator pressed button at the same time
+		err = repo.UpdatePendingReview(ctx, database.Review{HearingID: h.ID, State: database.ReviewRejected, Moderator: "second"})
+		require.ErrorIs(t, err, database.ErrReviewDecided)
+		err = repo.UpdatePendingReview(ctx, database.Review{HearingID: h.ID, State: database.ReviewPending, Moderator: "editor"})
+		require.ErrorIs(t, err, database.ErrReviewDecided, "decided review must not become pending again")
+
+		review, err := repo.Review(ctx, h.ID)
+		require.NoError(t, err)
+		require.Equal(t, database.ReviewApproved, review.State)
+		require.Equal(t, "first", review.Moderator)
+		require.Empty(t, review.Comment)
+	})
+
+	t.Run("unpublished are approved or not moderated", func(t *testing.T) {
+		repo := newRepo(t)
+		ids := make([]string, 0, 4)
+		for i := 0; i < 4; i++ {
+			require.NoError(t, repo.Create(ctx, testHearing(i, date.AddDate(0, 0, i))))
+			h, err := repo.Find(ctx, testHearing(i, date).URL)
+			require.NoError(t, err)
+			ids = append(ids, h.ID)
+		}
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: ids[1], State: database.ReviewPending}))
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: ids[2], State: database.ReviewRejected}))
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: ids[3], State: database.ReviewApproved}))
+
+		unpublished, err := repo.Unpublished(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, unpublished, 2)
+		require.Equal(t, ids[0], unpublished[0].ID)
+		require.Equal(t, ids[3], unpublished[1].ID)
+
+		page, err := repo.Query(ctx, database.ListOptions{HideUnapproved: true})
+		require.NoError(t, err)
+		require.Equal(t, 2, page.Total)
+		require.Equal(t, ids[0], page.Hearings[0].ID)
+		require.Equal(t, ids[3], page.Hearings[1].ID)
+		page, err = repo.Query(ctx, database.ListOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 4, page.Total)
+		found, err := repo.Search(ctx, "планировки", 10)
+		require.NoError(t, err)
+		require.Len(t, found, 2, "hearings held for review must not be found")
+
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: ids[1], State: database.ReviewApproved}))
+		unpublished, err = repo.Unpublished(ctx, false)
+		require.NoError(t, err)
+		require.Len(t, unpublished, 1)
+		require.Equal(t, ids[1], unpublished[0].ID)
+	})
+}
@@ -0,0 +1,119 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Digest is record of sent email digest
+type Digest struct {
+	ID string `json:"id"`
+	// Period of digest, daily or weekly
+	Period string `json:"period"`
+	// Recipient is email of recipient in lower case.
+	// Digests recorded before per recipient results have empty recipient and apply to all recipients.
+	Recipient string `json:"recipient"`
+	// LastHearingID is identifier of the last hearing existed when digest was sent
+	LastHearingID string `json:"last_hearing_id"`
+	// Recipients is number of recipients who received digest.
+	// It is zero if there were no hearings to send.
+	Recipients int       `json:"recipients"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+type digest struct {
+	ID            int    `db:"id"`
+	Period        string `db:"period"`
+	Recipient     string `db:"recipient"`
+	LastHearingID int    `db:"last_hearing_id"`
+	Recipients    int    `db:"recipients"`
+	SentAt        string `db:"sent_at"`
+}
+
+// AddDigest saves sent digest and returns it with identifier
+func (c Client) AddDigest(ctx context.Context, d Digest) (Digest, error) {
+	last, _ := strconv.Atoi(d.LastHearingID)
+	d.Recipient = strings.ToLower(strings.TrimSpace(d.Recipient))
+	d.SentAt = d.SentAt.Truncate(time.Second)
+	var id int
+	err := c.db.QueryRowxContext(ctx,
+		"INSERT INTO digests(period, recipient, last_hearing_id, recipients, sent_at) VALUES($1, $2, $3, $4, $5) RETURNING id",
+		d.Period, d.Recipient, last, d.Recipients, d.SentAt.UTC().Format(timeFormat),
+	).Scan(&id)
+	if err != nil {
+		return d, err
+	}
+	d.ID = strconv.Itoa(id)
+	return d, nil
+}
+
+// LastDigest returns the last digest of period sent to recipient. ErrNotFound is returned if digest was never sent.
+func (c Client) LastDigest(ctx context.Context, period, recipient string) (Digest, error) {
+	rows := make([]digest, 0, 1)
+	err := c.db.SelectContext(ctx, &rows,
+		`SELECT id, period, recipient, last_hearing_id, recipients, sent_at FROM digests
+		WHERE period = $1 AND recipient IN ($2, '') ORDER BY sent_at DESC, id DESC LIMIT 1`,
+		period, strings.ToLower(strings.TrimSpace(recipient)))
+	if err != nil {
+		return Digest{}, err
+	}
+	if len(rows) == 0 {
+		return Digest{}, ErrNotFound
+	}
+	sentAt, _ := time.Parse(timeFormat, rows[0].SentAt)
+	return Digest{
+		ID:            strconv.Itoa(rows[0].ID),
+		Period:        rows[0].Period,
+		Recipient:     rows[0].Recipient,
+		LastHearingID: strconv.Itoa(rows[0].LastHearingID),
+		Recipients:    rows[0].Recipients,
+		SentAt:        sentAt,
+	}, nil
+}
+
+// Unsubscribe excludes email from recipients of digests. Repeated unsubscribing is not an error.
+func (c Client) Unsubscribe(ctx context.Context, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	var exists int
+	if err = tx.GetContext(ctx, &exists, "SELECT count(*) FROM digest_unsubscribes WHERE email = $1", email); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO digest_unsubscribes(email, created_at) VALUES($1, $2)",
+		email, time.Now().UTC().Format(timeFormat))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Unsubscribed returns emails excluded from recipients of digests in lower case
+func (c Client) Unsubscribed(ctx context.Context) ([]string, error) {
+	res := make([]string, 0)
+	err := c.db.SelectContext(ctx, &res, "SELECT email FROM digest_unsubscribes ORDER BY email")
+	return res, err
+}
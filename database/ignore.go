@@ -0,0 +1,120 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Kinds of ignore rules
+const (
+	// IgnoreURL ignores the only link equal to pattern
+	IgnoreURL = "url"
+	// IgnorePattern ignores links matched by regular expression
+	IgnorePattern = "pattern"
+)
+
+// IgnoreRule describes links of the list of hearings which are not hearings
+type IgnoreRule struct {
+	ID string `json:"id"`
+	// Kind is IgnoreURL or IgnorePattern
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	// Reason is comment of operator why links are ignored
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ignoreRule struct {
+	ID        int    `db:"id"`
+	Kind      string `db:"kind"`
+	Pattern   string `db:"pattern"`
+	Reason    string `db:"reason"`
+	CreatedAt string `db:"created_at"`
+}
+
+// AddIgnoreRule saves rule and returns it with identifier.
+// ErrAlreadyExists is returned if rule with the same kind and pattern exists.
+func (c Client) AddIgnoreRule(ctx context.Context, rule IgnoreRule) (IgnoreRule, error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return rule, err
+	}
+	defer c.rollback(tx)
+
+	var exists int
+	err = tx.GetContext(ctx, &exists, "SELECT count(*) FROM ignore_rules WHERE kind = $1 AND pattern = $2", rule.Kind, rule.Pattern)
+	if err != nil {
+		return rule, err
+	}
+	if exists > 0 {
+		return rule, ErrAlreadyExists
+	}
+
+	rule.CreatedAt = time.Now().Truncate(time.Second)
+	var id int
+	err = tx.QueryRowxContext(ctx,
+		"INSERT INTO ignore_rules(kind, pattern, reason, created_at) VALUES($1, $2, $3, $4) RETURNING id",
+		rule.Kind, rule.Pattern, rule.Reason, rule.CreatedAt.UTC().Format(timeFormat),
+	).Scan(&id)
+	if err != nil {
+		return rule, err
+	}
+	rule.ID = strconv.Itoa(id)
+	return rule, tx.Commit()
+}
+
+// IgnoreRules returns all rules in order of creation
+func (c Client) IgnoreRules(ctx context.Context) ([]IgnoreRule, error) {
+	rows := make([]ignoreRule, 0)
+	err := c.db.SelectContext(ctx, &rows, "SELECT id, kind, pattern, reason, created_at FROM ignore_rules ORDER BY id")
+	if err != nil {
+		return make([]IgnoreRule, 0), err
+	}
+	res := make([]IgnoreRule, 0, len(rows))
+	for _, row := range rows {
+		createdAt, _ := time.Parse(timeFormat, row.CreatedAt)
+		res = append(res, IgnoreRule{
+			ID:        strconv.Itoa(row.ID),
+			Kind:      row.Kind,
+			Pattern:   row.Pattern,
+			Reason:    row.Reason,
+			CreatedAt: createdAt,
+		})
+	}
+	return res, nil
+}
+
+// DeleteIgnoreRule deletes rule by identifier. ErrNotFound is returned if rule does not exist.
+func (c Client) DeleteIgnoreRule(ctx context.Context, id string) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := c.db.ExecContext(ctx, "DELETE FROM ignore_rules WHERE id = $1", n)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
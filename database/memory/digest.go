@@ -0,0 +1,81 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+)
+
+// AddDigest saves sent digest in memory
+func (r *Repository) AddDigest(_ context.Context, d database.Digest) (database.Digest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastDigestID++
+	d.ID = strconv.Itoa(r.lastDigestID)
+	d.Recipient = strings.ToLower(strings.TrimSpace(d.Recipient))
+	d.SentAt = d.SentAt.Truncate(time.Second)
+	r.digests = append(r.digests, d)
+	return d, nil
+}
+
+// LastDigest returns the last digest of period sent to recipient from memory
+func (r *Repository) LastDigest(_ context.Context, period, recipient string) (database.Digest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	recipient = strings.ToLower(strings.TrimSpace(recipient))
+	var last *database.Digest
+	for i, v := range r.digests {
+		if v.Period != period || (v.Recipient != recipient && v.Recipient != "") {
+			continue
+		}
+		if last == nil || !v.SentAt.Before(last.SentAt) {
+			last = &r.digests[i]
+		}
+	}
+	if last == nil {
+		return database.Digest{}, database.ErrNotFound
+	}
+	return *last, nil
+}
+
+// Unsubscribe excludes email from recipients of digests
+func (r *Repository) Unsubscribe(_ context.Context, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.unsubscribed[strings.ToLower(strings.TrimSpace(email))] = struct{}{}
+	return nil
+}
+
+// Unsubscribed returns emails excluded from recipients of digests
+func (r *Repository) Unsubscribed(_ context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]string, 0, len(r.unsubscribed))
+	for email := range r.unsubscribed {
+		res = append(res, email)
+	}
+	sort.Strings(res)
+	return res, nil
+}
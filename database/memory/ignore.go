@@ -0,0 +1,62 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+)
+
+// AddIgnoreRule saves rule in memory
+func (r *Repository) AddIgnoreRule(_ context.Context, rule database.IgnoreRule) (database.IgnoreRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range r.rules {
+		if v.Kind == rule.Kind && v.Pattern == rule.Pattern {
+			return rule, database.ErrAlreadyExists
+		}
+	}
+	r.lastRuleID++
+	rule.ID = strconv.Itoa(r.lastRuleID)
+	rule.CreatedAt = time.Now().Truncate(time.Second)
+	r.rules = append(r.rules, rule)
+	return rule, nil
+}
+
+// IgnoreRules returns all rules in order of creation
+func (r *Repository) IgnoreRules(_ context.Context) ([]database.IgnoreRule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append(make([]database.IgnoreRule, 0, len(r.rules)), r.rules...), nil
+}
+
+// DeleteIgnoreRule deletes rule from memory
+func (r *Repository) DeleteIgnoreRule(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, v := range r.rules {
+		if v.ID == id {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return nil
+		}
+	}
+	return database.ErrNotFound
+}
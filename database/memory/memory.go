@@ -0,0 +1,238 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package memory is in-memory storage of public hearings for tests and ephemeral runs
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// Repository of hearings in memory. It is safe for concurrent use.
+type Repository struct {
+	mu       sync.Mutex
+	lastID   int
+	hearings map[string]domain.Hearing
+
+	lastRuleID int
+	rules      []database.IgnoreRule
+
+	lastDeliveryID int
+	deliveries     []database.Delivery
+
+	lastWebhookID int
+	webhooks      []database.Webhook
+
+	lastDigestID int
+	digests      []database.Digest
+	unsubscribed map[string]struct{}
+
+	lastSubscriptionID int
+	subscriptions      []database.Subscription
+
+	reviews map[string]database.Review
+}
+
+var _ database.Repository = (*Repository)(nil)
+
+// New empty repository
+func New() *Repository {
+	return &Repository{
+		hearings:     make(map[string]domain.Hearing),
+		unsubscribed: make(map[string]struct{}),
+		reviews:      make(map[string]database.Review),
+	}
+}
+
+// Close does nothing, it is for compatibility with database client
+func (r *Repository) Close() error {
+	return nil
+}
+
+// Create new hearing in memory
+func (r *Repository) Create(_ context.Context, publicHearing domain.Hearing) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.create(publicHearing)
+}
+
+// create saves new hearing with the next identifier. Lock must be held by caller.
+func (r *Repository) create(publicHearing domain.Hearing) error {
+	if _, ok := r.hearings[publicHearing.URL]; ok {
+		return fmt.Errorf("hearing already exists: %s", publicHearing.URL)
+	}
+	r.lastID++
+	h := clone(publicHearing)
+	h.ID = strconv.Itoa(r.lastID)
+	h.Published = false
+	h.Status = h.CurrentStatus(time.Now())
+	h.Category = h.DetectCategory()
+	h.District = h.DetectDistrict()
+	r.hearings[h.URL] = h
+	return nil
+}
+
+// Update hearing in memory
+func (r *Repository) Update(_ context.Context, publicHearing domain.Hearing) error {
+	if publicHearing.URL == "" {
+		return fmt.Errorf("cannot update hearing: empty link")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.hearings[publicHearing.URL]
+	if !ok {
+		return database.ErrNotFound
+	}
+	updated := clone(publicHearing)
+	if len(updated.Topic) > 0 {
+		h.Topic = updated.Topic
+	}
+	if updated.Place != "" {
+		h.Place = updated.Place
+	}
+	if !updated.Time.IsZero() {
+		h.Time = updated.Time
+	}
+	if updated.Published {
+		h.Published = true
+	}
+	if len(updated.Proposals) > 0 {
+		h.Proposals = updated.Proposals
+	}
+	if len(updated.Raw) > 0 {
+		h.Raw = updated.Raw
+	}
+	if updated.Status != "" {
+		h.Status = updated.Status
+	}
+	h.Status = h.CurrentStatus(time.Now())
+	h.Category = h.DetectCategory()
+	h.District = h.DetectDistrict()
+	r.hearings[h.URL] = h
+	return nil
+}
+
+// Find one hearing in memory
+func (r *Repository) Find(_ context.Context, link string) (domain.Hearing, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	h, ok := r.hearings[link]
+	if !ok {
+		return domain.Hearing{}, database.ErrNotFound
+	}
+	return clone(h), nil
+}
+
+// Get one hearing by identifier from memory
+func (r *Repository) Get(_ context.Context, id string) (domain.Hearing, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, h := range r.hearings {
+		if h.ID == id {
+			return clone(h), nil
+		}
+	}
+	return domain.Hearing{}, database.ErrNotFound
+}
+
+// List all hearings in memory
+func (r *Repository) List(_ context.Context) ([]domain.Hearing, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sorted(func(domain.Hearing) bool { return true }), nil
+}
+
+// Unpublished hearings in memory except ones which are pending review or rejected
+func (r *Repository) Unpublished(_ context.Context, mark bool) ([]domain.Hearing, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := r.sorted(func(h domain.Hearing) bool { return !h.Published && !h.Deleted && !r.moderated(h.ID) })
+	if mark {
+		for i := range res {
+			res[i].Published = true
+			h := r.hearings[res[i].URL]
+			h.Published = true
+			r.hearings[h.URL] = h
+		}
+	}
+	return res, nil
+}
+
+// MarkPublished marks published hearings in memory
+func (r *Repository) MarkPublished(_ context.Context, link string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if h, ok := r.hearings[link]; ok {
+		h.Published = true
+		r.hearings[link] = h
+	}
+	return nil
+}
+
+// Delete hearing softly in memory
+func (r *Repository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for link, h := range r.hearings {
+		if h.ID == id {
+			h.Deleted = true
+			r.hearings[link] = h
+			return nil
+		}
+	}
+	return database.ErrNotFound
+}
+
+// sorted returns copies of matched hearings ordered by date and creation
+func (r *Repository) sorted(match func(domain.Hearing) bool) []domain.Hearing {
+	res := make([]domain.Hearing, 0, len(r.hearings))
+	for _, h := range r.hearings {
+		if match(h) {
+			res = append(res, clone(h))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Time.Equal(res[j].Time) {
+			return res[i].Time.Before(res[j].Time)
+		}
+		a, _ := strconv.Atoi(res[i].ID)
+		b, _ := strconv.Atoi(res[j].ID)
+		return a < b
+	})
+	return res
+}
+
+// clone hearing with its slices
+func clone(h domain.Hearing) domain.Hearing {
+	h.Topic = append([]string(nil), h.Topic...)
+	h.Proposals = append([]string(nil), h.Proposals...)
+	h.Raw = append([]string(nil), h.Raw...)
+	return h
+}
@@ -0,0 +1,46 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/database/databasetest"
+)
+
+func TestRepository(t *testing.T) {
+	databasetest.TestHearingRepository(t, func(t *testing.T) database.HearingRepository {
+		return New()
+	})
+	databasetest.TestIgnoreRuleRepository(t, func(t *testing.T) database.IgnoreRuleRepository {
+		return New()
+	})
+	databasetest.TestOutboxRepository(t, func(t *testing.T) database.Repository {
+		return New()
+	})
+	databasetest.TestWebhookRepository(t, func(t *testing.T) database.WebhookRepository {
+		return New()
+	})
+	databasetest.TestDigestRepository(t, func(t *testing.T) database.DigestRepository {
+		return New()
+	})
+	databasetest.TestSubscriptionRepository(t, func(t *testing.T) database.SubscriptionRepository {
+		return New()
+	})
+	databasetest.TestReviewRepository(t, func(t *testing.T) database.Repository {
+		return New()
+	})
+}
@@ -0,0 +1,221 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// hearingByID returns hearing with identifier. Caller must hold the lock.
+func (r *Repository) hearingByID(id string) (domain.Hearing, bool) {
+	for _, h := range r.hearings {
+		if h.ID == id {
+			return h, true
+		}
+	}
+	return domain.Hearing{}, false
+}
+
+// Enqueue saves pending deliveries in memory
+func (r *Repository) Enqueue(_ context.Context, deliveries []database.Delivery, publish bool) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range deliveries {
+		if _, ok := r.hearingByID(d.HearingID); !ok {
+			return 0, database.ErrNotFound
+		}
+	}
+
+	// Time is truncated like in database
+	now := time.Now().Truncate(time.Second)
+	saved := 0
+	for _, d := range deliveries {
+		if publish {
+			h, _ := r.hearingByID(d.HearingID)
+			h.Published = true
+			r.hearings[h.URL] = h
+		}
+		if r.deliveryIndex(d) >= 0 {
+			continue
+		}
+		r.lastDeliveryID++
+		d.ID = strconv.Itoa(r.lastDeliveryID)
+		d.Status = database.DeliveryPending
+		d.Attempts = 0
+		d.LastError = ""
+		d.SentParts, d.LastMessageID = 0, 0
+		d.CreatedAt = now
+		d.SentAt = time.Time{}
+		if d.NextAttemptAt.IsZero() {
+			d.NextAttemptAt = now
+		}
+		d.NextAttemptAt = d.NextAttemptAt.Truncate(time.Second)
+		r.deliveries = append(r.deliveries, d)
+		saved++
+	}
+	return saved, nil
+}
+
+// deliveryIndex returns index of delivery with the same hearing, channel and event or -1
+func (r *Repository) deliveryIndex(d database.Delivery) int {
+	for i, v := range r.deliveries {
+		if v.HearingID == d.HearingID && v.Channel == d.Channel && v.Event == d.Event {
+			return i
+		}
+	}
+	return -1
+}
+
+// ClaimDeliveries returns due deliveries from memory and marks them as sending
+func (r *Repository) ClaimDeliveries(_ context.Context, now time.Time, lease time.Duration, limit int) ([]database.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now = now.Truncate(time.Second)
+	due := make([]int, 0)
+	for i, d := range r.deliveries {
+		if d.Status != database.DeliveryPending && d.Status != database.DeliverySending {
+			continue
+		}
+		if d.NextAttemptAt.After(now) {
+			continue
+		}
+		if h, ok := r.hearingByID(d.HearingID); !ok || h.Deleted {
+			continue
+		}
+		due = append(due, i)
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return r.deliveries[due[i]].NextAttemptAt.Before(r.deliveries[due[j]].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	res := make([]database.Delivery, 0, len(due))
+	for _, i := range due {
+		d := &r.deliveries[i]
+		d.Status = database.DeliverySending
+		d.Attempts++
+		d.NextAttemptAt = now.Add(lease)
+		res = append(res, *d)
+	}
+	return res, nil
+}
+
+// CompleteDelivery marks delivery claimed for attempt in memory as sent
+func (r *Repository) CompleteDelivery(_ context.Context, id string, attempt int, sentAt time.Time) error {
+	return r.updateDelivery(id, attempt, func(d *database.Delivery) {
+		d.Status = database.DeliverySent
+		d.LastError = ""
+		d.SentAt = sentAt.Truncate(time.Second)
+	})
+}
+
+// FailDelivery saves error of attempt of claimed delivery in memory
+func (r *Repository) FailDelivery(_ context.Context, id string, attempt int, lastError string, next time.Time) error {
+	return r.updateDelivery(id, attempt, func(d *database.Delivery) {
+		d.LastError = lastError
+		if next.IsZero() {
+			d.Status = database.DeliveryFailed
+			return
+		}
+		d.Status = database.DeliveryPending
+		d.NextAttemptAt = next.Truncate(time.Second)
+	})
+}
+
+// SetDeliveryProgress saves progress of split message of claimed delivery in memory
+func (r *Repository) SetDeliveryProgress(_ context.Context, id string, attempt, sentParts, lastMessageID int) error {
+	return r.updateDelivery(id, attempt, func(d *database.Delivery) {
+		d.SentParts = sentParts
+		d.LastMessageID = lastMessageID
+	})
+}
+
+// updateDelivery changes delivery claimed for attempt
+func (r *Repository) updateDelivery(id string, attempt int, update func(d *database.Delivery)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.deliveries {
+		d := &r.deliveries[i]
+		if d.ID != id {
+			continue
+		}
+		if d.Status != database.DeliverySending || d.Attempts != attempt {
+			return database.ErrNotClaimed
+		}
+		update(d)
+		return nil
+	}
+	return database.ErrNotFound
+}
+
+// Deliveries of hearing in memory
+func (r *Repository) Deliveries(_ context.Context, hearingID string) ([]database.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]database.Delivery, 0)
+	for _, d := range r.deliveries {
+		if hearingID == "" || d.HearingID == hearingID {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+// ChannelDeliveries returns deliveries to channel in order of creation
+func (r *Repository) ChannelDeliveries(_ context.Context, channel string) ([]database.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]database.Delivery, 0)
+	for _, d := range r.deliveries {
+		if d.Channel == channel {
+			res = append(res, d)
+		}
+	}
+	return res, nil
+}
+
+// LastSlot returns the latest time of sending or planned attempt of deliveries to channel in memory
+func (r *Repository) LastSlot(_ context.Context, channel string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last time.Time
+	for _, d := range r.deliveries {
+		if d.Channel != channel || d.Status == database.DeliveryFailed {
+			continue
+		}
+		at := d.NextAttemptAt
+		if !d.SentAt.IsZero() {
+			at = d.SentAt
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	return last, nil
+}
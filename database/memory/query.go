@@ -0,0 +1,126 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// timeFormat of sort values in cursors. Time of hearing is compared by its wall clock like in database.
+const timeFormat = "2006-01-02 15:04:05"
+
+// formatDate returns wall clock of time in local time zone like dates of hearings in database
+func formatDate(t time.Time) string {
+	return t.In(time.Local).Format(timeFormat)
+}
+
+// Query hearings filtered, sorted and paginated by options.
+// Hearings are created in order of identifiers, so sorting by creation is sorting by identifiers.
+func (r *Repository) Query(_ context.Context, opts database.ListOptions) (database.Page, error) {
+	page := database.Page{Hearings: make([]domain.Hearing, 0)}
+	if err := opts.Validate(); err != nil {
+		return page, err
+	}
+	statuses := make(map[domain.Status]struct{}, len(opts.Statuses))
+	for _, status := range opts.Statuses {
+		statuses[status] = struct{}{}
+	}
+	terms := database.SearchTerms(opts.Text)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := r.sorted(func(h domain.Hearing) bool {
+		date := formatDate(h.Time)
+		switch {
+		case h.Deleted,
+			!opts.From.IsZero() && date < formatDate(opts.From),
+			!opts.To.IsZero() && date >= formatDate(opts.To),
+			opts.Published != nil && h.Published != *opts.Published,
+			opts.Category != "" && h.Category != opts.Category,
+			opts.District != "" && h.District != opts.District,
+			opts.HideUnapproved && r.moderated(h.ID),
+			len(terms) > 0 && !matchesTerms(h, terms):
+			return false
+		}
+		_, ok := statuses[h.CurrentStatus(opts.Now)]
+		return ok || len(statuses) == 0
+	})
+	page.Total = len(list)
+
+	value := func(h domain.Hearing) string {
+		if opts.Sort == database.SortDate {
+			return formatDate(h.Time)
+		}
+		return ""
+	}
+	id := func(h domain.Hearing) int {
+		n, _ := strconv.Atoi(h.ID)
+		return n
+	}
+	// less compares hearings in ascending order
+	less := func(v1 string, id1 int, v2 string, id2 int) bool {
+		if v1 != v2 {
+			return v1 < v2
+		}
+		return id1 < id2
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if opts.Desc {
+			i, j = j, i
+		}
+		return less(value(list[i]), id(list[i]), value(list[j]), id(list[j]))
+	})
+
+	if opts.Cursor != "" {
+		v, cid, err := database.DecodeCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return page, err
+		}
+		if opts.Sort != database.SortDate {
+			v = ""
+		}
+		start := len(list)
+		for i, h := range list {
+			after := less(v, cid, value(h), id(h))
+			if opts.Desc {
+				after = less(value(h), id(h), v, cid)
+			}
+			if after {
+				start = i
+				break
+			}
+		}
+		list = list[start:]
+	}
+
+	if opts.Limit > 0 && len(list) > opts.Limit {
+		list = list[:opts.Limit]
+		last := list[opts.Limit-1]
+		v := value(last)
+		if opts.Sort != database.SortDate {
+			v = last.ID
+		}
+		page.Next = database.EncodeCursor(opts.Sort, v, id(last))
+	}
+	page.Hearings = list
+	return page, nil
+}
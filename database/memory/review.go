@@ -0,0 +1,125 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// SetReview saves review of hearing in memory
+func (r *Repository) SetReview(_ context.Context, review database.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.exists(review.HearingID) {
+		return database.ErrNotFound
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	review.CreatedAt, review.UpdatedAt = now, now
+	if prev, ok := r.reviews[review.HearingID]; ok {
+		review.CreatedAt = prev.CreatedAt
+	}
+	r.reviews[review.HearingID] = review
+	return nil
+}
+
+// UpdatePendingReview replaces review of hearing in memory only while it is pending
+func (r *Repository) UpdatePendingReview(_ context.Context, review database.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.reviews[review.HearingID]
+	if !ok {
+		return database.ErrNotFound
+	}
+	if prev.State != database.ReviewPending {
+		return database.ErrReviewDecided
+	}
+	review.CreatedAt = prev.CreatedAt
+	review.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	r.reviews[review.HearingID] = review
+	return nil
+}
+
+// CreateWithReview creates new hearing together with its review in memory
+func (r *Repository) CreateWithReview(_ context.Context, h domain.Hearing, review database.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.create(h); err != nil {
+		return err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	review.HearingID = strconv.Itoa(r.lastID)
+	review.CreatedAt, review.UpdatedAt = now, now
+	r.reviews[review.HearingID] = review
+	return nil
+}
+
+// Review of hearing from memory
+func (r *Repository) Review(_ context.Context, hearingID string) (database.Review, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	review, ok := r.reviews[hearingID]
+	if !ok {
+		return database.Review{}, database.ErrNotFound
+	}
+	return review, nil
+}
+
+// Reviews in state in order of creation
+func (r *Repository) Reviews(_ context.Context, state database.ReviewState) ([]database.Review, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]database.Review, 0)
+	for _, v := range r.reviews {
+		if state == "" || v.State == state {
+			res = append(res, v)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.Before(res[j].CreatedAt)
+		}
+		a, _ := strconv.Atoi(res[i].HearingID)
+		b, _ := strconv.Atoi(res[j].HearingID)
+		return a < b
+	})
+	return res, nil
+}
+
+// exists reports whether hearing with identifier is in memory
+func (r *Repository) exists(id string) bool {
+	for _, h := range r.hearings {
+		if h.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// moderated reports whether hearing is pending review or rejected
+func (r *Repository) moderated(id string) bool {
+	review, ok := r.reviews[id]
+	return ok && review.State != database.ReviewApproved
+}
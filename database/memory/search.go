@@ -0,0 +1,137 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// Weights of fields in rank of search results like in SQLite index
+const (
+	topicWeight    = 10
+	placeWeight    = 5
+	proposalWeight = 2
+	rawWeight      = 1
+)
+
+// Search hearings by prefixes of words like full-text index of database does.
+// Snippet is the best matched line of hearing escaped as HTML.
+func (r *Repository) Search(_ context.Context, query string, limit int) ([]database.SearchResult, error) {
+	res := make([]database.SearchResult, 0)
+	terms := database.SearchTerms(query)
+	if len(terms) == 0 {
+		return res, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, h := range r.sorted(func(h domain.Hearing) bool { return !h.Deleted && !r.moderated(h.ID) }) {
+		found := make(map[string]struct{}, len(terms))
+		rank := 0.0
+		bestScore := 0
+		snippet := ""
+		check := func(weight int, lines ...string) {
+			for _, line := range lines {
+				text, matched := highlight(line, terms)
+				for term, count := range matched {
+					found[term] = struct{}{}
+					rank += float64(weight * count)
+				}
+				if score := weight * len(matched); score > bestScore {
+					bestScore = score
+					snippet = text
+				}
+			}
+		}
+		check(topicWeight, h.Topic...)
+		check(placeWeight, h.Place)
+		check(proposalWeight, h.Proposals...)
+		check(rawWeight, h.Raw...)
+
+		if len(found) == len(terms) {
+			res = append(res, database.SearchResult{Hearing: h, Snippet: snippet, Rank: rank})
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Rank != res[j].Rank {
+			return res[i].Rank > res[j].Rank
+		}
+		return res[i].Hearing.Time.After(res[j].Hearing.Time)
+	})
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// matchesTerms returns true if every term matches words of hearing
+func matchesTerms(h domain.Hearing, terms []string) bool {
+	found := make(map[string]struct{}, len(terms))
+	lines := append(append(append([]string{h.Place}, h.Topic...), h.Proposals...), h.Raw...)
+	for _, line := range lines {
+		_, matched := highlight(line, terms)
+		for term := range matched {
+			found[term] = struct{}{}
+		}
+	}
+	return len(found) == len(terms)
+}
+
+// highlight escapes line as HTML, wraps words starting with terms and returns counts of matches by term
+func highlight(line string, terms []string) (string, map[string]int) {
+	matched := make(map[string]int)
+	var b strings.Builder
+	runes := []rune(line)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		normalized := strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+		hit := false
+		for _, term := range terms {
+			if strings.HasPrefix(normalized, term) {
+				matched[term]++
+				hit = true
+			}
+		}
+		if hit {
+			b.WriteString(database.SnippetStart + word + database.SnippetEnd)
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+	return b.String(), matched
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,71 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+)
+
+// AddSubscription saves subscription in memory
+func (r *Repository) AddSubscription(_ context.Context, sub database.Subscription) (database.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range r.subscriptions {
+		if v.ChatID == sub.ChatID && v.Kind == sub.Kind && v.Value == sub.Value {
+			return sub, database.ErrAlreadyExists
+		}
+	}
+	r.lastSubscriptionID++
+	sub.ID = strconv.Itoa(r.lastSubscriptionID)
+	sub.CreatedAt = time.Now().Truncate(time.Second)
+	r.subscriptions = append(r.subscriptions, sub)
+	return sub, nil
+}
+
+// Subscriptions of chat in order of creation
+func (r *Repository) Subscriptions(_ context.Context, chatID string) ([]database.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]database.Subscription, 0)
+	for _, v := range r.subscriptions {
+		if chatID == "" || v.ChatID == chatID {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+// DeleteSubscriptions of chat from memory
+func (r *Repository) DeleteSubscriptions(_ context.Context, chatID, kind, value string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.subscriptions[:0]
+	for _, v := range r.subscriptions {
+		if v.ChatID == chatID && (kind == "" || v.Kind == kind && v.Value == value) {
+			continue
+		}
+		kept = append(kept, v)
+	}
+	deleted := len(r.subscriptions) - len(kept)
+	r.subscriptions = kept
+	return deleted, nil
+}
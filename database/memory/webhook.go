@@ -0,0 +1,71 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package memory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+)
+
+// AddWebhook saves webhook in memory
+func (r *Repository) AddWebhook(_ context.Context, hook database.Webhook) (database.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastWebhookID++
+	hook.ID = strconv.Itoa(r.lastWebhookID)
+	hook.Events = append([]string(nil), hook.Events...)
+	hook.CreatedAt = time.Now().Truncate(time.Second)
+	r.webhooks = append(r.webhooks, hook)
+	return hook, nil
+}
+
+// Webhooks returns all webhooks in order of creation
+func (r *Repository) Webhooks(_ context.Context) ([]database.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append(make([]database.Webhook, 0, len(r.webhooks)), r.webhooks...), nil
+}
+
+// Webhook returns webhook from memory
+func (r *Repository) Webhook(_ context.Context, id string) (database.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, v := range r.webhooks {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return database.Webhook{}, database.ErrNotFound
+}
+
+// DeleteWebhook deletes webhook from memory
+func (r *Repository) DeleteWebhook(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, v := range r.webhooks {
+		if v.ID == id {
+			r.webhooks = append(r.webhooks[:i], r.webhooks[i+1:]...)
+			return nil
+		}
+	}
+	return database.ErrNotFound
+}
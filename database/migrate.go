@@ -0,0 +1,329 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*/*.sql
+var migrationsFS embed.FS
+
+// legacyVersions maps PRAGMA user_version of databases upgraded by inline queries to versions of migrations
+var legacyVersions = map[int]int{
+	1:  1,
+	2:  2,
+	3:  3,
+	12: 4,
+}
+
+var reMigrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration of database schema
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus is state of migration in database
+type MigrationStatus struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at"`
+	// Modified is true when applied migration differs from the embedded one
+	Modified bool `json:"modified"`
+}
+
+type appliedMigration struct {
+	Version   int    `db:"version"`
+	Name      string `db:"name"`
+	Checksum  string `db:"checksum"`
+	AppliedAt string `db:"applied_at"`
+}
+
+// loadMigrations returns sorted migrations from directory of file system
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		match := reMigrationFile.FindStringSubmatch(path.Base(file))
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", file)
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", file)
+		}
+		body, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+			m.Checksum = fmt.Sprintf("%x", sha256.Sum256(body))
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d (%s) must have up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// prepareMigrations creates table of applied migrations.
+// Databases upgraded by inline queries are marked as migrated up to the corresponding version.
+func (c Client) prepareMigrations(ctx context.Context, migrations []Migration) error {
+	var exists int
+	err := c.db.GetContext(ctx, &exists, c.dialect.tableExists, "schema_migrations")
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	legacy := 0
+	if c.dialect.legacy {
+		legacy, err = c.getSchemaVersion()
+		if err != nil {
+			return fmt.Errorf("could not get schema version: %w", err)
+		}
+	}
+	version := 0
+	if legacy > 0 {
+		var ok bool
+		version, ok = legacyVersions[legacy]
+		if !ok {
+			return fmt.Errorf("unsupported legacy schema version: %d", legacy)
+		}
+		c.logger.Info().Msgf("database legacy schema version %d is migration %d", legacy, version)
+	}
+
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE schema_migrations(
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TEXT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations[:version] {
+		if err = c.recordMigration(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c Client) recordMigration(ctx context.Context, tx *sqlx.Tx, m Migration) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations(version, name, checksum, applied_at) VALUES($1, $2, $3, $4)",
+		m.Version, m.Name, m.Checksum, time.Now().UTC().Format(timeFormat),
+	)
+	return err
+}
+
+func (c Client) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	rows := make([]appliedMigration, 0)
+	err := c.db.SelectContext(ctx, &rows, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int]appliedMigration, len(rows))
+	for _, row := range rows {
+		res[row.Version] = row
+	}
+	return res, nil
+}
+
+// MigrationStatus returns state of all known migrations
+func (c Client) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationsFS, c.dialect.migrations)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.prepareMigrations(ctx, migrations); err != nil {
+		return nil, err
+	}
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt, _ = time.Parse(timeFormat, a.AppliedAt)
+			status.Modified = a.Checksum != m.Checksum
+		}
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+// MigrateUp applies the next steps of migrations. Zero or negative steps apply all migrations.
+// It returns number of applied migrations.
+func (c Client) MigrateUp(ctx context.Context, steps int) (int, error) {
+	migrations, err := loadMigrations(migrationsFS, c.dialect.migrations)
+	if err != nil {
+		return 0, err
+	}
+	if err = c.prepareMigrations(ctx, migrations); err != nil {
+		return 0, err
+	}
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, m := range migrations {
+		a, ok := applied[m.Version]
+		if ok && a.Checksum != m.Checksum {
+			return 0, fmt.Errorf("checksum mismatch of applied migration %d (%s)", m.Version, m.Name)
+		}
+	}
+	for v := range applied {
+		if v > len(migrations) {
+			return 0, fmt.Errorf("database has unknown migration %d", v)
+		}
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if steps > 0 && count == steps {
+			break
+		}
+
+		c.logger.Info().Msgf("applying migration %d (%s)", m.Version, m.Name)
+		err = c.applyMigration(ctx, m.Up, func(tx *sqlx.Tx) error {
+			return c.recordMigration(ctx, tx, m)
+		})
+		if err != nil {
+			return count, fmt.Errorf("could not apply migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the last steps of applied migrations. Zero or negative steps revert all migrations.
+// It returns number of reverted migrations.
+func (c Client) MigrateDown(ctx context.Context, steps int) (int, error) {
+	migrations, err := loadMigrations(migrationsFS, c.dialect.migrations)
+	if err != nil {
+		return 0, err
+	}
+	if err = c.prepareMigrations(ctx, migrations); err != nil {
+		return 0, err
+	}
+	applied, err := c.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if steps > 0 && count == steps {
+			break
+		}
+
+		c.logger.Info().Msgf("reverting migration %d (%s)", m.Version, m.Name)
+		err = c.applyMigration(ctx, m.Down, func(tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("could not revert migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration executes query and records it in one transaction
+func (c Client) applyMigration(ctx context.Context, query string, record func(tx *sqlx.Tx) error) error {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	c.logger.Debug().Msgf("database executing query: %s", c.cleanQuery(query))
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	if err = record(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,224 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/domain"
+)
+
+func openTestClient(t *testing.T) *Client {
+	t.Helper()
+	l := zerolog.Nop()
+	c, err := Open(filepath.Join(t.TempDir(), "test"), &l)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS, sqliteDialect.migrations)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	for i, m := range migrations {
+		require.Equal(t, i+1, m.Version)
+		require.NotEmpty(t, m.Up, m.Name)
+		require.NotEmpty(t, m.Down, m.Name)
+		require.Len(t, m.Checksum, 64, m.Name)
+	}
+}
+
+func TestMigrate_fresh(t *testing.T) {
+	ctx := context.Background()
+	c := openTestClient(t)
+	migrations, err := loadMigrations(migrationsFS, sqliteDialect.migrations)
+	require.NoError(t, err)
+
+	// Apply and revert migrations one by one
+	for i := range migrations {
+		n, err := c.MigrateUp(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, n, migrations[i].Name)
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		n, err := c.MigrateDown(ctx, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, n, migrations[i].Name)
+	}
+	n, err := c.MigrateDown(ctx, 1)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// Apply all migrations at once
+	n, err = c.MigrateUp(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, len(migrations), n)
+
+	status, err := c.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, len(migrations))
+	for _, s := range status {
+		require.True(t, s.Applied, s.Name)
+		require.False(t, s.Modified, s.Name)
+	}
+
+	hearing := domain.Hearing{
+		URL:       "https://bga32.ru/hearing/",
+		Topic:     []string{"по проекту || планировки", "по проекту межевания"},
+		Proposals: []string{"Приём предложений"},
+		Place:     "ГДК Советского района",
+		Time:      time.Date(2022, time.March, 29, 11, 0, 0, 0, time.UTC),
+		Raw:       []string{"первый абзац", "второй абзац"},
+	}
+	require.NoError(t, c.Create(ctx, hearing))
+	got, err := c.Find(ctx, hearing.URL)
+	require.NoError(t, err)
+	require.Equal(t, hearing.Topic, got.Topic)
+	require.Equal(t, hearing.Proposals, got.Proposals)
+	require.Equal(t, hearing.Raw, got.Raw)
+}
+
+func TestMigrate_legacy(t *testing.T) {
+	ctx := context.Background()
+	c := openTestClient(t)
+
+	// Schema created by inline queries before migrations
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS hearings(
+			id INTEGER PRIMARY KEY,
+			link TEXT DEFAULT '' NOT NULL UNIQUE,
+			topics TEXT DEFAULT '',
+			proposals TEXT DEFAULT '',
+			place TEXT DEFAULT '',
+			date TEXT DEFAULT '1970-01-01 00:00:00',
+			published BOOLEAN DEFAULT false,
+			raw TEXT DEFAULT ''
+		)`,
+		`ALTER TABLE hearings ADD COLUMN created_at TEXT DEFAULT '1970-01-01 00:00:00'`,
+		`INSERT INTO hearings(link, topics, proposals, place, date, published, raw)
+			VALUES('https://bga32.ru/1/', 'первая тема||вторая тема', '', 'ГДК', '2022-03-29 11:00:00', true, 'абзац')`,
+		`INSERT INTO hearings(link, topics, proposals, place, date, raw)
+			VALUES('https://bga32.ru/2/', 'тема', 'предложения||заявления', 'ГДК', '2022-03-30 11:00:00', '')`,
+		`PRAGMA user_version = 2`,
+	}
+	for _, q := range queries {
+		_, err := c.db.Exec(q)
+		require.NoError(t, err)
+	}
+
+	n, err := c.MigrateUp(ctx, 0)
+	require.NoError(t, err)
+	migrations, err := loadMigrations(migrationsFS, sqliteDialect.migrations)
+	require.NoError(t, err)
+	require.Equal(t, len(migrations)-2, n)
+
+	list, err := c.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, []string{"первая тема", "вторая тема"}, list[0].Topic)
+	require.Empty(t, list[0].Proposals)
+	require.Equal(t, []string{"абзац"}, list[0].Raw)
+	require.True(t, list[0].Published)
+	require.Equal(t, []string{"тема"}, list[1].Topic)
+	require.Equal(t, []string{"предложения", "заявления"}, list[1].Proposals)
+	require.Empty(t, list[1].Raw)
+
+	// Existing hearings are indexed for search
+	found, err := c.Search(ctx, "вторые темы", 10)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, "https://bga32.ru/1/", found[0].Hearing.URL)
+
+	// Revert migrations down to normalization and check joined values
+	n, err = c.MigrateDown(ctx, len(migrations)-3)
+	require.NoError(t, err)
+	require.Equal(t, len(migrations)-3, n)
+	var topics string
+	require.NoError(t, c.db.Get(&topics, "SELECT topics FROM hearings WHERE link = 'https://bga32.ru/1/'"))
+	require.Equal(t, "первая тема||вторая тема", topics)
+}
+
+func TestMigrate_normalizeHearings(t *testing.T) {
+	ctx := context.Background()
+	c := openTestClient(t)
+	n, err := c.MigrateUp(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	// Rows stored before normalization with values joined by '||'
+	_, err = c.db.Exec(`INSERT INTO hearings(id, link, topics, proposals, place, date, raw) VALUES
+		(1, 'https://bga32.ru/1/', 'первая тема||вторая тема||третья тема', 'предложения', 'ГДК', '2022-03-29 11:00:00', 'первый абзац||второй абзац'),
+		(2, 'https://bga32.ru/2/', 'тема', '', 'ГДК', '2022-03-30 11:00:00', ''),
+		(3, 'https://bga32.ru/3/', '', 'предложения||заявления', 'ГДК', '2022-03-31 11:00:00', 'абзац')`)
+	require.NoError(t, err)
+
+	n, err = c.MigrateUp(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	type item struct {
+		HearingID int    `db:"hearing_id"`
+		Position  int    `db:"position"`
+		Value     string `db:"value"`
+	}
+	var topics, proposals, paragraphs []item
+	require.NoError(t, c.db.Select(&topics, "SELECT hearing_id, position, topic AS value FROM hearing_topics ORDER BY hearing_id, position"))
+	require.Equal(t, []item{{1, 0, "первая тема"}, {1, 1, "вторая тема"}, {1, 2, "третья тема"}, {2, 0, "тема"}}, topics)
+	require.NoError(t, c.db.Select(&proposals, "SELECT hearing_id, position, proposal AS value FROM hearing_proposals ORDER BY hearing_id, position"))
+	require.Equal(t, []item{{1, 0, "предложения"}, {3, 0, "предложения"}, {3, 1, "заявления"}}, proposals)
+	require.NoError(t, c.db.Select(&paragraphs, "SELECT hearing_id, position, paragraph AS value FROM hearing_paragraphs ORDER BY hearing_id, position"))
+	require.Equal(t, []item{{1, 0, "первый абзац"}, {1, 1, "второй абзац"}, {3, 0, "абзац"}}, paragraphs)
+
+	_, err = c.MigrateUp(ctx, 0)
+	require.NoError(t, err)
+	h, err := c.Find(ctx, "https://bga32.ru/1/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"первая тема", "вторая тема", "третья тема"}, h.Topic)
+	require.Equal(t, []string{"предложения"}, h.Proposals)
+	require.Equal(t, []string{"первый абзац", "второй абзац"}, h.Raw)
+}
+
+func TestMigrate_unsupportedLegacy(t *testing.T) {
+	c := openTestClient(t)
+	_, err := c.db.Exec("PRAGMA user_version = 7")
+	require.NoError(t, err)
+
+	_, err = c.MigrateUp(context.Background(), 0)
+	require.Error(t, err)
+}
+
+func TestMigrate_checksum(t *testing.T) {
+	ctx := context.Background()
+	c := openTestClient(t)
+	_, err := c.MigrateUp(ctx, 0)
+	require.NoError(t, err)
+
+	_, err = c.db.Exec("UPDATE schema_migrations SET checksum = 'modified' WHERE version = 1")
+	require.NoError(t, err)
+
+	status, err := c.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.True(t, status[0].Modified)
+
+	_, err = c.MigrateUp(ctx, 0)
+	require.Error(t, err)
+}
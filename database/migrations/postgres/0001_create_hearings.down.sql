@@ -0,0 +1,4 @@
+DROP TABLE hearing_paragraphs;
+DROP TABLE hearing_proposals;
+DROP TABLE hearing_topics;
+DROP TABLE hearings;
@@ -0,0 +1,31 @@
+-- Dates are stored as text in format "2006-01-02 15:04:05" like in SQLite
+CREATE TABLE hearings(
+    id BIGSERIAL PRIMARY KEY,
+    link TEXT DEFAULT '' NOT NULL UNIQUE,
+    place TEXT DEFAULT '',
+    date TEXT DEFAULT '1970-01-01 00:00:00',
+    published BOOLEAN DEFAULT false,
+    created_at TEXT DEFAULT '1970-01-01 00:00:00',
+    status TEXT DEFAULT ''
+);
+
+CREATE TABLE hearing_topics(
+    hearing_id BIGINT NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    topic TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (hearing_id, position)
+);
+
+CREATE TABLE hearing_proposals(
+    hearing_id BIGINT NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    proposal TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (hearing_id, position)
+);
+
+CREATE TABLE hearing_paragraphs(
+    hearing_id BIGINT NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    paragraph TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (hearing_id, position)
+);
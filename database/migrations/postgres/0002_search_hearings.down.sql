@@ -0,0 +1,8 @@
+DROP TRIGGER hearings_search_paragraphs ON hearing_paragraphs;
+DROP TRIGGER hearings_search_proposals ON hearing_proposals;
+DROP TRIGGER hearings_search_topics ON hearing_topics;
+DROP TRIGGER hearings_search_hearing ON hearings;
+DROP FUNCTION hearings_search_child();
+DROP FUNCTION hearings_search_hearing();
+DROP FUNCTION hearings_search_refresh(BIGINT);
+DROP TABLE hearings_search;
@@ -0,0 +1,66 @@
+-- Full-text index of hearings with russian stemming.
+-- Topics have the highest weight, then place, proposals and paragraphs.
+CREATE TABLE hearings_search(
+    hearing_id BIGINT PRIMARY KEY REFERENCES hearings(id) ON DELETE CASCADE,
+    topics TEXT DEFAULT '' NOT NULL,
+    proposals TEXT DEFAULT '' NOT NULL,
+    place TEXT DEFAULT '' NOT NULL,
+    raw TEXT DEFAULT '' NOT NULL,
+    document TSVECTOR NOT NULL
+);
+
+CREATE INDEX hearings_search_document ON hearings_search USING GIN(document);
+
+CREATE FUNCTION hearings_search_refresh(hid BIGINT) RETURNS void AS $$
+BEGIN
+    DELETE FROM hearings_search WHERE hearing_id = hid;
+    INSERT INTO hearings_search(hearing_id, topics, proposals, place, raw, document)
+    SELECT s.id, s.topics, s.proposals, s.place, s.raw,
+        setweight(to_tsvector('russian', s.topics), 'A') ||
+        setweight(to_tsvector('russian', s.place), 'B') ||
+        setweight(to_tsvector('russian', s.proposals), 'C') ||
+        setweight(to_tsvector('russian', s.raw), 'D')
+    FROM (
+        SELECT h.id,
+            coalesce((SELECT string_agg(topic, E'\n' ORDER BY position) FROM hearing_topics WHERE hearing_id = h.id), '') AS topics,
+            coalesce((SELECT string_agg(proposal, E'\n' ORDER BY position) FROM hearing_proposals WHERE hearing_id = h.id), '') AS proposals,
+            coalesce(h.place, '') AS place,
+            coalesce((SELECT string_agg(paragraph, E'\n' ORDER BY position) FROM hearing_paragraphs WHERE hearing_id = h.id), '') AS raw
+        FROM hearings h
+        WHERE h.id = hid
+    ) s;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION hearings_search_hearing() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM hearings_search WHERE hearing_id = OLD.id;
+    ELSE
+        PERFORM hearings_search_refresh(NEW.id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION hearings_search_child() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM hearings_search_refresh(OLD.hearing_id);
+    ELSE
+        PERFORM hearings_search_refresh(NEW.hearing_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER hearings_search_hearing AFTER INSERT OR UPDATE OF place OR DELETE ON hearings
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_hearing();
+CREATE TRIGGER hearings_search_topics AFTER INSERT OR DELETE ON hearing_topics
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_child();
+CREATE TRIGGER hearings_search_proposals AFTER INSERT OR DELETE ON hearing_proposals
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_child();
+CREATE TRIGGER hearings_search_paragraphs AFTER INSERT OR DELETE ON hearing_paragraphs
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_child();
+
+SELECT hearings_search_refresh(id) FROM hearings;
@@ -0,0 +1,5 @@
+DROP INDEX hearings_district;
+DROP INDEX hearings_category;
+DROP INDEX hearings_date;
+ALTER TABLE hearings DROP COLUMN district;
+ALTER TABLE hearings DROP COLUMN category;
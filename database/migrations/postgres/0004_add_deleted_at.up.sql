@@ -0,0 +1,2 @@
+-- Soft deleted hearings have time of deletion
+ALTER TABLE hearings ADD COLUMN deleted_at TEXT;
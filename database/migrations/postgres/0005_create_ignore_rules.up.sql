@@ -0,0 +1,9 @@
+-- Links of the list of hearings which are not hearings
+CREATE TABLE ignore_rules(
+    id BIGSERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    reason TEXT DEFAULT '' NOT NULL,
+    created_at TEXT NOT NULL,
+    UNIQUE (kind, pattern)
+);
@@ -0,0 +1,18 @@
+-- Deliveries of messages about hearings to channels. Message is saved together with
+-- the change of hearing, so it is sent even if service is stopped before sending.
+CREATE TABLE outbox(
+    id BIGSERIAL PRIMARY KEY,
+    hearing_id BIGINT NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    channel TEXT NOT NULL,
+    event TEXT NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT DEFAULT 'pending' NOT NULL,
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    next_attempt_at TEXT NOT NULL,
+    last_error TEXT DEFAULT '' NOT NULL,
+    created_at TEXT NOT NULL,
+    sent_at TEXT,
+    UNIQUE (hearing_id, channel, event)
+);
+
+CREATE INDEX outbox_due ON outbox(status, next_attempt_at);
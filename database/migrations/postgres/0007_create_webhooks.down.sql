@@ -0,0 +1,2 @@
+DROP INDEX outbox_channel;
+DROP TABLE webhooks;
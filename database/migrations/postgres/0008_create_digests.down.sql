@@ -0,0 +1,3 @@
+DROP TABLE digest_unsubscribes;
+DROP INDEX digests_period;
+DROP TABLE digests;
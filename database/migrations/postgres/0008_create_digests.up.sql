@@ -0,0 +1,16 @@
+-- Sent email digests. The next digest lists hearings created after the last hearing of the previous one.
+CREATE TABLE digests(
+    id BIGSERIAL PRIMARY KEY,
+    period TEXT NOT NULL,
+    last_hearing_id BIGINT NOT NULL,
+    recipients INTEGER NOT NULL,
+    sent_at TEXT NOT NULL
+);
+
+CREATE INDEX digests_period ON digests(period, sent_at);
+
+-- Recipients of digests who unsubscribed by link in email
+CREATE TABLE digest_unsubscribes(
+    email TEXT PRIMARY KEY,
+    created_at TEXT NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP INDEX subscriptions_chat;
+DROP TABLE subscriptions;
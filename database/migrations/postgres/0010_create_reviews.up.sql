@@ -0,0 +1,11 @@
+-- Moderation reviews of hearings. Hearing without review is not moderated.
+CREATE TABLE reviews(
+    hearing_id BIGINT PRIMARY KEY REFERENCES hearings(id) ON DELETE CASCADE,
+    state TEXT NOT NULL,
+    moderator TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+CREATE INDEX reviews_state ON reviews(state);
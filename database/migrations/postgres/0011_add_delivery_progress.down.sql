@@ -0,0 +1,2 @@
+ALTER TABLE outbox DROP COLUMN last_message_id;
+ALTER TABLE outbox DROP COLUMN sent_parts;
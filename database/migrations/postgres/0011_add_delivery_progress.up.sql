@@ -0,0 +1,3 @@
+-- Progress of message which is split to parts, so failed delivery is resumed from the failed part
+ALTER TABLE outbox ADD COLUMN sent_parts INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE outbox ADD COLUMN last_message_id BIGINT DEFAULT 0 NOT NULL;
@@ -0,0 +1,32 @@
+CREATE FUNCTION hearings_search_hearing() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM hearings_search WHERE hearing_id = OLD.id;
+    ELSE
+        PERFORM hearings_search_refresh(NEW.id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION hearings_search_child() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM hearings_search_refresh(OLD.hearing_id);
+    ELSE
+        PERFORM hearings_search_refresh(NEW.hearing_id);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER hearings_search_hearing AFTER INSERT OR UPDATE OF place OR DELETE ON hearings
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_hearing();
+CREATE TRIGGER hearings_search_topics AFTER INSERT OR DELETE ON hearing_topics
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_child();
+CREATE TRIGGER hearings_search_proposals AFTER INSERT OR DELETE ON hearing_proposals
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_child();
+CREATE TRIGGER hearings_search_paragraphs AFTER INSERT OR DELETE ON hearing_paragraphs
+    FOR EACH ROW EXECUTE PROCEDURE hearings_search_child();
+
+SELECT hearings_search_refresh(id) FROM hearings;
@@ -0,0 +1,8 @@
+-- Index of hearing is rebuilt once by hearings_search_refresh when hearing is saved, because triggers of child rows
+-- rebuilt it for every saved topic, proposal and paragraph. Deleted hearings are removed by foreign key.
+DROP TRIGGER hearings_search_paragraphs ON hearing_paragraphs;
+DROP TRIGGER hearings_search_proposals ON hearing_proposals;
+DROP TRIGGER hearings_search_topics ON hearing_topics;
+DROP TRIGGER hearings_search_hearing ON hearings;
+DROP FUNCTION hearings_search_child();
+DROP FUNCTION hearings_search_hearing();
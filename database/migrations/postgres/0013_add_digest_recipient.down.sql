@@ -0,0 +1 @@
+ALTER TABLE digests DROP COLUMN recipient;
@@ -0,0 +1,3 @@
+-- Digests are recorded per recipient, so recipient who failed to receive digest gets it on the next attempt.
+-- Digests recorded before have empty recipient and apply to all recipients.
+ALTER TABLE digests ADD COLUMN recipient TEXT DEFAULT '' NOT NULL;
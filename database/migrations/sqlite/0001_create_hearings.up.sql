@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS hearings(
+    id INTEGER PRIMARY KEY,
+    link TEXT DEFAULT '' NOT NULL UNIQUE,
+    topics TEXT DEFAULT '',
+    proposals TEXT DEFAULT '',
+    place TEXT DEFAULT '',
+    date TEXT DEFAULT '1970-01-01 00:00:00',
+    published BOOLEAN DEFAULT false,
+    raw TEXT DEFAULT ''
+);
@@ -0,0 +1 @@
+ALTER TABLE hearings ADD COLUMN created_at TEXT DEFAULT '1970-01-01 00:00:00';
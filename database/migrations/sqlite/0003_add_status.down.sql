@@ -0,0 +1 @@
+ALTER TABLE hearings DROP COLUMN status;
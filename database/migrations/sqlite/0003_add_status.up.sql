@@ -0,0 +1 @@
+ALTER TABLE hearings ADD COLUMN status TEXT DEFAULT '';
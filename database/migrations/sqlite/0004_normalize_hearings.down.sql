@@ -0,0 +1,18 @@
+ALTER TABLE hearings ADD COLUMN topics TEXT DEFAULT '';
+ALTER TABLE hearings ADD COLUMN proposals TEXT DEFAULT '';
+ALTER TABLE hearings ADD COLUMN raw TEXT DEFAULT '';
+
+UPDATE hearings SET
+    topics = coalesce((SELECT group_concat(topic, '||') FROM (
+        SELECT topic FROM hearing_topics WHERE hearing_id = hearings.id ORDER BY position
+    )), ''),
+    proposals = coalesce((SELECT group_concat(proposal, '||') FROM (
+        SELECT proposal FROM hearing_proposals WHERE hearing_id = hearings.id ORDER BY position
+    )), ''),
+    raw = coalesce((SELECT group_concat(paragraph, '||') FROM (
+        SELECT paragraph FROM hearing_paragraphs WHERE hearing_id = hearings.id ORDER BY position
+    )), '');
+
+DROP TABLE hearing_paragraphs;
+DROP TABLE hearing_proposals;
+DROP TABLE hearing_topics;
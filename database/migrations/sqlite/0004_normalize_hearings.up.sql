@@ -0,0 +1,52 @@
+-- Topics, proposals and paragraphs were stored in hearings joined with '||'
+CREATE TABLE hearing_topics(
+    hearing_id INTEGER NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    topic TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (hearing_id, position)
+);
+
+CREATE TABLE hearing_proposals(
+    hearing_id INTEGER NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    proposal TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (hearing_id, position)
+);
+
+CREATE TABLE hearing_paragraphs(
+    hearing_id INTEGER NOT NULL REFERENCES hearings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    paragraph TEXT DEFAULT '' NOT NULL,
+    PRIMARY KEY (hearing_id, position)
+);
+
+INSERT INTO hearing_topics(hearing_id, position, topic)
+WITH RECURSIVE split(hearing_id, position, item, rest) AS (
+    SELECT id, -1, '', topics || '||' FROM hearings WHERE topics != ''
+    UNION ALL
+    SELECT hearing_id, position + 1, substr(rest, 1, instr(rest, '||') - 1), substr(rest, instr(rest, '||') + 2)
+    FROM split WHERE rest != ''
+)
+SELECT hearing_id, position, item FROM split WHERE position >= 0;
+
+INSERT INTO hearing_proposals(hearing_id, position, proposal)
+WITH RECURSIVE split(hearing_id, position, item, rest) AS (
+    SELECT id, -1, '', proposals || '||' FROM hearings WHERE proposals != ''
+    UNION ALL
+    SELECT hearing_id, position + 1, substr(rest, 1, instr(rest, '||') - 1), substr(rest, instr(rest, '||') + 2)
+    FROM split WHERE rest != ''
+)
+SELECT hearing_id, position, item FROM split WHERE position >= 0;
+
+INSERT INTO hearing_paragraphs(hearing_id, position, paragraph)
+WITH RECURSIVE split(hearing_id, position, item, rest) AS (
+    SELECT id, -1, '', raw || '||' FROM hearings WHERE raw != ''
+    UNION ALL
+    SELECT hearing_id, position + 1, substr(rest, 1, instr(rest, '||') - 1), substr(rest, instr(rest, '||') + 2)
+    FROM split WHERE rest != ''
+)
+SELECT hearing_id, position, item FROM split WHERE position >= 0;
+
+ALTER TABLE hearings DROP COLUMN topics;
+ALTER TABLE hearings DROP COLUMN proposals;
+ALTER TABLE hearings DROP COLUMN raw;
@@ -0,0 +1,11 @@
+DROP TRIGGER hearings_search_topics_delete;
+DROP TRIGGER hearings_search_topics_insert;
+DROP TRIGGER hearings_search_proposals_delete;
+DROP TRIGGER hearings_search_proposals_insert;
+DROP TRIGGER hearings_search_paragraphs_delete;
+DROP TRIGGER hearings_search_paragraphs_insert;
+DROP TRIGGER hearings_search_delete;
+DROP TRIGGER hearings_search_update;
+DROP TRIGGER hearings_search_insert;
+DROP VIEW hearings_search_source;
+DROP TABLE hearings_search;
@@ -0,0 +1,77 @@
+-- Full-text index of hearings. Row id of index is id of hearing.
+-- Tokenizer unicode61 folds case of cyrillic letters and removes diacritics (ё is searched as е).
+CREATE VIRTUAL TABLE hearings_search USING fts5(
+    topics,
+    proposals,
+    place,
+    raw,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE VIEW hearings_search_source AS
+SELECT h.id,
+    coalesce((SELECT group_concat(topic, char(10)) FROM (
+        SELECT topic FROM hearing_topics WHERE hearing_id = h.id ORDER BY position
+    )), '') AS topics,
+    coalesce((SELECT group_concat(proposal, char(10)) FROM (
+        SELECT proposal FROM hearing_proposals WHERE hearing_id = h.id ORDER BY position
+    )), '') AS proposals,
+    coalesce(h.place, '') AS place,
+    coalesce((SELECT group_concat(paragraph, char(10)) FROM (
+        SELECT paragraph FROM hearing_paragraphs WHERE hearing_id = h.id ORDER BY position
+    )), '') AS raw
+FROM hearings h;
+
+INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+SELECT id, topics, proposals, place, raw FROM hearings_search_source;
+
+CREATE TRIGGER hearings_search_insert AFTER INSERT ON hearings BEGIN
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.id;
+END;
+
+CREATE TRIGGER hearings_search_update AFTER UPDATE OF place ON hearings BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.id;
+END;
+
+CREATE TRIGGER hearings_search_delete AFTER DELETE ON hearings BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER hearings_search_topics_insert AFTER INSERT ON hearing_topics BEGIN
+    DELETE FROM hearings_search WHERE rowid = new.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_topics_delete AFTER DELETE ON hearing_topics BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = old.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_proposals_insert AFTER INSERT ON hearing_proposals BEGIN
+    DELETE FROM hearings_search WHERE rowid = new.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_proposals_delete AFTER DELETE ON hearing_proposals BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = old.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_paragraphs_insert AFTER INSERT ON hearing_paragraphs BEGIN
+    DELETE FROM hearings_search WHERE rowid = new.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_paragraphs_delete AFTER DELETE ON hearing_paragraphs BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = old.hearing_id;
+END;
@@ -0,0 +1,27 @@
+-- Category and district are detected by domain.Hearing on saving.
+-- Existing hearings are classified by the same keywords.
+ALTER TABLE hearings ADD COLUMN category TEXT DEFAULT '';
+ALTER TABLE hearings ADD COLUMN district TEXT DEFAULT '';
+
+UPDATE hearings SET category = CASE
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id AND t.topic LIKE '%енеральн% план%') THEN 'master_plan'
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id AND t.topic LIKE '%емлепользовани%') THEN 'zoning'
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id AND t.topic LIKE '%словно разреш%нн%') THEN 'permitted_use'
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id AND t.topic LIKE '%тклонени%') THEN 'deviation'
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id AND (t.topic LIKE '%ланировк%' OR t.topic LIKE '%ежевани%')) THEN 'planning'
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id AND t.topic LIKE '%лагоустройств%') THEN 'improvement'
+    WHEN EXISTS(SELECT 1 FROM hearing_topics t WHERE t.hearing_id = hearings.id) THEN 'other'
+    ELSE ''
+END;
+
+UPDATE hearings SET district = CASE
+    WHEN place LIKE '%ежицк% район%' THEN 'bezhitsky'
+    WHEN place LIKE '%олодарск% район%' THEN 'volodarsky'
+    WHEN place LIKE '%оветск% район%' THEN 'sovetsky'
+    WHEN place LIKE '%окинск% район%' THEN 'fokinsky'
+    ELSE ''
+END;
+
+CREATE INDEX hearings_date ON hearings(date);
+CREATE INDEX hearings_category ON hearings(category);
+CREATE INDEX hearings_district ON hearings(district);
@@ -0,0 +1 @@
+DROP TABLE ignore_rules;
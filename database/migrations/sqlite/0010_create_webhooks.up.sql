@@ -0,0 +1,10 @@
+-- Subscriptions of external services to events of hearings
+CREATE TABLE webhooks(
+    id INTEGER PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX outbox_channel ON outbox(channel);
@@ -0,0 +1,10 @@
+-- Subscriptions of Telegram chats to hearings by district, category or keyword
+CREATE TABLE subscriptions(
+    id INTEGER PRIMARY KEY,
+    chat_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    value TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX subscriptions_chat ON subscriptions(chat_id, kind, value);
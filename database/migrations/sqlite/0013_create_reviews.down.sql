@@ -0,0 +1,2 @@
+DROP INDEX reviews_state;
+DROP TABLE reviews;
@@ -0,0 +1,46 @@
+CREATE TRIGGER hearings_search_insert AFTER INSERT ON hearings BEGIN
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.id;
+END;
+
+CREATE TRIGGER hearings_search_update AFTER UPDATE OF place ON hearings BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.id;
+END;
+
+CREATE TRIGGER hearings_search_topics_insert AFTER INSERT ON hearing_topics BEGIN
+    DELETE FROM hearings_search WHERE rowid = new.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_topics_delete AFTER DELETE ON hearing_topics BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = old.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_proposals_insert AFTER INSERT ON hearing_proposals BEGIN
+    DELETE FROM hearings_search WHERE rowid = new.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_proposals_delete AFTER DELETE ON hearing_proposals BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = old.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_paragraphs_insert AFTER INSERT ON hearing_paragraphs BEGIN
+    DELETE FROM hearings_search WHERE rowid = new.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = new.hearing_id;
+END;
+
+CREATE TRIGGER hearings_search_paragraphs_delete AFTER DELETE ON hearing_paragraphs BEGIN
+    DELETE FROM hearings_search WHERE rowid = old.hearing_id;
+    INSERT INTO hearings_search(rowid, topics, proposals, place, raw)
+    SELECT id, topics, proposals, place, raw FROM hearings_search_source WHERE id = old.hearing_id;
+END;
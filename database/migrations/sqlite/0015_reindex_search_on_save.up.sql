@@ -0,0 +1,10 @@
+-- Index of hearing is rebuilt once when hearing is saved, because triggers of child rows
+-- rebuilt it for every saved topic, proposal and paragraph. Deleted hearings are still removed by trigger.
+DROP TRIGGER hearings_search_topics_delete;
+DROP TRIGGER hearings_search_topics_insert;
+DROP TRIGGER hearings_search_proposals_delete;
+DROP TRIGGER hearings_search_proposals_insert;
+DROP TRIGGER hearings_search_paragraphs_delete;
+DROP TRIGGER hearings_search_paragraphs_insert;
+DROP TRIGGER hearings_search_update;
+DROP TRIGGER hearings_search_insert;
@@ -0,0 +1,309 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// DeliveryStatus is state of delivery of message to channel
+type DeliveryStatus string
+
+// Statuses of delivery
+const (
+	// DeliveryPending is waiting for the next attempt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySending is claimed by worker. It is claimed again if worker does not finish it in time.
+	DeliverySending DeliveryStatus = "sending"
+	// DeliverySent is successfully sent
+	DeliverySent DeliveryStatus = "sent"
+	// DeliveryFailed is not sent and will not be retried
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery of message about hearing to channel.
+// Hearing, channel and event identify delivery, so the same message is not enqueued twice.
+type Delivery struct {
+	ID        string `json:"id"`
+	HearingID string `json:"hearing_id"`
+	Channel   string `json:"channel"`
+	// Event is a reason of message like announcement or change of status
+	Event         string         `json:"event"`
+	Message       string         `json:"message"`
+	Status        DeliveryStatus `json:"status"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     string         `json:"last_error,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	// SentAt is zero for not sent delivery
+	SentAt time.Time `json:"sent_at"`
+	// SentParts is number of parts of split message which are already sent.
+	// The next attempt resumes delivery from the first part which is not sent.
+	SentParts int `json:"sent_parts,omitempty"`
+	// LastMessageID is identifier of the last sent part in channel. Continuation parts are replies to it.
+	LastMessageID int `json:"last_message_id,omitempty"`
+}
+
+type delivery struct {
+	ID            int            `db:"id"`
+	HearingID     int            `db:"hearing_id"`
+	Channel       string         `db:"channel"`
+	Event         string         `db:"event"`
+	Message       string         `db:"message"`
+	Status        string         `db:"status"`
+	Attempts      int            `db:"attempts"`
+	NextAttemptAt string         `db:"next_attempt_at"`
+	LastError     string         `db:"last_error"`
+	CreatedAt     string         `db:"created_at"`
+	SentAt        sql.NullString `db:"sent_at"`
+	SentParts     int            `db:"sent_parts"`
+	LastMessageID int            `db:"last_message_id"`
+}
+
+const deliveryColumns = "id, hearing_id, channel, event, message, status, attempts, next_attempt_at, last_error, created_at, sent_at, " +
+	"sent_parts, last_message_id"
+
+func (d delivery) cast() Delivery {
+	res := Delivery{
+		ID:        strconv.Itoa(d.ID),
+		HearingID: strconv.Itoa(d.HearingID),
+		Channel:   d.Channel,
+		Event:     d.Event,
+		Message:   d.Message,
+		Status:    DeliveryStatus(d.Status),
+		Attempts:  d.Attempts,
+		LastError: d.LastError,
+
+		SentParts:     d.SentParts,
+		LastMessageID: d.LastMessageID,
+	}
+	res.NextAttemptAt, _ = time.Parse(timeFormat, d.NextAttemptAt)
+	res.CreatedAt, _ = time.Parse(timeFormat, d.CreatedAt)
+	if d.SentAt.Valid {
+		res.SentAt, _ = time.Parse(timeFormat, d.SentAt.String)
+	}
+	return res
+}
+
+// Enqueue saves pending deliveries and returns number of saved ones.
+// Deliveries which are already saved for the same hearing, channel and event are skipped.
+// If publish is true, hearings of deliveries are marked as published in the same transaction.
+func (c Client) Enqueue(ctx context.Context, deliveries []Delivery, publish bool) (int, error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer c.rollback(tx)
+
+	now := time.Now().UTC()
+	saved := 0
+	for _, d := range deliveries {
+		id, err := strconv.Atoi(d.HearingID)
+		if err != nil {
+			return 0, ErrNotFound
+		}
+		if publish {
+			res, err := tx.ExecContext(ctx, "UPDATE hearings SET published = TRUE WHERE id = $1", id)
+			if err != nil {
+				return 0, err
+			}
+			if n, err := res.RowsAffected(); err != nil || n == 0 {
+				return 0, ErrNotFound
+			}
+		}
+
+		var exists int
+		err = tx.GetContext(ctx, &exists, "SELECT count(*) FROM outbox WHERE hearing_id = $1 AND channel = $2 AND event = $3",
+			id, d.Channel, d.Event)
+		if err != nil {
+			return 0, err
+		}
+		if exists > 0 {
+			continue
+		}
+		next := d.NextAttemptAt
+		if next.IsZero() {
+			next = now
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO outbox(hearing_id, channel, event, message, status, next_attempt_at, created_at) "+
+				"VALUES($1, $2, $3, $4, $5, $6, $7)",
+			id, d.Channel, d.Event, d.Message, string(DeliveryPending), next.UTC().Format(timeFormat), now.Format(timeFormat),
+		)
+		if err != nil {
+			return 0, err
+		}
+		saved++
+	}
+	return saved, tx.Commit()
+}
+
+// ClaimDeliveries returns deliveries due at now and marks them as sending until now+lease.
+// Deliveries of deleted hearings are not claimed. Delivery which is not completed or failed
+// until the end of lease is claimed again, so it is not lost if worker is stopped.
+func (c Client) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	res := make([]Delivery, 0)
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return res, err
+	}
+	defer c.rollback(tx)
+
+	due := now.UTC().Format(timeFormat)
+	rows := make([]delivery, 0)
+	query := "SELECT o.id, o.hearing_id, o.channel, o.event, o.message, o.status, o.attempts, " +
+		"o.next_attempt_at, o.last_error, o.created_at, o.sent_at, o.sent_parts, o.last_message_id " +
+		"FROM outbox o JOIN hearings h ON h.id = o.hearing_id " +
+		"WHERE o.status IN ($1, $2) AND o.next_attempt_at <= $3 AND h.deleted_at IS NULL " +
+		"ORDER BY o.next_attempt_at, o.id LIMIT $4"
+	err = tx.SelectContext(ctx, &rows, query, string(DeliveryPending), string(DeliverySending), due, limit)
+	if err != nil {
+		return res, err
+	}
+
+	until := now.Add(lease).UTC().Format(timeFormat)
+	for _, row := range rows {
+		// Delivery may be claimed by another worker after select
+		r, err := tx.ExecContext(ctx,
+			"UPDATE outbox SET status = $1, attempts = attempts + 1, next_attempt_at = $2 "+
+				"WHERE id = $3 AND status IN ($4, $5) AND next_attempt_at <= $6",
+			string(DeliverySending), until, row.ID, string(DeliveryPending), string(DeliverySending), due,
+		)
+		if err != nil {
+			return make([]Delivery, 0), err
+		}
+		if n, err := r.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+		row.Status = string(DeliverySending)
+		row.Attempts++
+		row.NextAttemptAt = until
+		res = append(res, row.cast())
+	}
+	return res, tx.Commit()
+}
+
+// CompleteDelivery marks delivery claimed for attempt as sent. ErrNotFound is returned if delivery does not exist,
+// ErrNotClaimed is returned if delivery is not sending or is claimed again for another attempt.
+func (c Client) CompleteDelivery(ctx context.Context, id string, attempt int, sentAt time.Time) error {
+	return c.updateDelivery(ctx, id, attempt,
+		"status = $4, last_error = '', sent_at = $5",
+		string(DeliverySent), sentAt.UTC().Format(timeFormat),
+	)
+}
+
+// FailDelivery saves error of attempt of claimed delivery and schedules the next attempt.
+// If next is zero, delivery is failed and will not be retried. Errors are the same as of CompleteDelivery.
+func (c Client) FailDelivery(ctx context.Context, id string, attempt int, lastError string, next time.Time) error {
+	if next.IsZero() {
+		return c.updateDelivery(ctx, id, attempt,
+			"status = $4, last_error = $5",
+			string(DeliveryFailed), lastError,
+		)
+	}
+	return c.updateDelivery(ctx, id, attempt,
+		"status = $4, last_error = $5, next_attempt_at = $6",
+		string(DeliveryPending), lastError, next.UTC().Format(timeFormat),
+	)
+}
+
+// SetDeliveryProgress saves number of sent parts of split message and identifier of the last sent part,
+// so the next attempt is resumed from the failed part. Errors are the same as of CompleteDelivery.
+func (c Client) SetDeliveryProgress(ctx context.Context, id string, attempt, sentParts, lastMessageID int) error {
+	return c.updateDelivery(ctx, id, attempt,
+		"sent_parts = $4, last_message_id = $5",
+		sentParts, lastMessageID,
+	)
+}
+
+// updateDelivery sets columns of delivery claimed for attempt. Placeholders of values in set start from $4.
+func (c Client) updateDelivery(ctx context.Context, id string, attempt int, set string, args ...interface{}) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	query := "UPDATE outbox SET " + set + " WHERE id = $1 AND status = $2 AND attempts = $3"
+	res, err := c.db.ExecContext(ctx, query, append([]interface{}{n, string(DeliverySending), attempt}, args...)...)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+	var exists int
+	if err = c.db.GetContext(ctx, &exists, "SELECT count(*) FROM outbox WHERE id = $1", n); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	return ErrNotClaimed
+}
+
+// Deliveries of hearing in order of creation. All deliveries are returned for empty identifier of hearing.
+func (c Client) Deliveries(ctx context.Context, hearingID string) ([]Delivery, error) {
+	res := make([]Delivery, 0)
+	rows := make([]delivery, 0)
+	var err error
+	if hearingID == "" {
+		err = c.db.SelectContext(ctx, &rows, "SELECT "+deliveryColumns+" FROM outbox ORDER BY id")
+	} else {
+		id, cerr := strconv.Atoi(hearingID)
+		if cerr != nil {
+			return res, nil
+		}
+		err = c.db.SelectContext(ctx, &rows, "SELECT "+deliveryColumns+" FROM outbox WHERE hearing_id = $1 ORDER BY id", id)
+	}
+	if err != nil {
+		return res, err
+	}
+	for _, row := range rows {
+		res = append(res, row.cast())
+	}
+	return res, nil
+}
+
+// ChannelDeliveries returns deliveries to channel in order of creation
+func (c Client) ChannelDeliveries(ctx context.Context, channel string) ([]Delivery, error) {
+	res := make([]Delivery, 0)
+	rows := make([]delivery, 0)
+	err := c.db.SelectContext(ctx, &rows, "SELECT "+deliveryColumns+" FROM outbox WHERE channel = $1 ORDER BY id", channel)
+	if err != nil {
+		return res, err
+	}
+	for _, row := range rows {
+		res = append(res, row.cast())
+	}
+	return res, nil
+}
+
+// LastSlot returns the latest time of sending or planned attempt of deliveries to channel
+func (c Client) LastSlot(ctx context.Context, channel string) (time.Time, error) {
+	var last string
+	err := c.db.GetContext(ctx, &last,
+		"SELECT COALESCE(MAX(COALESCE(sent_at, next_attempt_at)), '') FROM outbox WHERE channel = $1 AND status <> $2",
+		channel, string(DeliveryFailed))
+	if err != nil || last == "" {
+		return time.Time{}, err
+	}
+	return time.Parse(timeFormat, last)
+}
@@ -0,0 +1,273 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brurbanko/mercury/domain"
+)
+
+// Fields of sorting hearings
+const (
+	// SortDate sorts hearings by date of hearing
+	SortDate = "date"
+	// SortCreated sorts hearings by time of creation
+	SortCreated = "created"
+	// SortID sorts hearings by identifier
+	SortID = "id"
+)
+
+// ErrInvalidCursor is returned when cursor of page cannot be decoded or it is for another sorting
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ListOptions filters, sorts and paginates hearings
+type ListOptions struct {
+	// From and To limit time of hearing: From <= time < To. Zero values are not limits.
+	From time.Time
+	To   time.Time
+	// Published filters hearings by published state if it is not nil
+	Published *bool
+	// Statuses filters hearings by current statuses
+	Statuses []domain.Status
+	Category domain.Category
+	District domain.District
+	// Text filters hearings by words like Search
+	Text string
+	// HideUnapproved hides hearings which are pending review or rejected by moderators
+	HideUnapproved bool
+	// Sort is one of SortDate, SortCreated or SortID. Default is SortDate.
+	Sort string
+	Desc bool
+	// Limit of hearings on page. Zero or negative limit returns all hearings.
+	Limit int
+	// Cursor is Page.Next of previous page
+	Cursor string
+	// Now is moment of computing current statuses. Zero is current time.
+	Now time.Time
+}
+
+// Page of hearings
+type Page struct {
+	Hearings []domain.Hearing
+	// Total is number of hearings matched filters on all pages
+	Total int
+	// Next is cursor of the next page. It is empty for the last page.
+	Next string
+}
+
+// Validate options and set default values
+func (o *ListOptions) Validate() error {
+	switch o.Sort {
+	case "":
+		o.Sort = SortDate
+	case SortDate, SortCreated, SortID:
+	default:
+		return fmt.Errorf("unknown sort field: %s", o.Sort)
+	}
+	for _, status := range o.Statuses {
+		if _, _, err := statusCondition(status, time.Time{}); err != nil {
+			return err
+		}
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	return nil
+}
+
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// EncodeCursor returns opaque cursor of page after hearing with sort value and identifier
+func EncodeCursor(sort, value string, id int) string {
+	data, _ := json.Marshal(cursor{Sort: sort, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor returns sort value and identifier of the last hearing of previous page.
+// ErrInvalidCursor is returned if cursor is malformed or it is for another sort field.
+func DecodeCursor(s, sort string) (string, int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return "", 0, ErrInvalidCursor
+	}
+	return c.Value, c.ID, nil
+}
+
+// DayBounds returns beginning of the day of now and of the next day
+func DayBounds(now time.Time) (time.Time, time.Time) {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	return today, today.AddDate(0, 0, 1)
+}
+
+// where is builder of conditions of query with "?" placeholders
+type where struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *where) add(cond string, args ...interface{}) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+func (w *where) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// statusCondition returns condition of hearings which have status at the moment like domain.Hearing.CurrentStatus
+func statusCondition(status domain.Status, now time.Time) (string, []interface{}, error) {
+	today, tomorrow := DayBounds(now)
+	t, n := formatDate(today), formatDate(tomorrow)
+	cancelled, postponed := string(domain.StatusCancelled), string(domain.StatusPostponed)
+	switch status {
+	case domain.StatusCancelled:
+		return "h.status = ?", []interface{}{cancelled}, nil
+	case domain.StatusToday:
+		return "(h.status != ? AND h.date >= ? AND h.date < ?)", []interface{}{cancelled, t, n}, nil
+	case domain.StatusHeld:
+		return "(h.status != ? AND h.date < ?)", []interface{}{cancelled, t}, nil
+	case domain.StatusPostponed:
+		return "(h.status = ? AND h.date >= ?)", []interface{}{postponed, n}, nil
+	case domain.StatusAnnounced:
+		return "(h.status NOT IN (?, ?) AND h.published IS NOT TRUE AND h.date >= ?)",
+			[]interface{}{cancelled, postponed, n}, nil
+	case domain.StatusUpcoming:
+		return "(h.status NOT IN (?, ?) AND h.published IS TRUE AND h.date >= ?)",
+			[]interface{}{cancelled, postponed, n}, nil
+	}
+	return "", nil, fmt.Errorf("unknown status: %s", status)
+}
+
+// filter returns conditions of options except cursor
+func (c Client) filter(opts ListOptions) (*where, error) {
+	w := &where{}
+	w.add("h.deleted_at IS NULL")
+	if !opts.From.IsZero() {
+		w.add("h.date >= ?", formatDate(opts.From))
+	}
+	if !opts.To.IsZero() {
+		w.add("h.date < ?", formatDate(opts.To))
+	}
+	if opts.Published != nil {
+		if *opts.Published {
+			w.add("h.published IS TRUE")
+		} else {
+			w.add("h.published IS NOT TRUE")
+		}
+	}
+	if len(opts.Statuses) > 0 {
+		conds := make([]string, 0, len(opts.Statuses))
+		args := make([]interface{}, 0)
+		for _, status := range opts.Statuses {
+			cond, a, err := statusCondition(status, opts.Now)
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+			args = append(args, a...)
+		}
+		w.add("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	if opts.Category != "" {
+		w.add("h.category = ?", string(opts.Category))
+	}
+	if opts.District != "" {
+		w.add("h.district = ?", string(opts.District))
+	}
+	if opts.HideUnapproved {
+		w.add("h." + approvedOrUnmoderated)
+	}
+	if match := c.dialect.searchMatch(opts.Text); match != "" {
+		w.add(c.dialect.textFilter, match)
+	}
+	return w, nil
+}
+
+// Query hearings filtered, sorted and paginated by options
+func (c Client) Query(ctx context.Context, opts ListOptions) (Page, error) {
+	page := Page{Hearings: make([]domain.Hearing, 0)}
+	if err := opts.Validate(); err != nil {
+		return page, err
+	}
+	w, err := c.filter(opts)
+	if err != nil {
+		return page, err
+	}
+
+	err = c.db.GetContext(ctx, &page.Total, c.db.Rebind("SELECT count(*) FROM hearings h"+w.String()), w.args...)
+	if err != nil {
+		return page, err
+	}
+
+	column := map[string]string{SortDate: "h.date", SortCreated: "h.created_at", SortID: "h.id"}[opts.Sort]
+	op, dir := ">", "ASC"
+	if opts.Desc {
+		op, dir = "<", "DESC"
+	}
+	if opts.Cursor != "" {
+		value, id, err := DecodeCursor(opts.Cursor, opts.Sort)
+		if err != nil {
+			return page, err
+		}
+		if opts.Sort == SortID {
+			w.add("h.id "+op+" ?", id)
+		} else {
+			w.add(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND h.id %[2]s ?))", column, op), value, value, id)
+		}
+	}
+
+	query := "SELECT h.id, h.link, h.place, h.date, h.published, h.status, h.category, h.district, h.created_at, h.deleted_at " +
+		"FROM hearings h" + w.String() + fmt.Sprintf(" ORDER BY %s %s, h.id %s", column, dir, dir)
+	args := w.args
+	if opts.Limit > 0 {
+		// One more hearing shows that there is the next page
+		query += " LIMIT ?"
+		args = append(args, opts.Limit+1)
+	}
+
+	tempHearings := make([]hearing, 0)
+	if err = c.db.SelectContext(ctx, &tempHearings, c.db.Rebind(query), args...); err != nil {
+		return page, err
+	}
+	if opts.Limit > 0 && len(tempHearings) > opts.Limit {
+		tempHearings = tempHearings[:opts.Limit]
+		last := tempHearings[opts.Limit-1]
+		value := map[string]string{SortDate: last.Date, SortCreated: last.CreatedAt, SortID: strconv.Itoa(last.ID)}[opts.Sort]
+		page.Next = EncodeCursor(opts.Sort, value, last.ID)
+	}
+
+	page.Hearings, err = c.castToHearing(ctx, c.db, tempHearings)
+	return page, err
+}
@@ -0,0 +1,170 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/brurbanko/mercury/domain"
+)
+
+// Errors of repository
+var (
+	// ErrNotFound is returned when hearing or other record is not found in repository
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is returned when the same record exists in repository
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrNotClaimed is returned when result of delivery is saved by worker which does not hold its claim anymore
+	ErrNotClaimed = errors.New("delivery is not claimed")
+	// ErrReviewDecided is returned when review of hearing is changed after it is approved or rejected
+	ErrReviewDecided = errors.New("review is already decided")
+)
+
+// HearingRepository is storage of public hearings
+type HearingRepository interface {
+	// Create new hearing. URL of hearing must be unique.
+	Create(ctx context.Context, publicHearing domain.Hearing) error
+	// Update hearing found by URL. Empty fields of passed hearing are not changed.
+	// ErrNotFound is returned if hearing does not exist.
+	Update(ctx context.Context, publicHearing domain.Hearing) error
+	// Find one hearing by URL. ErrNotFound is returned if hearing does not exist.
+	// Deleted hearing is returned with Deleted flag.
+	Find(ctx context.Context, link string) (domain.Hearing, error)
+	// Get one hearing by identifier. ErrNotFound is returned if hearing does not exist.
+	// Deleted hearing is returned with Deleted flag.
+	Get(ctx context.Context, id string) (domain.Hearing, error)
+	// List all hearings ordered by date including deleted ones
+	List(ctx context.Context) ([]domain.Hearing, error)
+	// Query hearings filtered, sorted and paginated by options
+	Query(ctx context.Context, opts ListOptions) (Page, error)
+	// Unpublished hearings ordered by date except deleted ones and ones which are pending review or rejected.
+	// If mark is true, hearings are atomically marked as published.
+	Unpublished(ctx context.Context, mark bool) ([]domain.Hearing, error)
+	// MarkPublished marks hearing found by URL as published
+	MarkPublished(ctx context.Context, link string) error
+	// Delete hearing by identifier softly. It is hidden from Query, Unpublished and Search.
+	// ErrNotFound is returned if hearing does not exist.
+	Delete(ctx context.Context, id string) error
+	// Search hearings by words in topics, proposals, place and paragraphs except ones which are pending review or rejected.
+	// Results are ordered by relevance and limited by limit.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// IgnoreRuleRepository is storage of rules of ignored links
+type IgnoreRuleRepository interface {
+	// AddIgnoreRule saves rule and returns it with identifier.
+	// ErrAlreadyExists is returned if rule with the same kind and pattern exists.
+	AddIgnoreRule(ctx context.Context, rule IgnoreRule) (IgnoreRule, error)
+	// IgnoreRules returns all rules in order of creation
+	IgnoreRules(ctx context.Context) ([]IgnoreRule, error)
+	// DeleteIgnoreRule deletes rule by identifier. ErrNotFound is returned if rule does not exist.
+	DeleteIgnoreRule(ctx context.Context, id string) error
+}
+
+// OutboxRepository is storage of deliveries of messages to channels
+type OutboxRepository interface {
+	// Enqueue saves pending deliveries and returns number of saved ones.
+	// Deliveries which are already saved for the same hearing, channel and event are skipped.
+	// If publish is true, hearings of deliveries are marked as published in the same transaction.
+	Enqueue(ctx context.Context, deliveries []Delivery, publish bool) (int, error)
+	// ClaimDeliveries returns deliveries due at now and marks them as sending until now+lease.
+	// Deliveries of deleted hearings are not claimed.
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// CompleteDelivery marks delivery claimed for attempt as sent. ErrNotFound is returned if delivery does not exist,
+	// ErrNotClaimed is returned if delivery is not sending or is claimed again for another attempt.
+	CompleteDelivery(ctx context.Context, id string, attempt int, sentAt time.Time) error
+	// FailDelivery saves error of attempt of claimed delivery and schedules the next attempt.
+	// If next is zero, delivery is failed and will not be retried. Errors are the same as of CompleteDelivery.
+	FailDelivery(ctx context.Context, id string, attempt int, lastError string, next time.Time) error
+	// SetDeliveryProgress saves number of sent parts of split message and identifier of the last sent part
+	// of delivery claimed for attempt. Errors are the same as of CompleteDelivery.
+	SetDeliveryProgress(ctx context.Context, id string, attempt, sentParts, lastMessageID int) error
+	// Deliveries of hearing in order of creation. All deliveries are returned for empty identifier of hearing.
+	Deliveries(ctx context.Context, hearingID string) ([]Delivery, error)
+	// ChannelDeliveries returns deliveries to channel in order of creation
+	ChannelDeliveries(ctx context.Context, channel string) ([]Delivery, error)
+	// LastSlot returns the latest time of sending or planned attempt of deliveries to channel.
+	// Failed deliveries are skipped. Zero time is returned if there are no deliveries.
+	LastSlot(ctx context.Context, channel string) (time.Time, error)
+}
+
+// WebhookRepository is storage of subscriptions to events of hearings
+type WebhookRepository interface {
+	// AddWebhook saves webhook and returns it with identifier
+	AddWebhook(ctx context.Context, hook Webhook) (Webhook, error)
+	// Webhooks returns all webhooks in order of creation
+	Webhooks(ctx context.Context) ([]Webhook, error)
+	// Webhook returns webhook by identifier. ErrNotFound is returned if webhook does not exist.
+	Webhook(ctx context.Context, id string) (Webhook, error)
+	// DeleteWebhook deletes webhook by identifier. ErrNotFound is returned if webhook does not exist.
+	// Deliveries to webhook are kept as a log.
+	DeleteWebhook(ctx context.Context, id string) error
+}
+
+// DigestRepository is storage of sent email digests and unsubscribed recipients
+type DigestRepository interface {
+	// AddDigest saves sent digest and returns it with identifier
+	AddDigest(ctx context.Context, d Digest) (Digest, error)
+	// LastDigest returns the last digest of period sent to recipient.
+	// Digests with empty recipient apply to all recipients. ErrNotFound is returned if digest was never sent.
+	LastDigest(ctx context.Context, period, recipient string) (Digest, error)
+	// Unsubscribe excludes email from recipients of digests. Repeated unsubscribing is not an error.
+	Unsubscribe(ctx context.Context, email string) error
+	// Unsubscribed returns emails excluded from recipients of digests in lower case
+	Unsubscribed(ctx context.Context) ([]string, error)
+}
+
+// SubscriptionRepository is storage of subscriptions of Telegram chats
+type SubscriptionRepository interface {
+	// AddSubscription saves subscription and returns it with identifier.
+	// ErrAlreadyExists is returned if chat has subscription with the same kind and value.
+	AddSubscription(ctx context.Context, sub Subscription) (Subscription, error)
+	// Subscriptions of chat in order of creation. Subscriptions of all chats are returned for empty chat.
+	Subscriptions(ctx context.Context, chatID string) ([]Subscription, error)
+	// DeleteSubscriptions of chat with kind and value and returns number of deleted ones.
+	// All subscriptions of chat are deleted if kind is empty.
+	DeleteSubscriptions(ctx context.Context, chatID, kind, value string) (int, error)
+}
+
+// ReviewRepository is storage of moderation reviews of hearings
+type ReviewRepository interface {
+	// SetReview saves review of hearing replacing the previous one. Time of creation of the first review is kept.
+	// ErrNotFound is returned if hearing does not exist.
+	SetReview(ctx context.Context, r Review) error
+	// UpdatePendingReview replaces review of hearing only while it is pending, so concurrent decisions do not overwrite
+	// each other. ErrNotFound is returned if hearing is not moderated, ErrReviewDecided if review is not pending.
+	UpdatePendingReview(ctx context.Context, r Review) error
+	// CreateWithReview creates new hearing together with its review atomically. Identifier of hearing in review is ignored.
+	CreateWithReview(ctx context.Context, h domain.Hearing, r Review) error
+	// Review of hearing. ErrNotFound is returned if hearing is not moderated.
+	Review(ctx context.Context, hearingID string) (Review, error)
+	// Reviews in state in order of creation. Reviews in all states are returned for empty state.
+	Reviews(ctx context.Context, state ReviewState) ([]Review, error)
+}
+
+// Repository is storage of all data of service
+type Repository interface {
+	HearingRepository
+	IgnoreRuleRepository
+	OutboxRepository
+	WebhookRepository
+	DigestRepository
+	SubscriptionRepository
+	ReviewRepository
+}
+
+var _ Repository = (*Client)(nil)
@@ -0,0 +1,196 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/brurbanko/mercury/domain"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReviewState is state of hearing in moderation queue
+type ReviewState string
+
+// States of review
+const (
+	// ReviewPending is waiting for decision of moderator. Hearing is not published.
+	ReviewPending ReviewState = "pending"
+	// ReviewApproved is allowed to be published
+	ReviewApproved ReviewState = "approved"
+	// ReviewRejected is never published
+	ReviewRejected ReviewState = "rejected"
+)
+
+// Review of hearing in moderation queue.
+// Hearing without review is not moderated and is published as usual.
+type Review struct {
+	HearingID string      `json:"hearing_id"`
+	State     ReviewState `json:"state"`
+	// Moderator who made decision
+	Moderator string `json:"moderator,omitempty"`
+	// Comment of decision, for example reason of rejection
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type review struct {
+	HearingID int    `db:"hearing_id"`
+	State     string `db:"state"`
+	Moderator string `db:"moderator"`
+	Comment   string `db:"comment"`
+	CreatedAt string `db:"created_at"`
+	UpdatedAt string `db:"updated_at"`
+}
+
+func (r review) cast() Review {
+	res := Review{
+		HearingID: strconv.Itoa(r.HearingID),
+		State:     ReviewState(r.State),
+		Moderator: r.Moderator,
+		Comment:   r.Comment,
+	}
+	res.CreatedAt, _ = time.Parse(timeFormat, r.CreatedAt)
+	res.UpdatedAt, _ = time.Parse(timeFormat, r.UpdatedAt)
+	return res
+}
+
+// SetReview saves review of hearing replacing the previous one. Time of creation of the first review is kept.
+// ErrNotFound is returned if hearing does not exist.
+func (c Client) SetReview(ctx context.Context, r Review) error {
+	id, err := strconv.Atoi(r.HearingID)
+	if err != nil {
+		return ErrNotFound
+	}
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	var exists int
+	if err = tx.GetContext(ctx, &exists, "SELECT count(*) FROM hearings WHERE id = $1", id); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrNotFound
+	}
+	if err = c.saveReview(ctx, tx, id, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePendingReview replaces review of hearing only while it is pending.
+// ErrNotFound is returned if hearing is not moderated, ErrReviewDecided is returned if review is not pending.
+func (c Client) UpdatePendingReview(ctx context.Context, r Review) error {
+	id, err := strconv.Atoi(r.HearingID)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := c.db.ExecContext(ctx,
+		"UPDATE reviews SET state = $2, moderator = $3, comment = $4, updated_at = $5 WHERE hearing_id = $1 AND state = $6",
+		id, string(r.State), r.Moderator, r.Comment, time.Now().UTC().Format(timeFormat), string(ReviewPending),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err = c.Review(ctx, r.HearingID); err != nil {
+		return err
+	}
+	return ErrReviewDecided
+}
+
+// CreateWithReview creates new hearing together with its review in one transaction,
+// so the hearing is never seen without review. Identifier of hearing in review is ignored.
+func (c Client) CreateWithReview(ctx context.Context, h domain.Hearing, r Review) error {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer c.rollback(tx)
+
+	id, err := c.create(ctx, tx, h)
+	if err != nil {
+		return err
+	}
+	if err = c.saveReview(ctx, tx, id, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// saveReview inserts or replaces review of hearing in transaction
+func (c Client) saveReview(ctx context.Context, tx *sqlx.Tx, id int, r Review) error {
+	now := time.Now().UTC().Format(timeFormat)
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO reviews(hearing_id, state, moderator, comment, created_at, updated_at) VALUES($1, $2, $3, $4, $5, $5) "+
+			"ON CONFLICT(hearing_id) DO UPDATE SET state = excluded.state, moderator = excluded.moderator, "+
+			"comment = excluded.comment, updated_at = excluded.updated_at",
+		id, string(r.State), r.Moderator, r.Comment, now,
+	)
+	return err
+}
+
+// Review of hearing. ErrNotFound is returned if hearing is not moderated.
+func (c Client) Review(ctx context.Context, hearingID string) (Review, error) {
+	id, err := strconv.Atoi(hearingID)
+	if err != nil {
+		return Review{}, ErrNotFound
+	}
+	var row review
+	err = c.db.GetContext(ctx, &row,
+		"SELECT hearing_id, state, moderator, comment, created_at, updated_at FROM reviews WHERE hearing_id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Review{}, ErrNotFound
+	}
+	if err != nil {
+		return Review{}, err
+	}
+	return row.cast(), nil
+}
+
+// Reviews in state in order of creation. Reviews in all states are returned for empty state.
+func (c Client) Reviews(ctx context.Context, state ReviewState) ([]Review, error) {
+	query := "SELECT hearing_id, state, moderator, comment, created_at, updated_at FROM reviews ORDER BY created_at, hearing_id"
+	args := make([]interface{}, 0, 1)
+	if state != "" {
+		query = "SELECT hearing_id, state, moderator, comment, created_at, updated_at FROM reviews " +
+			"WHERE state = $1 ORDER BY created_at, hearing_id"
+		args = append(args, string(state))
+	}
+	rows := make([]review, 0)
+	if err := c.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return make([]Review, 0), err
+	}
+	res := make([]Review, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.cast())
+	}
+	return res, nil
+}
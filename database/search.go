@@ -0,0 +1,137 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/brurbanko/mercury/domain"
+)
+
+// Markers of matched words in snippets of search results
+const (
+	SnippetStart = "<b>"
+	SnippetEnd   = "</b>"
+)
+
+// Markers of matched words in snippets made by database. Characters of private use area do not occur in texts,
+// so markers are replaced by SnippetStart and SnippetEnd after text of snippet is escaped.
+const (
+	snippetStart = "\ue000"
+	snippetEnd   = "\ue001"
+)
+
+// snippetMarkers replaces markers of database by markers of search results
+var snippetMarkers = strings.NewReplacer(snippetStart, SnippetStart, snippetEnd, SnippetEnd)
+
+// escapeSnippet returns snippet of database escaped as HTML with matched words wrapped in SnippetStart and SnippetEnd
+func escapeSnippet(snippet string) string {
+	return snippetMarkers.Replace(html.EscapeString(snippet))
+}
+
+// SearchResult is hearing found by full-text search
+type SearchResult struct {
+	Hearing domain.Hearing `json:"hearing"`
+	// Snippet is fragment of hearing text escaped as HTML with matched words wrapped in SnippetStart and SnippetEnd
+	Snippet string `json:"snippet"`
+	// Rank is relevance of result. Higher is better.
+	Rank float64 `json:"rank"`
+}
+
+type searchRow struct {
+	hearing
+	Snippet string  `db:"snippet"`
+	Rank    float64 `db:"rank"`
+}
+
+// endings of russian words removed by SearchTerms. Longer endings are checked first.
+var endings = []string{
+	"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ией", "иях", "ах", "ях",
+	"ий", "ый", "ой", "ая", "яя", "ое", "ее", "ые", "ие", "ей", "ом", "ем", "ам", "ям",
+	"ую", "юю", "ов", "ев", "ью", "ия", "ья",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// minStemLength is the shortest stem left after removing ending of word
+const minStemLength = 3
+
+// SearchTerms splits query into lower case words and strips common russian endings from them,
+// so words are searched by prefix regardless of their case form:
+// "Фосфоритной улице" is searched as "фосфоритн" and "улиц".
+func SearchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.ReplaceAll(word, "ё", "е")
+		for _, ending := range endings {
+			stem := strings.TrimSuffix(word, ending)
+			if stem != word && utf8.RuneCountInString(stem) >= minStemLength {
+				word = stem
+				break
+			}
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// sqliteMatch returns FTS5 query where all terms are required and searched by prefix
+func sqliteMatch(query string) string {
+	terms := SearchTerms(query)
+	for i, term := range terms {
+		terms[i] = `"` + term + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// postgresMatch returns query for plainto_tsquery which does stemming itself
+func postgresMatch(query string) string {
+	return strings.TrimSpace(query)
+}
+
+// Search hearings by words of query in topics, proposals, place and paragraphs.
+// Hearings pending review or rejected are not found. Results are ordered by relevance. Empty query returns no results.
+func (c Client) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	res := make([]SearchResult, 0)
+	match := c.dialect.searchMatch(query)
+	if match == "" {
+		return res, nil
+	}
+
+	rows := make([]searchRow, 0)
+	err := c.db.SelectContext(ctx, &rows, c.dialect.search, match, limit)
+	if err != nil {
+		return res, err
+	}
+
+	tempHearings := make([]hearing, 0, len(rows))
+	for _, row := range rows {
+		tempHearings = append(tempHearings, row.hearing)
+	}
+	hearings, err := c.castToHearing(ctx, c.db, tempHearings)
+	if err != nil {
+		return res, err
+	}
+	for i, h := range hearings {
+		res = append(res, SearchResult{Hearing: h, Snippet: escapeSnippet(rows[i].Snippet), Rank: rows[i].Rank})
+	}
+	return res, nil
+}
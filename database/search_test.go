@@ -0,0 +1,44 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{}},
+		{query: "  ...  ", want: []string{}},
+		{query: "Фосфоритной улице", want: []string{"фосфоритн", "улиц"}},
+		{query: "ул. Калинина, д. 66", want: []string{"ул", "калинин", "д", "66"}},
+		{query: "Партизан", want: []string{"партизан"}},
+		{query: "объёмами", want: []string{"объем"}},
+		{query: `"кавычки" OR NEAR(*)`, want: []string{"кавычк", "or", "near"}},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, SearchTerms(tt.query), tt.query)
+	}
+}
+
+func TestSqliteMatch(t *testing.T) {
+	require.Equal(t, `"фосфоритн"* "улиц"*`, sqliteMatch("Фосфоритной улице"))
+	require.Equal(t, "", sqliteMatch("***"))
+}
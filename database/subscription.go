@@ -0,0 +1,125 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Kinds of subscriptions
+const (
+	// SubscribeDistrict matches hearings of district
+	SubscribeDistrict = "district"
+	// SubscribeCategory matches hearings of category
+	SubscribeCategory = "category"
+	// SubscribeKeyword matches hearings with word in topics, proposals or place
+	SubscribeKeyword = "keyword"
+)
+
+// Subscription of Telegram chat to hearings
+type Subscription struct {
+	ID     string `json:"id"`
+	ChatID string `json:"chat_id"`
+	// Kind is SubscribeDistrict, SubscribeCategory or SubscribeKeyword
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type subscription struct {
+	ID        int    `db:"id"`
+	ChatID    string `db:"chat_id"`
+	Kind      string `db:"kind"`
+	Value     string `db:"value"`
+	CreatedAt string `db:"created_at"`
+}
+
+// AddSubscription saves subscription and returns it with identifier.
+// ErrAlreadyExists is returned if chat has subscription with the same kind and value.
+func (c Client) AddSubscription(ctx context.Context, sub Subscription) (Subscription, error) {
+	tx, err := c.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return sub, err
+	}
+	defer c.rollback(tx)
+
+	var exists int
+	err = tx.GetContext(ctx, &exists, "SELECT count(*) FROM subscriptions WHERE chat_id = $1 AND kind = $2 AND value = $3",
+		sub.ChatID, sub.Kind, sub.Value)
+	if err != nil {
+		return sub, err
+	}
+	if exists > 0 {
+		return sub, ErrAlreadyExists
+	}
+
+	sub.CreatedAt = time.Now().Truncate(time.Second)
+	var id int
+	err = tx.QueryRowxContext(ctx,
+		"INSERT INTO subscriptions(chat_id, kind, value, created_at) VALUES($1, $2, $3, $4) RETURNING id",
+		sub.ChatID, sub.Kind, sub.Value, sub.CreatedAt.UTC().Format(timeFormat),
+	).Scan(&id)
+	if err != nil {
+		return sub, err
+	}
+	sub.ID = strconv.Itoa(id)
+	return sub, tx.Commit()
+}
+
+// Subscriptions of chat in order of creation. Subscriptions of all chats are returned for empty chat.
+func (c Client) Subscriptions(ctx context.Context, chatID string) ([]Subscription, error) {
+	query := "SELECT id, chat_id, kind, value, created_at FROM subscriptions ORDER BY id"
+	args := make([]interface{}, 0, 1)
+	if chatID != "" {
+		query = "SELECT id, chat_id, kind, value, created_at FROM subscriptions WHERE chat_id = $1 ORDER BY id"
+		args = append(args, chatID)
+	}
+	rows := make([]subscription, 0)
+	err := c.db.SelectContext(ctx, &rows, query, args...)
+	if err != nil {
+		return make([]Subscription, 0), err
+	}
+	res := make([]Subscription, 0, len(rows))
+	for _, row := range rows {
+		createdAt, _ := time.Parse(timeFormat, row.CreatedAt)
+		res = append(res, Subscription{
+			ID:        strconv.Itoa(row.ID),
+			ChatID:    row.ChatID,
+			Kind:      row.Kind,
+			Value:     row.Value,
+			CreatedAt: createdAt,
+		})
+	}
+	return res, nil
+}
+
+// DeleteSubscriptions of chat with kind and value and returns number of deleted ones.
+// All subscriptions of chat are deleted if kind is empty.
+func (c Client) DeleteSubscriptions(ctx context.Context, chatID, kind, value string) (int, error) {
+	query := "DELETE FROM subscriptions WHERE chat_id = $1 AND kind = $2 AND value = $3"
+	args := []interface{}{chatID, kind, value}
+	if kind == "" {
+		query = "DELETE FROM subscriptions WHERE chat_id = $1"
+		args = args[:1]
+	}
+	res, err := c.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	return int(affected), err
+}
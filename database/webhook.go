@@ -0,0 +1,119 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webhook is subscription of external service to events of hearings
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret is key of HMAC signature of requests
+	Secret string `json:"secret,omitempty"`
+	// Events which are sent to webhook
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type webhook struct {
+	ID        int    `db:"id"`
+	URL       string `db:"url"`
+	Secret    string `db:"secret"`
+	Events    string `db:"events"`
+	CreatedAt string `db:"created_at"`
+}
+
+func (w webhook) cast() Webhook {
+	createdAt, _ := time.Parse(timeFormat, w.CreatedAt)
+	return Webhook{
+		ID:        strconv.Itoa(w.ID),
+		URL:       w.URL,
+		Secret:    w.Secret,
+		Events:    strings.Split(w.Events, ","),
+		CreatedAt: createdAt,
+	}
+}
+
+// AddWebhook saves webhook and returns it with identifier
+func (c Client) AddWebhook(ctx context.Context, hook Webhook) (Webhook, error) {
+	hook.CreatedAt = time.Now().Truncate(time.Second)
+	var id int
+	err := c.db.QueryRowxContext(ctx,
+		"INSERT INTO webhooks(url, secret, events, created_at) VALUES($1, $2, $3, $4) RETURNING id",
+		hook.URL, hook.Secret, strings.Join(hook.Events, ","), hook.CreatedAt.UTC().Format(timeFormat),
+	).Scan(&id)
+	if err != nil {
+		return hook, err
+	}
+	hook.ID = strconv.Itoa(id)
+	return hook, nil
+}
+
+// Webhooks returns all webhooks in order of creation
+func (c Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	rows := make([]webhook, 0)
+	err := c.db.SelectContext(ctx, &rows, "SELECT id, url, secret, events, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return make([]Webhook, 0), err
+	}
+	res := make([]Webhook, 0, len(rows))
+	for _, row := range rows {
+		res = append(res, row.cast())
+	}
+	return res, nil
+}
+
+// Webhook returns webhook by identifier. ErrNotFound is returned if webhook does not exist.
+func (c Client) Webhook(ctx context.Context, id string) (Webhook, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return Webhook{}, ErrNotFound
+	}
+	rows := make([]webhook, 0, 1)
+	err = c.db.SelectContext(ctx, &rows, "SELECT id, url, secret, events, created_at FROM webhooks WHERE id = $1", n)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if len(rows) == 0 {
+		return Webhook{}, ErrNotFound
+	}
+	return rows[0].cast(), nil
+}
+
+// DeleteWebhook deletes webhook by identifier. ErrNotFound is returned if webhook does not exist.
+// Deliveries to webhook are kept as a log.
+func (c Client) DeleteWebhook(ctx context.Context, id string) error {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	res, err := c.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", n)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
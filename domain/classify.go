@@ -0,0 +1,117 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Category of hearing by subject of its topics
+type Category string
+
+// Categories of hearing
+const (
+	// CategoryMasterPlan is changing of the master plan of city
+	CategoryMasterPlan Category = "master_plan"
+	// CategoryZoning is changing of rules of land use and development
+	CategoryZoning Category = "zoning"
+	// CategoryPermittedUse is permission for conditionally permitted use of land plot
+	CategoryPermittedUse Category = "permitted_use"
+	// CategoryDeviation is permission for deviation from limit parameters of construction
+	CategoryDeviation Category = "deviation"
+	// CategoryPlanning is project of planning or land surveying of territory
+	CategoryPlanning Category = "planning"
+	// CategoryImprovement is rules of improvement of territory
+	CategoryImprovement Category = "improvement"
+	// CategoryOther is category of topics which are not recognized
+	CategoryOther Category = "other"
+)
+
+// District of Bryansk
+type District string
+
+// Districts of Bryansk
+const (
+	DistrictBezhitsky  District = "bezhitsky"
+	DistrictVolodarsky District = "volodarsky"
+	DistrictSovetsky   District = "sovetsky"
+	DistrictFokinsky   District = "fokinsky"
+)
+
+// categories in order of priority. Hearing with several topics has category with the highest priority.
+var categories = []struct {
+	category Category
+	re       *regexp.Regexp
+}{
+	{category: CategoryMasterPlan, re: regexp.MustCompile(`(?i)генеральн\S*\s+план`)},
+	{category: CategoryZoning, re: regexp.MustCompile(`(?i)землепользовани`)},
+	{category: CategoryPermittedUse, re: regexp.MustCompile(`(?i)условно\s+разреш[её]нн`)},
+	{category: CategoryDeviation, re: regexp.MustCompile(`(?i)отклонени`)},
+	{category: CategoryPlanning, re: regexp.MustCompile(`(?i)планировк|межевани`)},
+	{category: CategoryImprovement, re: regexp.MustCompile(`(?i)благоустройств`)},
+}
+
+var (
+	reDistrict = regexp.MustCompile(`(?i)(бежицк|володарск|советск|фокинск)\S*\s+район`)
+	districts  = map[string]District{
+		"бежицк":    DistrictBezhitsky,
+		"володарск": DistrictVolodarsky,
+		"советск":   DistrictSovetsky,
+		"фокинск":   DistrictFokinsky,
+	}
+)
+
+// Name returns russian name of district
+func (d District) Name() string {
+	switch d {
+	case DistrictBezhitsky:
+		return "Бежицкий район"
+	case DistrictVolodarsky:
+		return "Володарский район"
+	case DistrictSovetsky:
+		return "Советский район"
+	case DistrictFokinsky:
+		return "Фокинский район"
+	}
+	return ""
+}
+
+// DetectCategory returns category of hearing by its topics.
+// Empty category is returned for hearing without topics.
+func (h Hearing) DetectCategory() Category {
+	for _, c := range categories {
+		for _, topic := range h.Topic {
+			if c.re.MatchString(topic) {
+				return c.category
+			}
+		}
+	}
+	if len(h.Topic) > 0 {
+		return CategoryOther
+	}
+	return ""
+}
+
+// DetectDistrict returns district mentioned in topics of hearing.
+// If topics do not mention any district, district of the place of hearing is returned.
+func (h Hearing) DetectDistrict() District {
+	for _, text := range append(append([]string(nil), h.Topic...), h.Place) {
+		if match := reDistrict.FindStringSubmatch(text); match != nil {
+			return districts[strings.ToLower(match[1])]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHearing_DetectCategory(t *testing.T) {
+	tests := []struct {
+		name  string
+		topic []string
+		want  Category
+	}{
+		{name: "no topics", want: ""},
+		{name: "unknown", topic: []string{"по вопросу о переименовании улицы"}, want: CategoryOther},
+		{name: "planning", topic: []string{"по проекту планировки территории по ул. Фосфоритной"}, want: CategoryPlanning},
+		{name: "surveying", topic: []string{"по проекту межевания территории"}, want: CategoryPlanning},
+		{
+			name:  "zoning",
+			topic: []string{"по проекту Решения «О внесении изменений в Правила землепользования и застройки города Брянска»"},
+			want:  CategoryZoning,
+		},
+		{
+			name:  "permitted use",
+			topic: []string{"о предоставлении разрешения на условно разрешённый вид использования земельного участка"},
+			want:  CategoryPermittedUse,
+		},
+		{
+			name: "priority of several topics",
+			topic: []string{
+				"по проекту планировки территории",
+				"о внесении изменений в Генеральный план города Брянска",
+			},
+			want: CategoryMasterPlan,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Hearing{Topic: tt.topic}.DetectCategory(), tt.name)
+		})
+	}
+}
+
+func TestHearing_DetectDistrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		hearing Hearing
+		want    District
+	}{
+		{name: "empty", want: ""},
+		{name: "place", hearing: Hearing{Place: "ГДК Советского района (ул. Калинина, д. 66)"}, want: DistrictSovetsky},
+		{
+			name: "topic is preferred",
+			hearing: Hearing{
+				Topic: []string{"по проекту планировки территории в Фокинском районе"},
+				Place: "ГДК Советского района",
+			},
+			want: DistrictFokinsky,
+		},
+		{name: "upper case", hearing: Hearing{Place: "ДК БЕЖИЦКОГО РАЙОНА"}, want: DistrictBezhitsky},
+		{name: "unknown", hearing: Hearing{Place: "пл. К.Маркса, д. 10"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.hearing.DetectDistrict(), tt.name)
+		})
+	}
+}
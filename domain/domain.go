@@ -19,12 +19,24 @@ import (
 	"time"
 )
 
-// IHearing is interface for hearing
-// Have two methods: String and Markdown
-type IHearing interface {
-	String() string
-	Markdown() string
-}
+// Status of hearing lifecycle
+type Status string
+
+// Statuses of hearing
+const (
+	// StatusAnnounced is status of found but not published yet hearing
+	StatusAnnounced Status = "announced"
+	// StatusUpcoming is status of published hearing which will be held in future
+	StatusUpcoming Status = "upcoming"
+	// StatusToday is status of hearing which is held today
+	StatusToday Status = "today"
+	// StatusHeld is status of past hearing
+	StatusHeld Status = "held"
+	// StatusCancelled is status of cancelled hearing
+	StatusCancelled Status = "cancelled"
+	// StatusPostponed is status of hearing moved to another date
+	StatusPostponed Status = "postponed"
+)
 
 // Hearing of BGA32
 type Hearing struct {
@@ -35,90 +47,52 @@ type Hearing struct {
 	URL       string    `json:"url"`
 	Time      time.Time `json:"time"`
 	Published bool      `json:"published"`
+	Status    Status    `json:"status"`
+	Category  Category  `json:"category"`
+	District  District  `json:"district"`
+	Deleted   bool      `json:"deleted,omitempty"`
 	Raw       []string  `json:"raw"`
 }
 
-// String returns text representation of hearing
-func (h Hearing) String() string {
-	if h.Place == "" {
-		return ""
-	}
-	var sb strings.Builder
-	sb.WriteString(h.Time.Format("02.01.2006"))
-	sb.WriteString(" в ")
-	sb.WriteString(h.Time.Format("15:04"))
-	sb.WriteString(" в ")
-	sb.WriteString(h.Place)
-
-	if len(h.Topic) == 1 {
-		sb.WriteString(" состоятся публичные слушания ")
-		sb.WriteString(h.Topic[0])
-	} else {
-		sb.WriteString(" состоятся публичные слушания:")
-		for _, t := range h.Topic {
-			if len(t) > 0 {
-				sb.WriteString("\n - ")
-				sb.WriteString(t)
-			}
-		}
+// CurrentStatus returns status of hearing at the moment.
+// Cancelled hearing stays cancelled, other statuses depend on time of hearing.
+func (h Hearing) CurrentStatus(now time.Time) Status {
+	if h.Status == StatusCancelled {
+		return StatusCancelled
 	}
-	sb.WriteString("\n")
 
-	for _, p := range h.Proposals {
-		sb.WriteString(p)
-		sb.WriteString("\n")
+	y1, m1, d1 := h.Time.In(now.Location()).Date()
+	y2, m2, d2 := now.Date()
+	switch {
+	case y1 == y2 && m1 == m2 && d1 == d2:
+		return StatusToday
+	case h.Time.Before(now):
+		return StatusHeld
+	case h.Status == StatusPostponed:
+		return StatusPostponed
+	case !h.Published:
+		return StatusAnnounced
 	}
-
-	sb.WriteString("Ссылка на публикацию: ")
-	sb.WriteString(h.URL)
-	sb.WriteString("\n")
-
-	return sb.String()
+	return StatusUpcoming
 }
 
-// Markdown returns formatted representation of hearing
-func (h Hearing) Markdown() string {
-	if h.Place == "" {
-		return ""
-	}
-	var sb strings.Builder
-	sb.WriteString("*")
-	sb.WriteString(h.escape(h.Time.Format("02.01.2006")))
-	sb.WriteString(" в ")
-	sb.WriteString(h.escape(h.Time.Format("15:04")))
-	sb.WriteString(" в ")
-	sb.WriteString(h.escape(h.Place))
-	sb.WriteString("*")
-
-	if len(h.Topic) == 1 {
-		sb.WriteString(" состоятся публичные слушания ")
-		sb.WriteString(h.escape(h.Topic[0]))
-	} else {
-		sb.WriteString(" состоятся публичные слушания:")
-		for _, t := range h.Topic {
-			sb.WriteString(h.escape("\n\n - "))
-			sb.WriteString(h.escape(t))
-		}
-	}
-	sb.WriteString("\n\n")
-
-	for _, p := range h.Proposals {
-		sb.WriteString(h.escape(p))
-		sb.WriteString("\n\n")
-	}
-
-	sb.WriteString("[Ссылка на публикацию](")
-	sb.WriteString(h.URL)
-	sb.WriteString(")\n")
+// markdownEscaper escapes special characters of Telegram MarkdownV2
+var markdownEscaper = strings.NewReplacer(
+	"\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(",
+	"\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>",
+	"#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|",
+	"\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
 
-	return sb.String()
+// EscapeMarkdown escapes text for Telegram MarkdownV2
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
 }
 
-func (h Hearing) escape(s string) string {
-	return strings.NewReplacer(
-		"_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(",
-		"\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>",
-		"#", "\\#", "+", "\\+", "-", "\\-", "=", "\\=", "|",
-		"\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
-	).Replace(s)
+// markdownURLEscaper escapes characters which close or escape URL of MarkdownV2 link
+var markdownURLEscaper = strings.NewReplacer("\\", "\\\\", ")", "\\)")
+
+// EscapeMarkdownURL escapes URL inside (...) of Telegram MarkdownV2 link
+func EscapeMarkdownURL(s string) string {
+	return markdownURLEscaper.Replace(s)
 }
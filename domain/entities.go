@@ -0,0 +1,190 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Types of entities of formatted text
+const (
+	EntityBold          = "bold"
+	EntityItalic        = "italic"
+	EntityUnderline     = "underline"
+	EntityStrikethrough = "strikethrough"
+	EntityCode          = "code"
+	EntityPre           = "pre"
+	EntityTextLink      = "text_link"
+)
+
+// Entity is formatting of part of text like MessageEntity of Telegram Bot API.
+// Offset and Length are counted in UTF-16 code units.
+type Entity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	// URL of text_link
+	URL string `json:"url,omitempty"`
+}
+
+// FormattedText is plain text with entities. Text is sent as is, so it needs no escaping.
+type FormattedText struct {
+	Text     string   `json:"text"`
+	Entities []Entity `json:"entities,omitempty"`
+}
+
+// Write appends plain text
+func (t *FormattedText) Write(s string) {
+	t.Text += s
+}
+
+// Format appends text with entity of type. URL is used by text links.
+func (t *FormattedText) Format(typ, s, url string) {
+	if s == "" {
+		return
+	}
+	t.Entities = append(t.Entities, Entity{Type: typ, Offset: MessageLength(t.Text), Length: MessageLength(s), URL: url})
+	t.Text += s
+}
+
+// entityTags are tags of HTML of Telegram with types of their entities
+var entityTags = map[string]string{
+	"b": EntityBold, "strong": EntityBold,
+	"i": EntityItalic, "em": EntityItalic,
+	"u": EntityUnderline, "ins": EntityUnderline,
+	"s": EntityStrikethrough, "strike": EntityStrikethrough, "del": EntityStrikethrough,
+	"code": EntityCode,
+	"pre":  EntityPre,
+	"a":    EntityTextLink,
+}
+
+var reHref = regexp.MustCompile(`(?i)href\s*=\s*"([^"]*)"`)
+
+// ParseTelegramHTML converts HTML of Telegram to plain text with entities.
+// Character references are unescaped, unknown tags are dropped and their text is kept.
+func ParseTelegramHTML(text string) FormattedText {
+	var t FormattedText
+	open := make([]Entity, 0)
+	for i := 0; i < len(text); {
+		next := nextHTMLAtom(text, i)
+		atom := text[i:next]
+		i = next
+		if len(atom) < 3 || atom[0] != '<' || atom[len(atom)-1] != '>' {
+			t.Write(html.UnescapeString(atom))
+			continue
+		}
+		typ, ok := entityTags[tagName(atom)]
+		if !ok {
+			continue
+		}
+		if !strings.HasPrefix(atom, "</") {
+			e := Entity{Type: typ, Offset: MessageLength(t.Text)}
+			if m := reHref.FindStringSubmatch(atom); typ == EntityTextLink && m != nil {
+				e.URL = html.UnescapeString(m[1])
+			}
+			open = append(open, e)
+			continue
+		}
+		for j := len(open) - 1; j >= 0; j-- {
+			if open[j].Type != typ {
+				continue
+			}
+			e := open[j]
+			open = append(open[:j], open[j+1:]...)
+			if e.Length = MessageLength(t.Text) - e.Offset; e.Length > 0 {
+				t.Entities = append(t.Entities, e)
+			}
+			break
+		}
+	}
+	// Entities are ordered by offset like ones of Telegram
+	sort.SliceStable(t.Entities, func(i, j int) bool { return t.Entities[i].Offset < t.Entities[j].Offset })
+	return t
+}
+
+// SplitFormatted splits formatted text to parts not longer than limit.
+// Text is split on paragraphs, then on lines and words. Entities crossing the place of split are cut in both parts.
+func SplitFormatted(t FormattedText, limit int) []FormattedText {
+	if MessageLength(t.Text) <= limit {
+		return []FormattedText{t}
+	}
+	parts := make([]FormattedText, 0)
+	for start := 0; start < len(t.Text); {
+		end := len(t.Text)
+		if MessageLength(t.Text[start:]) > limit {
+			end = start + cutText(t.Text[start:], limit)
+		}
+		// Separators between parts are dropped
+		if part := strings.TrimRight(t.Text[start:end], " \n"); part != "" {
+			parts = append(parts, t.slice(start, start+len(part)))
+		}
+		start = len(t.Text) - len(strings.TrimLeft(t.Text[end:], " \n"))
+	}
+	return parts
+}
+
+// slice returns text between byte indexes with entities cut to it
+func (t FormattedText) slice(from, to int) FormattedText {
+	res := FormattedText{Text: t.Text[from:to]}
+	lo, hi := MessageLength(t.Text[:from]), MessageLength(t.Text[:to])
+	for _, e := range t.Entities {
+		start, end := e.Offset, e.Offset+e.Length
+		if start < lo {
+			start = lo
+		}
+		if end > hi {
+			end = hi
+		}
+		if start >= end {
+			continue
+		}
+		e.Offset, e.Length = start-lo, end-start
+		res.Entities = append(res.Entities, e)
+	}
+	return res
+}
+
+// cutText returns index of the best place to split plain text within budget.
+// At least one character is left before the index.
+func cutText(text string, budget int) int {
+	paragraph, line, word, char := 0, 0, 0, 0
+	length := 0
+	for i, r := range text {
+		if i > 0 {
+			switch {
+			case strings.HasPrefix(text[i:], "\n\n"):
+				paragraph = i
+			case r == '\n':
+				line = i
+			case r == ' ':
+				word = i
+			}
+		}
+		length += MessageLength(string(r))
+		if length > budget {
+			break
+		}
+		char = i + runeLen(text[i:])
+	}
+	for _, cut := range []int{paragraph, line, word, char} {
+		if cut > 0 {
+			return cut
+		}
+	}
+	return runeLen(text)
+}
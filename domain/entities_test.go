@@ -0,0 +1,82 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+)
+
+// entityText returns text of entity as Telegram finds it by offsets in UTF-16 code units
+func entityText(t FormattedText, e Entity) string {
+	units := utf16.Encode([]rune(t.Text))
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+}
+
+func TestParseTelegramHTML(t *testing.T) {
+	require.Empty(t, ParseTelegramHTML("").Text)
+	text := ParseTelegramHTML("<b>Публичные слушания отменены</b>\n\n" +
+		"<b>29.03.2022 в 11:00 в 🏛 ул. C:\\Windows_*[1](2)* &lt;b&gt;</b> состоятся публичные слушания:\n\n" +
+		" - о ~`>#+-=|{}.! и \\ 😀\n\n - <i>о <u>сносе</u></i>\n\n" +
+		"до 16.03.2022 &amp; &lt;далее&gt; <span>как есть</span>\n\n" +
+		`<a href="https://ru.wikipedia.org/wiki/Брянск_(город)?q=&#34;\&amp;x=1">Ссылка на публикацию</a>` + "\n")
+	require.Equal(t, "Публичные слушания отменены\n\n"+
+		"29.03.2022 в 11:00 в 🏛 ул. C:\\Windows_*[1](2)* <b> состоятся публичные слушания:\n\n"+
+		" - о ~`>#+-=|{}.! и \\ 😀\n\n - о сносе\n\n"+
+		"до 16.03.2022 & <далее> как есть\n\n"+
+		"Ссылка на публикацию\n", text.Text)
+	require.Len(t, text.Entities, 5)
+	require.Equal(t, "Публичные слушания отменены", entityText(text, text.Entities[0]))
+	require.Equal(t, EntityBold, text.Entities[1].Type)
+	require.Equal(t, "29.03.2022 в 11:00 в 🏛 ул. C:\\Windows_*[1](2)* <b>", entityText(text, text.Entities[1]))
+	require.Equal(t, EntityItalic, text.Entities[2].Type)
+	require.Equal(t, "о сносе", entityText(text, text.Entities[2]))
+	require.Equal(t, EntityUnderline, text.Entities[3].Type)
+	require.Equal(t, "сносе", entityText(text, text.Entities[3]))
+	require.Equal(t, Entity{Type: EntityTextLink, Offset: text.Entities[4].Offset, Length: 20, URL: `https://ru.wikipedia.org/wiki/Брянск_(город)?q="\&x=1`}, text.Entities[4])
+	require.Equal(t, "Ссылка на публикацию", entityText(text, text.Entities[4]))
+}
+
+func TestSplitFormatted(t *testing.T) {
+	short := ParseTelegramHTML("<b>17.03.2099 в 11:00</b> состоятся публичные слушания <a href=\"https://bga32.ru/\">ссылка</a>")
+	require.Equal(t, []FormattedText{short}, SplitFormatted(short, MaxMessageLength))
+
+	var text FormattedText
+	text.Format(EntityBold, strings.Repeat("жирный 😀 текст. ", 10), "")
+	text.Write("обычный текст ")
+	text.Format(EntityTextLink, "ссылка (1)", "https://example.com/a)b")
+	parts := SplitFormatted(text, 50)
+	require.Greater(t, len(parts), 3)
+
+	joined := ""
+	for i, part := range parts {
+		require.LessOrEqual(t, MessageLength(part.Text), 50, "part %d", i)
+		for _, e := range part.Entities {
+			require.Greater(t, e.Length, 0)
+			require.LessOrEqual(t, e.Offset+e.Length, MessageLength(part.Text), "entity of part %d is out of text", i)
+		}
+		joined += part.Text
+	}
+	require.Equal(t, stripSpaces(text.Text), stripSpaces(joined))
+	require.Equal(t, EntityBold, parts[1].Entities[0].Type, "bold text must continue in the next part")
+	require.Zero(t, parts[1].Entities[0].Offset)
+	last := parts[len(parts)-1]
+	link := last.Entities[len(last.Entities)-1]
+	require.Equal(t, "ссылка (1)", entityText(last, link))
+	require.Equal(t, "https://example.com/a)b", link.URL)
+}
@@ -0,0 +1,164 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"strings"
+)
+
+// SplitHTML splits text with HTML markup of Telegram to parts not longer than limit.
+// Text is split on paragraphs, then on lines and words. Tags and character references are never split.
+// Tags opened at the end of part are closed and reopened at the beginning of the next part.
+func SplitHTML(text string, limit int) []string {
+	if MessageLength(text) <= limit {
+		return []string{text}
+	}
+	parts := make([]string, 0)
+	prefix := ""
+	rest := text
+	for rest != "" {
+		if MessageLength(prefix+rest) <= limit {
+			parts = append(parts, prefix+rest)
+			break
+		}
+		// Budget is decreased until part fits with closing tags
+		var part, closing string
+		var open []string
+		cut := 0
+		for budget := limit - MessageLength(prefix); ; {
+			cut = cutHTML(rest, budget)
+			part = prefix + strings.TrimRight(rest[:cut], " \n")
+			open = openTags(part)
+			closing = closingTags(open)
+			over := MessageLength(part+closing) - limit
+			if over <= 0 || budget <= 1 {
+				break
+			}
+			budget -= over
+		}
+		rest = strings.TrimLeft(rest[cut:], " \n")
+		prefix = strings.Join(open, "")
+		if stripTags(part) != "" {
+			parts = append(parts, part+closing)
+		}
+	}
+	return parts
+}
+
+// cutHTML returns index of the best place to split text within budget.
+// At least one atom of text is left before the index.
+func cutHTML(text string, budget int) int {
+	paragraph, line, word, atom := 0, 0, 0, 0
+	length := 0
+	for i := 0; i < len(text); {
+		if i > 0 {
+			switch {
+			case strings.HasPrefix(text[i:], "\n\n"):
+				paragraph = i
+			case text[i] == '\n':
+				line = i
+			case text[i] == ' ':
+				word = i
+			}
+		}
+		next := nextHTMLAtom(text, i)
+		length += MessageLength(text[i:next])
+		if length > budget {
+			break
+		}
+		atom = next
+		i = next
+	}
+	for _, cut := range []int{paragraph, line, word, atom} {
+		if cut > 0 {
+			return cut
+		}
+	}
+	return nextHTMLAtom(text, 0)
+}
+
+// nextHTMLAtom returns index after indivisible sequence of text starting at i:
+// tag, character reference or single character
+func nextHTMLAtom(text string, i int) int {
+	switch text[i] {
+	case '<':
+		if end := strings.IndexByte(text[i:], '>'); end > 0 {
+			return i + end + 1
+		}
+	case '&':
+		if end := strings.IndexByte(text[i:], ';'); end > 1 && !strings.ContainsAny(text[i+1:i+end], " \n<&") {
+			return i + end + 1
+		}
+	}
+	return i + runeLen(text[i:])
+}
+
+// openTags returns opening tags which are not closed at the end of text in order of opening
+func openTags(text string) []string {
+	open := make([]string, 0)
+	for i := 0; i < len(text); {
+		next := nextHTMLAtom(text, i)
+		tag := text[i:next]
+		i = next
+		if len(tag) < 3 || tag[0] != '<' || tag[len(tag)-1] != '>' {
+			continue
+		}
+		if strings.HasPrefix(tag, "</") {
+			name := tagName(tag)
+			for j := len(open) - 1; j >= 0; j-- {
+				if tagName(open[j]) == name {
+					open = append(open[:j], open[j+1:]...)
+					break
+				}
+			}
+			continue
+		}
+		if !strings.HasSuffix(tag, "/>") {
+			open = append(open, tag)
+		}
+	}
+	return open
+}
+
+// closingTags returns tags closing open ones in reverse order
+func closingTags(open []string) string {
+	var sb strings.Builder
+	for i := len(open) - 1; i >= 0; i-- {
+		sb.WriteString("</" + tagName(open[i]) + ">")
+	}
+	return sb.String()
+}
+
+// tagName returns lowercase name of opening or closing tag
+func tagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if end := strings.IndexAny(name, " \t\n/>"); end >= 0 {
+		name = name[:end]
+	}
+	return strings.ToLower(name)
+}
+
+// stripTags returns text without tags
+func stripTags(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		next := nextHTMLAtom(text, i)
+		if text[i] != '<' || text[next-1] != '>' {
+			sb.WriteString(text[i:next])
+		}
+		i = next
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,42 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitHTML(t *testing.T) {
+	short := "<b>коротко</b> &amp; ясно"
+	require.Equal(t, []string{short}, SplitHTML(short, MaxMessageLength))
+
+	words := strings.Repeat("слово &lt;1&gt; &amp; ", 10)
+	text := "<b>" + words + "</b> обычный текст " + words + `<a href="https://example.com/?a=1&amp;b=(2)">ссылка &quot;1&quot;</a>`
+	parts := SplitHTML(text, 80)
+	require.Greater(t, len(parts), 3)
+
+	for i, part := range parts {
+		require.LessOrEqual(t, MessageLength(part), 80, "part %d", i)
+		require.Empty(t, openTags(part), "part %d has not closed tags: %s", i, part)
+		require.NotRegexp(t, `&[a-z]*$`, part, "character reference is broken at the end of part %d", i)
+		require.NotRegexp(t, `<[^>]*$`, part, "tag is broken at the end of part %d", i)
+	}
+	require.True(t, strings.HasPrefix(parts[1], "<b>"), "bold text must be reopened in continuation")
+	require.True(t, strings.HasSuffix(parts[len(parts)-1], `<a href="https://example.com/?a=1&amp;b=(2)">ссылка &quot;1&quot;</a>`))
+	require.Equal(t, stripSpaces(stripTags(text)), stripSpaces(stripTags(strings.Join(parts, ""))))
+}
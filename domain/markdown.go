@@ -0,0 +1,223 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Limits of messages in Telegram
+const (
+	// MaxMessageLength is maximum length of message in UTF-16 code units
+	MaxMessageLength = 4096
+	// MaxMessageParts is maximum number of parts of long message.
+	// Hearing which needs more parts is published in summarized format.
+	MaxMessageParts = 3
+)
+
+// MessageLength returns length of message as Telegram counts it
+func MessageLength(s string) int {
+	n := 0
+	for _, r := range s {
+		if r1, _ := utf16.EncodeRune(r); r1 != '\uFFFD' {
+			// Rune outside of basic plane is encoded by surrogate pair
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// markdownMarkers are markers of MarkdownV2 entities which may be opened in one part and closed in another
+var markdownMarkers = []string{"```", "||", "__", "*", "_", "~", "`"}
+
+// SplitMarkdown splits MarkdownV2 text to parts not longer than limit.
+// Text is split on paragraphs, then on lines and words. Escape sequences and links are never split.
+// Entities opened at the end of part are closed and reopened at the beginning of the next part.
+func SplitMarkdown(text string, limit int) []string {
+	if MessageLength(text) <= limit {
+		return []string{text}
+	}
+	parts := make([]string, 0)
+	prefix := ""
+	rest := text
+	for rest != "" {
+		// Reserve room for markers reopened and closed in part
+		budget := limit - 2*MessageLength(prefix) - 2*len("```")
+		if MessageLength(prefix+rest) <= limit {
+			parts = append(parts, prefix+rest)
+			break
+		}
+		cut := cutMarkdown(rest, budget)
+		part := prefix + strings.TrimRight(rest[:cut], " \n")
+		rest = strings.TrimLeft(rest[cut:], " \n")
+
+		open := openMarkers(part)
+		for i := len(open) - 1; i >= 0; i-- {
+			part += open[i]
+		}
+		prefix = strings.Join(open, "")
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// cutMarkdown returns index of the best place to split text within budget.
+// At least one atom of text is left before the index.
+func cutMarkdown(text string, budget int) int {
+	paragraph, line, word, atom := 0, 0, 0, 0
+	length := 0
+	for i := 0; i < len(text); {
+		if i > 0 {
+			switch {
+			case strings.HasPrefix(text[i:], "\n\n"):
+				paragraph = i
+			case text[i] == '\n':
+				line = i
+			case text[i] == ' ':
+				word = i
+			}
+		}
+		next := nextAtom(text, i)
+		length += MessageLength(text[i:next])
+		if length > budget {
+			break
+		}
+		atom = next
+		i = next
+	}
+	for _, cut := range []int{paragraph, line, word, atom} {
+		if cut > 0 {
+			return cut
+		}
+	}
+	return nextAtom(text, 0)
+}
+
+// nextAtom returns index after indivisible sequence of text starting at i:
+// escape sequence, link or single character
+func nextAtom(text string, i int) int {
+	switch text[i] {
+	case '\\':
+		if i+1 < len(text) {
+			return i + 1 + runeLen(text[i+1:])
+		}
+	case '[':
+		if end := linkEnd(text, i); end > 0 {
+			return end
+		}
+	}
+	return i + runeLen(text[i:])
+}
+
+func runeLen(s string) int {
+	for i := range s {
+		if i > 0 {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// linkEnd returns index after link [text](url) starting at i or 0 if there is no link
+func linkEnd(text string, i int) int {
+	j := i + 1
+	for ; j < len(text) && text[j] != ']'; j++ {
+		if text[j] == '\\' {
+			j++
+		}
+	}
+	if j+1 >= len(text) || text[j+1] != '(' {
+		return 0
+	}
+	for j += 2; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case ')':
+			return j + 1
+		}
+	}
+	return 0
+}
+
+// openMarkers returns markers of entities which are not closed at the end of text in order of opening
+func openMarkers(text string) []string {
+	open := make([]string, 0)
+	for i := 0; i < len(text); {
+		if text[i] == '\\' || text[i] == '[' {
+			i = nextAtom(text, i)
+			continue
+		}
+		marker := ""
+		for _, m := range markdownMarkers {
+			if strings.HasPrefix(text[i:], m) {
+				marker = m
+				break
+			}
+		}
+		if marker == "" {
+			i++
+			continue
+		}
+		i += len(marker)
+
+		found := -1
+		for j := len(open) - 1; j >= 0; j-- {
+			if open[j] == marker {
+				found = j
+				break
+			}
+		}
+		if found >= 0 {
+			open = open[:found]
+		} else {
+			open = append(open, marker)
+		}
+	}
+	return open
+}
+
+// Truncate text to limit of characters on word boundary
+func Truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	cut := string(runes[:limit])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:") + "…"
+}
+
+// Plural returns russian form of word for number n
+func Plural(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	}
+	return many
+}
@@ -0,0 +1,93 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// longMarkdown returns markdown laid out like message of hearing with topics
+func longMarkdown(topics int) string {
+	var sb strings.Builder
+	sb.WriteString("*17\\.03\\.2099 в 11:00 в ГДК Советского района \\(ул\\. Калинина, д\\. 66\\)* состоятся публичные слушания:")
+	for i := 0; i < topics; i++ {
+		sb.WriteString("\n\n \\- " + EscapeMarkdown(strings.Repeat("по проекту планировки территории по ул. Фосфоритной, д.1; ", 5)))
+	}
+	sb.WriteString("\n\n" + EscapeMarkdown(strings.Repeat("Приём предложений осуществляет оргкомитет до 16.03.2099 (включительно). ", 20)))
+	sb.WriteString("\n\n[Ссылка на публикацию](https://bga32.ru/hearing_1/)\n")
+	return sb.String()
+}
+
+func stripSpaces(s string) string {
+	return strings.NewReplacer(" ", "", "\n", "").Replace(s)
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	short := longMarkdown(1)
+	require.Equal(t, []string{short}, SplitMarkdown(short, MaxMessageLength))
+
+	text := longMarkdown(30)
+	require.Greater(t, MessageLength(text), MaxMessageLength)
+	parts := SplitMarkdown(text, MaxMessageLength)
+	require.Greater(t, len(parts), 1)
+	for i, part := range parts {
+		require.LessOrEqual(t, MessageLength(part), MaxMessageLength, "part %d", i)
+		if i < len(parts)-1 {
+			require.False(t, strings.HasSuffix(part, "\n"), "part %d must be trimmed", i)
+		}
+	}
+	require.True(t, strings.HasPrefix(parts[1], "\\- по проекту"), "parts must be split on paragraphs")
+	require.Equal(t, stripSpaces(text), stripSpaces(strings.Join(parts, "")))
+	require.True(t, strings.HasSuffix(parts[len(parts)-1], "[Ссылка на публикацию](https://bga32.ru/hearing_1/)\n"))
+}
+
+func TestSplitMarkdown_entities(t *testing.T) {
+	words := strings.Repeat("слово\\. ", 20)
+	text := "*" + words + "* обычный текст " + words + "[ссылка \\[1\\]](https://example.com/a\\)b)"
+	parts := SplitMarkdown(text, 50)
+	require.Greater(t, len(parts), 3)
+
+	for i, part := range parts {
+		require.LessOrEqual(t, MessageLength(part), 50, "part %d", i)
+		require.Empty(t, openMarkers(part), "part %d has not closed entities: %s", i, part)
+		trailing := len(part) - len(strings.TrimRight(part, "\\"))
+		require.Zero(t, trailing%2, "escape sequence is broken at the end of part %d: %s", i, part)
+	}
+	require.True(t, strings.HasPrefix(parts[1], "*"), "bold text must be reopened in continuation")
+	require.Equal(t, "[ссылка \\[1\\]](https://example.com/a\\)b)", parts[len(parts)-1][strings.Index(parts[len(parts)-1], "["):])
+	require.Equal(t,
+		strings.ReplaceAll(stripSpaces(text), "*", ""),
+		strings.ReplaceAll(stripSpaces(strings.Join(parts, "")), "*", ""),
+	)
+}
+
+func TestMessageLength(t *testing.T) {
+	require.Equal(t, 6, MessageLength("слушан"))
+	require.Equal(t, 3, MessageLength("a😀"))
+}
+
+func TestTruncate(t *testing.T) {
+	require.Equal(t, "короткий текст", Truncate("короткий текст", 20))
+	require.Equal(t, "по проекту…", Truncate("по проекту, планировки", 15))
+}
+
+func TestPlural(t *testing.T) {
+	for n, want := range map[int]string{1: "вопрос", 2: "вопроса", 5: "вопросов", 11: "вопросов", 21: "вопрос", 112: "вопросов", 104: "вопроса"} {
+		require.Equal(t, want, Plural(n, "вопрос", "вопроса", "вопросов"), n)
+	}
+}
@@ -0,0 +1,267 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultEmailSubject is subject of emails if it is not set in options
+const DefaultEmailSubject = "Публичные слушания"
+
+// Email publishes messages as emails with SMTP
+type Email struct {
+	logger  *zerolog.Logger
+	addr    string
+	host    string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	subject string
+	html    bool
+}
+
+// EmailOptions for creating a new email publisher
+type EmailOptions struct {
+	Logger *zerolog.Logger
+
+	// Host and Port of SMTP server. Port 25 is used if empty.
+	Host string
+	Port string
+	// Username and Password for PLAIN authentication. Authentication is skipped if username is empty.
+	Username string
+	Password string
+
+	From    string
+	To      []string
+	Subject string
+	// HTML sends messages as text/html instead of text/plain
+	HTML bool
+}
+
+// NewEmail returns publisher to email recipients
+func NewEmail(opt *EmailOptions) (*Email, error) {
+	switch {
+	case opt.Host == "":
+		return nil, required("email", "host")
+	case opt.From == "":
+		return nil, required("email", "from")
+	case len(opt.To) == 0:
+		return nil, required("email", "to")
+	}
+	port := opt.Port
+	if port == "" {
+		port = "25"
+	}
+	subject := opt.Subject
+	if subject == "" {
+		subject = DefaultEmailSubject
+	}
+	var auth smtp.Auth
+	if opt.Username != "" {
+		auth = smtp.PlainAuth("", opt.Username, opt.Password, opt.Host)
+	}
+	return &Email{
+		logger:  newLogger(opt.Logger, "email"),
+		addr:    net.JoinHostPort(opt.Host, port),
+		host:    opt.Host,
+		auth:    auth,
+		from:    opt.From,
+		to:      opt.To,
+		subject: subject,
+		html:    opt.HTML,
+	}, nil
+}
+
+// Mail is email message. It is multipart with plain text and HTML alternatives if both are set.
+type Mail struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers are added to message, for example List-Unsubscribe
+	Headers map[string]string
+}
+
+// Publish message to all recipients.
+// Rejections of SMTP server with 5xx codes are permanent, other errors are transient.
+func (p Email) Publish(ctx context.Context, message string) error {
+	m := Mail{To: p.to, Subject: p.subject, Text: message}
+	if p.html {
+		m = Mail{To: p.to, Subject: p.subject, HTML: message}
+	}
+	return p.Send(ctx, m)
+}
+
+// Send mail. Subject and recipients of publisher are used if they are empty in mail.
+// Rejections of SMTP server with 5xx codes are permanent, other errors are transient.
+func (p Email) Send(ctx context.Context, m Mail) error {
+	if len(m.To) == 0 {
+		m.To = p.to
+	}
+	if m.Subject == "" {
+		m.Subject = p.subject
+	}
+	msg, err := p.build(m, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	if err = p.send(ctx, m.To, msg); err != nil {
+		p.logger.Error().Err(err).Msg("failed to send email")
+		return smtpError(err)
+	}
+	return nil
+}
+
+// build returns message with headers and quoted-printable body
+func (p Email) build(m Mail, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	headers := [][2]string{
+		{"From", p.from},
+		{"To", strings.Join(m.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+	}
+	for _, k := range sortedKeys(m.Headers) {
+		headers = append(headers, [2]string{k, m.Headers[k]})
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", h[0], h[1])
+	}
+
+	if m.Text == "" || m.HTML == "" {
+		contentType, body := "text/plain", m.Text
+		if m.HTML != "" {
+			contentType, body = "text/html", m.HTML
+		}
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n", contentType)
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", w.Boundary())
+	for _, part := range [][2]string{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part[0] + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err = writeQuotedPrintable(pw, part[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable writes text with CRLF line endings in quoted-printable encoding
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// sortedKeys returns keys of headers in stable order
+func sortedKeys(headers map[string]string) []string {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// send message with SMTP. It is like smtp.SendMail but respects deadline of context.
+func (p Email) send(ctx context.Context, to []string, msg []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, p.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: p.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+	if p.auth != nil {
+		if err = c.Auth(p.auth); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(p.from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err = c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// smtpError returns error of SMTP server with kind by its code
+func smtpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	return transientError{err}
+}
@@ -0,0 +1,124 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/brurbanko/mercury/internal/smtptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmail_Publish(t *testing.T) {
+	sink := smtptest.New(t)
+	p, err := NewEmail(&EmailOptions{
+		Host:     sink.Host(),
+		Port:     sink.Port(),
+		Username: "user",
+		Password: "secret",
+		From:     "bot@example.com",
+		To:       []string{"a@example.com", "b@example.com"},
+		HTML:     true,
+	})
+	require.NoError(t, err)
+
+	message := "<b>Публичные слушания</b>\n" + strings.Repeat("по проекту планировки территории ", 10)
+	require.NoError(t, p.Publish(context.Background(), message))
+
+	messages := sink.Messages()
+	require.Len(t, messages, 1)
+	msg := messages[0]
+	require.Equal(t, "bot@example.com", msg.From)
+	require.Equal(t, []string{"a@example.com", "b@example.com"}, msg.To)
+	require.Equal(t, "\x00user\x00secret", msg.Auth)
+	require.Contains(t, msg.Data, "To: a@example.com, b@example.com\n")
+	require.Contains(t, msg.Data, "Subject: =?utf-8?q?")
+	require.Contains(t, msg.Data, "Content-Type: text/html; charset=utf-8\n")
+	require.Contains(t, msg.Data, "Content-Transfer-Encoding: quoted-printable\n")
+	for _, line := range strings.Split(msg.Data[strings.Index(msg.Data, "\n\n"):], "\n") {
+		require.LessOrEqual(t, len(line), 76, "line of quoted-printable body is too long")
+	}
+}
+
+func TestEmail_Publish_errors(t *testing.T) {
+	sink := smtptest.New(t)
+	opt := &EmailOptions{Host: sink.Host(), Port: sink.Port(), From: "bot@example.com", To: []string{"a@example.com"}}
+	p, err := NewEmail(opt)
+	require.NoError(t, err)
+
+	sink.SetReject("550 mailbox unavailable")
+	err = p.Publish(context.Background(), "text")
+	require.True(t, IsPermanent(err))
+
+	sink.SetReject("451 try again later")
+	err = p.Publish(context.Background(), "text")
+	require.ErrorIs(t, err, ErrTransient)
+	require.Empty(t, sink.Messages())
+
+	opt.Port = "1"
+	p, err = NewEmail(opt)
+	require.NoError(t, err)
+	require.ErrorIs(t, p.Publish(context.Background(), "text"), ErrTransient)
+
+	_, err = NewEmail(&EmailOptions{Host: "localhost", From: "bot@example.com"})
+	require.EqualError(t, err, "email publisher: to is required")
+}
+
+func TestEmail_Send_multipart(t *testing.T) {
+	sink := smtptest.New(t)
+	p, err := NewEmail(&EmailOptions{Host: sink.Host(), Port: sink.Port(), From: "bot@example.com", To: []string{"all@example.com"}})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Send(context.Background(), Mail{
+		To:      []string{"one@example.com"},
+		Subject: "Дайджест",
+		Text:    "Слушания",
+		HTML:    "<p>Слушания</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.com/unsubscribe>"},
+	}))
+
+	messages := sink.Messages()
+	require.Len(t, messages, 1)
+	require.Equal(t, []string{"one@example.com"}, messages[0].To)
+
+	msg, err := mail.ReadMessage(strings.NewReader(messages[0].Data))
+	require.NoError(t, err)
+	require.Equal(t, "<https://example.com/unsubscribe>", msg.Header.Get("List-Unsubscribe"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "Дайджест", subject)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range [][2]string{{"text/plain; charset=utf-8", "Слушания"}, {"text/html; charset=utf-8", "<p>Слушания</p>"}} {
+		part, err := r.NextPart()
+		require.NoError(t, err)
+		require.Equal(t, want[0], part.Header.Get("Content-Type"))
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		require.Equal(t, want[1], string(body))
+	}
+	_, err = r.NextPart()
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,137 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kinds of publishing errors. Use errors.Is to check the kind of error returned by Publisher.
+var (
+	// ErrPermanent is returned when message cannot be published without changes of message or settings,
+	// for example message has bad markup or chat is not found
+	ErrPermanent = errors.New("permanent publishing error")
+	// ErrTransient is returned when publishing may succeed later, for example on rate limit or network error
+	ErrTransient = errors.New("transient publishing error")
+)
+
+// APIError is error response of API of channel
+type APIError struct {
+	// StatusCode of HTTP response
+	StatusCode int
+	// Code is code of error in response. It is HTTP status code if API has no own codes.
+	Code        int
+	Description string
+	// RetryAfter is time to wait before the next request on rate limit
+	RetryAfter time.Duration
+	// Transient marks error as transient regardless of code
+	Transient bool
+}
+
+// Error implements error interface
+func (e *APIError) Error() string {
+	if e.Description == "" {
+		return fmt.Sprintf("api error %d", e.Code)
+	}
+	return fmt.Sprintf("api error %d: %s", e.Code, e.Description)
+}
+
+// Permanent returns true if the same request will fail again.
+// Errors are permanent except timeouts, rate limits, server errors and errors marked as transient.
+func (e *APIError) Permanent() bool {
+	switch {
+	case e.Transient:
+		return false
+	case e.Code == http.StatusRequestTimeout, e.Code == http.StatusTooManyRequests, e.Code >= http.StatusInternalServerError:
+		return false
+	}
+	return true
+}
+
+// Unwrap returns ErrPermanent or ErrTransient
+func (e *APIError) Unwrap() error {
+	if e.Permanent() {
+		return ErrPermanent
+	}
+	return ErrTransient
+}
+
+// IsPermanent returns true if publishing failed with permanent error and should not be retried
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrPermanent)
+}
+
+// RetryAfter returns time requested by API of channel to wait before the next request or zero
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// Progress of publishing of message which is split to parts
+type Progress struct {
+	// Parts is number of sent parts
+	Parts int
+	// MessageID is identifier of the last sent part. Continuation parts are replies to it.
+	MessageID int
+}
+
+// PartialError is returned when some parts of split message are sent before failure.
+// Publishing is resumed from its progress by Resumer, so sent parts are not sent again.
+type PartialError struct {
+	Progress Progress
+	Err      error
+}
+
+// Error implements error interface
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d parts of message are sent: %s", e.Progress.Parts, e.Err)
+}
+
+// Unwrap returns error of the failed part
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// SentProgress returns progress of partially published message or false if nothing is sent
+func SentProgress(err error) (Progress, bool) {
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		return partial.Progress, true
+	}
+	return Progress{}, false
+}
+
+// transientError wraps error of network or reading response as transient
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e transientError) Is(target error) bool {
+	return target == ErrTransient
+}
+
+func (e transientError) Unwrap() error {
+	return e.err
+}
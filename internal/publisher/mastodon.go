@@ -0,0 +1,124 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultMastodonLength is default limit of characters of status
+const DefaultMastodonLength = 500
+
+// Mastodon publishes messages as statuses of Mastodon account
+type Mastodon struct {
+	logger     *zerolog.Logger
+	client     *http.Client
+	url        string
+	token      string
+	visibility string
+	maxLength  int
+}
+
+// MastodonOptions for creating a new mastodon publisher
+type MastodonOptions struct {
+	Logger *zerolog.Logger
+	// Instance is base URL of server, for example https://mastodon.social
+	Instance string
+	// Token is access token of application with write:statuses scope
+	Token string
+	// Visibility of statuses: public, unlisted, private or direct. Default of account is used if empty.
+	Visibility string
+	// MaxLength of status. Longer messages are truncated. DefaultMastodonLength is used if zero.
+	MaxLength int
+	// HTTPClient sends requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+type mastodonError struct {
+	Error string `json:"error"`
+}
+
+// NewMastodon returns publisher to mastodon account
+func NewMastodon(opt *MastodonOptions) (*Mastodon, error) {
+	switch {
+	case opt.Instance == "":
+		return nil, required("mastodon", "instance")
+	case opt.Token == "":
+		return nil, required("mastodon", "token")
+	}
+	maxLength := opt.MaxLength
+	if maxLength <= 0 {
+		maxLength = DefaultMastodonLength
+	}
+	return &Mastodon{
+		logger:     newLogger(opt.Logger, "mastodon"),
+		client:     opt.HTTPClient,
+		url:        strings.TrimSuffix(opt.Instance, "/") + "/api/v1/statuses",
+		token:      opt.Token,
+		visibility: opt.Visibility,
+		maxLength:  maxLength,
+	}, nil
+}
+
+// Publish message as status. Message longer than limit is truncated.
+// Idempotency key is hash of message, so server ignores repeated publishing of the same message.
+func (p Mastodon) Publish(ctx context.Context, message string) error {
+	status := truncateText(message, p.maxLength)
+	form := url.Values{"status": {status}}
+	if p.visibility != "" {
+		form.Set("visibility", p.visibility)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Idempotency-Key", messageHash(status))
+
+	if response, err := do(p.client, req); err != nil {
+		var apiErr *APIError
+		var mErr mastodonError
+		if errors.As(err, &apiErr) && json.Unmarshal(response, &mErr) == nil && mErr.Error != "" {
+			apiErr.Description = mErr.Error
+		}
+		p.logger.Error().Err(err).Msg("failed to publish status")
+		return err
+	}
+	return nil
+}
+
+// truncateText cuts text to limit of characters on word boundary and adds ellipsis
+func truncateText(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)[:limit-1]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n.,;:") + "…"
+}
@@ -0,0 +1,59 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMastodon_Publish(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeResponse{status: http.StatusOK, body: `{"id":"1"}`},
+		fakeResponse{status: http.StatusUnprocessableEntity, body: `{"error":"Validation failed: Text can't be blank"}`},
+	)
+	p, err := NewMastodon(&MastodonOptions{Instance: srv.URL, Token: "secret", Visibility: "unlisted"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	message := strings.Repeat("Публичные слушания по проекту планировки. ", 20)
+	require.NoError(t, p.Publish(ctx, message))
+	err = p.Publish(ctx, "")
+	require.True(t, IsPermanent(err))
+	require.EqualError(t, err, "api error 422: Validation failed: Text can't be blank")
+
+	requests := srv.received()
+	require.Len(t, requests, 2)
+	require.Equal(t, "/api/v1/statuses", requests[0].Path)
+	require.Equal(t, "Bearer secret", requests[0].Header.Get("Authorization"))
+	require.NotEmpty(t, requests[0].Header.Get("Idempotency-Key"))
+	form, err := url.ParseQuery(requests[0].Body)
+	require.NoError(t, err)
+	require.Equal(t, "unlisted", form.Get("visibility"))
+	status := form.Get("status")
+	require.LessOrEqual(t, utf8.RuneCountInString(status), DefaultMastodonLength)
+	require.True(t, strings.HasSuffix(status, " по проекту…"), status)
+}
+
+func TestTruncateText(t *testing.T) {
+	require.Equal(t, "короткий текст", truncateText("короткий текст", 20))
+	require.Equal(t, "по проекту…", truncateText("по проекту, планировки", 15))
+}
@@ -0,0 +1,146 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Matrix publishes messages to room with Matrix client-server API
+type Matrix struct {
+	logger *zerolog.Logger
+	client *http.Client
+	url    string
+	token  string
+	html   bool
+}
+
+// MatrixOptions for creating a new matrix publisher
+type MatrixOptions struct {
+	Logger *zerolog.Logger
+	// Homeserver is base URL of server, for example https://matrix.org
+	Homeserver string
+	// Token is access token of user which sends messages
+	Token string
+	// Room is identifier of room, for example !abc:matrix.org
+	Room string
+	// HTML sends messages as formatted body in addition to plain text
+	HTML bool
+	// HTTPClient sends requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+}
+
+type matrixError struct {
+	ErrCode      string `json:"errcode"`
+	Error        string `json:"error"`
+	RetryAfterMS int    `json:"retry_after_ms"`
+}
+
+// NewMatrix returns publisher to matrix room
+func NewMatrix(opt *MatrixOptions) (*Matrix, error) {
+	switch {
+	case opt.Homeserver == "":
+		return nil, required("matrix", "homeserver")
+	case opt.Token == "":
+		return nil, required("matrix", "token")
+	case opt.Room == "":
+		return nil, required("matrix", "room")
+	}
+	return &Matrix{
+		logger: newLogger(opt.Logger, "matrix"),
+		client: opt.HTTPClient,
+		url: fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/",
+			strings.TrimSuffix(opt.Homeserver, "/"), url.PathEscape(opt.Room)),
+		token: opt.Token,
+		html:  opt.HTML,
+	}, nil
+}
+
+// Publish message.
+// Transaction identifier is hash of message, so homeserver ignores repeated publishing of the same message.
+func (p Matrix) Publish(ctx context.Context, message string) error {
+	msg := matrixMessage{MsgType: "m.text", Body: message}
+	if p.html {
+		msg.Body = stripTags(message)
+		msg.Format = "org.matrix.custom.html"
+		msg.FormattedBody = message
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.url+messageHash(message), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.token)
+
+	if response, err := do(p.client, req); err != nil {
+		var apiErr *APIError
+		var mErr matrixError
+		if errors.As(err, &apiErr) && json.Unmarshal(response, &mErr) == nil && mErr.ErrCode != "" {
+			apiErr.Description = mErr.ErrCode + ": " + mErr.Error
+			apiErr.RetryAfter = time.Duration(mErr.RetryAfterMS) * time.Millisecond
+		}
+		p.logger.Error().Err(err).Msg("failed to publish message")
+		return err
+	}
+	return nil
+}
+
+// messageHash returns hex of SHA-256 of message for idempotency keys
+func messageHash(message string) string {
+	sum := sha256.Sum256([]byte(message))
+	return hex.EncodeToString(sum[:])
+}
+
+// stripTags returns text of HTML fragment for clients without HTML support
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(b.String())
+}
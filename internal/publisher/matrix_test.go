@@ -0,0 +1,61 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatrix_Publish(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeResponse{status: http.StatusOK, body: `{"event_id":"$1"}`},
+		fakeResponse{status: http.StatusTooManyRequests, body: `{"errcode":"M_LIMIT_EXCEEDED","error":"Too many requests","retry_after_ms":2000}`},
+		fakeResponse{status: http.StatusForbidden, body: `{"errcode":"M_FORBIDDEN","error":"Not in room"}`},
+	)
+	p, err := NewMatrix(&MatrixOptions{Homeserver: srv.URL + "/", Token: "secret", Room: "!room:example.com", HTML: true})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	message := "<b>Публичные слушания</b> &amp; обсуждения"
+	require.NoError(t, p.Publish(ctx, message))
+
+	err = p.Publish(ctx, message)
+	require.ErrorIs(t, err, ErrTransient)
+	require.Equal(t, 2*time.Second, RetryAfter(err))
+
+	err = p.Publish(ctx, "other")
+	require.True(t, IsPermanent(err))
+	require.EqualError(t, err, "api error 403: M_FORBIDDEN: Not in room")
+
+	requests := srv.received()
+	require.Len(t, requests, 3)
+	require.Equal(t, http.MethodPut, requests[0].Method)
+	require.True(t, strings.HasPrefix(requests[0].Path, "/_matrix/client/v3/rooms/%21room:example.com/send/m.room.message/"), requests[0].Path)
+	require.Equal(t, requests[0].Path, requests[1].Path, "transaction of the same message must be the same")
+	require.NotEqual(t, requests[0].Path, requests[2].Path)
+	require.Equal(t, "Bearer secret", requests[0].Header.Get("Authorization"))
+	require.JSONEq(t, `{
+		"msgtype": "m.text",
+		"body": "Публичные слушания & обсуждения",
+		"format": "org.matrix.custom.html",
+		"formatted_body": "<b>Публичные слушания</b> &amp; обсуждения"
+	}`, requests[0].Body)
+}
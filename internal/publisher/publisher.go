@@ -12,111 +12,100 @@
 //   See the License for the specific language governing permissions and
 //   limitations under the License.
 
+// Package publisher publishes messages to Telegram, webhooks, email, Matrix, Mastodon and VK
 package publisher
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/rs/zerolog"
 )
 
-// Publisher is library to publish messages with HTTP
-type Publisher struct {
-	logger *zerolog.Logger
+// Publisher publishes messages to one channel.
+// Returned error matches ErrPermanent or ErrTransient with errors.Is.
+type Publisher interface {
+	Publish(ctx context.Context, message string) error
+}
 
-	skip bool
-	url  string
-	chat string
+// Resumer publishes the rest of message which is published partially, see PartialError
+type Resumer interface {
+	Resume(ctx context.Context, message string, progress Progress) error
 }
 
-// Options for creating a new publisher
-type Options struct {
-	Logger *zerolog.Logger
+// Formats of messages of targets
+const (
+	// FormatText is plain text
+	FormatText = "text"
+	// FormatMarkdown is Telegram MarkdownV2
+	FormatMarkdown = "markdown"
+	// FormatHTML is HTML fragment
+	FormatHTML = "html"
+	// FormatJSON is JSON object with event and hearing
+	FormatJSON = "json"
+	// FormatEmail is plain text with labeled fields for emails
+	FormatEmail = "email"
+	// FormatMastodon is short plain text which fits in status
+	FormatMastodon = "mastodon"
+	// FormatTelegramHTML is HTML with the tags supported by Telegram
+	FormatTelegramHTML = "telegram_html"
+	// FormatEntities is JSON of domain.FormattedText, plain text with Telegram entities
+	FormatEntities = "entities"
+)
 
-	Token  string
-	ChatID string
+// Target is named channel of publishing with its own format of messages.
+// Name identifies deliveries to the target, so it must not be changed.
+type Target struct {
+	Name string
+	// Format of messages. Format of publishing call is used if empty.
+	Format    string
+	Publisher Publisher
+	// Schedule of publishing. Messages are published at any time if it is empty.
+	Schedule Schedule
 }
 
-type tgMessage struct {
-	ChatID         string `json:"chat_id"`
-	ParseMode      string `json:"parse_mode"`
-	Text           string `json:"text"`
-	DisablePreview bool   `json:"disable_web_page_preview"`
-}
+var _ = []Publisher{(*Telegram)(nil), (*Webhook)(nil), (*Email)(nil), (*Matrix)(nil), (*Mastodon)(nil), (*VK)(nil)}
 
-// New instance of publisher
-func New(opt *Options) (*Publisher, error) {
-	var l zerolog.Logger
-	if opt.Logger == nil {
-		l = zerolog.Nop()
+// newLogger returns logger of publisher or nop logger
+func newLogger(l *zerolog.Logger, name string) *zerolog.Logger {
+	var res zerolog.Logger
+	if l == nil {
+		res = zerolog.Nop()
 	} else {
-		l = opt.Logger.With().Str("package", "publisher").Logger()
+		res = l.With().Str("package", "publisher").Str("publisher", name).Logger()
 	}
-
-	return &Publisher{
-		logger: &l,
-
-		skip: opt.Token == "",
-		url:  fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", opt.Token),
-		chat: opt.ChatID,
-	}, nil
+	return &res
 }
 
-// Publish message
-func (p Publisher) Publish(ctx context.Context, message string) error {
-	if p.skip {
-		p.logger.Debug().Msg("Token is empty. Publish skipped")
-		return nil
-	}
-	p.logger.Debug().Msg("Publishing")
-
-	msg := tgMessage{
-		ParseMode:      "MarkdownV2",
-		DisablePreview: true,
-		ChatID:         p.chat,
-		Text:           message,
+// do sends request and returns body of successful response.
+// Network errors are transient, error statuses are returned as APIError with body in description.
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
 	}
-	var body bytes.Buffer
-	err := json.NewEncoder(&body).Encode(msg)
-	if err != nil {
-		p.logger.Error().Err(err).Msg("error creating body")
-		return err
-	}
-
-	// prepare request
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, &body)
+	resp, err := client.Do(req)
 	if err != nil {
-		p.logger.Error().Err(err).Msg("error creating request")
-		return err
+		return nil, transientError{err}
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		p.logger.Error().Err(err).Msg("error sending request")
-		return err
+		return nil, transientError{err}
 	}
-	defer func() {
-		cerr := resp.Body.Close()
-		if cerr != nil {
-			p.logger.Error().Err(cerr).Msg("error closing response body")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, &APIError{
+			StatusCode:  resp.StatusCode,
+			Code:        resp.StatusCode,
+			Description: strings.TrimSpace(string(body)),
 		}
-	}()
-	p.logger.Debug().Msg("response received")
-	if resp.StatusCode != http.StatusOK {
-		p.logger.Error().Msgf("response status code is not OK: %d", resp.StatusCode)
-		response, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
-		p.logger.Error().Msgf("response: %s", response)
-		return fmt.Errorf("response status code is not OK: %d", resp.StatusCode)
 	}
+	return body, nil
+}
 
-	return nil
+// required returns error about empty required option
+func required(publisher, option string) error {
+	return fmt.Errorf("%s publisher: %s is required", publisher, option)
 }
@@ -0,0 +1,66 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeRequest is request received by fakeServer
+type fakeRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// fakeServer replies with queued responses or 200 with empty JSON object and records requests
+type fakeServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []fakeRequest
+}
+
+func newFakeServer(t *testing.T, responses ...fakeResponse) *fakeServer {
+	t.Helper()
+	srv := &fakeServer{responses: responses}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		srv.requests = append(srv.requests, fakeRequest{Method: r.Method, Path: r.URL.EscapedPath(), Header: r.Header, Body: string(body)})
+
+		resp := fakeResponse{status: http.StatusOK, body: "{}"}
+		if len(srv.responses) > 0 {
+			resp, srv.responses = srv.responses[0], srv.responses[1:]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		_, _ = w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeServer) received() []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeRequest(nil), f.requests...)
+}
@@ -0,0 +1,149 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is daily period of publishing in minutes since midnight.
+// Window which ends before its start lasts over midnight.
+type Window struct {
+	From int
+	To   int
+}
+
+// ParseWindow parses window like 09:00-21:00
+func ParseWindow(s string) (Window, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+	}
+	var w Window
+	var err error
+	if w.From, err = parseClock(from); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.To, err = parseClock(to); err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.From == w.To {
+		return Window{}, fmt.Errorf("invalid window %q: empty period", s)
+	}
+	return w, nil
+}
+
+// parseClock returns minutes since midnight of time like 09:00. 24:00 is the end of day.
+func parseClock(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// String returns window like 09:00-21:00
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.From/60, w.From%60, w.To/60, w.To%60)
+}
+
+// Schedule limits time of publishing to channel
+type Schedule struct {
+	// Windows of publishing in local time. Publishing is allowed at any time if empty.
+	Windows []Window
+	// Spacing is minimal interval between messages to channel
+	Spacing time.Duration
+	// Silent publishes messages outside of windows at once without notification instead of waiting for window
+	Silent bool
+	// Location of windows. time.Local is used if nil.
+	Location *time.Location
+}
+
+// ParseSchedule returns schedule of windows like 09:00-21:00
+func ParseSchedule(windows []string, spacing time.Duration, silent bool) (Schedule, error) {
+	s := Schedule{Spacing: spacing, Silent: silent}
+	if spacing < 0 {
+		return s, fmt.Errorf("negative spacing %s", spacing)
+	}
+	for _, v := range windows {
+		if strings.TrimSpace(v) == "" {
+			continue
+		}
+		w, err := ParseWindow(v)
+		if err != nil {
+			return s, err
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	return s, nil
+}
+
+// Open reports whether publishing is allowed at time
+func (s Schedule) Open(t time.Time) bool {
+	return s.Next(t).Equal(t)
+}
+
+// Next returns the earliest time not before t when publishing is allowed
+func (s Schedule) Next(t time.Time) time.Time {
+	if len(s.Windows) == 0 {
+		return t
+	}
+	loc := s.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	local := t.In(loc)
+	var next time.Time
+	// Window of the previous day may last over midnight
+	for day := -1; day <= 1; day++ {
+		d := local.AddDate(0, 0, day)
+		midnight := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+		for _, w := range s.Windows {
+			start := midnight.Add(time.Duration(w.From) * time.Minute)
+			end := midnight.Add(time.Duration(w.To) * time.Minute)
+			if w.To < w.From {
+				end = end.AddDate(0, 0, 1)
+			}
+			if !local.Before(start) && local.Before(end) {
+				return t
+			}
+			if start.After(local) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next.In(t.Location())
+}
+
+type silentKey struct{}
+
+// WithoutNotification returns context of publishing without notification of subscribers.
+// It is supported by Telegram and ignored by other publishers.
+func WithoutNotification(ctx context.Context) context.Context {
+	return context.WithValue(ctx, silentKey{}, true)
+}
+
+// notificationDisabled reports whether message is published without notification
+func notificationDisabled(ctx context.Context) bool {
+	silent, _ := ctx.Value(silentKey{}).(bool)
+	return silent
+}
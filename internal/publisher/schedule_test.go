@@ -0,0 +1,83 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	w, err := ParseWindow(" 09:00 - 21:30 ")
+	require.NoError(t, err)
+	require.Equal(t, Window{From: 9 * 60, To: 21*60 + 30}, w)
+	require.Equal(t, "09:00-21:30", w.String())
+
+	w, err = ParseWindow("22:00-24:00")
+	require.NoError(t, err)
+	require.Equal(t, Window{From: 22 * 60, To: 24 * 60}, w)
+
+	for _, s := range []string{"", "09:00", "9-21", "09:00-25:00", "10:00-10:00"} {
+		_, err = ParseWindow(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2022, time.March, day, hour, minute, 0, 0, loc)
+	}
+	s, err := ParseSchedule([]string{"09:00-13:00", "15:00-21:00"}, time.Hour, false)
+	require.NoError(t, err)
+	s.Location = loc
+
+	for _, tc := range []struct {
+		t, next time.Time
+	}{
+		{at(17, 3, 0), at(17, 9, 0)},
+		{at(17, 9, 0), at(17, 9, 0)},
+		{at(17, 12, 59), at(17, 12, 59)},
+		{at(17, 13, 0), at(17, 15, 0)},
+		{at(17, 21, 0), at(18, 9, 0)},
+		{at(17, 23, 30), at(18, 9, 0)},
+	} {
+		require.True(t, tc.next.Equal(s.Next(tc.t)), "next of %s is %s", tc.t, s.Next(tc.t))
+		require.Equal(t, tc.t.Equal(tc.next), s.Open(tc.t))
+	}
+	utc := at(17, 22, 0).UTC()
+	require.Equal(t, time.UTC, s.Next(utc).Location(), "location of time must be kept")
+
+	night, err := ParseSchedule([]string{"22:00-02:00"}, 0, false)
+	require.NoError(t, err)
+	night.Location = loc
+	require.True(t, night.Open(at(17, 1, 0)), "window of previous day lasts over midnight")
+	require.True(t, night.Open(at(17, 23, 0)))
+	require.True(t, at(17, 22, 0).Equal(night.Next(at(17, 2, 0))))
+
+	always := Schedule{}
+	require.True(t, always.Open(at(17, 3, 0)))
+
+	_, err = ParseSchedule([]string{"09:00-21:00"}, -time.Minute, false)
+	require.Error(t, err)
+}
+
+func TestWithoutNotification(t *testing.T) {
+	require.False(t, notificationDisabled(context.Background()))
+	require.True(t, notificationDisabled(WithoutNotification(context.Background())))
+}
@@ -0,0 +1,172 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+)
+
+// TargetConfig is description of target in YAML file.
+// Only fields of the type of target are used.
+type TargetConfig struct {
+	Name string `yaml:"name"`
+	// Type is telegram, webhook, email, matrix, mastodon or vk
+	Type   string `yaml:"type"`
+	Format string `yaml:"format"`
+
+	// URL of webhook, Telegram Bot API or VK API
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Token   string            `yaml:"token"`
+	// Chat of Telegram, room of Matrix or owner_id of VK wall
+	Chat string `yaml:"chat"`
+
+	Host     string   `yaml:"host"`
+	Port     string   `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"`
+
+	Visibility string `yaml:"visibility"`
+	MaxLength  int    `yaml:"max_length"`
+
+	// Windows of publishing in local time like 09:00-21:00. Messages are published at any time if empty.
+	Windows []string `yaml:"windows"`
+	// Spacing is minimal interval between messages like 30m
+	Spacing time.Duration `yaml:"spacing"`
+	// Silent publishes messages outside of windows at once without notification
+	Silent bool `yaml:"silent"`
+}
+
+type targetsFile struct {
+	Targets []TargetConfig `yaml:"targets"`
+}
+
+// defaultFormats of targets by type
+var defaultFormats = map[string]string{
+	"telegram": FormatMarkdown,
+	"webhook":  FormatJSON,
+	"email":    FormatEmail,
+	"matrix":   FormatHTML,
+	"mastodon": FormatMastodon,
+	"vk":       FormatText,
+}
+
+// ParseTargets returns targets from YAML document.
+// Environment variables like ${TOKEN} are expanded in document, so secrets may be kept out of file.
+func ParseTargets(data []byte, logger *zerolog.Logger) ([]Target, error) {
+	var file targetsFile
+	dec := yaml.NewDecoder(bytes.NewReader([]byte(os.ExpandEnv(string(data)))))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed decode targets: %w", err)
+	}
+
+	targets := make([]Target, 0, len(file.Targets))
+	names := make(map[string]struct{})
+	for i, cfg := range file.Targets {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("invalid targets: target #%d: empty name", i+1)
+		}
+		if _, ok := names[cfg.Name]; ok {
+			return nil, fmt.Errorf("invalid targets: target %s: duplicate name", cfg.Name)
+		}
+		names[cfg.Name] = struct{}{}
+
+		t, err := cfg.Target(logger)
+		if err != nil {
+			return nil, fmt.Errorf("invalid targets: target %s: %w", cfg.Name, err)
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// ReadTargets returns targets from YAML file
+func ReadTargets(filename string, logger *zerolog.Logger) ([]Target, error) {
+	data, err := os.ReadFile(path.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed read targets: %w", err)
+	}
+	return ParseTargets(data, logger)
+}
+
+// Target creates publisher of target
+func (c TargetConfig) Target(logger *zerolog.Logger) (Target, error) {
+	format, ok := defaultFormats[c.Type]
+	if !ok {
+		return Target{}, fmt.Errorf("unknown type %q", c.Type)
+	}
+	if c.Format != "" {
+		format = c.Format
+	}
+	switch format {
+	case FormatText, FormatMarkdown, FormatHTML, FormatJSON, FormatEmail, FormatMastodon:
+	case FormatTelegramHTML, FormatEntities:
+		if c.Type != "telegram" {
+			return Target{}, fmt.Errorf("format %q is supported only by telegram", format)
+		}
+	default:
+		return Target{}, fmt.Errorf("unknown format %q", format)
+	}
+	html := format == FormatHTML
+	schedule, err := ParseSchedule(c.Windows, c.Spacing, c.Silent)
+	if err != nil {
+		return Target{}, err
+	}
+
+	var p Publisher
+	switch c.Type {
+	case "telegram":
+		// Telegram without token skips publishing, it is not expected from target of file
+		if c.Token == "" {
+			return Target{}, required("telegram", "token")
+		}
+		if c.Chat == "" {
+			return Target{}, required("telegram", "chat")
+		}
+		p, err = NewTelegram(&TelegramOptions{Logger: logger, APIURL: c.URL, Token: c.Token, ChatID: c.Chat, Format: format})
+	case "webhook":
+		p, err = NewWebhook(&WebhookOptions{Logger: logger, URL: c.URL, Headers: c.Headers})
+	case "email":
+		p, err = NewEmail(&EmailOptions{
+			Logger: logger, Host: c.Host, Port: c.Port, Username: c.Username, Password: c.Password,
+			From: c.From, To: c.To, Subject: c.Subject, HTML: html,
+		})
+	case "matrix":
+		p, err = NewMatrix(&MatrixOptions{Logger: logger, Homeserver: c.URL, Token: c.Token, Room: c.Chat, HTML: html})
+	case "mastodon":
+		p, err = NewMastodon(&MastodonOptions{
+			Logger: logger, Instance: c.URL, Token: c.Token, Visibility: c.Visibility, MaxLength: c.MaxLength,
+		})
+	case "vk":
+		p, err = NewVK(&VKOptions{Logger: logger, APIURL: c.URL, Token: c.Token, OwnerID: c.Chat})
+	}
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{Name: c.Name, Format: format, Publisher: p, Schedule: schedule}, nil
+}
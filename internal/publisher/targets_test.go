@@ -0,0 +1,94 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	t.Setenv("TEST_MATRIX_TOKEN", "matrix-secret")
+	targets, err := ParseTargets([]byte(`
+targets:
+  - name: telegram
+    type: telegram
+    token: token
+    chat: "@chat"
+    windows: ["09:00-21:00"]
+    spacing: 30m
+    silent: true
+  - name: hook
+    type: webhook
+    url: https://example.com/hook
+  - name: mail
+    type: email
+    format: html
+    host: localhost
+    from: bot@example.com
+    to: [a@example.com]
+  - name: matrix
+    type: matrix
+    url: https://matrix.example.com
+    token: ${TEST_MATRIX_TOKEN}
+    chat: "!room:example.com"
+  - name: mastodon
+    type: mastodon
+    url: https://mastodon.example.com
+    token: token
+  - name: vk
+    type: vk
+    token: token
+    chat: "-1"
+`), nil)
+	require.NoError(t, err)
+	require.Len(t, targets, 6)
+
+	formats := make(map[string]string)
+	for _, target := range targets {
+		formats[target.Name] = target.Format
+	}
+	require.Equal(t, map[string]string{
+		"telegram": FormatMarkdown,
+		"hook":     FormatJSON,
+		"mail":     FormatHTML,
+		"matrix":   FormatHTML,
+		"mastodon": FormatMastodon,
+		"vk":       FormatText,
+	}, formats)
+	require.Equal(t, Schedule{Windows: []Window{{From: 9 * 60, To: 21 * 60}}, Spacing: 30 * time.Minute, Silent: true}, targets[0].Schedule)
+	require.Empty(t, targets[1].Schedule.Windows)
+	require.IsType(t, &Email{}, targets[2].Publisher)
+	require.True(t, targets[2].Publisher.(*Email).html)
+	require.Equal(t, "matrix-secret", targets[3].Publisher.(*Matrix).token)
+
+	for doc, msg := range map[string]string{
+		"targets: [{type: webhook, url: u}]":                                        "invalid targets: target #1: empty name",
+		"targets: [{name: a, type: webhook, url: u}, {name: a, type: vk}]":          "invalid targets: target a: duplicate name",
+		"targets: [{name: a, type: fax}]":                                           `invalid targets: target a: unknown type "fax"`,
+		"targets: [{name: a, type: webhook, url: u, format: pdf}]":                  `invalid targets: target a: unknown format "pdf"`,
+		"targets: [{name: a, type: matrix, url: u}]":                                "invalid targets: target a: matrix publisher: token is required",
+		"targets: [{name: a, type: telegram, token: ${TEST_UNSET_TOKEN}, chat: c}]": "invalid targets: target a: telegram publisher: token is required",
+		"targets: [{name: a, type: telegram, token: t}]":                            "invalid targets: target a: telegram publisher: chat is required",
+		"targets: [{name: a, type: webhook, url: u, windows: [9-21]}]":              `invalid targets: target a: invalid window "9-21": invalid time "9"`,
+	} {
+		_, err = ParseTargets([]byte(doc), nil)
+		require.EqualError(t, err, msg)
+	}
+	_, err = ParseTargets([]byte("targets: [{name: a, typ: vk}]"), nil)
+	require.Error(t, err)
+}
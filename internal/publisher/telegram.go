@@ -0,0 +1,485 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brurbanko/mercury/domain"
+
+	"github.com/rs/zerolog"
+)
+
+// Default settings of telegram publisher
+const (
+	// DefaultTelegramURL is base URL of Telegram Bot API
+	DefaultTelegramURL = "https://api.telegram.org"
+	// DefaultRetries is number of retries of transient errors
+	DefaultRetries = 3
+	// DefaultBackoff is delay before the first retry. Delay is doubled for every next retry.
+	DefaultBackoff = time.Second
+	// DefaultMaxBackoff limits delay between retries
+	DefaultMaxBackoff = time.Minute
+	// DefaultChatInterval is minimal interval between messages to one chat.
+	// Telegram allows about 20 messages per minute to groups and channels.
+	DefaultChatInterval = 3 * time.Second
+)
+
+// Parse modes of Telegram messages. Message without parse mode is formatted by its entities.
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+)
+
+// Telegram publishes messages to chat or channel with Telegram Bot API
+type Telegram struct {
+	logger *zerolog.Logger
+	client *http.Client
+
+	skip   bool
+	api    string
+	chat   string
+	format string
+
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	limiter    *chatLimiter
+	maxLength  int
+}
+
+// TelegramOptions for creating a new telegram publisher
+type TelegramOptions struct {
+	Logger *zerolog.Logger
+
+	Token  string
+	ChatID string
+
+	// APIURL is base URL of Telegram Bot API. DefaultTelegramURL is used if empty.
+	APIURL string
+	// HTTPClient sends requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+	// Retries of transient errors. DefaultRetries is used if zero, negative value disables retries.
+	Retries int
+	// Backoff is delay before the first retry. DefaultBackoff is used if zero.
+	Backoff time.Duration
+	// MaxBackoff limits delay between retries. DefaultMaxBackoff is used if zero.
+	MaxBackoff time.Duration
+	// ChatInterval is minimal interval between messages to one chat. DefaultChatInterval is used if zero.
+	ChatInterval time.Duration
+	// MaxLength of one message. Longer messages are split. domain.MaxMessageLength is used if zero.
+	MaxLength int
+	// Format of published messages: FormatMarkdown, FormatTelegramHTML, FormatEntities or FormatText.
+	// FormatMarkdown is used if empty.
+	Format string
+}
+
+// TelegramMessage is text with its parse mode. Text without parse mode is formatted by entities.
+type TelegramMessage struct {
+	Text      string
+	ParseMode string
+	Entities  []domain.Entity
+	// Buttons are rows of inline keyboard attached to the last part of message
+	Buttons [][]Button
+}
+
+// Button of inline keyboard. Data is sent back to bot in callback query when button is pressed.
+type Button struct {
+	Text string `json:"text"`
+	Data string `json:"callback_data"`
+}
+
+type tgReplyMarkup struct {
+	InlineKeyboard [][]Button `json:"inline_keyboard"`
+}
+
+// keyboard returns inline keyboard of buttons or nil if there are no buttons
+func keyboard(buttons [][]Button) *tgReplyMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	return &tgReplyMarkup{InlineKeyboard: buttons}
+}
+
+type tgMessage struct {
+	ChatID         string          `json:"chat_id"`
+	ParseMode      string          `json:"parse_mode,omitempty"`
+	Text           string          `json:"text"`
+	Entities       []domain.Entity `json:"entities,omitempty"`
+	DisablePreview bool            `json:"disable_web_page_preview"`
+	// DisableNotification sends message silently
+	DisableNotification bool `json:"disable_notification,omitempty"`
+	// ReplyToMessageID links continuation of long message to its previous part
+	ReplyToMessageID         int            `json:"reply_to_message_id,omitempty"`
+	AllowSendingWithoutReply bool           `json:"allow_sending_without_reply,omitempty"`
+	ReplyMarkup              *tgReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+// tgResponse is common response of Bot API
+type tgResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+	Result struct {
+		MessageID int `json:"message_id"`
+	} `json:"result"`
+}
+
+// NewTelegram returns publisher to telegram chat
+func NewTelegram(opt *TelegramOptions) (*Telegram, error) {
+	apiURL := strings.TrimSuffix(opt.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultTelegramURL
+	}
+	client := opt.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	retries := opt.Retries
+	switch {
+	case retries == 0:
+		retries = DefaultRetries
+	case retries < 0:
+		retries = 0
+	}
+	backoff := opt.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	maxBackoff := opt.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	interval := opt.ChatInterval
+	if interval <= 0 {
+		interval = DefaultChatInterval
+	}
+	maxLength := opt.MaxLength
+	if maxLength <= 0 {
+		maxLength = domain.MaxMessageLength
+	}
+	format := opt.Format
+	switch format {
+	case "":
+		format = FormatMarkdown
+	case FormatMarkdown, FormatTelegramHTML, FormatEntities, FormatText:
+	default:
+		return nil, fmt.Errorf("telegram publisher: unsupported format %q", format)
+	}
+
+	return &Telegram{
+		logger: newLogger(opt.Logger, "telegram"),
+		client: client,
+
+		skip:   opt.Token == "",
+		api:    fmt.Sprintf("%s/bot%s/", apiURL, opt.Token),
+		chat:   opt.ChatID,
+		format: format,
+
+		retries:    retries,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		limiter:    newChatLimiter(interval),
+		maxLength:  maxLength,
+	}, nil
+}
+
+// Publish message.
+// Message longer than limit of Telegram is split on paragraphs, continuation parts are sent as replies.
+// If continuation part fails, PartialError with progress is returned, so publishing is resumed by Resume.
+// Transient errors are retried with exponential backoff and jitter or after time requested by Telegram.
+// Returned error matches ErrPermanent or ErrTransient with errors.Is.
+func (p Telegram) Publish(ctx context.Context, message string) error {
+	return p.PublishTo(ctx, p.chat, message)
+}
+
+// Resume publishing of message which parts are partially sent to chat of publisher
+func (p Telegram) Resume(ctx context.Context, message string, progress Progress) error {
+	return p.ResumeTo(ctx, p.chat, message, progress)
+}
+
+// PublishTo sends message to chat instead of chat of publisher, for example reply of bot to user.
+// It splits and retries messages like Publish.
+func (p Telegram) PublishTo(ctx context.Context, chatID, message string) error {
+	return p.ResumeTo(ctx, chatID, message, Progress{})
+}
+
+// ResumeTo resumes publishing of message to chat from progress returned in PartialError
+func (p Telegram) ResumeTo(ctx context.Context, chatID, message string, progress Progress) error {
+	m, err := p.parse(message)
+	if err != nil {
+		return err
+	}
+	return p.sendParts(ctx, chatID, m, progress)
+}
+
+// parse returns message in format of publisher with its parse mode
+func (p Telegram) parse(message string) (TelegramMessage, error) {
+	switch p.format {
+	case FormatTelegramHTML:
+		return TelegramMessage{Text: message, ParseMode: ParseModeHTML}, nil
+	case FormatEntities:
+		var text domain.FormattedText
+		if err := json.Unmarshal([]byte(message), &text); err != nil {
+			return TelegramMessage{}, fmt.Errorf("%w: invalid formatted text: %s", ErrPermanent, err)
+		}
+		return TelegramMessage{Text: text.Text, Entities: text.Entities}, nil
+	case FormatText:
+		return TelegramMessage{Text: message}, nil
+	}
+	return TelegramMessage{Text: message, ParseMode: ParseModeMarkdownV2}, nil
+}
+
+// Send message with its own parse mode to chat.
+// Message is split on paragraphs without breaking of markup or entities, continuation parts are sent as replies.
+// Message is sent without notification if context is created by WithoutNotification.
+func (p Telegram) Send(ctx context.Context, chatID string, m TelegramMessage) error {
+	return p.sendParts(ctx, chatID, m, Progress{})
+}
+
+// sendParts sends parts of message which are not sent yet by progress
+func (p Telegram) sendParts(ctx context.Context, chatID string, m TelegramMessage, progress Progress) error {
+	if p.skip {
+		p.logger.Debug().Msg("Token is empty. Publish skipped")
+		return nil
+	}
+	p.logger.Debug().Msg("Publishing")
+
+	replyTo := progress.MessageID
+	parts := p.split(m)
+	for i := progress.Parts; i < len(parts); i++ {
+		msg := tgMessage{
+			ParseMode:                m.ParseMode,
+			DisablePreview:           true,
+			ChatID:                   chatID,
+			Text:                     parts[i].Text,
+			Entities:                 parts[i].Entities,
+			ReplyToMessageID:         replyTo,
+			AllowSendingWithoutReply: replyTo != 0,
+			DisableNotification:      notificationDisabled(ctx),
+		}
+		if i == len(parts)-1 {
+			msg.ReplyMarkup = keyboard(m.Buttons)
+		}
+		id, err := p.publish(ctx, msg)
+		if err != nil {
+			if i == 0 {
+				return err
+			}
+			p.logger.Error().Err(err).Int("part", i+1).Msg("failed to publish continuation of message")
+			return &PartialError{Progress: Progress{Parts: i, MessageID: replyTo}, Err: err}
+		}
+		replyTo = id
+	}
+	return nil
+}
+
+// split message to parts not longer than limit of publisher
+func (p Telegram) split(m TelegramMessage) []domain.FormattedText {
+	var texts []string
+	switch m.ParseMode {
+	case ParseModeMarkdownV2:
+		texts = domain.SplitMarkdown(m.Text, p.maxLength)
+	case ParseModeHTML:
+		texts = domain.SplitHTML(m.Text, p.maxLength)
+	default:
+		return domain.SplitFormatted(domain.FormattedText{Text: m.Text, Entities: m.Entities}, p.maxLength)
+	}
+	parts := make([]domain.FormattedText, 0, len(texts))
+	for _, text := range texts {
+		parts = append(parts, domain.FormattedText{Text: text})
+	}
+	return parts
+}
+
+// publish one message with retries and returns its identifier
+func (p Telegram) publish(ctx context.Context, msg tgMessage) (int, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("error creating body")
+		return 0, fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err = p.limiter.wait(ctx, msg.ChatID); err != nil {
+			return 0, err
+		}
+		id, err := p.send(ctx, "sendMessage", body)
+		retryAfter := RetryAfter(err)
+		if retryAfter > 0 {
+			// Chat is limited by Telegram for all messages
+			p.limiter.delay(msg.ChatID, retryAfter)
+		}
+		if err == nil || IsPermanent(err) || attempt >= p.retries || ctx.Err() != nil {
+			return id, err
+		}
+
+		delay := p.delay(attempt)
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+		p.logger.Warn().Err(err).Int("attempt", attempt+1).Dur("delay", delay).Msg("retrying publishing")
+		// Limiter waits for delay before the next attempt and other messages to the chat
+		p.limiter.delay(msg.ChatID, delay)
+	}
+}
+
+// delay returns exponential backoff of retry with jitter from a half to the full delay
+func (p Telegram) delay(attempt int) time.Duration {
+	d := p.backoff << attempt
+	if d > p.maxBackoff || d <= 0 {
+		d = p.maxBackoff
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// AnswerCallback confirms callback query of inline button. Text is shown to user as notification if not empty.
+func (p Telegram) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	return p.call(ctx, "answerCallbackQuery", map[string]string{"callback_query_id": callbackID, "text": text})
+}
+
+// EditButtons replaces inline keyboard of sent message. Keyboard is removed if buttons are empty.
+func (p Telegram) EditButtons(ctx context.Context, chatID string, messageID int, buttons [][]Button) error {
+	markup := keyboard(buttons)
+	if markup == nil {
+		markup = &tgReplyMarkup{InlineKeyboard: [][]Button{}}
+	}
+	return p.call(ctx, "editMessageReplyMarkup", struct {
+		ChatID      string         `json:"chat_id"`
+		MessageID   int            `json:"message_id"`
+		ReplyMarkup *tgReplyMarkup `json:"reply_markup"`
+	}{chatID, messageID, markup})
+}
+
+// call method of Bot API once without retries, for interactive requests which are useless later
+func (p Telegram) call(ctx context.Context, method string, payload interface{}) error {
+	if p.skip {
+		p.logger.Debug().Str("method", method).Msg("Token is empty. Call skipped")
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	_, err = p.send(ctx, method, body)
+	return err
+}
+
+// send request to method of Bot API once and parse response. Identifier of sent message is returned.
+func (p Telegram) send(ctx context.Context, method string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.api+method, bytes.NewReader(body))
+	if err != nil {
+		p.logger.Error().Err(err).Msg("error creating request")
+		return 0, fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("error sending request")
+		return 0, transientError{err}
+	}
+	defer func() {
+		cerr := resp.Body.Close()
+		if cerr != nil {
+			p.logger.Error().Err(cerr).Msg("error closing response body")
+		}
+	}()
+	p.logger.Debug().Msg("response received")
+	response, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, transientError{err}
+	}
+	var tgResp tgResponse
+	parsed := json.Unmarshal(response, &tgResp) == nil
+	if resp.StatusCode == http.StatusOK {
+		return tgResp.Result.MessageID, nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Code: resp.StatusCode}
+	if parsed {
+		if tgResp.ErrorCode != 0 {
+			apiErr.Code = tgResp.ErrorCode
+		}
+		apiErr.Description = tgResp.Description
+		apiErr.RetryAfter = time.Duration(tgResp.Parameters.RetryAfter) * time.Second
+	} else {
+		apiErr.Description = strings.TrimSpace(string(response))
+	}
+	p.logger.Error().Int("status", resp.StatusCode).Str("response", string(response)).Msg("response status code is not OK")
+	return 0, apiErr
+}
+
+// sleep waits for duration or until context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// chatLimiter keeps minimal interval between messages to every chat
+type chatLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newChatLimiter(interval time.Duration) *chatLimiter {
+	return &chatLimiter{interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait until message may be sent to chat and reserve the slot
+func (l *chatLimiter) wait(ctx context.Context, chat string) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[chat]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[chat] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		return sleep(ctx, d)
+	}
+	return nil
+}
+
+// delay the next message to chat at least by d from now
+func (l *chatLimiter) delay(chat string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at := time.Now().Add(d); l.next[chat].Before(at) {
+		l.next[chat] = at
+	}
+}
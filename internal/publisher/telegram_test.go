@@ -0,0 +1,398 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/domain"
+)
+
+// fakeBotAPI replies to sendMessage with queued responses and records requests
+type fakeBotAPI struct {
+	*httptest.Server
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []tgMessage
+	times     []time.Time
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+func newFakeBotAPI(t *testing.T, responses ...fakeResponse) *fakeBotAPI {
+	t.Helper()
+	api := &fakeBotAPI{responses: responses}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if r.URL.Path != "/bottoken/sendMessage" {
+			http.NotFound(w, r)
+			return
+		}
+		var msg tgMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		api.requests = append(api.requests, msg)
+		api.times = append(api.times, time.Now())
+
+		resp := fakeResponse{status: http.StatusOK, body: fmt.Sprintf(`{"ok":true,"result":{"message_id":%d}}`, 100+len(api.requests))}
+		if len(api.responses) > 0 {
+			resp, api.responses = api.responses[0], api.responses[1:]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		_, _ = w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (f *fakeBotAPI) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.requests)
+}
+
+func newTestTelegram(t *testing.T, api *fakeBotAPI, opt TelegramOptions) *Telegram {
+	t.Helper()
+	opt.Token = "token"
+	opt.ChatID = "@channel"
+	opt.APIURL = api.URL
+	if opt.Backoff == 0 {
+		opt.Backoff = time.Millisecond
+	}
+	if opt.ChatInterval == 0 {
+		opt.ChatInterval = time.Millisecond
+	}
+	p, err := NewTelegram(&opt)
+	require.NoError(t, err)
+	return p
+}
+
+func TestTelegram_Publish(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{})
+
+	require.NoError(t, p.Publish(context.Background(), "*hearing*"))
+	require.Len(t, api.requests, 1)
+	require.Equal(t, tgMessage{
+		ChatID:         "@channel",
+		ParseMode:      "MarkdownV2",
+		Text:           "*hearing*",
+		DisablePreview: true,
+	}, api.requests[0])
+}
+
+func TestTelegram_PublishTo(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{ChatInterval: time.Hour})
+
+	// Interval is kept for every chat separately
+	require.NoError(t, p.PublishTo(context.Background(), "100", "reply"))
+	require.NoError(t, p.PublishTo(context.Background(), "200", "reply"))
+	require.Len(t, api.requests, 2)
+	require.Equal(t, "100", api.requests[0].ChatID)
+	require.Equal(t, "200", api.requests[1].ChatID)
+}
+
+func TestTelegram_Publish_formats(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{Format: FormatTelegramHTML})
+	html := `<b>ул. C:\Windows_*[1](2)*</b> &lt;зал&gt; <a href="https://ru.wikipedia.org/wiki/Брянск_(город)?q=\">ссылка</a>`
+	require.NoError(t, p.Publish(context.Background(), html))
+	require.Equal(t, tgMessage{ChatID: "@channel", ParseMode: "HTML", Text: html, DisablePreview: true}, api.requests[0])
+
+	api = newFakeBotAPI(t)
+	p = newTestTelegram(t, api, TelegramOptions{Format: FormatEntities})
+	text := domain.ParseTelegramHTML("<b>29.03.2022 в 11:00 в ул. C:\\Windows_*[1](2)* 🏛</b> состоятся публичные слушания " +
+		"о ~`>#+-=|{}.! и \\\n\n" + `<a href="https://ru.wikipedia.org/wiki/Брянск_(город)?q=\">Ссылка на публикацию</a>`)
+	message, err := json.Marshal(text)
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(context.Background(), string(message)))
+	require.Equal(t, tgMessage{
+		ChatID: "@channel", Text: text.Text, Entities: text.Entities, DisablePreview: true,
+	}, api.requests[0], "text with entities must be sent without parse mode")
+	require.True(t, IsPermanent(p.Publish(context.Background(), "*not json*")))
+	require.Equal(t, 1, api.count())
+
+	api = newFakeBotAPI(t)
+	p = newTestTelegram(t, api, TelegramOptions{Format: FormatText})
+	require.NoError(t, p.Publish(context.Background(), "*as is*"))
+	require.Equal(t, tgMessage{ChatID: "@channel", Text: "*as is*", DisablePreview: true}, api.requests[0])
+
+	_, err = NewTelegram(&TelegramOptions{Format: FormatJSON})
+	require.Error(t, err)
+}
+
+func TestTelegram_Send(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{MaxLength: 40})
+
+	require.NoError(t, p.Send(context.Background(), "100", TelegramMessage{Text: "<b>жирный текст и ещё текст</b> и <i>курсив</i>", ParseMode: ParseModeHTML}))
+	require.Len(t, api.requests, 2)
+	require.Equal(t, "<b>жирный текст и ещё текст</b> и", api.requests[0].Text)
+	require.Equal(t, "<i>курсив</i>", api.requests[1].Text)
+	require.Equal(t, "HTML", api.requests[1].ParseMode)
+
+	api = newFakeBotAPI(t)
+	p = newTestTelegram(t, api, TelegramOptions{MaxLength: 30})
+	entities := []domain.Entity{{Type: domain.EntityBold, Offset: 0, Length: 40}}
+	require.NoError(t, p.Send(context.Background(), "100", TelegramMessage{Text: strings.Repeat("слово ", 7), Entities: entities}))
+	require.Len(t, api.requests, 2)
+	require.Equal(t, []domain.Entity{{Type: domain.EntityBold, Offset: 0, Length: 29}}, api.requests[0].Entities)
+	require.Equal(t, []domain.Entity{{Type: domain.EntityBold, Offset: 0, Length: 10}}, api.requests[1].Entities)
+	require.Empty(t, api.requests[1].ParseMode)
+}
+
+func TestTelegram_Publish_silent(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{})
+	require.NoError(t, p.Publish(context.Background(), "text"))
+	require.NoError(t, p.Publish(WithoutNotification(context.Background()), "text"))
+	require.Len(t, api.requests, 2)
+	require.False(t, api.requests[0].DisableNotification)
+	require.True(t, api.requests[1].DisableNotification)
+}
+
+func TestTelegram_Send_buttons(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{MaxLength: 30})
+	buttons := [][]Button{{{Text: "Одобрить", Data: "approve:1"}, {Text: "Отклонить", Data: "reject:1"}}}
+	require.NoError(t, p.Send(context.Background(), "100", TelegramMessage{Text: strings.Repeat("слово ", 7), Buttons: buttons}))
+	require.Len(t, api.requests, 2)
+	require.Nil(t, api.requests[0].ReplyMarkup, "keyboard must be attached to the last part only")
+	require.NotNil(t, api.requests[1].ReplyMarkup)
+	require.Equal(t, buttons, api.requests[1].ReplyMarkup.InlineKeyboard)
+}
+
+func TestTelegram_callbacks(t *testing.T) {
+	var paths []string
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, body)
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	t.Cleanup(srv.Close)
+	p, err := NewTelegram(&TelegramOptions{Token: "token", APIURL: srv.URL})
+	require.NoError(t, err)
+
+	require.NoError(t, p.AnswerCallback(context.Background(), "42", "Одобрено"))
+	require.NoError(t, p.EditButtons(context.Background(), "100", 7, nil))
+	require.Equal(t, []string{"/bottoken/answerCallbackQuery", "/bottoken/editMessageReplyMarkup"}, paths)
+	require.Equal(t, map[string]interface{}{"callback_query_id": "42", "text": "Одобрено"}, bodies[0])
+	require.Equal(t, float64(7), bodies[1]["message_id"])
+	require.Equal(t, map[string]interface{}{"inline_keyboard": []interface{}{}}, bodies[1]["reply_markup"])
+}
+
+func TestTelegram_Publish_permanent(t *testing.T) {
+	api := newFakeBotAPI(t, fakeResponse{
+		status: http.StatusBadRequest,
+		body:   `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities: Character '.' is reserved"}`,
+	})
+	p := newTestTelegram(t, api, TelegramOptions{})
+
+	err := p.Publish(context.Background(), "bad.")
+	require.Error(t, err)
+	require.True(t, IsPermanent(err))
+	require.ErrorIs(t, err, ErrPermanent)
+	require.False(t, errors.Is(err, ErrTransient))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, 400, apiErr.Code)
+	require.Equal(t, "Bad Request: can't parse entities: Character '.' is reserved", apiErr.Description)
+	require.Equal(t, 1, api.count(), "permanent error must not be retried")
+
+	api = newFakeBotAPI(t, fakeResponse{
+		status: http.StatusBadRequest,
+		body:   `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`,
+	})
+	p = newTestTelegram(t, api, TelegramOptions{})
+	require.True(t, IsPermanent(p.Publish(context.Background(), "text")))
+}
+
+func TestTelegram_Publish_retry(t *testing.T) {
+	api := newFakeBotAPI(t,
+		fakeResponse{status: http.StatusInternalServerError, body: "internal error"},
+		fakeResponse{status: http.StatusBadGateway, body: `{"ok":false,"error_code":502,"description":"Bad Gateway"}`},
+	)
+	p := newTestTelegram(t, api, TelegramOptions{})
+	require.NoError(t, p.Publish(context.Background(), "text"))
+	require.Equal(t, 3, api.count())
+
+	api = newFakeBotAPI(t,
+		fakeResponse{status: http.StatusInternalServerError},
+		fakeResponse{status: http.StatusInternalServerError},
+		fakeResponse{status: http.StatusInternalServerError},
+	)
+	p = newTestTelegram(t, api, TelegramOptions{Retries: 2})
+	err := p.Publish(context.Background(), "text")
+	require.ErrorIs(t, err, ErrTransient)
+	require.False(t, IsPermanent(err))
+	require.Equal(t, 3, api.count(), "request must be retried twice")
+
+	api = newFakeBotAPI(t, fakeResponse{status: http.StatusInternalServerError})
+	p = newTestTelegram(t, api, TelegramOptions{Retries: -1})
+	require.ErrorIs(t, p.Publish(context.Background(), "text"), ErrTransient)
+	require.Equal(t, 1, api.count())
+}
+
+func TestTelegram_Publish_retryAfter(t *testing.T) {
+	api := newFakeBotAPI(t, fakeResponse{
+		status: http.StatusTooManyRequests,
+		body:   `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+	})
+	p := newTestTelegram(t, api, TelegramOptions{})
+
+	require.NoError(t, p.Publish(context.Background(), "text"))
+	require.Equal(t, 2, api.count())
+	require.GreaterOrEqual(t, api.times[1].Sub(api.times[0]), 900*time.Millisecond, "retry_after must be respected")
+
+	api = newFakeBotAPI(t, fakeResponse{
+		status: http.StatusTooManyRequests,
+		body:   `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`,
+	})
+	p = newTestTelegram(t, api, TelegramOptions{Retries: -1})
+	err := p.Publish(context.Background(), "text")
+	require.ErrorIs(t, err, ErrTransient)
+	require.Equal(t, 30*time.Second, RetryAfter(err))
+
+	// The chat is limited after rate limit error
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.Publish(ctx, "text"), context.DeadlineExceeded)
+	require.Equal(t, 1, api.count())
+}
+
+func TestTelegram_Publish_chatInterval(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{ChatInterval: 100 * time.Millisecond})
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			errs <- p.Publish(context.Background(), "text")
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	require.Len(t, api.times, 3)
+	first, last := api.times[0], api.times[0]
+	for _, tm := range api.times {
+		if tm.Before(first) {
+			first = tm
+		}
+		if tm.After(last) {
+			last = tm
+		}
+	}
+	require.GreaterOrEqual(t, last.Sub(first), 190*time.Millisecond, "messages to chat must be spaced")
+}
+
+func TestTelegram_Publish_split(t *testing.T) {
+	api := newFakeBotAPI(t)
+	p := newTestTelegram(t, api, TelegramOptions{MaxLength: 100})
+
+	paragraphs := []string{
+		"*" + strings.Repeat("заголовок ", 5) + "*",
+		strings.Repeat("тема\\. ", 12),
+		strings.Repeat("предложения ", 7),
+		"[Ссылка на публикацию](https://bga32.ru/1/)",
+	}
+	require.NoError(t, p.Publish(context.Background(), strings.Join(paragraphs, "\n\n")))
+	require.Len(t, api.requests, 4)
+	for i, r := range api.requests {
+		require.Equal(t, strings.TrimSpace(paragraphs[i]), r.Text, "message must be split on paragraphs")
+	}
+	require.Zero(t, api.requests[0].ReplyToMessageID)
+	require.False(t, api.requests[0].AllowSendingWithoutReply)
+	require.Equal(t, 101, api.requests[1].ReplyToMessageID, "continuation must reply to the previous part")
+	require.True(t, api.requests[1].AllowSendingWithoutReply)
+	require.Equal(t, 103, api.requests[3].ReplyToMessageID)
+
+	// Failed continuation fails publishing
+	api = newFakeBotAPI(t,
+		fakeResponse{status: http.StatusOK, body: `{"ok":true,"result":{"message_id":7}}`},
+		fakeResponse{status: http.StatusBadRequest, body: `{"ok":false,"error_code":400,"description":"Bad Request: message is too long"}`},
+	)
+	p = newTestTelegram(t, api, TelegramOptions{MaxLength: 100})
+	err := p.Publish(context.Background(), strings.Join(paragraphs, "\n\n"))
+	require.True(t, IsPermanent(err))
+	require.Equal(t, 2, api.count())
+	progress, ok := SentProgress(err)
+	require.True(t, ok, "sent parts must be reported")
+	require.Equal(t, Progress{Parts: 1, MessageID: 7}, progress)
+}
+
+func TestTelegram_Resume(t *testing.T) {
+	paragraphs := []string{
+		"*" + strings.Repeat("заголовок ", 5) + "*",
+		strings.Repeat("тема\\. ", 12),
+		strings.Repeat("предложения ", 7),
+		"[Ссылка на публикацию](https://bga32.ru/1/)",
+	}
+	message := strings.Join(paragraphs, "\n\n")
+	api := newFakeBotAPI(t,
+		fakeResponse{status: http.StatusOK, body: `{"ok":true,"result":{"message_id":7}}`},
+		fakeResponse{status: http.StatusOK, body: `{"ok":true,"result":{"message_id":8}}`},
+		fakeResponse{status: http.StatusInternalServerError},
+	)
+	p := newTestTelegram(t, api, TelegramOptions{MaxLength: 100, Retries: -1})
+	err := p.Publish(context.Background(), message)
+	require.ErrorIs(t, err, ErrTransient)
+	progress, ok := SentProgress(err)
+	require.True(t, ok)
+	require.Equal(t, Progress{Parts: 2, MessageID: 8}, progress)
+
+	api = newFakeBotAPI(t)
+	p = newTestTelegram(t, api, TelegramOptions{MaxLength: 100})
+	require.NoError(t, p.Resume(context.Background(), message, progress))
+	require.Len(t, api.requests, 2, "sent parts must not be sent again")
+	require.Equal(t, strings.TrimSpace(paragraphs[2]), api.requests[0].Text)
+	require.Equal(t, 8, api.requests[0].ReplyToMessageID, "resumed part must reply to the last sent part")
+	require.Equal(t, 101, api.requests[1].ReplyToMessageID)
+
+	// Failure of the first part is not partial
+	api = newFakeBotAPI(t, fakeResponse{status: http.StatusInternalServerError})
+	p = newTestTelegram(t, api, TelegramOptions{MaxLength: 100, Retries: -1})
+	_, ok = SentProgress(p.Publish(context.Background(), message))
+	require.False(t, ok)
+}
+
+func TestTelegram_Publish_skip(t *testing.T) {
+	p, err := NewTelegram(&TelegramOptions{})
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(context.Background(), "text"))
+}
@@ -0,0 +1,125 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Settings of VK API
+const (
+	// DefaultVKURL is base URL of VK API
+	DefaultVKURL = "https://api.vk.com"
+	// VKVersion is version of VK API used for requests
+	VKVersion = "5.131"
+)
+
+// Transient error codes of VK API: unknown error, too many requests per second, flood control and internal error
+var vkTransientCodes = map[int]bool{1: true, 6: true, 9: true, 10: true}
+
+// VK publishes messages as posts on wall of VK community
+type VK struct {
+	logger *zerolog.Logger
+	client *http.Client
+	url    string
+	token  string
+	owner  string
+}
+
+// VKOptions for creating a new VK publisher
+type VKOptions struct {
+	Logger *zerolog.Logger
+	// APIURL is base URL of VK API. DefaultVKURL is used if empty.
+	APIURL string
+	// Token is access token of community with wall permission
+	Token string
+	// OwnerID of wall. Identifier of community is negative, for example -123456.
+	OwnerID string
+	// HTTPClient sends requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+type vkResponse struct {
+	Error *struct {
+		Code    int    `json:"error_code"`
+		Message string `json:"error_msg"`
+	} `json:"error"`
+}
+
+// NewVK returns publisher to wall of VK community
+func NewVK(opt *VKOptions) (*VK, error) {
+	switch {
+	case opt.Token == "":
+		return nil, required("vk", "token")
+	case opt.OwnerID == "":
+		return nil, required("vk", "owner_id")
+	}
+	apiURL := strings.TrimSuffix(opt.APIURL, "/")
+	if apiURL == "" {
+		apiURL = DefaultVKURL
+	}
+	return &VK{
+		logger: newLogger(opt.Logger, "vk"),
+		client: opt.HTTPClient,
+		url:    apiURL + "/method/wall.post",
+		token:  opt.Token,
+		owner:  opt.OwnerID,
+	}, nil
+}
+
+// Publish message as post on wall of community.
+// VK API returns errors with status 200, so error of response is checked.
+func (p VK) Publish(ctx context.Context, message string) error {
+	form := url.Values{
+		"owner_id":     {p.owner},
+		"from_group":   {"1"},
+		"message":      {message},
+		"access_token": {p.token},
+		"v":            {VKVersion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := do(p.client, req)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("failed to publish post")
+		return err
+	}
+	var resp vkResponse
+	if err = json.Unmarshal(response, &resp); err != nil {
+		return transientError{err}
+	}
+	if resp.Error != nil {
+		err = &APIError{
+			StatusCode:  http.StatusOK,
+			Code:        resp.Error.Code,
+			Description: resp.Error.Message,
+			Transient:   vkTransientCodes[resp.Error.Code],
+		}
+		p.logger.Error().Err(err).Msg("failed to publish post")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVK_Publish(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeResponse{status: http.StatusOK, body: `{"response":{"post_id":1}}`},
+		fakeResponse{status: http.StatusOK, body: `{"error":{"error_code":6,"error_msg":"Too many requests per second"}}`},
+		fakeResponse{status: http.StatusOK, body: `{"error":{"error_code":15,"error_msg":"Access denied"}}`},
+		fakeResponse{status: http.StatusBadGateway},
+	)
+	p, err := NewVK(&VKOptions{APIURL: srv.URL, Token: "secret", OwnerID: "-123"})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, "Публичные слушания"))
+	require.ErrorIs(t, p.Publish(ctx, "text"), ErrTransient)
+	err = p.Publish(ctx, "text")
+	require.True(t, IsPermanent(err))
+	require.EqualError(t, err, "api error 15: Access denied")
+	require.ErrorIs(t, p.Publish(ctx, "text"), ErrTransient)
+
+	requests := srv.received()
+	require.Len(t, requests, 4)
+	require.Equal(t, "/method/wall.post", requests[0].Path)
+	form, err := url.ParseQuery(requests[0].Body)
+	require.NoError(t, err)
+	require.Equal(t, url.Values{
+		"owner_id":     {"-123"},
+		"from_group":   {"1"},
+		"message":      {"Публичные слушания"},
+		"access_token": {"secret"},
+		"v":            {VKVersion},
+	}, form)
+}
@@ -0,0 +1,110 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// SignatureHeader contains HMAC-SHA256 signature of body of webhook request in form "sha256=<hex>"
+const SignatureHeader = "X-Mercury-Signature"
+
+// Webhook publishes messages with JSON POST requests
+type Webhook struct {
+	logger  *zerolog.Logger
+	client  *http.Client
+	url     string
+	headers map[string]string
+	secret  string
+}
+
+// WebhookOptions for creating a new webhook publisher
+type WebhookOptions struct {
+	Logger *zerolog.Logger
+	// URL receives POST requests
+	URL string
+	// Headers are added to every request, for example for authorization
+	Headers map[string]string
+	// Secret signs body of requests with HMAC-SHA256 in SignatureHeader. Requests are not signed if empty.
+	Secret string
+	// HTTPClient sends requests. http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// webhookMessage is body of request for messages which are not JSON objects
+type webhookMessage struct {
+	Text string `json:"text"`
+}
+
+// NewWebhook returns publisher to webhook
+func NewWebhook(opt *WebhookOptions) (*Webhook, error) {
+	if opt.URL == "" {
+		return nil, required("webhook", "url")
+	}
+	return &Webhook{
+		logger:  newLogger(opt.Logger, "webhook"),
+		client:  opt.HTTPClient,
+		url:     opt.URL,
+		headers: opt.Headers,
+		secret:  opt.Secret,
+	}, nil
+}
+
+// Publish message. JSON object is sent as is, other messages are sent as {"text": message}.
+// Client errors of webhook are permanent except timeouts and rate limits.
+func (p Webhook) Publish(ctx context.Context, message string) error {
+	body := []byte(message)
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(body, &obj) != nil {
+		var err error
+		if body, err = json.Marshal(webhookMessage{Text: message}); err != nil {
+			return fmt.Errorf("%w: %s", ErrPermanent, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPermanent, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	if p.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(p.secret, body))
+	}
+	if _, err = do(p.client, req); err != nil {
+		p.logger.Error().Err(err).Msg("failed to publish message")
+		return err
+	}
+	return nil
+}
+
+// Sign returns value of SignatureHeader for body signed by secret.
+// Receiver of webhook computes the same value and compares it with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
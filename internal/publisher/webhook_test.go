@@ -0,0 +1,71 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package publisher
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook_Publish(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeResponse{status: http.StatusNoContent},
+		fakeResponse{status: http.StatusOK},
+		fakeResponse{status: http.StatusBadRequest, body: "bad payload"},
+		fakeResponse{status: http.StatusServiceUnavailable},
+	)
+	p, err := NewWebhook(&WebhookOptions{URL: srv.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer secret"}})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, p.Publish(ctx, `{"event":"published","hearing":{"id":"1"}}`))
+	require.NoError(t, p.Publish(ctx, "Публичные слушания \"1\""))
+
+	err = p.Publish(ctx, "bad")
+	require.True(t, IsPermanent(err))
+	require.EqualError(t, err, "api error 400: bad payload")
+	err = p.Publish(ctx, "later")
+	require.ErrorIs(t, err, ErrTransient)
+
+	requests := srv.received()
+	require.Len(t, requests, 4)
+	require.Equal(t, http.MethodPost, requests[0].Method)
+	require.Equal(t, "/hook", requests[0].Path)
+	require.Equal(t, "Bearer secret", requests[0].Header.Get("Authorization"))
+	require.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+	require.Equal(t, `{"event":"published","hearing":{"id":"1"}}`, requests[0].Body)
+	require.JSONEq(t, `{"text":"Публичные слушания \"1\""}`, requests[1].Body)
+
+	require.Empty(t, requests[0].Header.Get(SignatureHeader), "request without secret must not be signed")
+
+	_, err = NewWebhook(&WebhookOptions{})
+	require.EqualError(t, err, "webhook publisher: url is required")
+}
+
+func TestWebhook_Publish_signed(t *testing.T) {
+	srv := newFakeServer(t)
+	p, err := NewWebhook(&WebhookOptions{URL: srv.URL, Secret: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, p.Publish(context.Background(), `{"id":"1"}`))
+
+	requests := srv.received()
+	require.Len(t, requests, 1)
+	// echo -n '{"id":"1"}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t, "sha256=6146142a2ce0159e84c0767881e4ec80bc397da62526e7d19f70795eb79460c0", requests[0].Header.Get(SignatureHeader))
+	require.Equal(t, Sign("secret", []byte(requests[0].Body)), requests[0].Header.Get(SignatureHeader))
+}
@@ -0,0 +1,181 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package render renders messages about hearings from templates.
+// Templates are chosen by format of channel and event, and may be overridden for single channel.
+package render
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+// Events of messages. Template of event is file <event>.tmpl.
+const (
+	EventNew       = "new"
+	EventChanged   = "changed"
+	EventCancelled = "cancelled"
+	EventReminder  = "reminder"
+	// EventSummary is short markdown or HTML of Telegram which is published instead of too long full text
+	EventSummary = "summary"
+)
+
+// ErrNoTemplate is returned if there is no template for format and event
+var ErrNoTemplate = errors.New("no template")
+
+//go:embed templates
+var builtin embed.FS
+
+// Formats are formats with built-in templates
+var Formats = []string{
+	publisher.FormatText, publisher.FormatMarkdown, publisher.FormatHTML,
+	publisher.FormatEmail, publisher.FormatMastodon, publisher.FormatTelegramHTML,
+}
+
+// Data of templates
+type Data struct {
+	Hearing domain.Hearing
+	// Text of reminder
+	Text string
+}
+
+// funcs are helpers available in templates
+var funcs = template.FuncMap{
+	"md":    domain.EscapeMarkdown,
+	"mdurl": domain.EscapeMarkdownURL,
+	"html":  html.EscapeString,
+	"br":    func(s string) string { return strings.ReplaceAll(s, "\n", "<br>\n") },
+	"date":  func(t time.Time) string { return t.Format("02.01.2006") },
+	"time":  func(t time.Time) string { return t.Format("15:04") },
+	// plural returns russian form of word for number: plural 3 "вопрос" "вопроса" "вопросов"
+	"plural": domain.Plural,
+	// truncate cuts text to limit of characters on word boundary: truncate 300 .Place
+	"truncate": func(limit int, s string) string { return domain.Truncate(s, limit) },
+	"add":      func(a, b int) int { return a + b },
+	"sub":      func(a, b int) int { return a - b },
+}
+
+// std renders fallbacks with built-in templates
+var std = New()
+
+// Text returns plain text of new hearing rendered from built-in templates.
+// It is a fallback for messages whose templates fail.
+func Text(h domain.Hearing) string {
+	// Built-in templates are checked by tests
+	text, _ := std.Render("", publisher.FormatText, EventNew, Data{Hearing: h})
+	return text
+}
+
+// Renderer renders messages from templates
+type Renderer struct {
+	formats  map[string]*template.Template
+	channels map[string]map[string]*template.Template
+}
+
+// New returns renderer with built-in templates
+func New() *Renderer {
+	r := &Renderer{
+		formats:  make(map[string]*template.Template, len(Formats)),
+		channels: make(map[string]map[string]*template.Template),
+	}
+	for _, f := range Formats {
+		// Built-in templates are checked by tests
+		r.formats[f] = template.Must(template.New(f).Funcs(funcs).ParseFS(builtin, "templates/"+f+"/*.tmpl"))
+	}
+	return r
+}
+
+// Load returns renderer with built-in templates overridden by templates from directory.
+// Files dir/<format>/<event>.tmpl override templates of format,
+// files dir/channels/<channel>/<event>.tmpl override templates of channel in any format.
+// Templates which are not overridden stay built-in.
+func Load(dir string) (*Renderer, error) {
+	r := New()
+	for _, f := range Formats {
+		t, err := override(r.formats[f], filepath.Join(dir, f))
+		if err != nil {
+			return nil, fmt.Errorf("failed load templates of format %s: %w", f, err)
+		}
+		r.formats[f] = t
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "channels"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed load templates of channels: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		// Templates of channel are compiled for every format, so errors are found on start
+		sets := make(map[string]*template.Template, len(Formats))
+		for _, f := range Formats {
+			t, err := override(r.formats[f], filepath.Join(dir, "channels", e.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed load templates of channel %s: %w", e.Name(), err)
+			}
+			sets[f] = t
+		}
+		r.channels[e.Name()] = sets
+	}
+	return r, nil
+}
+
+// override returns copy of templates with templates from directory. Missing directory is not an error.
+func override(base *template.Template, dir string) (*template.Template, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	t, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return t, nil
+	}
+	return t.ParseFiles(files...)
+}
+
+// Render returns message of event in format. Templates of channel are used if they are loaded.
+func (r *Renderer) Render(channel, format, event string, data Data) (string, error) {
+	t, ok := r.channels[channel][format]
+	if !ok {
+		t, ok = r.formats[format]
+	}
+	if !ok {
+		return "", fmt.Errorf("%w: unknown format %q", ErrNoTemplate, format)
+	}
+	tmpl := t.Lookup(event + ".tmpl")
+	if tmpl == nil {
+		return "", fmt.Errorf("%w: event %q in format %q", ErrNoTemplate, event, format)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
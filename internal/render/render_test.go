@@ -0,0 +1,374 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+func testHearings() []domain.Hearing {
+	h := domain.Hearing{
+		Topic:     []string{"по проекту планировки территории (ул. Ленина, 1)", "по проекту межевания"},
+		Proposals: []string{"Предложения принимаются до 5 марта [по адресу] ул. Калинина, 66!"},
+		Place:     "актовом зале <администрации> & по адресу: ул. Гагарина, 12",
+		URL:       "https://bga32.ru/hearing?id=1&x=2",
+		Time:      time.Date(2022, time.March, 17, 11, 0, 0, 0, time.UTC),
+	}
+	single := h
+	single.Topic = []string{"о внесении изменений в правила землепользования_и_застройки"}
+	single.Status = domain.StatusCancelled
+	postponed := h
+	postponed.Topic = []string{"", "о сносе"}
+	postponed.Status = domain.StatusPostponed
+	return []domain.Hearing{h, single, postponed, {}}
+}
+
+func TestRenderer_markdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		hearing domain.Hearing
+		want    string
+	}{
+		{name: "empty", hearing: domain.Hearing{}, want: ""},
+		{
+			name: "single topic",
+			hearing: domain.Hearing{
+				Time:  time.Date(2022, time.March, 29, 11, 0, 0, 0, time.Local),
+				Place: "г.Брянск, ул. Клинцовская, д. 60 (здание Городского Дворца культуры им. Д.Н. Медведева)",
+				Topic: []string{
+					"по проекту Постановления Брянской городской администрации «О предоставлении (об отказе в предоставлении) разрешений на условно разрешенный вид использования земельных участков, отклонение от предельных параметров разрешенного строительства» (далее по тексту — проект Постановления)., назначенные постановлением главы города Брянска №1151-пг от 03.03.2022 г.",
+				},
+				Proposals: []string{
+					"Приём предложений от участников публичных слушаний, прошедших идентификацию по проекту Постановления, осуществляет оргкомитет до 28 марта 2022 года по адресу: г. Брянск, проспект Ленина, д. 28, каб. №204, в рабочие дни с 14:00 до 16:30, а 29 марта 2022 года по адресу: ул. Клинцовская, д. 60 (здание МБУК «Городской Дворец культуры им. Д.Н. Медведева») в ходе проведения публичных слушаний",
+					"Приём заявлений на участие в публичных слушаниях по проекту Постановления также осуществляет оргкомитет до 28 марта 2022 года по адресу: г. Брянск, проспект Ленина, д. 28, каб. №204, в рабочие дни с 14:00 до 16:30.",
+				},
+				URL: "https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-29-marta-2022-goda/",
+			},
+			want: "*29\\.03\\.2022 в 11:00 в г\\.Брянск, ул\\. Клинцовская, д\\. 60 \\(здание Городского Дворца культуры им\\. Д\\.Н\\. Медведева\\)* состоятся публичные слушания по проекту Постановления Брянской городской администрации «О предоставлении \\(об отказе в предоставлении\\) разрешений на условно разрешенный вид использования земельных участков, отклонение от предельных параметров разрешенного строительства» \\(далее по тексту — проект Постановления\\)\\., назначенные постановлением главы города Брянска №1151\\-пг от 03\\.03\\.2022 г\\.\n\nПриём предложений от участников публичных слушаний, прошедших идентификацию по проекту Постановления, осуществляет оргкомитет до 28 марта 2022 года по адресу: г\\. Брянск, проспект Ленина, д\\. 28, каб\\. №204, в рабочие дни с 14:00 до 16:30, а 29 марта 2022 года по адресу: ул\\. Клинцовская, д\\. 60 \\(здание МБУК «Городской Дворец культуры им\\. Д\\.Н\\. Медведева»\\) в ходе проведения публичных слушаний\n\nПриём заявлений на участие в публичных слушаниях по проекту Постановления также осуществляет оргкомитет до 28 марта 2022 года по адресу: г\\. Брянск, проспект Ленина, д\\. 28, каб\\. №204, в рабочие дни с 14:00 до 16:30\\.\n\n[Ссылка на публикацию](https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-29-marta-2022-goda/)\n",
+		},
+		{
+			name: "multi topics",
+			hearing: domain.Hearing{
+				Time:  time.Date(2021, time.March, 17, 11, 0, 0, 0, time.Local),
+				Place: "ГДК Советского района (ул. Калинина, д. 66)",
+				Topic: []string{
+					"по проекту планировки территории, ограниченной кольцевым пересечением в районе железнодорожного вокзала Брянск-1 территорией железнодорожного вокзала Брянск-1, руслом реки Десна и дома №19 по улице Речной в Володарском районе города Брянска",
+					"по проекту внесения изменений в проект планировки и проект межевания территории, ограниченной улицами Бежицкой, Горбатова, жилой улицей № 4 в Советском районе города Брянска, в целях многоэтажного жилищного строительства в части земельных участков с кадастровыми номерами 32:28:0030902:1228, 32:28:0030902:1224, утверждённый постановлением Брянской городской администрации от 12.08.2014 №2208-п",
+					"по проекту планировки, содержащему проект межевания, территории по ул. Фосфоритной, д.1 в Володарском районе города Брянска",
+				},
+				Proposals: []string{
+					"Приём предложений от участников публичных слушаний, прошедших идентификацию по проекту Решения будет осуществлять оргкомитет до 16 марта 2021 года (включительно) по адресу: город Брянск, пр-т Ленина, д. 28, каб. №208, в рабочие дни с 14:00 до 16:30, и 17 марта 2021 года по адресу: город Брянск, улица Калинина, 66 (здание МБУК «Городской Дом культуры Советского района») в ходе проведения публичных слушаний.",
+					"Приём заявлений на участие в публичных слушаниях по проекту Решения также осуществляет оргкомитет до 16 марта 2021 года (включительно) по адресу: пр-т Ленина, д. 28, каб. №208, в рабочие дни с 14.00 до 16.30.",
+				},
+				URL: "https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-17-marta-2021-goda/",
+			},
+			want: "*17\\.03\\.2021 в 11:00 в ГДК Советского района \\(ул\\. Калинина, д\\. 66\\)* состоятся публичные слушания:\n\n \\- по проекту планировки территории, ограниченной кольцевым пересечением в районе железнодорожного вокзала Брянск\\-1 территорией железнодорожного вокзала Брянск\\-1, руслом реки Десна и дома №19 по улице Речной в Володарском районе города Брянска\n\n \\- по проекту внесения изменений в проект планировки и проект межевания территории, ограниченной улицами Бежицкой, Горбатова, жилой улицей № 4 в Советском районе города Брянска, в целях многоэтажного жилищного строительства в части земельных участков с кадастровыми номерами 32:28:0030902:1228, 32:28:0030902:1224, утверждённый постановлением Брянской городской администрации от 12\\.08\\.2014 №2208\\-п\n\n \\- по проекту планировки, содержащему проект межевания, территории по ул\\. Фосфоритной, д\\.1 в Володарском районе города Брянска\n\nПриём предложений от участников публичных слушаний, прошедших идентификацию по проекту Решения будет осуществлять оргкомитет до 16 марта 2021 года \\(включительно\\) по адресу: город Брянск, пр\\-т Ленина, д\\. 28, каб\\. №208, в рабочие дни с 14:00 до 16:30, и 17 марта 2021 года по адресу: город Брянск, улица Калинина, 66 \\(здание МБУК «Городской Дом культуры Советского района»\\) в ходе проведения публичных слушаний\\.\n\nПриём заявлений на участие в публичных слушаниях по проекту Решения также осуществляет оргкомитет до 16 марта 2021 года \\(включительно\\) по адресу: пр\\-т Ленина, д\\. 28, каб\\. №208, в рабочие дни с 14\\.00 до 16\\.30\\.\n\n[Ссылка на публикацию](https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-17-marta-2021-goda/)\n",
+		},
+		{
+			name: "nasty characters",
+			hearing: domain.Hearing{
+				Time:  time.Date(2022, time.March, 29, 11, 0, 0, 0, time.UTC),
+				Place: `ул. C:\Windows_*[1](2)*`,
+				Topic: []string{"о ~`>#+-=|{}.! и \\"},
+				URL:   `https://ru.wikipedia.org/wiki/Брянск_(город)?q=\`,
+			},
+			want: "*29\\.03\\.2022 в 11:00 в ул\\. C:\\\\Windows\\_\\*\\[1\\]\\(2\\)\\** " +
+				"состоятся публичные слушания о \\~\\`\\>\\#\\+\\-\\=\\|\\{\\}\\.\\! и \\\\\n\n" +
+				"[Ссылка на публикацию](https://ru.wikipedia.org/wiki/Брянск_(город\\)?q=\\\\)\n",
+		},
+		{
+			name:    "postponed",
+			hearing: testHearings()[2],
+			want: "*Публичные слушания перенесены*\n\n*17\\.03\\.2022 в 11:00 в актовом зале <администрации\\> & по адресу: ул\\. Гагарина, 12* " +
+				"состоятся публичные слушания:\n\n \\- \n\n \\- о сносе\n\nПредложения принимаются до 5 марта \\[по адресу\\] ул\\. Калинина, 66\\!\n\n" +
+				"[Ссылка на публикацию](https://bga32.ru/hearing?id=1&x=2)\n",
+		},
+	}
+	r := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, event := range []string{EventNew, EventChanged, EventCancelled} {
+				msg, err := r.Render("telegram", publisher.FormatMarkdown, event, Data{Hearing: tt.hearing})
+				require.NoError(t, err)
+				require.Equal(t, tt.want, msg, event)
+			}
+		})
+	}
+}
+
+func TestRenderer_text(t *testing.T) {
+	tests := []struct {
+		name    string
+		hearing domain.Hearing
+		want    string
+	}{
+		{name: "empty", hearing: domain.Hearing{}, want: ""},
+		{
+			name: "single topic",
+			hearing: domain.Hearing{
+				Time:  time.Date(2022, time.March, 29, 11, 0, 0, 0, time.Local),
+				Place: "г.Брянск, ул. Клинцовская, д. 60 (здание Городского Дворца культуры им. Д.Н. Медведева)",
+				Topic: []string{
+					"по проекту Постановления Брянской городской администрации «О предоставлении (об отказе в предоставлении) разрешений на условно разрешенный вид использования земельных участков, отклонение от предельных параметров разрешенного строительства» (далее по тексту — проект Постановления)., назначенные постановлением главы города Брянска №1151-пг от 03.03.2022 г.",
+				},
+				Proposals: []string{
+					"Приём предложений от участников публичных слушаний, прошедших идентификацию по проекту Постановления, осуществляет оргкомитет до 28 марта 2022 года по адресу: г. Брянск, проспект Ленина, д. 28, каб. №204, в рабочие дни с 14:00 до 16:30, а 29 марта 2022 года по адресу: ул. Клинцовская, д. 60 (здание МБУК «Городской Дворец культуры им. Д.Н. Медведева») в ходе проведения публичных слушаний",
+					"Приём заявлений на участие в публичных слушаниях по проекту Постановления также осуществляет оргкомитет до 28 марта 2022 года по адресу: г. Брянск, проспект Ленина, д. 28, каб. №204, в рабочие дни с 14:00 до 16:30.",
+				},
+				URL: "https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-29-marta-2022-goda/",
+			},
+			want: "29.03.2022 в 11:00 в г.Брянск, ул. Клинцовская, д. 60 (здание Городского Дворца культуры им. Д.Н. Медведева) состоятся публичные слушания по проекту Постановления Брянской городской администрации «О предоставлении (об отказе в предоставлении) разрешений на условно разрешенный вид использования земельных участков, отклонение от предельных параметров разрешенного строительства» (далее по тексту — проект Постановления)., назначенные постановлением главы города Брянска №1151-пг от 03.03.2022 г.\nПриём предложений от участников публичных слушаний, прошедших идентификацию по проекту Постановления, осуществляет оргкомитет до 28 марта 2022 года по адресу: г. Брянск, проспект Ленина, д. 28, каб. №204, в рабочие дни с 14:00 до 16:30, а 29 марта 2022 года по адресу: ул. Клинцовская, д. 60 (здание МБУК «Городской Дворец культуры им. Д.Н. Медведева») в ходе проведения публичных слушаний\nПриём заявлений на участие в публичных слушаниях по проекту Постановления также осуществляет оргкомитет до 28 марта 2022 года по адресу: г. Брянск, проспект Ленина, д. 28, каб. №204, в рабочие дни с 14:00 до 16:30.\nСсылка на публикацию: https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-29-marta-2022-goda/\n",
+		},
+		{
+			name: "multi topics",
+			hearing: domain.Hearing{
+				Time:  time.Date(2021, time.March, 17, 11, 0, 0, 0, time.Local),
+				Place: "ГДК Советского района (ул. Калинина, д. 66)",
+				Topic: []string{
+					"по проекту планировки территории, ограниченной кольцевым пересечением в районе железнодорожного вокзала Брянск-1 территорией железнодорожного вокзала Брянск-1, руслом реки Десна и дома №19 по улице Речной в Володарском районе города Брянска",
+					"по проекту внесения изменений в проект планировки и проект межевания территории, ограниченной улицами Бежицкой, Горбатова, жилой улицей № 4 в Советском районе города Брянска, в целях многоэтажного жилищного строительства в части земельных участков с кадастровыми номерами 32:28:0030902:1228, 32:28:0030902:1224, утверждённый постановлением Брянской городской администрации от 12.08.2014 №2208-п",
+					"по проекту планировки, содержащему проект межевания, территории по ул. Фосфоритной, д.1 в Володарском районе города Брянска",
+				},
+				Proposals: []string{
+					"Приём предложений от участников публичных слушаний, прошедших идентификацию по проекту Решения будет осуществлять оргкомитет до 16 марта 2021 года (включительно) по адресу: город Брянск, пр-т Ленина, д. 28, каб. №208, в рабочие дни с 14:00 до 16:30, и 17 марта 2021 года по адресу: город Брянск, улица Калинина, 66 (здание МБУК «Городской Дом культуры Советского района») в ходе проведения публичных слушаний.",
+					"Приём заявлений на участие в публичных слушаниях по проекту Решения также осуществляет оргкомитет до 16 марта 2021 года (включительно) по адресу: пр-т Ленина, д. 28, каб. №208, в рабочие дни с 14.00 до 16.30.",
+				},
+				URL: "https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-17-marta-2021-goda/",
+			},
+			want: "17.03.2021 в 11:00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания:\n - по проекту планировки территории, ограниченной кольцевым пересечением в районе железнодорожного вокзала Брянск-1 территорией железнодорожного вокзала Брянск-1, руслом реки Десна и дома №19 по улице Речной в Володарском районе города Брянска\n - по проекту внесения изменений в проект планировки и проект межевания территории, ограниченной улицами Бежицкой, Горбатова, жилой улицей № 4 в Советском районе города Брянска, в целях многоэтажного жилищного строительства в части земельных участков с кадастровыми номерами 32:28:0030902:1228, 32:28:0030902:1224, утверждённый постановлением Брянской городской администрации от 12.08.2014 №2208-п\n - по проекту планировки, содержащему проект межевания, территории по ул. Фосфоритной, д.1 в Володарском районе города Брянска\nПриём предложений от участников публичных слушаний, прошедших идентификацию по проекту Решения будет осуществлять оргкомитет до 16 марта 2021 года (включительно) по адресу: город Брянск, пр-т Ленина, д. 28, каб. №208, в рабочие дни с 14:00 до 16:30, и 17 марта 2021 года по адресу: город Брянск, улица Калинина, 66 (здание МБУК «Городской Дом культуры Советского района») в ходе проведения публичных слушаний.\nПриём заявлений на участие в публичных слушаниях по проекту Решения также осуществляет оргкомитет до 16 марта 2021 года (включительно) по адресу: пр-т Ленина, д. 28, каб. №208, в рабочие дни с 14.00 до 16.30.\nСсылка на публикацию: https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-17-marta-2021-goda/\n",
+		},
+		{
+			name:    "postponed",
+			hearing: testHearings()[2],
+			want: "Публичные слушания перенесены\n17.03.2022 в 11:00 в актовом зале <администрации> & по адресу: ул. Гагарина, 12 " +
+				"состоятся публичные слушания:\n - о сносе\nПредложения принимаются до 5 марта [по адресу] ул. Калинина, 66!\n" +
+				"Ссылка на публикацию: https://bga32.ru/hearing?id=1&x=2\n",
+		},
+	}
+	r := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, event := range []string{EventNew, EventChanged, EventCancelled} {
+				msg, err := r.Render("telegram", publisher.FormatText, event, Data{Hearing: tt.hearing})
+				require.NoError(t, err)
+				require.Equal(t, tt.want, msg, event)
+			}
+		})
+	}
+}
+
+func TestRenderer_html(t *testing.T) {
+	r := New()
+	msg, err := r.Render("site", publisher.FormatHTML, EventNew, Data{})
+	require.NoError(t, err)
+	require.Empty(t, msg)
+
+	h := domain.Hearing{
+		Time:      time.Date(2021, time.March, 17, 11, 0, 0, 0, time.UTC),
+		Place:     "ГДК <Советского> района",
+		Topic:     []string{"по проекту «А» & «Б»", "по проекту планировки"},
+		Proposals: []string{"Приём предложений до 16.03.2021"},
+		URL:       "https://bga32.ru/?a=1&b=2",
+		Status:    domain.StatusCancelled,
+	}
+	msg, err = r.Render("site", publisher.FormatHTML, EventCancelled, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, "<p><b>Публичные слушания отменены</b></p>\n"+
+		"<p><b>17.03.2021 в 11:00 в ГДК &lt;Советского&gt; района</b> состоятся публичные слушания:</p>\n"+
+		"<ul>\n<li>по проекту «А» &amp; «Б»</li>\n<li>по проекту планировки</li>\n</ul>\n"+
+		"<p>Приём предложений до 16.03.2021</p>\n"+
+		"<p><a href=\"https://bga32.ru/?a=1&amp;b=2\">Ссылка на публикацию</a></p>\n", msg)
+}
+
+func TestRenderer_summary(t *testing.T) {
+	r := New()
+	msg, err := r.Render("telegram", publisher.FormatMarkdown, EventSummary, Data{Hearing: testHearings()[0]})
+	require.NoError(t, err)
+	require.Equal(t, "*17\\.03\\.2022 в 11:00 в актовом зале <администрации\\> & по адресу: ул\\. Гагарина, 12* "+
+		"состоятся публичные слушания по проекту планировки территории \\(ул\\. Ленина, 1\\)\n\nи ещё 1 вопрос\n\n"+
+		"Полный текст и порядок приёма предложений в [публикации](https://bga32.ru/hearing?id=1&x=2)\n", msg)
+
+	msg, err = r.Render("telegram", publisher.FormatMarkdown, EventSummary, Data{Hearing: testHearings()[1]})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(msg, "*Публичные слушания отменены*\n\n"), msg)
+	require.NotContains(t, msg, "и ещё")
+
+	msg, err = r.Render("telegram", publisher.FormatMarkdown, EventSummary, Data{})
+	require.NoError(t, err)
+	require.Empty(t, msg)
+
+	h := domain.Hearing{
+		Time:      time.Date(2099, time.March, 17, 11, 0, 0, 0, time.UTC),
+		Place:     "ГДК Советского района (ул. Калинина, д. 66)",
+		Proposals: []string{strings.Repeat("Приём предложений осуществляет оргкомитет до 16.03.2099 (включительно). ", 20)},
+		URL:       "https://bga32.ru/hearing_1/",
+		Status:    domain.StatusPostponed,
+	}
+	for i := 0; i < 30; i++ {
+		h.Topic = append(h.Topic, strings.Repeat("по проекту планировки территории по ул. Фосфоритной, д.1; ", 5))
+	}
+	msg, err = r.Render("telegram", publisher.FormatMarkdown, EventSummary, Data{Hearing: h})
+	require.NoError(t, err)
+	require.LessOrEqual(t, domain.MessageLength(msg), domain.MaxMessageLength)
+	require.True(t, strings.HasPrefix(msg, "*Публичные слушания перенесены*\n\n*17\\.03\\.2099 в 11:00 в ГДК Советского района \\(ул\\. Калинина, д\\. 66\\)* состоятся публичные слушания по проекту"))
+	require.Contains(t, msg, "\n\nи ещё 29 вопросов\n\n")
+	require.True(t, strings.HasSuffix(msg, "[публикации](https://bga32.ru/hearing_1/)\n"))
+}
+
+func TestRenderer_email(t *testing.T) {
+	h := testHearings()[0]
+	msg, err := New().Render("email", publisher.FormatEmail, EventNew, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, `Дата: 17.03.2022 в 11:00
+Место: актовом зале <администрации> & по адресу: ул. Гагарина, 12
+Вопросы публичных слушаний:
+ - по проекту планировки территории (ул. Ленина, 1)
+ - по проекту межевания
+
+Предложения принимаются до 5 марта [по адресу] ул. Калинина, 66!
+
+Подробнее: https://bga32.ru/hearing?id=1&x=2
+`, msg)
+}
+
+func TestRenderer_mastodon(t *testing.T) {
+	h := testHearings()[0]
+	h.Topic = append(h.Topic, strings.Repeat("очень длинный вопрос ", 50))
+	h.Place = strings.Repeat("место ", 50)
+	msg, err := New().Render("mastodon", publisher.FormatMastodon, EventNew, Data{Hearing: h})
+	require.NoError(t, err)
+	require.LessOrEqual(t, utf8.RuneCountInString(msg), 500)
+	require.True(t, strings.HasPrefix(msg, "17.03.2022 в 11:00, место"), msg)
+	require.Contains(t, msg, "— публичные слушания по проекту планировки территории (ул. Ленина, 1) и ещё 2 вопроса\n\n")
+	require.True(t, strings.HasSuffix(msg, "\n\nhttps://bga32.ru/hearing?id=1&x=2"), msg)
+
+	h.Status = domain.StatusCancelled
+	msg, err = New().Render("mastodon", publisher.FormatMastodon, EventCancelled, Data{Hearing: h})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(msg, "Отменены: 17.03.2022"), msg)
+}
+
+func TestRenderer_telegramHTML(t *testing.T) {
+	h := testHearings()[1]
+	h.Place = `ул. C:\Windows_*[1](2)* <b>`
+	h.URL = `https://ru.wikipedia.org/wiki/Брянск_(город)?q="\"&x=1`
+	msg, err := New().Render("telegram", publisher.FormatTelegramHTML, EventCancelled, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, "<b>Публичные слушания отменены</b>\n\n"+
+		"<b>17.03.2022 в 11:00 в ул. C:\\Windows_*[1](2)* &lt;b&gt;</b> состоятся публичные слушания "+
+		"о внесении изменений в правила землепользования_и_застройки\n\n"+
+		"Предложения принимаются до 5 марта [по адресу] ул. Калинина, 66!\n\n"+
+		`<a href="https://ru.wikipedia.org/wiki/Брянск_(город)?q=&#34;\&#34;&amp;x=1">Ссылка на публикацию</a>`+"\n", msg)
+
+	h = testHearings()[0]
+	msg, err = New().Render("telegram", publisher.FormatTelegramHTML, EventSummary, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, "<b>17.03.2022 в 11:00 в актовом зале &lt;администрации&gt; &amp; по адресу: ул. Гагарина, 12</b> "+
+		"состоятся публичные слушания по проекту планировки территории (ул. Ленина, 1)\n\nи ещё 1 вопрос\n\n"+
+		`Полный текст и порядок приёма предложений в <a href="https://bga32.ru/hearing?id=1&amp;x=2">публикации</a>`+"\n", msg)
+}
+
+func TestRenderer_reminder(t *testing.T) {
+	r := New()
+	text := "Завтра слушания.\nМесто: ул. Ленина, 1 <зал>"
+	want := map[string]string{
+		publisher.FormatText:         text,
+		publisher.FormatMarkdown:     domain.EscapeMarkdown(text),
+		publisher.FormatHTML:         "<p>Завтра слушания.<br>\nМесто: ул. Ленина, 1 &lt;зал&gt;</p>\n",
+		publisher.FormatEmail:        text + "\n",
+		publisher.FormatMastodon:     text,
+		publisher.FormatTelegramHTML: "Завтра слушания.\nМесто: ул. Ленина, 1 &lt;зал&gt;",
+	}
+	for format, expected := range want {
+		msg, err := r.Render("", format, EventReminder, Data{Text: text})
+		require.NoError(t, err)
+		require.Equal(t, expected, msg, format)
+	}
+}
+
+func TestText(t *testing.T) {
+	h := testHearings()[0]
+	msg, err := New().Render("", publisher.FormatText, EventNew, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, msg, Text(h))
+	require.Empty(t, Text(domain.Hearing{}))
+}
+
+func TestRenderer_errors(t *testing.T) {
+	r := New()
+	_, err := r.Render("", "json", EventNew, Data{})
+	require.ErrorIs(t, err, ErrNoTemplate)
+	_, err = r.Render("", publisher.FormatText, "unknown", Data{})
+	require.ErrorIs(t, err, ErrNoTemplate)
+	_, err = r.Render("", publisher.FormatText, EventSummary, Data{})
+	require.ErrorIs(t, err, ErrNoTemplate)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("text/new.tmpl", `Новые слушания {{date .Hearing.Time}}`)
+	write("markdown/hearing.tmpl", `{{define "hearing"}}*{{md .Hearing.Place}}*{{end}}`)
+	write("channels/vk/new.tmpl", `ВК: {{.Hearing.Place}}, {{len .Hearing.Topic}} {{plural (len .Hearing.Topic) "вопрос" "вопроса" "вопросов"}}`)
+
+	r, err := Load(dir)
+	require.NoError(t, err)
+	h := testHearings()[0]
+	builtin, err := New().Render("telegram", publisher.FormatText, EventChanged, Data{Hearing: h})
+	require.NoError(t, err)
+
+	msg, err := r.Render("telegram", publisher.FormatText, EventNew, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, "Новые слушания 17.03.2022", msg)
+	msg, err = r.Render("telegram", publisher.FormatText, EventChanged, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, builtin, msg, "not overridden template must stay built-in")
+
+	msg, err = r.Render("telegram", publisher.FormatMarkdown, EventChanged, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, "*"+domain.EscapeMarkdown(h.Place)+"*", msg, "overridden definition must be used by events")
+
+	msg, err = r.Render("vk", publisher.FormatText, EventNew, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, "ВК: "+h.Place+", 2 вопроса", msg)
+	msg, err = r.Render("vk", publisher.FormatText, EventChanged, Data{Hearing: h})
+	require.NoError(t, err)
+	require.Equal(t, builtin, msg)
+
+	msg, err = New().Render("telegram", publisher.FormatText, EventNew, Data{Hearing: h})
+	require.NoError(t, err)
+	require.NotEqual(t, "Новые слушания 17.03.2022", msg, "loading must not change built-in templates")
+
+	write("channels/broken/new.tmpl", `{{.Hearing.Place`)
+	_, err = Load(dir)
+	require.Error(t, err)
+
+	_, err = Load(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+}
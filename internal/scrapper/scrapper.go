@@ -98,11 +98,33 @@ func (s Scrapper) ExtractContent(ctx context.Context, link, selector string, for
 		Bool("force", force).
 		Logger()
 	l.Debug().Msg("extracting content")
-	body, err := s.fetch(ctx, link, false)
+	body, err := s.fetch(ctx, link, force)
 	if err != nil {
 		l.Error().Err(err).Msg("error fetching")
 		return nil, err
 	}
+	return s.extractContent(&l, body, selector)
+}
+
+// ExtractCachedContent returns content of passed link only from the cache.
+// It never fetches the page from the network.
+func (s Scrapper) ExtractCachedContent(link, selector string) ([]string, error) {
+	l := s.logger.With().
+		Str("method", "ExtractCachedContent").
+		Str("link", link).
+		Str("selector", selector).
+		Logger()
+	l.Debug().Msg("extracting cached content")
+	body, err := s.loadFromCache(link)
+	if err != nil {
+		l.Debug().Err(err).Msg("error loading from cache")
+		return nil, err
+	}
+	return s.extractContent(&l, body, selector)
+}
+
+// extractContent returns text of elements matched by selector
+func (s Scrapper) extractContent(l *zerolog.Logger, body []byte, selector string) ([]string, error) {
 	l.Debug().Msg("creating document")
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
@@ -0,0 +1,177 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package smtptest is local SMTP sink for tests of sending emails
+package smtptest
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Message received by sink
+type Message struct {
+	From string
+	To   []string
+	// Data is message with headers as it is received
+	Data string
+	// Auth is credentials of AUTH PLAIN command in form "\x00username\x00password"
+	Auth string
+}
+
+// Server is SMTP sink accepting all messages
+type Server struct {
+	// Addr is host:port of listening server
+	Addr string
+
+	listener net.Listener
+	mu       sync.Mutex
+	messages []Message
+	reject   string
+}
+
+// New starts SMTP sink which is closed at the end of test
+func New(t *testing.T) *Server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	s := &Server{Addr: l.Addr().String(), listener: l}
+	go s.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return s
+}
+
+// Host of server
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port of server
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	return port
+}
+
+// Messages returns received messages
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// SetReject sets reply to RCPT command instead of acceptance, for example "550 mailbox unavailable".
+// Empty reply accepts recipients.
+func (s *Server) SetReject(reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reply
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	tp := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return tp.PrintfLine("%s", line) == nil
+	}
+	if !reply("220 localhost smtptest") {
+		return
+	}
+
+	var msg Message
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			if reply("250-localhost") && reply("250-8BITMIME") {
+				reply("250 AUTH PLAIN")
+			}
+		case strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			msg.Auth = decodeAuth(strings.TrimSpace(line[len("AUTH PLAIN"):]))
+			reply("235 authenticated")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = Message{Auth: msg.Auth, From: trimAddress(line[len("MAIL FROM:"):])}
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			s.mu.Lock()
+			reject := s.reject
+			s.mu.Unlock()
+			if reject != "" {
+				reply(reject)
+				continue
+			}
+			msg.To = append(msg.To, trimAddress(line[len("RCPT TO:"):]))
+			reply("250 ok")
+		case cmd == "DATA":
+			if !reply("354 go ahead") {
+				return
+			}
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "RSET", cmd == "NOOP":
+			reply("250 ok")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func decodeAuth(s string) string {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func trimAddress(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, " "); i > 0 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "<>")
+}
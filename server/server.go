@@ -16,12 +16,19 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
 	"github.com/brurbanko/mercury/service/hearings"
 
 	"github.com/go-chi/chi/v5"
@@ -93,16 +100,48 @@ func (s Server) initRouter(token string) {
 	mux.Use(middleware.Logger)
 	mux.Use(middleware.Recoverer)
 
-	if token != "" {
-		s.logger.Info().Msg("auth token enabled")
-		mux.Use(s.authTokenMiddleware(token))
-	}
+	// Links in emails are opened without token, they are signed by themselves
+	mux.Get("/digest/unsubscribe", s.confirmUnsubscribeDigest)
+	mux.Post("/digest/unsubscribe", s.unsubscribeDigest)
+
+	mux.Group(func(r chi.Router) {
+		if token != "" {
+			s.logger.Info().Msg("auth token enabled")
+			r.Use(s.authTokenMiddleware(token))
+		}
 
-	mux.Route("/hearings", func(r chi.Router) {
-		r.Get("/", s.listHearings)
-		r.Post("/new", s.newHearings)
-		r.Get("/new", s.unpublishedHearings)
-		r.Get("/links", s.hearingLinks)
+		r.Route("/hearings", func(r chi.Router) {
+			r.Get("/", s.listHearings)
+			r.Get("/search", s.searchHearings)
+			r.Get("/{id}", s.getHearing)
+			r.Delete("/{id}", s.deleteHearing)
+			r.Get("/{id}/deliveries", s.hearingDeliveries)
+			r.Post("/new", s.newHearings)
+			r.Get("/new", s.unpublishedHearings)
+			r.Post("/new/ack", s.acknowledgeHearings)
+			r.Get("/links", s.hearingLinks)
+			r.Post("/rules/dry-run", s.dryRunRules)
+			r.Post("/preview", s.previewHearing)
+			r.Post("/status", s.refreshStatuses)
+			r.Get("/ignore", s.ignoreRules)
+			r.Post("/ignore", s.addIgnoreRule)
+			r.Delete("/ignore/{id}", s.deleteIgnoreRule)
+		})
+
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Get("/", s.webhooks)
+			r.Post("/", s.addWebhook)
+			r.Delete("/{id}", s.deleteWebhook)
+			r.Get("/{id}/deliveries", s.webhookDeliveries)
+		})
+
+		r.Route("/moderation", func(r chi.Router) {
+			r.Get("/", s.reviews)
+			r.Get("/{id}", s.review)
+			r.Patch("/{id}", s.editReview)
+			r.Post("/{id}/approve", s.approveReview)
+			r.Post("/{id}/reject", s.rejectReview)
+		})
 	})
 
 	s.server.Handler = mux
@@ -138,7 +177,14 @@ type errorResponse struct {
 }
 
 type dataResponse struct {
-	Data interface{} `json:"data"`
+	Data       interface{} `json:"data"`
+	Pagination *pagination `json:"pagination,omitempty"`
+}
+
+type pagination struct {
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 type statusResponse struct {
@@ -149,9 +195,93 @@ type listResponse struct {
 	List []string `json:"list"`
 }
 
+// unpublishedResponse is list of unpublished hearings with their identifiers for acknowledging
+type unpublishedResponse struct {
+	List []string `json:"list"`
+	IDs  []string `json:"ids"`
+}
+
+// Limits of number of hearings on page
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// dateFormat of date range parameters
+const dateFormat = "2006-01-02"
+
+// listOptions returns options of hearings list from query parameters
+func listOptions(r *http.Request) (database.ListOptions, error) {
+	q := r.URL.Query()
+	opts := database.ListOptions{
+		Category: domain.Category(q.Get("category")),
+		District: domain.District(q.Get("district")),
+		Text:     q.Get("q"),
+		Sort:     q.Get("sort"),
+		Desc:     true,
+		Limit:    defaultListLimit,
+		Cursor:   q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if opts.From, err = time.Parse(dateFormat, v); err != nil {
+			return opts, fmt.Errorf("from must be a date in format YYYY-MM-DD")
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if opts.To, err = time.Parse(dateFormat, v); err != nil {
+			return opts, fmt.Errorf("to must be a date in format YYYY-MM-DD")
+		}
+		// The last day is included
+		opts.To = opts.To.AddDate(0, 0, 1)
+	}
+	if v := q.Get("published"); v != "" {
+		published, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("published must be true or false")
+		}
+		opts.Published = &published
+	}
+	if v := q.Get("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			opts.Statuses = append(opts.Statuses, domain.Status(strings.TrimSpace(status)))
+		}
+	}
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		opts.Desc = false
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+	if v := q.Get("limit"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit <= 0 || opts.Limit > maxListLimit {
+			return opts, fmt.Errorf("limit must be a number from 1 to %d", maxListLimit)
+		}
+	}
+	return opts, opts.Validate()
+}
+
 func (s Server) listHearings(w http.ResponseWriter, r *http.Request) {
+	if link := r.URL.Query().Get("url"); link != "" {
+		s.lookupHearing(w, r, link)
+		return
+	}
 	s.logger.Debug().Msg("list hearings")
-	h, err := s.hearings.List(r.Context())
+	opts, err := listOptions(r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	page, err := s.hearings.List(r.Context(), opts)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
 	if err != nil {
 		s.logger.Err(err).Msg("failed show hearings list")
 		render.Status(r, http.StatusInternalServerError)
@@ -160,7 +290,185 @@ func (s Server) listHearings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, dataResponse{h})
+	render.JSON(w, r, dataResponse{
+		Data: page.Hearings,
+		Pagination: &pagination{
+			Limit:      opts.Limit,
+			Total:      page.Total,
+			NextCursor: page.Next,
+		},
+	})
+}
+
+// Formats of single hearing
+const (
+	formatJSON     = "json"
+	formatText     = "text"
+	formatMarkdown = "markdown"
+)
+
+// mediaFormats maps media types of Accept header to formats of hearing
+var mediaFormats = map[string]string{
+	"application/json": formatJSON,
+	"application/*":    formatJSON,
+	"*/*":              formatJSON,
+	"text/plain":       formatText,
+	"text/*":           formatText,
+	"text/markdown":    formatMarkdown,
+}
+
+// negotiateFormat returns format of hearing from parameter "format" or Accept header.
+// Empty format is returned if no one of accepted media types is supported.
+func negotiateFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch format {
+		case formatJSON, formatText, formatMarkdown:
+			return format
+		}
+		return ""
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return formatJSON
+	}
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		format, ok := mediaFormats[strings.ToLower(strings.TrimSpace(params[0]))]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				q, _ = strconv.ParseFloat(v[2:], 64)
+			}
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// renderHearing writes hearing in negotiated format
+func (s Server) renderHearing(w http.ResponseWriter, r *http.Request, h domain.Hearing) {
+	w.Header().Set("Content-Location", "/hearings/"+h.ID)
+	w.Header().Add("Vary", "Accept")
+	switch negotiateFormat(r) {
+	case formatJSON:
+		render.Status(r, http.StatusOK)
+		render.JSON(w, r, dataResponse{Data: h})
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, s.hearings.Render(hearings.ChannelAPI, publisher.FormatText, h))
+	case formatMarkdown:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, s.hearings.Render(hearings.ChannelAPI, publisher.FormatMarkdown, h))
+	default:
+		render.Status(r, http.StatusNotAcceptable)
+		render.JSON(w, r, errorResponse{"supported formats are json, text and markdown"})
+	}
+}
+
+// hearingError writes error of getting hearing
+func (s Server) hearingError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, database.ErrNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	s.logger.Err(err).Msg("failed get hearing")
+	render.Status(r, http.StatusInternalServerError)
+	render.JSON(w, r, errorResponse{err.Error()})
+}
+
+func (s Server) getHearing(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("get hearing")
+	h, err := s.hearings.Get(r.Context(), id)
+	if err != nil {
+		s.hearingError(w, r, err)
+		return
+	}
+	s.renderHearing(w, r, h)
+}
+
+func (s Server) lookupHearing(w http.ResponseWriter, r *http.Request, link string) {
+	s.logger.Debug().Str("link", link).Msg("lookup hearing")
+	h, err := s.hearings.Find(r.Context(), link)
+	if err != nil {
+		s.hearingError(w, r, err)
+		return
+	}
+	s.renderHearing(w, r, h)
+}
+
+func (s Server) deleteHearing(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("delete hearing")
+	if err := s.hearings.Delete(r.Context(), id); err != nil {
+		s.hearingError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) hearingDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("get deliveries of hearing")
+	if _, err := s.hearings.Get(r.Context(), id); err != nil {
+		s.hearingError(w, r, err)
+		return
+	}
+	deliveries, err := s.hearings.Deliveries(r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: deliveries})
+}
+
+// Limits of number of search results
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+func (s Server) searchHearings(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("search hearings")
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{"query parameter q is required"})
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxSearchLimit {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, errorResponse{fmt.Sprintf("limit must be a number from 1 to %d", maxSearchLimit)})
+			return
+		}
+		limit = n
+	}
+
+	res, err := s.hearings.Search(r.Context(), query, limit)
+	if err != nil {
+		s.logger.Err(err).Msg("failed search hearings")
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: res})
 }
 
 func (s Server) newHearings(w http.ResponseWriter, r *http.Request) {
@@ -185,7 +493,7 @@ func (s Server) newHearings(w http.ResponseWriter, r *http.Request) {
 	}
 	cnt := len(h)
 	if publish {
-		c, err := s.hearings.Publish(r.Context(), "markdown")
+		c, err := s.hearings.Publish(r.Context(), publisher.FormatMarkdown)
 		if err != nil {
 			s.logger.Err(err).Msgf("failed publish %d hearings", len(h))
 			render.Status(r, http.StatusInternalServerError)
@@ -202,10 +510,31 @@ func (s Server) newHearings(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s Server) refreshStatuses(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("refreshing statuses of hearings")
+	publish := r.URL.Query().Get("publish") == "true"
+	if !publish {
+		err := r.ParseForm()
+		if err == nil {
+			publish = r.Form.Get("publish") == "true"
+		}
+	}
+
+	h, err := s.hearings.RefreshStatuses(r.Context(), "markdown", publish)
+	if err != nil {
+		s.logger.Err(err).Msgf("failed refresh statuses of %d hearings", len(h))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: h})
+}
+
 func (s Server) unpublishedHearings(w http.ResponseWriter, r *http.Request) {
 	s.logger.Debug().Msg("getting unpublished hearings")
-	mark := r.URL.Query().Get("dry-run") != "true"
-	h, err := s.hearings.ListUnpublished(r.Context(), mark)
+	h, err := s.hearings.ListUnpublished(r.Context())
 	if err != nil {
 		s.logger.Err(err).Msg("failed find unpublished hearings")
 		render.Status(r, http.StatusInternalServerError)
@@ -214,20 +543,53 @@ func (s Server) unpublishedHearings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	list := make([]string, len(h))
-	format := r.URL.Query().Get("format")
-	if format == "markdown" {
-		for i, v := range h {
-			list[i] = v.Markdown()
-		}
-	} else {
-		for i, v := range h {
-			list[i] = v.String()
-		}
+	ids := make([]string, len(h))
+	format := publisher.FormatText
+	if r.URL.Query().Get("format") == formatMarkdown {
+		format = publisher.FormatMarkdown
+	}
+	for i, v := range h {
+		ids[i] = v.ID
+		list[i] = s.hearings.Render(hearings.ChannelAPI, format, v)
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, listResponse{
+	render.JSON(w, r, unpublishedResponse{
 		List: list,
+		IDs:  ids,
+	})
+}
+
+type acknowledgeRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// acknowledgeHearings marks hearings of list as published by caller
+func (s Server) acknowledgeHearings(w http.ResponseWriter, r *http.Request) {
+	var req acknowledgeRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	if len(req.IDs) == 0 {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{"ids are required"})
+		return
+	}
+
+	cnt, err := s.hearings.Acknowledge(r.Context(), req.IDs)
+	if err != nil {
+		s.logger.Err(err).Msg("failed acknowledge hearings")
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, statusResponse{
+		Status: fmt.Sprintf("marked %d hearings as published", cnt),
 	})
 }
 
@@ -242,5 +604,398 @@ func (s Server) hearingLinks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, dataResponse{links})
+	render.JSON(w, r, dataResponse{Data: links})
+}
+
+func (s Server) dryRunRules(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("dry-run parsing rules")
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	rules, err := hearings.ParseRules(body)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	report, err := s.hearings.DryRunRules(r.Context(), rules)
+	if err != nil {
+		s.logger.Err(err).Msg("failed dry-run parsing rules")
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: report})
+}
+
+type previewRequest struct {
+	URL        string   `json:"url"`
+	Paragraphs []string `json:"paragraphs"`
+}
+
+func (s Server) previewHearing(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("preview hearing")
+	var req previewRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	if req.URL == "" && len(req.Paragraphs) == 0 {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{"url or paragraphs are required"})
+		return
+	}
+
+	preview, err := s.hearings.Preview(r.Context(), req.URL, req.Paragraphs)
+	if err != nil {
+		s.logger.Err(err).Msg("failed preview hearing")
+		render.Status(r, http.StatusBadGateway)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: preview})
+}
+
+func (s Server) ignoreRules(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("list ignore rules")
+	rules, err := s.hearings.IgnoreRules(r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: rules})
+}
+
+// ignoreRequest has exactly one of URL of ignored page or regular expression of ignored links
+type ignoreRequest struct {
+	URL     string `json:"url"`
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"`
+}
+
+type ignoreResponse struct {
+	Rule database.IgnoreRule `json:"rule"`
+	// Deleted hearings matched by rule
+	Deleted []domain.Hearing `json:"deleted"`
+}
+
+func (s Server) addIgnoreRule(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("add ignore rule")
+	var req ignoreRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	rule := database.IgnoreRule{Kind: database.IgnoreURL, Pattern: req.URL, Reason: req.Reason}
+	if req.Pattern != "" {
+		rule.Kind, rule.Pattern = database.IgnorePattern, req.Pattern
+	}
+	if (req.URL == "") == (req.Pattern == "") {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{"exactly one of url or pattern is required"})
+		return
+	}
+
+	rule, deleted, err := s.hearings.Ignore(r.Context(), rule)
+	switch {
+	case errors.Is(err, hearings.ErrInvalidIgnoreRule):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	case errors.Is(err, database.ErrAlreadyExists):
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	case err != nil:
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dataResponse{Data: ignoreResponse{Rule: rule, Deleted: deleted}})
+}
+
+func (s Server) deleteIgnoreRule(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("delete ignore rule")
+	err := s.hearings.Unignore(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) webhooks(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("list webhooks")
+	hooks, err := s.hearings.Webhooks(r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: hooks})
+}
+
+// webhookRequest subscribes URL to events. Secret is generated if it is empty.
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+func (s Server) addWebhook(w http.ResponseWriter, r *http.Request) {
+	s.logger.Debug().Msg("add webhook")
+	var req webhookRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	hook, err := s.hearings.AddWebhook(r.Context(), req.URL, req.Secret, req.Events)
+	switch {
+	case errors.Is(err, hearings.ErrInvalidWebhook):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	case err != nil:
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, dataResponse{Data: hook})
+}
+
+func (s Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("delete webhook")
+	err := s.hearings.DeleteWebhook(r.Context(), id)
+	if errors.Is(err, database.ErrNotFound) {
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s Server) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("get deliveries of webhook")
+	deliveries, err := s.hearings.WebhookDeliveries(r.Context(), id)
+	if err != nil {
+		s.hearingError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: deliveries})
+}
+
+// moderatorAPI is moderator of decisions made by API without name of moderator
+const moderatorAPI = "api"
+
+// reviewRequest is decision or edit of moderator. Body of request is optional for decisions.
+type reviewRequest struct {
+	Moderator string `json:"moderator"`
+	Comment   string `json:"comment"`
+	hearings.ReviewEdit
+}
+
+// decodeReview returns request of moderator from optional body
+func decodeReview(w http.ResponseWriter, r *http.Request) (reviewRequest, error) {
+	var req reviewRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req)
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	if req.Moderator == "" {
+		req.Moderator = moderatorAPI
+	}
+	return req, err
+}
+
+// reviewError writes error of moderation
+func (s Server) reviewError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, hearings.ErrInvalidReview):
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+	case errors.Is(err, hearings.ErrReviewDecided):
+		render.Status(r, http.StatusConflict)
+		render.JSON(w, r, errorResponse{err.Error()})
+	default:
+		s.hearingError(w, r, err)
+	}
+}
+
+func (s Server) reviews(w http.ResponseWriter, r *http.Request) {
+	state := database.ReviewState(r.URL.Query().Get("state"))
+	s.logger.Debug().Str("state", string(state)).Msg("list reviews")
+	switch state {
+	case "", database.ReviewPending, database.ReviewApproved, database.ReviewRejected:
+	default:
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{"state must be pending, approved or rejected"})
+		return
+	}
+	items, err := s.hearings.Reviews(r.Context(), state)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: items})
+}
+
+func (s Server) review(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("get review")
+	item, err := s.hearings.ReviewItem(r.Context(), id)
+	if err != nil {
+		s.reviewError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: item})
+}
+
+func (s Server) editReview(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("edit review")
+	req, err := decodeReview(w, r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	item, err := s.hearings.EditReview(r.Context(), id, req.Moderator, req.ReviewEdit)
+	if err != nil {
+		s.reviewError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: item})
+}
+
+func (s Server) approveReview(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("approve hearing")
+	req, err := decodeReview(w, r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	item, err := s.hearings.Approve(r.Context(), id, req.Moderator)
+	if err != nil {
+		s.reviewError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: item})
+}
+
+func (s Server) rejectReview(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	s.logger.Debug().Str("id", id).Msg("reject hearing")
+	req, err := decodeReview(w, r)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, errorResponse{err.Error()})
+		return
+	}
+	item, err := s.hearings.Reject(r.Context(), id, req.Moderator, req.Comment)
+	if err != nil {
+		s.reviewError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, dataResponse{Data: item})
+}
+
+// unsubscribePage asks to confirm unsubscribing, so link prefetched by mail scanners does not unsubscribe
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>Отписка от рассылки</title></head>
+<body>
+<form method="post" action="unsubscribe">
+<p>Отписать {{.Email}} от рассылки публичных слушаний?</p>
+<input type="hidden" name="email" value="{{.Email}}">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Отписаться</button>
+</form>
+</body>
+</html>
+`))
+
+// confirmUnsubscribeDigest handles link of digest email with page confirming unsubscribing
+func (s Server) confirmUnsubscribeDigest(w http.ResponseWriter, r *http.Request) {
+	email, token := r.FormValue("email"), r.FormValue("token")
+	if err := s.hearings.CheckUnsubscribe(email, token); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.PlainText(w, r, "Неверная ссылка для отписки от рассылки")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = unsubscribePage.Execute(w, struct{ Email, Token string }{email, token})
+}
+
+// unsubscribeDigest handles confirmation form and one-click unsubscribing of mail clients
+func (s Server) unsubscribeDigest(w http.ResponseWriter, r *http.Request) {
+	email := r.FormValue("email")
+	s.logger.Debug().Str("email", email).Msg("unsubscribe from digest")
+	err := s.hearings.Unsubscribe(r.Context(), email, r.FormValue("token"))
+	if errors.Is(err, hearings.ErrInvalidUnsubscribe) {
+		render.Status(r, http.StatusBadRequest)
+		render.PlainText(w, r, "Неверная ссылка для отписки от рассылки")
+		return
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.PlainText(w, r, "Не удалось отписаться от рассылки, попробуйте позже")
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.PlainText(w, r, "Вы отписались от рассылки публичных слушаний")
 }
@@ -0,0 +1,615 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/database/memory"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
+	"github.com/brurbanko/mercury/internal/scrapper"
+	"github.com/brurbanko/mercury/service/hearings"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T) (*Server, *memory.Repository) {
+	t.Helper()
+	logger := zerolog.Nop()
+	repo := memory.New()
+	pub, err := publisher.NewTelegram(&publisher.TelegramOptions{Logger: &logger})
+	require.NoError(t, err)
+
+	svc := hearings.New(&hearings.Config{
+		Database:  repo,
+		Logger:    &logger,
+		Scrapper:  scrapper.New(&scrapper.Options{CacheDir: t.TempDir()}),
+		Publisher: pub,
+	})
+	return New(Config{Logger: &logger, Token: testToken, Hearings: svc}), repo
+}
+
+func (s Server) serve(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s Server) serveAccept(t *testing.T, target, accept string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, http.NoBody)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_auth(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/hearings/", http.NoBody)
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req.Header.Set("Authorization", "wrong")
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestServer_listHearings(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1", Time: time.Now().AddDate(0, 0, -7)}))
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/2", Time: time.Now().AddDate(0, 0, 7)}))
+
+	var resp struct {
+		Data []domain.Hearing `json:"data"`
+	}
+	rec := s.serve(t, http.MethodGet, "/hearings/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+	require.Equal(t, "https://example.com/2", resp.Data[0].URL, "newer hearings must be first")
+
+	rec = s.serve(t, http.MethodGet, "/hearings/?status=announced,upcoming", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, domain.StatusAnnounced, resp.Data[0].Status)
+
+	for _, target := range []string{
+		"/hearings/?from=yesterday",
+		"/hearings/?published=maybe",
+		"/hearings/?status=unknown",
+		"/hearings/?sort=place",
+		"/hearings/?order=up",
+		"/hearings/?limit=0",
+		"/hearings/?cursor=garbage",
+	} {
+		rec = s.serve(t, http.MethodGet, target, "")
+		require.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestServer_listHearings_pagination(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	date := time.Date(2099, 3, 17, 11, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, repo.Create(ctx, domain.Hearing{
+			URL:  fmt.Sprintf("https://example.com/%d", i),
+			Time: date.AddDate(0, 0, i),
+		}))
+	}
+
+	var resp struct {
+		Data       []domain.Hearing `json:"data"`
+		Pagination pagination       `json:"pagination"`
+	}
+	rec := s.serve(t, http.MethodGet, "/hearings/?order=asc&limit=2&from=2099-03-18", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+	require.Equal(t, "https://example.com/1", resp.Data[0].URL)
+	require.Equal(t, pagination{Limit: 2, Total: 2}, resp.Pagination)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/?limit=2&to=2099-03-19", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 2)
+	require.Equal(t, "https://example.com/2", resp.Data[0].URL)
+	require.Equal(t, 3, resp.Pagination.Total)
+	require.NotEmpty(t, resp.Pagination.NextCursor)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/?limit=2&cursor="+resp.Pagination.NextCursor, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp.Pagination = pagination{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, "https://example.com/0", resp.Data[0].URL)
+	require.Empty(t, resp.Pagination.NextCursor)
+}
+
+func TestServer_unpublishedHearings(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1", Topic: []string{"topic"}}))
+
+	var resp unpublishedResponse
+	rec := s.serve(t, http.MethodGet, "/hearings/new", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.List, 1)
+	require.Len(t, resp.IDs, 1)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/new", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.List, 1, "listing must not mark hearings as published")
+
+	rec = s.serve(t, http.MethodPost, "/hearings/new/ack", `{}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = s.serve(t, http.MethodPost, "/hearings/new/ack", `{"ids": ["`+resp.IDs[0]+`"]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"status": "marked 1 hearings as published"}`, rec.Body.String())
+
+	rec = s.serve(t, http.MethodGet, "/hearings/new", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Empty(t, resp.List, "acknowledged hearings must be marked as published")
+}
+
+func TestServer_previewHearing(t *testing.T) {
+	s, repo := newTestServer(t)
+
+	rec := s.serve(t, http.MethodPost, "/hearings/preview", `{}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = s.serve(t, http.MethodPost, "/hearings/preview", `{"paragraphs": [
+		"17 марта 2099 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания по проекту планировки территории"
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Data hearings.Preview `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Empty(t, resp.Data.Report.Error)
+	require.Equal(t, "ГДК Советского района (ул. Калинина, д. 66)", resp.Data.Hearing.Place)
+
+	list, err := repo.List(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
+
+func TestServer_dryRunRules(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = fmt.Fprint(w, `<div class="thecontent"><p>17 марта 2099 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания по проекту планировки территории</p></div>`)
+	}))
+	t.Cleanup(site.Close)
+
+	// Preview of the page puts it to cache
+	rec := s.serve(t, http.MethodPost, "/hearings/preview", `{"url":"`+site.URL+`/1/"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: site.URL + "/1/", Place: "old place"}))
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: site.URL + "/2/"}))
+
+	for _, body := range []string{"", "sources: [", "sources:\n  - {name: dry, url: dry}\n"} {
+		rec = s.serve(t, http.MethodPost, "/hearings/rules/dry-run", body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	rec = s.serve(t, http.MethodPost, "/hearings/rules/dry-run", `
+sources:
+  - name: dry
+    url: `+site.URL+`/list/
+    links_selector: .thecontent ol li a
+    content_selector: .thecontent p
+`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp struct {
+		Data hearings.RulesReport `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, 1, resp.Data.Checked)
+	require.Equal(t, 1, resp.Data.Parsed)
+	require.Equal(t, 1, resp.Data.Changed)
+	require.Equal(t, 1, resp.Data.Skipped, "page missing in cache must be skipped")
+	require.Len(t, resp.Data.Results, 1)
+	require.Equal(t, "dry", resp.Data.Results[0].Source)
+	require.True(t, resp.Data.Results[0].Changed)
+	require.Equal(t, "ГДК Советского района (ул. Калинина, д. 66)", resp.Data.Results[0].Hearing.Place)
+
+	h, err := repo.Find(ctx, site.URL+"/1/")
+	require.NoError(t, err)
+	require.Equal(t, "old place", h.Place, "dry run must not change database")
+}
+
+func TestServer_searchHearings(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{
+		URL:   "https://example.com/1",
+		Topic: []string{"по проекту планировки территории по ул. Фосфоритной"},
+		Time:  time.Now().AddDate(0, 0, 7),
+	}))
+	require.NoError(t, repo.Create(ctx, domain.Hearing{
+		URL:   "https://example.com/2",
+		Topic: []string{"по проекту межевания территории по ул. Речной"},
+		Time:  time.Now().AddDate(0, 0, 7),
+	}))
+
+	rec := s.serve(t, http.MethodGet, "/hearings/search", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = s.serve(t, http.MethodGet, "/hearings/search?q=test&limit=1000", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/search?q="+url.QueryEscape("улица Фосфоритная"), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct {
+		Data []database.SearchResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 0, "all words must match")
+
+	rec = s.serve(t, http.MethodGet, "/hearings/search?q="+url.QueryEscape("Фосфоритная территория"), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, "https://example.com/1", resp.Data[0].Hearing.URL)
+	require.Equal(t, domain.StatusAnnounced, resp.Data[0].Hearing.Status)
+	require.Contains(t, resp.Data[0].Snippet, "<b>Фосфоритной</b>")
+}
+
+func TestServer_getHearing(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{
+		URL:   "https://example.com/1",
+		Topic: []string{"по проекту планировки территории"},
+		Place: "ГДК Советского района",
+		Time:  time.Date(2099, 3, 17, 11, 0, 0, 0, time.UTC),
+	}))
+	h, err := repo.Find(ctx, "https://example.com/1")
+	require.NoError(t, err)
+
+	var resp struct {
+		Data domain.Hearing `json:"data"`
+	}
+	rec := s.serve(t, http.MethodGet, "/hearings/"+h.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, h.ID, resp.Data.ID)
+	require.Equal(t, h.URL, resp.Data.URL)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/?url="+url.QueryEscape(h.URL), "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "/hearings/"+h.ID, rec.Header().Get("Content-Location"))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, h.ID, resp.Data.ID)
+
+	markdown, err := render.New().Render(hearings.ChannelAPI, publisher.FormatMarkdown, render.EventNew, render.Data{Hearing: h})
+	require.NoError(t, err)
+	text := render.Text(h)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/"+h.ID+"?format=markdown", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/markdown; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, markdown, rec.Body.String())
+
+	rec = s.serveAccept(t, "/hearings/"+h.ID, "text/html, text/plain;q=0.9, application/json;q=0.5")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, text, rec.Body.String())
+
+	rec = s.serveAccept(t, "/hearings/"+h.ID, "text/markdown")
+	require.Equal(t, markdown, rec.Body.String())
+
+	rec = s.serveAccept(t, "/hearings/"+h.ID, "image/png")
+	require.Equal(t, http.StatusNotAcceptable, rec.Code)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/999", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = s.serve(t, http.MethodGet, "/hearings/?url=https://example.com/missing", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_deleteHearing(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1", Time: time.Now().AddDate(0, 0, 7)}))
+	h, err := repo.Find(ctx, "https://example.com/1")
+	require.NoError(t, err)
+
+	rec := s.serve(t, http.MethodDelete, "/hearings/"+h.ID, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/"+h.ID, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = s.serve(t, http.MethodDelete, "/hearings/"+h.ID, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	var resp struct {
+		Data []domain.Hearing `json:"data"`
+	}
+	rec = s.serve(t, http.MethodGet, "/hearings/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Empty(t, resp.Data)
+}
+
+func TestServer_ignoreRules(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/itogi/1", Time: time.Now().AddDate(0, 0, 7)}))
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/2", Time: time.Now().AddDate(0, 0, 7)}))
+
+	for _, body := range []string{`{}`, `{"url":"https://example.com/1","pattern":"x"}`, `{"pattern":"("}`, `{`} {
+		rec := s.serve(t, http.MethodPost, "/hearings/ignore", body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	var created struct {
+		Data struct {
+			Rule    database.IgnoreRule `json:"rule"`
+			Deleted []domain.Hearing    `json:"deleted"`
+		} `json:"data"`
+	}
+	rec := s.serve(t, http.MethodPost, "/hearings/ignore", `{"pattern":"/itogi/","reason":"results"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Equal(t, database.IgnorePattern, created.Data.Rule.Kind)
+	require.Equal(t, "results", created.Data.Rule.Reason)
+	require.Len(t, created.Data.Deleted, 1)
+	require.Equal(t, "https://example.com/itogi/1", created.Data.Deleted[0].URL)
+
+	rec = s.serve(t, http.MethodPost, "/hearings/ignore", `{"pattern":"/itogi/"}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	var list struct {
+		Data []database.IgnoreRule `json:"data"`
+	}
+	rec = s.serve(t, http.MethodGet, "/hearings/ignore", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	require.Equal(t, created.Data.Rule.ID, list.Data[0].ID)
+
+	var hearingsResp struct {
+		Data []domain.Hearing `json:"data"`
+	}
+	rec = s.serve(t, http.MethodGet, "/hearings/", "")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hearingsResp))
+	require.Len(t, hearingsResp.Data, 1)
+	require.Equal(t, "https://example.com/2", hearingsResp.Data[0].URL)
+
+	rec = s.serve(t, http.MethodDelete, "/hearings/ignore/"+created.Data.Rule.ID, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = s.serve(t, http.MethodDelete, "/hearings/ignore/"+created.Data.Rule.ID, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_hearingDeliveries(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1", Time: time.Now().AddDate(0, 0, 7)}))
+	h, err := repo.Find(ctx, "https://example.com/1")
+	require.NoError(t, err)
+
+	rec := s.serve(t, http.MethodGet, "/hearings/new", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp struct {
+		Data []database.Delivery `json:"data"`
+	}
+	rec = s.serve(t, http.MethodGet, "/hearings/"+h.ID+"/deliveries", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Empty(t, resp.Data, "listing of new hearings must not post them to channels")
+
+	_, err = repo.Enqueue(ctx, []database.Delivery{{HearingID: h.ID, Channel: "telegram", Event: "published"}}, true)
+	require.NoError(t, err)
+	rec = s.serve(t, http.MethodGet, "/hearings/"+h.ID+"/deliveries", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.Equal(t, database.DeliveryPending, resp.Data[0].Status)
+
+	rec = s.serve(t, http.MethodGet, "/hearings/999/deliveries", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_webhooks(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1", Time: time.Now().AddDate(0, 0, 7)}))
+
+	for _, body := range []string{`{}`, `{"url":"ftp://example.com"}`, `{"url":"https://example.com/hook","events":["hearing.deleted"]}`, `{`} {
+		rec := s.serve(t, http.MethodPost, "/webhooks/", body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	var created struct {
+		Data database.Webhook `json:"data"`
+	}
+	rec := s.serve(t, http.MethodPost, "/webhooks/", `{"url":"https://example.com/hook","events":["hearing.published"]}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &created))
+	require.Len(t, created.Data.Secret, 64, "secret must be generated")
+	require.Equal(t, []string{"hearing.published"}, created.Data.Events)
+
+	var list struct {
+		Data []database.Webhook `json:"data"`
+	}
+	rec = s.serve(t, http.MethodGet, "/webhooks/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	require.Equal(t, created.Data.ID, list.Data[0].ID)
+	require.Empty(t, list.Data[0].Secret, "secret must not be listed")
+
+	rec = s.serve(t, http.MethodGet, "/hearings/new", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var deliveries struct {
+		Data []database.Delivery `json:"data"`
+	}
+	rec = s.serve(t, http.MethodGet, "/webhooks/"+created.Data.ID+"/deliveries", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &deliveries))
+	require.Empty(t, deliveries.Data, "hearings marked by listing are published by caller")
+
+	rec = s.serve(t, http.MethodDelete, "/webhooks/"+created.Data.ID, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = s.serve(t, http.MethodDelete, "/webhooks/"+created.Data.ID, "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = s.serve(t, http.MethodGet, "/webhooks/"+created.Data.ID+"/deliveries", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestServer_moderation(t *testing.T) {
+	s, repo := newTestServer(t)
+	ctx := context.Background()
+	for _, link := range []string{"https://example.com/1", "https://example.com/2"} {
+		require.NoError(t, repo.Create(ctx, domain.Hearing{
+			URL: link, Time: time.Now().AddDate(0, 0, 7), Place: "ГДК Советского района", Topic: []string{"по проекту планировки"},
+		}))
+		h, err := repo.Find(ctx, link)
+		require.NoError(t, err)
+		require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: h.ID, State: database.ReviewPending}))
+	}
+
+	var list struct {
+		Data []hearings.ReviewItem `json:"data"`
+	}
+	rec := s.serve(t, http.MethodGet, "/moderation/?state=pending", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Data, 2)
+	require.Contains(t, list.Data[0].Preview, "ГДК Советского района")
+	rec = s.serve(t, http.MethodGet, "/moderation/?state=unknown", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = s.serve(t, http.MethodPatch, "/moderation/1", `{}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	var item struct {
+		Data hearings.ReviewItem `json:"data"`
+	}
+	rec = s.serve(t, http.MethodPatch, "/moderation/1", `{"moderator":"admin","place":"ДК железнодорожников"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+	require.Equal(t, "ДК железнодорожников", item.Data.Hearing.Place)
+	require.Equal(t, "admin", item.Data.Review.Moderator)
+
+	rec = s.serve(t, http.MethodPost, "/moderation/1/approve", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+	require.Equal(t, database.ReviewApproved, item.Data.Review.State)
+	require.Equal(t, "api", item.Data.Review.Moderator)
+	rec = s.serve(t, http.MethodPost, "/moderation/1/reject", "")
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = s.serve(t, http.MethodPost, "/moderation/2/reject", `{"moderator":"admin","comment":"дубликат"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = s.serve(t, http.MethodGet, "/moderation/2", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+	require.Equal(t, database.ReviewRejected, item.Data.Review.State)
+	require.Equal(t, "дубликат", item.Data.Review.Comment)
+
+	rec = s.serve(t, http.MethodPost, "/moderation/100/approve", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	var unpublished listResponse
+	rec = s.serve(t, http.MethodGet, "/hearings/new", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &unpublished))
+	require.Len(t, unpublished.List, 1, "rejected hearing must not be published")
+	require.Contains(t, unpublished.List[0], "ДК железнодорожников")
+}
+
+func TestServer_unsubscribeDigest(t *testing.T) {
+	logger := zerolog.Nop()
+	repo := memory.New()
+	svc := hearings.New(&hearings.Config{
+		Database: repo,
+		Logger:   &logger,
+		Digest:   &hearings.DigestConfig{Period: hearings.DigestDaily, Secret: "digest", URL: "https://example.com"},
+	})
+	s := New(Config{Logger: &logger, Token: testToken, Hearings: svc})
+
+	unsubscribe := func(method, email, token string) *httptest.ResponseRecorder {
+		q := url.Values{"email": {email}, "token": {token}}
+		req := httptest.NewRequest(method, "/digest/unsubscribe?"+q.Encode(), http.NoBody)
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+	token := svc.UnsubscribeToken("user@example.com")
+	require.Equal(t, http.StatusBadRequest, unsubscribe(http.MethodGet, "user@example.com", "wrong").Code)
+	require.Equal(t, http.StatusBadRequest, unsubscribe(http.MethodPost, "user@example.com", "wrong").Code)
+
+	rec := unsubscribe(http.MethodGet, "user@example.com", token)
+	require.Equal(t, http.StatusOK, rec.Code, "link from email works without auth token")
+	require.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), `<form method="post" action="unsubscribe">`)
+	require.Contains(t, rec.Body.String(), `value="`+token+`"`)
+	emails, err := repo.Unsubscribed(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, emails, "opening of link must not unsubscribe")
+
+	// Confirmation form posts fields in body
+	form := url.Values{"email": {"user@example.com"}, "token": {token}}
+	req := httptest.NewRequest(http.MethodPost, "/digest/unsubscribe", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	emails, err = repo.Unsubscribed(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"user@example.com"}, emails)
+
+	// One-click unsubscribing of mail clients posts to link from email
+	require.Equal(t, http.StatusOK, unsubscribe(http.MethodPost, "other@example.com", svc.UnsubscribeToken("other@example.com")).Code)
+	emails, err = repo.Unsubscribed(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"other@example.com", "user@example.com"}, emails)
+}
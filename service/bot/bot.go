@@ -0,0 +1,425 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package bot is interactive Telegram bot for residents.
+// It answers commands and manages subscriptions of chats to hearings, receiving updates by long polling.
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/service/hearings"
+)
+
+// Default settings of bot
+const (
+	// DefaultTimeout of long polling of updates
+	DefaultTimeout = 30 * time.Second
+	// DefaultRetryDelay is delay before the next polling after failed one
+	DefaultRetryDelay = 5 * time.Second
+	// listLimit is maximum number of hearings in replies with lists
+	listLimit = 10
+)
+
+// Bot answers commands of Telegram users
+type Bot struct {
+	logger   *zerolog.Logger
+	client   *http.Client
+	url      string
+	timeout  time.Duration
+	hearings *hearings.Service
+	sender   hearings.ChatPublisher
+	offset   int
+
+	moderationChat string
+	callbacks      CallbackAnswerer
+	mu             sync.Mutex
+	editing        map[string]pendingEdit
+}
+
+// Options for creating a new bot
+type Options struct {
+	Logger *zerolog.Logger
+	Token  string
+	// APIURL is base URL of Telegram Bot API. publisher.DefaultTelegramURL is used if empty.
+	APIURL string
+	// Timeout of long polling. DefaultTimeout is used if zero.
+	Timeout  time.Duration
+	Hearings *hearings.Service
+	// Sender sends replies, for example publisher.Telegram with the same token
+	Sender hearings.ChatPublisher
+	// HTTPClient receives updates. Client with timeout longer than polling is used if nil.
+	HTTPClient *http.Client
+	// ModerationChat is chat of moderators where buttons of previews are handled. Buttons are ignored if empty.
+	ModerationChat string
+	// Callbacks answers pressed buttons, for example publisher.Telegram with the same token. It is required for ModerationChat.
+	Callbacks CallbackAnswerer
+}
+
+type update struct {
+	UpdateID int `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+	CallbackQuery *callbackQuery `json:"callback_query"`
+}
+
+type updatesResponse struct {
+	OK          bool     `json:"ok"`
+	Description string   `json:"description"`
+	Result      []update `json:"result"`
+}
+
+// New returns bot
+func New(opt *Options) (*Bot, error) {
+	switch {
+	case opt.Token == "":
+		return nil, errors.New("bot: token is required")
+	case opt.Hearings == nil:
+		return nil, errors.New("bot: hearings service is required")
+	case opt.Sender == nil:
+		return nil, errors.New("bot: sender is required")
+	case opt.ModerationChat != "" && opt.Callbacks == nil:
+		return nil, errors.New("bot: callbacks are required for moderation")
+	}
+	apiURL := strings.TrimSuffix(opt.APIURL, "/")
+	if apiURL == "" {
+		apiURL = publisher.DefaultTelegramURL
+	}
+	timeout := opt.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client := opt.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: timeout + 10*time.Second}
+	}
+	l := opt.Logger.With().Str("service", "bot").Logger()
+	return &Bot{
+		logger:   &l,
+		client:   client,
+		url:      fmt.Sprintf("%s/bot%s/getUpdates", apiURL, opt.Token),
+		timeout:  timeout,
+		hearings: opt.Hearings,
+		sender:   opt.Sender,
+
+		moderationChat: opt.ModerationChat,
+		callbacks:      opt.Callbacks,
+		editing:        make(map[string]pendingEdit),
+	}, nil
+}
+
+// Run receives and answers updates until context is done
+func (b *Bot) Run(ctx context.Context) {
+	b.logger.Info().Msg("starting bot")
+	for ctx.Err() == nil {
+		if _, err := b.Poll(ctx); err != nil && ctx.Err() == nil {
+			b.logger.Error().Err(err).Msg("failed to receive updates")
+			timer := time.NewTimer(DefaultRetryDelay)
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}
+}
+
+// Poll waits for updates once, answers them and returns number of handled updates.
+// Updates are confirmed by offset of the next polling.
+func (b *Bot) Poll(ctx context.Context) (int, error) {
+	updates, err := b.updates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, u := range updates {
+		b.offset = u.UpdateID + 1
+		if u.CallbackQuery != nil {
+			b.callback(ctx, u.CallbackQuery)
+			continue
+		}
+		if u.Message == nil || u.Message.Text == "" {
+			continue
+		}
+		chatID := strconv.FormatInt(u.Message.Chat.ID, 10)
+		reply := b.Handle(ctx, chatID, u.Message.Text)
+		if err = b.sender.PublishTo(ctx, chatID, reply); err != nil {
+			b.logger.Error().Err(err).Str("chat", chatID).Msg("failed to send reply")
+		}
+	}
+	return len(updates), nil
+}
+
+// updates requests new updates with long polling
+func (b *Bot) updates(ctx context.Context) ([]update, error) {
+	allowed := `["message"]`
+	if b.moderationChat != "" {
+		allowed = `["message","callback_query"]`
+	}
+	q := url.Values{
+		"offset":          {strconv.Itoa(b.offset)},
+		"timeout":         {strconv.Itoa(int(b.timeout.Seconds()))},
+		"allowed_updates": {allowed},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url+"?"+q.Encode(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	var res updatesResponse
+	if err = json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("failed decode updates: status %d: %w", resp.StatusCode, err)
+	}
+	if !res.OK {
+		return nil, fmt.Errorf("failed get updates: status %d: %s", resp.StatusCode, res.Description)
+	}
+	return res.Result, nil
+}
+
+// Handle command of chat and returns reply in MarkdownV2.
+// Commands may have arguments after space or underscore, for example /hearing 12 or /hearing_12.
+// Text without command in chat of moderators is edit of hearing if moderator pressed button of editing.
+func (b *Bot) Handle(ctx context.Context, chatID, text string) string {
+	command, args := parseCommand(text)
+	l := b.logger.With().Str("chat", chatID).Str("command", command).Logger()
+	l.Debug().Msg("handling command")
+
+	if pending, ok := b.pendingEdit(chatID); ok {
+		switch command {
+		case "":
+			return b.edit(ctx, chatID, pending, text)
+		case "/cancel":
+			b.setPendingEdit(chatID, nil)
+			return escape("Исправление отменено.")
+		}
+	}
+
+	var reply string
+	var err error
+	switch command {
+	case "/upcoming":
+		reply, err = b.upcoming(ctx)
+	case "/search":
+		reply, err = b.search(ctx, args)
+	case "/hearing":
+		reply, err = b.hearing(ctx, args)
+	case "/subscribe":
+		reply, err = b.subscribe(ctx, chatID, args)
+	case "/unsubscribe":
+		reply, err = b.unsubscribe(ctx, chatID, args)
+	default:
+		reply = helpText
+	}
+	if err != nil {
+		l.Error().Err(err).Msg("failed to handle command")
+		return escape("Не удалось выполнить команду, попробуйте позже.")
+	}
+	return reply
+}
+
+// parseCommand returns command without mention of bot and its arguments
+func parseCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "/") {
+		return "", text
+	}
+	command, args := text, ""
+	if i := strings.IndexAny(text, " \n"); i > 0 {
+		command, args = text[:i], strings.TrimSpace(text[i+1:])
+	}
+	if i := strings.Index(command, "@"); i > 0 {
+		command = command[:i]
+	}
+	if i := strings.Index(command, "_"); i > 0 && args == "" {
+		command, args = command[:i], command[i+1:]
+	}
+	return strings.ToLower(command), args
+}
+
+var helpText = escape(`Бот публичных слушаний Брянска.
+
+/upcoming — ближайшие слушания
+/search текст — поиск слушаний
+/hearing номер — полный текст слушания
+/subscribe — подписки на новые слушания
+/unsubscribe — отписаться от всех новых слушаний`)
+
+var subscribeHelp = escape(`Подписка на новые слушания:
+/subscribe district советский — район: бежицкий, володарский, советский, фокинский
+/subscribe category planning — категория: master_plan, zoning, permitted_use, deviation, planning, improvement, other
+/subscribe keyword Речная — слово в темах, предложениях или месте
+
+/unsubscribe keyword Речная — отменить одну подписку
+/unsubscribe — отменить все подписки`)
+
+// upcoming returns list of hearings which are not held yet
+func (b *Bot) upcoming(ctx context.Context) (string, error) {
+	page, err := b.hearings.List(ctx, database.ListOptions{
+		From:     time.Now(),
+		Statuses: []domain.Status{domain.StatusAnnounced, domain.StatusUpcoming, domain.StatusToday, domain.StatusPostponed},
+		Sort:     database.SortDate,
+		Limit:    listLimit,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(page.Hearings) == 0 {
+		return escape("Ближайших слушаний нет."), nil
+	}
+	return escape("Ближайшие слушания:") + "\n\n" + list(page.Hearings), nil
+}
+
+// search returns list of hearings found by text
+func (b *Bot) search(ctx context.Context, text string) (string, error) {
+	if text == "" {
+		return escape("Напишите, что искать, например: /search Речная"), nil
+	}
+	results, err := b.hearings.Search(ctx, text, listLimit)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return escape("Ничего не найдено."), nil
+	}
+	found := make([]domain.Hearing, 0, len(results))
+	for _, r := range results {
+		found = append(found, r.Hearing)
+	}
+	return list(found), nil
+}
+
+// hearing returns full text of hearing
+func (b *Bot) hearing(ctx context.Context, id string) (string, error) {
+	if id == "" {
+		return escape("Укажите номер слушания, например: /hearing 12"), nil
+	}
+	h, err := b.hearings.Get(ctx, id)
+	if errors.Is(err, database.ErrNotFound) {
+		return escape("Слушание не найдено."), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return b.hearings.Render(hearings.ChannelBot, publisher.FormatMarkdown, h), nil
+}
+
+// subscribe chat by kind and value of arguments, or lists subscriptions of chat without arguments
+func (b *Bot) subscribe(ctx context.Context, chatID, args string) (string, error) {
+	kind, value := splitArgs(args)
+	if kind == "" {
+		subs, err := b.hearings.Subscriptions(ctx, chatID)
+		if err != nil {
+			return "", err
+		}
+		if len(subs) == 0 {
+			return subscribeHelp, nil
+		}
+		lines := make([]string, 0, len(subs))
+		for _, sub := range subs {
+			lines = append(lines, sub.Kind+" "+sub.Value)
+		}
+		return escape("Ваши подписки:\n"+strings.Join(lines, "\n")) + "\n\n" + subscribeHelp, nil
+	}
+
+	sub, err := b.hearings.Subscribe(ctx, chatID, kind, value)
+	switch {
+	case errors.Is(err, hearings.ErrInvalidSubscription):
+		return subscribeHelp, nil
+	case errors.Is(err, database.ErrAlreadyExists):
+		return escape("Вы уже подписаны."), nil
+	case err != nil:
+		return "", err
+	}
+	return escape(fmt.Sprintf("Вы подписались: %s %s. Новые слушания будут приходить в этот чат.", sub.Kind, sub.Value)), nil
+}
+
+// unsubscribe chat from subscription of arguments or from all subscriptions
+func (b *Bot) unsubscribe(ctx context.Context, chatID, args string) (string, error) {
+	kind, value := splitArgs(args)
+	n, err := b.hearings.DeleteSubscriptions(ctx, chatID, kind, value)
+	switch {
+	case errors.Is(err, hearings.ErrInvalidSubscription):
+		return subscribeHelp, nil
+	case err != nil:
+		return "", err
+	case n == 0:
+		return escape("Подписки не найдены."), nil
+	case kind == "":
+		return escape("Вы отписались от всех новых слушаний."), nil
+	}
+	return escape("Подписка отменена."), nil
+}
+
+// splitArgs returns kind of subscription and its value
+func splitArgs(args string) (string, string) {
+	fields := strings.SplitN(args, " ", 2)
+	kind := strings.ToLower(fields[0])
+	if len(fields) == 1 {
+		return kind, ""
+	}
+	return kind, strings.TrimSpace(fields[1])
+}
+
+// list returns short lines about hearings with commands to get full text
+func list(hs []domain.Hearing) string {
+	var sb strings.Builder
+	for i, h := range hs {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString("*")
+		sb.WriteString(escape(h.Time.Format("02.01.2006 15:04") + ", " + h.Place))
+		sb.WriteString("*")
+		if h.Status == domain.StatusCancelled {
+			sb.WriteString(escape(" (отменены)"))
+		}
+		if len(h.Topic) > 0 {
+			sb.WriteString("\n")
+			sb.WriteString(escape(h.Topic[0]))
+		}
+		sb.WriteString("\n")
+		sb.WriteString(escape("/hearing_" + h.ID))
+	}
+	return sb.String()
+}
+
+func escape(s string) string {
+	return domain.EscapeMarkdown(s)
+}
@@ -0,0 +1,304 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/database/memory"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/service/hearings"
+)
+
+// fakeBotAPI returns queued updates and records sent messages
+type fakeBotAPI struct {
+	*httptest.Server
+	mu      sync.Mutex
+	updates []string
+	offsets []string
+	sent    []map[string]interface{}
+	calls   []string
+}
+
+func newFakeBotAPI(t *testing.T) *fakeBotAPI {
+	t.Helper()
+	api := &fakeBotAPI{}
+	api.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/bottoken/getUpdates":
+			api.offsets = append(api.offsets, r.URL.Query().Get("offset"))
+			result := "[" + strings.Join(api.updates, ",") + "]"
+			api.updates = nil
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+		case "/bottoken/sendMessage":
+			var msg map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&msg)
+			api.sent = append(api.sent, msg)
+			_, _ = fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d}}`, len(api.sent))
+		case "/bottoken/answerCallbackQuery", "/bottoken/editMessageReplyMarkup":
+			api.calls = append(api.calls, strings.TrimPrefix(r.URL.Path, "/bottoken/"))
+			_, _ = fmt.Fprint(w, `{"ok":true,"result":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (f *fakeBotAPI) push(id int, chat int64, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b, _ := json.Marshal(text)
+	f.updates = append(f.updates, fmt.Sprintf(`{"update_id":%d,"message":{"chat":{"id":%d},"text":%s}}`, id, chat, b))
+}
+
+func (f *fakeBotAPI) pushCallback(id int, chat int64, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, fmt.Sprintf(
+		`{"update_id":%d,"callback_query":{"id":"cb%d","from":{"id":7,"username":"moderator"},"message":{"message_id":5,"chat":{"id":%d}},"data":%q}}`,
+		id, id, chat, data))
+}
+
+func newTestBot(t *testing.T, api *fakeBotAPI) (*Bot, *memory.Repository) {
+	t.Helper()
+	logger := zerolog.Nop()
+	repo := memory.New()
+	sender, err := publisher.NewTelegram(&publisher.TelegramOptions{
+		Logger: &logger, Token: "token", APIURL: api.URL, ChatInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	b, err := New(&Options{
+		Logger:   &logger,
+		Token:    "token",
+		APIURL:   api.URL,
+		Timeout:  time.Second,
+		Hearings: hearings.New(&hearings.Config{Database: repo, Logger: &logger, Bot: sender}),
+		Sender:   sender,
+	})
+	require.NoError(t, err)
+	return b, repo
+}
+
+func TestBot_Poll(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeBotAPI(t)
+	b, repo := newTestBot(t, api)
+
+	api.push(10, 42, "/subscribe district советский")
+	api.push(11, 42, "/help@mercury_bot")
+	n, err := b.Poll(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	n, err = b.Poll(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+	require.Equal(t, []string{"0", "12"}, api.offsets, "handled updates are confirmed by offset")
+
+	require.Len(t, api.sent, 2)
+	require.Equal(t, "42", api.sent[0]["chat_id"])
+	require.Equal(t, "MarkdownV2", api.sent[0]["parse_mode"])
+	require.Contains(t, api.sent[0]["text"], "Вы подписались: district sovetsky")
+	require.Equal(t, helpText, api.sent[1]["text"])
+
+	subs, err := repo.Subscriptions(ctx, "42")
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+}
+
+func TestBot_Handle(t *testing.T) {
+	ctx := context.Background()
+	b, repo := newTestBot(t, newFakeBotAPI(t))
+	at := time.Now().AddDate(0, 0, 7).Truncate(time.Minute)
+	require.NoError(t, repo.Create(ctx, domain.Hearing{
+		URL:   "https://example.com/1",
+		Time:  at,
+		Place: "ГДК (ул. Калинина, д. 66)",
+		Topic: []string{"по проекту планировки по ул. Речной"},
+	}))
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/2", Time: at.AddDate(0, -1, 0), Place: "ДК", Topic: []string{"прошедшие"}}))
+
+	reply := b.Handle(ctx, "1", "/upcoming")
+	require.Contains(t, reply, `ГДК \(ул\. Калинина, д\. 66\)`)
+	require.Contains(t, reply, `/hearing\_1`)
+	require.NotContains(t, reply, "прошедшие")
+
+	require.Contains(t, b.Handle(ctx, "1", "/search"), "Напишите, что искать")
+	require.Contains(t, b.Handle(ctx, "1", "/search Речной"), `/hearing\_1`)
+
+	require.Contains(t, b.Handle(ctx, "1", "/hearing_1"), "Ссылка на публикацию")
+	require.Contains(t, b.Handle(ctx, "1", "/hearing 1"), "Ссылка на публикацию")
+	require.Contains(t, b.Handle(ctx, "1", "/hearing 999"), "Слушание не найдено")
+
+	require.Equal(t, subscribeHelp, b.Handle(ctx, "1", "/subscribe"))
+	require.Equal(t, subscribeHelp, b.Handle(ctx, "1", "/subscribe district центральный"))
+	require.Contains(t, b.Handle(ctx, "1", "/subscribe keyword Речной"), "Вы подписались")
+	require.Contains(t, b.Handle(ctx, "1", "/subscribe keyword речной"), "Вы уже подписаны")
+	require.Contains(t, b.Handle(ctx, "1", "/subscribe category planning"), "Вы подписались")
+	require.Contains(t, b.Handle(ctx, "1", "/subscribe"), "Ваши подписки:\nkeyword речной\ncategory planning")
+
+	require.Contains(t, b.Handle(ctx, "1", "/unsubscribe keyword Речной"), "Подписка отменена")
+	require.Contains(t, b.Handle(ctx, "1", "/unsubscribe"), "от всех новых слушаний")
+	require.Contains(t, b.Handle(ctx, "1", "/unsubscribe"), "Подписки не найдены")
+	subs, err := repo.Subscriptions(ctx, "1")
+	require.NoError(t, err)
+	require.Empty(t, subs)
+
+	require.Equal(t, helpText, b.Handle(ctx, "1", "привет"))
+}
+
+func TestParseCommand(t *testing.T) {
+	for text, expected := range map[string][2]string{
+		"/hearing 12":                     {"/hearing", "12"},
+		"/hearing_12":                     {"/hearing", "12"},
+		"/Search@mercury_bot Речная":      {"/search", "Речная"},
+		"/subscribe keyword  ул. Речная ": {"/subscribe", "keyword  ул. Речная"},
+		"text": {"", "text"},
+	} {
+		command, args := parseCommand(text)
+		require.Equal(t, expected, [2]string{command, args}, text)
+	}
+}
+
+func newModerationBot(t *testing.T, api *fakeBotAPI) (*Bot, *memory.Repository, string) {
+	t.Helper()
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	repo := memory.New()
+	sender, err := publisher.NewTelegram(&publisher.TelegramOptions{
+		Logger: &logger, Token: "token", APIURL: api.URL, ChatInterval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	b, err := New(&Options{
+		Logger:  &logger,
+		Token:   "token",
+		APIURL:  api.URL,
+		Timeout: time.Second,
+		Hearings: hearings.New(&hearings.Config{
+			Database:   repo,
+			Logger:     &logger,
+			Moderation: &hearings.ModerationConfig{Chat: "-100", Sender: sender},
+		}),
+		Sender:         sender,
+		ModerationChat: "-100",
+		Callbacks:      sender,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Create(ctx, domain.Hearing{
+		URL:   "https://example.com/1",
+		Time:  time.Now().AddDate(0, 0, 7).Truncate(time.Minute),
+		Place: "ГДК (ул. Калинина, д. 66)",
+		Topic: []string{"по проекту планировки по ул. Речной"},
+	}))
+	h, err := repo.Find(ctx, "https://example.com/1")
+	require.NoError(t, err)
+	require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: h.ID, State: database.ReviewPending}))
+	return b, repo, h.ID
+}
+
+func TestBot_Poll_callback(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeBotAPI(t)
+	b, repo, id := newModerationBot(t, api)
+
+	_, err := New(&Options{Token: "token", Hearings: b.hearings, Sender: b.sender, ModerationChat: "-100"})
+	require.Error(t, err, "callbacks are required for moderation")
+
+	api.pushCallback(1, 42, "approve:"+id)
+	_, err = b.Poll(ctx)
+	require.NoError(t, err)
+	review, err := repo.Review(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, database.ReviewPending, review.State, "buttons must be handled in chat of moderators only")
+	require.Equal(t, []string{"answerCallbackQuery"}, api.calls)
+
+	api.pushCallback(2, -100, "approve:"+id)
+	_, err = b.Poll(ctx)
+	require.NoError(t, err)
+	review, err = repo.Review(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, database.ReviewApproved, review.State)
+	require.Equal(t, "@moderator", review.Moderator)
+	require.Equal(t, []string{"answerCallbackQuery", "answerCallbackQuery", "editMessageReplyMarkup"}, api.calls)
+	require.Len(t, api.sent, 1)
+	require.Equal(t, "-100", api.sent[0]["chat_id"])
+	require.Contains(t, api.sent[0]["text"], "одобрено \\(@moderator\\)")
+
+	res := b.HandleCallback(ctx, "-100", "@moderator", "reject:"+id)
+	require.True(t, res.Done)
+	require.Equal(t, "Решение по слушанию уже принято.", res.Notice)
+}
+
+func TestBot_Handle_held(t *testing.T) {
+	ctx := context.Background()
+	b, repo, id := newModerationBot(t, newFakeBotAPI(t))
+
+	require.Contains(t, b.Handle(ctx, "1", "/upcoming"), "Ближайших слушаний нет")
+	require.Contains(t, b.Handle(ctx, "1", "/search Речной"), "Ничего не найдено")
+	require.Contains(t, b.Handle(ctx, "1", "/hearing "+id), "Слушание не найдено")
+
+	require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: id, State: database.ReviewRejected}))
+	require.Contains(t, b.Handle(ctx, "1", "/upcoming"), "Ближайших слушаний нет")
+	require.Contains(t, b.Handle(ctx, "1", "/hearing "+id), "Слушание не найдено")
+
+	require.NoError(t, repo.SetReview(ctx, database.Review{HearingID: id, State: database.ReviewApproved}))
+	require.Contains(t, b.Handle(ctx, "1", "/upcoming"), `/hearing\_`+id)
+	require.Contains(t, b.Handle(ctx, "1", "/search Речной"), `/hearing\_`+id)
+	require.Contains(t, b.Handle(ctx, "1", "/hearing "+id), "Ссылка на публикацию")
+}
+
+func TestBot_Handle_edit(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeBotAPI(t)
+	b, repo, id := newModerationBot(t, api)
+
+	require.Equal(t, helpText, b.Handle(ctx, "-100", "место: ДК"), "text is not edit until button is pressed")
+
+	res := b.HandleCallback(ctx, "-100", "@moderator", "edit:"+id)
+	require.False(t, res.Done)
+	require.Contains(t, res.Reply, editHelp)
+	require.Equal(t, helpText, b.Handle(ctx, "42", "место: ДК"), "edit is pending in chat of moderators only")
+
+	require.Contains(t, b.Handle(ctx, "-100", "цвет: красный"), "Не удалось разобрать исправления")
+	require.Contains(t, b.Handle(ctx, "-100", "место: Дворец культуры железнодорожников"), "исправлено")
+	h, err := repo.Get(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, "Дворец культуры железнодорожников", h.Place)
+	require.Len(t, api.sent, 1, "new preview must be sent to moderators")
+	require.NotEmpty(t, api.sent[0]["reply_markup"])
+
+	require.Equal(t, helpText, b.Handle(ctx, "-100", "место: ДК"), "edit is done")
+	b.HandleCallback(ctx, "-100", "@moderator", "edit:"+id)
+	require.Contains(t, b.Handle(ctx, "-100", "/cancel"), "Исправление отменено")
+}
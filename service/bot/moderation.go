@@ -0,0 +1,203 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/service/hearings"
+)
+
+// CallbackAnswerer answers callback queries of inline buttons. It is implemented by publisher.Telegram.
+type CallbackAnswerer interface {
+	AnswerCallback(ctx context.Context, callbackID, text string) error
+	EditButtons(ctx context.Context, chatID string, messageID int, buttons [][]publisher.Button) error
+}
+
+type user struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// name of user for records of moderation
+func (u user) name() string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strconv.FormatInt(u.ID, 10)
+}
+
+type callbackQuery struct {
+	ID      string `json:"id"`
+	From    user   `json:"from"`
+	Message *struct {
+		MessageID int `json:"message_id"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+	Data string `json:"data"`
+}
+
+// pendingEdit is hearing which moderator is going to correct by the next message
+type pendingEdit struct {
+	HearingID string
+	Moderator string
+}
+
+var editHelp = escape(`Отправьте исправления одним сообщением, по полю в строке:
+место: ГДК Советского района
+время: 17.03.2022 11:00
+вопрос: по проекту планировки территории
+предложения: порядок приёма предложений
+
+Вопросы и предложения можно повторять. /cancel — отменить исправление.`)
+
+// CallbackResult is result of pressed button
+type CallbackResult struct {
+	// Notice is short plain text shown to moderator who pressed button
+	Notice string
+	// Reply is message in MarkdownV2 sent to chat. Nothing is sent if it is empty.
+	Reply string
+	// Done is true if decision is made and buttons of preview must be removed
+	Done bool
+}
+
+// callback answers pressed button of preview
+func (b *Bot) callback(ctx context.Context, q *callbackQuery) {
+	if b.callbacks == nil {
+		return
+	}
+	l := b.logger.With().Str("data", q.Data).Logger()
+	var res CallbackResult
+	chatID := ""
+	if q.Message != nil {
+		chatID = strconv.FormatInt(q.Message.Chat.ID, 10)
+		res = b.HandleCallback(ctx, chatID, q.From.name(), q.Data)
+	}
+	if err := b.callbacks.AnswerCallback(ctx, q.ID, res.Notice); err != nil {
+		l.Error().Err(err).Msg("failed to answer callback")
+	}
+	if res.Done {
+		if err := b.callbacks.EditButtons(ctx, chatID, q.Message.MessageID, nil); err != nil {
+			l.Error().Err(err).Msg("failed to remove buttons")
+		}
+	}
+	if res.Reply != "" {
+		if err := b.sender.PublishTo(ctx, chatID, res.Reply); err != nil {
+			l.Error().Err(err).Str("chat", chatID).Msg("failed to send reply")
+		}
+	}
+}
+
+// HandleCallback handles data of button pressed by moderator in chat.
+// Buttons are handled in chat of moderators only.
+func (b *Bot) HandleCallback(ctx context.Context, chatID, moderator, data string) CallbackResult {
+	if b.moderationChat == "" || chatID != b.moderationChat {
+		return CallbackResult{Notice: "Действие недоступно в этом чате."}
+	}
+	l := b.logger.With().Str("chat", chatID).Str("moderator", moderator).Str("data", data).Logger()
+	action, id, _ := strings.Cut(data, ":")
+
+	var err error
+	var res CallbackResult
+	switch action {
+	case hearings.ActionApprove:
+		_, err = b.hearings.Approve(ctx, id, moderator)
+		res = CallbackResult{
+			Notice: "Одобрено",
+			Reply:  escape(fmt.Sprintf("Слушание %s одобрено (%s) и будет опубликовано.", id, moderator)),
+			Done:   true,
+		}
+	case hearings.ActionReject:
+		_, err = b.hearings.Reject(ctx, id, moderator, "")
+		res = CallbackResult{
+			Notice: "Отклонено",
+			Reply:  escape(fmt.Sprintf("Слушание %s отклонено (%s).", id, moderator)),
+			Done:   true,
+		}
+	case hearings.ActionEdit:
+		var item hearings.ReviewItem
+		item, err = b.hearings.ReviewItem(ctx, id)
+		if err == nil && item.Review.State != database.ReviewPending {
+			err = hearings.ErrReviewDecided
+		}
+		if err == nil {
+			b.setPendingEdit(chatID, &pendingEdit{HearingID: id, Moderator: moderator})
+			res = CallbackResult{Notice: "Ожидаю исправления", Reply: escape("Исправление слушания "+id+".\n") + editHelp}
+		}
+	default:
+		return CallbackResult{Notice: "Неизвестное действие."}
+	}
+
+	switch {
+	case errors.Is(err, hearings.ErrReviewDecided):
+		return CallbackResult{Notice: "Решение по слушанию уже принято.", Done: true}
+	case errors.Is(err, database.ErrNotFound):
+		return CallbackResult{Notice: "Слушание не найдено.", Done: true}
+	case err != nil:
+		l.Error().Err(err).Msg("failed to handle callback")
+		return CallbackResult{Notice: "Не удалось выполнить действие, попробуйте позже."}
+	}
+	l.Info().Msg("moderation action is done")
+	return res
+}
+
+// edit applies correction of moderator to pending hearing
+func (b *Bot) edit(ctx context.Context, chatID string, pending pendingEdit, text string) string {
+	edit, err := hearings.ParseReviewEdit(text)
+	if err != nil {
+		return escape("Не удалось разобрать исправления: "+err.Error()) + "\n\n" + editHelp
+	}
+	_, err = b.hearings.EditReview(ctx, pending.HearingID, pending.Moderator, edit)
+	switch {
+	case errors.Is(err, hearings.ErrReviewDecided), errors.Is(err, database.ErrNotFound):
+		b.setPendingEdit(chatID, nil)
+		return escape("Слушание " + pending.HearingID + " больше нельзя исправить.")
+	case err != nil:
+		b.logger.Error().Err(err).Str("chat", chatID).Str("id", pending.HearingID).Msg("failed to edit hearing")
+		return escape("Не удалось исправить слушание, попробуйте позже.")
+	}
+	b.setPendingEdit(chatID, nil)
+	return escape("Слушание " + pending.HearingID + " исправлено, новое превью отправлено.")
+}
+
+// pendingEdit returns hearing which is being corrected in chat of moderators
+func (b *Bot) pendingEdit(chatID string) (pendingEdit, bool) {
+	if chatID != b.moderationChat {
+		return pendingEdit{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.editing[chatID]
+	return p, ok
+}
+
+// setPendingEdit sets or clears hearing which is being corrected in chat
+func (b *Bot) setPendingEdit(chatID string, p *pendingEdit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p == nil {
+		delete(b.editing, chatID)
+		return
+	}
+	b.editing[chatID] = *p
+}
@@ -0,0 +1,311 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+// Periods of email digest
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// digestHorizon is how far ahead upcoming hearings are listed in digest
+const digestHorizon = 14 * 24 * time.Hour
+
+//go:embed templates/digest.*.tmpl
+var templatesFS embed.FS
+
+var digestFuncs = map[string]interface{}{
+	"date": func(t time.Time) string { return t.Format("02.01.2006 в 15:04") },
+}
+
+var (
+	digestText = texttemplate.Must(texttemplate.New("digest.txt.tmpl").Funcs(digestFuncs).
+			ParseFS(templatesFS, "templates/digest.txt.tmpl"))
+	digestHTML = htmltemplate.Must(htmltemplate.New("digest.html.tmpl").Funcs(digestFuncs).
+			ParseFS(templatesFS, "templates/digest.html.tmpl"))
+)
+
+// Mailer sends emails. It is implemented by publisher.Email.
+type Mailer interface {
+	Send(ctx context.Context, m publisher.Mail) error
+}
+
+// DigestConfig is settings of email digest of new and upcoming hearings
+type DigestConfig struct {
+	// Period is daily or weekly. Weekly digest is sent on Monday.
+	Period string
+	// Hour of local time after which digest is sent
+	Hour int
+	// Recipients of digest
+	Recipients []string
+	// Secret signs unsubscribe links
+	Secret string
+	// URL is public base URL of service for unsubscribe links, for example https://hearings.example.com
+	URL    string
+	Mailer Mailer
+}
+
+// Validate settings of digest
+func (c DigestConfig) Validate() error {
+	switch {
+	case c.Period != DigestDaily && c.Period != DigestWeekly:
+		return fmt.Errorf("unknown period of digest: %q", c.Period)
+	case c.Hour < 0 || c.Hour > 23:
+		return fmt.Errorf("invalid hour of digest: %d", c.Hour)
+	case len(c.Recipients) == 0:
+		return errors.New("no recipients of digest")
+	case c.Secret == "":
+		return errors.New("empty secret of digest")
+	case c.URL == "":
+		return errors.New("empty URL of digest")
+	case c.Mailer == nil:
+		return errors.New("no mailer of digest")
+	}
+	return nil
+}
+
+// slot returns the latest scheduled time of digest not after now
+func (c DigestConfig) slot(now time.Time) time.Time {
+	y, m, d := now.Date()
+	t := time.Date(y, m, d, c.Hour, 0, 0, 0, now.Location())
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	for c.Period == DigestWeekly && t.Weekday() != time.Monday {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// digestData is data of digest templates
+type digestData struct {
+	Title          string
+	New            []domain.Hearing
+	Upcoming       []domain.Hearing
+	UnsubscribeURL string
+}
+
+// data returns digest of hearings for recipient who received the previous digest with last hearing lastID.
+// New hearings are ones created after lastID, upcoming hearings are held in the next two weeks.
+// Digest has only upcoming hearings if lastID is negative.
+func (c DigestConfig) data(hearings []domain.Hearing, held map[string]bool, lastID int, now time.Time) digestData {
+	data := digestData{Title: "Публичные слушания за неделю"}
+	if c.Period == DigestDaily {
+		data.Title = "Публичные слушания за день"
+	}
+	for _, h := range hearings {
+		h.Status = h.CurrentStatus(now)
+		if h.Status == domain.StatusCancelled || held[h.ID] {
+			continue
+		}
+		id, _ := strconv.Atoi(h.ID)
+		switch {
+		case lastID >= 0 && id > lastID:
+			data.New = append(data.New, h)
+		case h.Time.Before(now.Add(digestHorizon)):
+			data.Upcoming = append(data.Upcoming, h)
+		}
+	}
+	sort.SliceStable(data.New, func(i, j int) bool { return data.New[i].Time.Before(data.New[j].Time) })
+	return data
+}
+
+// SendDigest emails digest to recipients if it is due and returns number of sent emails.
+// New hearings are ones created after the previous digest, upcoming hearings are held in the next two weeks.
+// The first digest has only upcoming hearings. Digest without hearings is not sent but is recorded.
+// Digest is recorded per recipient, so recipient who failed to receive it gets it on the next call.
+// Error of sending is returned only if digest is not sent to anyone.
+func (s Service) SendDigest(ctx context.Context, now time.Time) (int, error) {
+	cfg := s.digest
+	if cfg == nil {
+		return 0, nil
+	}
+	l := s.logger.With().Str("method", "SendDigest").Str("period", cfg.Period).Logger()
+
+	unsubscribed, err := s.db.Unsubscribed(ctx)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get unsubscribed recipients")
+		return 0, err
+	}
+	skip := make(map[string]bool, len(unsubscribed))
+	for _, email := range unsubscribed {
+		skip[email] = true
+	}
+
+	latest, err := s.db.Query(ctx, database.ListOptions{Sort: database.SortID, Desc: true, Limit: 1, Now: now})
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get latest hearing")
+		return 0, err
+	}
+	latestID := -1
+	if len(latest.Hearings) > 0 {
+		latestID, _ = strconv.Atoi(latest.Hearings[0].ID)
+	}
+
+	page, err := s.db.Query(ctx, database.ListOptions{From: now, Sort: database.SortDate, Now: now})
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get hearings")
+		return 0, err
+	}
+	held, err := s.heldForReview(ctx)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get reviews")
+		return 0, err
+	}
+
+	sent, failed := 0, 0
+	var lastErr error
+	for _, rcpt := range cfg.Recipients {
+		if skip[strings.ToLower(rcpt)] {
+			continue
+		}
+		lastID := -1
+		last, err := s.db.LastDigest(ctx, cfg.Period, rcpt)
+		switch {
+		case err == nil:
+			if !last.SentAt.Before(cfg.slot(now)) {
+				continue
+			}
+			lastID, _ = strconv.Atoi(last.LastHearingID)
+		case !errors.Is(err, database.ErrNotFound):
+			l.Error().Err(err).Msg("failed to get last digest")
+			return sent, err
+		}
+
+		d := database.Digest{Period: cfg.Period, Recipient: rcpt, LastHearingID: strconv.Itoa(lastID), SentAt: now}
+		if latestID >= 0 {
+			d.LastHearingID = strconv.Itoa(latestID)
+		}
+		data := cfg.data(page.Hearings, held, lastID, now)
+		if len(data.New) > 0 || len(data.Upcoming) > 0 {
+			if err = s.mailDigest(ctx, rcpt, data); err != nil {
+				l.Warn().Err(err).Str("recipient", rcpt).Msg("failed to send digest. it will be sent on the next attempt")
+				failed++
+				lastErr = err
+				continue
+			}
+			d.Recipients = 1
+			sent++
+		}
+		if _, err = s.db.AddDigest(ctx, d); err != nil {
+			l.Error().Err(err).Str("recipient", rcpt).Msg("failed to save digest")
+			return sent, err
+		}
+	}
+	if sent == 0 && lastErr != nil {
+		return 0, lastErr
+	}
+	if sent > 0 || failed > 0 {
+		l.Info().Int("recipients", sent).Int("failed", failed).Msg("digest sent")
+	}
+	return sent, nil
+}
+
+// mailDigest sends digest to recipient with personal unsubscribe link
+func (s Service) mailDigest(ctx context.Context, rcpt string, data digestData) error {
+	data.UnsubscribeURL = s.unsubscribeURL(rcpt)
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, data); err != nil {
+		return err
+	}
+	if err := digestHTML.Execute(&html, data); err != nil {
+		return err
+	}
+	return s.digest.Mailer.Send(ctx, publisher.Mail{
+		To:      []string{rcpt},
+		Subject: data.Title,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
+
+// RunDigest sends digest when it is due, checking every interval until context is done
+func (s Service) RunDigest(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.SendDigest(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("method", "RunDigest").Msg("failed to send digest")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UnsubscribeToken returns token of unsubscribe link of email
+func (s Service) UnsubscribeToken(email string) string {
+	if s.digest == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(s.digest.Secret))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// unsubscribeURL returns link which unsubscribes email from digest
+func (s Service) unsubscribeURL(email string) string {
+	q := url.Values{"email": {email}, "token": {s.UnsubscribeToken(email)}}
+	return strings.TrimSuffix(s.digest.URL, "/") + "/digest/unsubscribe?" + q.Encode()
+}
+
+// CheckUnsubscribe returns ErrInvalidUnsubscribe if token of unsubscribe link does not match email
+func (s Service) CheckUnsubscribe(email, token string) error {
+	expected := s.UnsubscribeToken(email)
+	if email == "" || expected == "" || !hmac.Equal([]byte(expected), []byte(strings.ToLower(token))) {
+		return ErrInvalidUnsubscribe
+	}
+	return nil
+}
+
+// Unsubscribe email from digest. ErrInvalidUnsubscribe is returned if token does not match email.
+func (s Service) Unsubscribe(ctx context.Context, email, token string) error {
+	if err := s.CheckUnsubscribe(email, token); err != nil {
+		return err
+	}
+	if err := s.db.Unsubscribe(ctx, email); err != nil {
+		s.logger.Error().Err(err).Str("method", "Unsubscribe").Msg("failed to unsubscribe")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database/memory"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/smtptest"
+)
+
+func TestDigestConfig_slot(t *testing.T) {
+	// 2030-06-05 is Wednesday
+	now := time.Date(2030, time.June, 5, 8, 30, 0, 0, time.UTC)
+	daily := DigestConfig{Period: DigestDaily, Hour: 9}
+	require.Equal(t, time.Date(2030, time.June, 4, 9, 0, 0, 0, time.UTC), daily.slot(now))
+	require.Equal(t, time.Date(2030, time.June, 5, 9, 0, 0, 0, time.UTC), daily.slot(now.Add(time.Hour)))
+	weekly := DigestConfig{Period: DigestWeekly, Hour: 9}
+	require.Equal(t, time.Date(2030, time.June, 3, 9, 0, 0, 0, time.UTC), weekly.slot(now))
+	require.Equal(t, time.Date(2030, time.May, 27, 9, 0, 0, 0, time.UTC), weekly.slot(time.Date(2030, time.June, 3, 8, 0, 0, 0, time.UTC)))
+
+	require.Error(t, DigestConfig{Period: "monthly"}.Validate())
+}
+
+func TestService_SendDigest(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	sink := smtptest.New(t)
+	mailer, err := publisher.NewEmail(&publisher.EmailOptions{
+		Logger: &logger, Host: sink.Host(), Port: sink.Port(), From: "digest@example.com", To: []string{"a@example.com"},
+	})
+	require.NoError(t, err)
+	repo := memory.New()
+	cfg := &DigestConfig{
+		Period:     DigestDaily,
+		Hour:       9,
+		Recipients: []string{"a@example.com", "B@example.com"},
+		Secret:     "secret",
+		URL:        "https://hearings.example.com/",
+		Mailer:     mailer,
+	}
+	require.NoError(t, cfg.Validate())
+	s := New(&Config{Database: repo, Logger: &logger, Digest: cfg})
+
+	now := time.Date(2030, time.June, 5, 10, 0, 0, 0, time.Local)
+	create := func(link string, at time.Time, topic string) {
+		require.NoError(t, repo.Create(ctx, domain.Hearing{URL: link, Time: at, Place: "ГДК", Topic: []string{topic}}))
+	}
+	create("https://example.com/1/", now.Add(72*time.Hour), "по проекту <планировки>")
+	create("https://example.com/2/", now.Add(30*24*time.Hour), "по проекту межевания")
+	create("https://example.com/3/", now.Add(-72*time.Hour), "по прошедшему проекту")
+
+	sent, err := s.SendDigest(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 2, sent)
+	messages := sink.Messages()
+	require.Len(t, messages, 2)
+	require.Equal(t, []string{"B@example.com"}, messages[1].To)
+
+	header, text, html := digestParts(t, messages[0].Data)
+	unsubscribe := header.Get("List-Unsubscribe")
+	require.True(t, strings.HasPrefix(unsubscribe, "<https://hearings.example.com/digest/unsubscribe?"), unsubscribe)
+	require.Equal(t, "List-Unsubscribe=One-Click", header.Get("List-Unsubscribe-Post"))
+	require.Contains(t, text, "Ближайшие публичные слушания")
+	require.Contains(t, text, "по проекту <планировки>")
+	require.NotContains(t, text, "Новые публичные слушания", "the first digest has no new hearings")
+	require.NotContains(t, text, "межевания", "hearing after two weeks is not upcoming")
+	require.NotContains(t, text, "прошедшему")
+	require.Contains(t, html, "по проекту &lt;планировки&gt;")
+
+	sent, err = s.SendDigest(ctx, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, sent, "digest is sent once a day")
+
+	link, err := url.Parse(strings.Trim(unsubscribe, "<>"))
+	require.NoError(t, err)
+	require.Equal(t, "a@example.com", link.Query().Get("email"))
+	require.ErrorIs(t, s.Unsubscribe(ctx, "b@example.com", link.Query().Get("token")), ErrInvalidUnsubscribe)
+	require.NoError(t, s.Unsubscribe(ctx, "b@example.com", s.UnsubscribeToken("B@example.com")))
+
+	create("https://example.com/4/", now.Add(20*24*time.Hour), "по новому проекту")
+	sent, err = s.SendDigest(ctx, now.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, sent, "unsubscribed recipient is skipped")
+	messages = sink.Messages()
+	require.Len(t, messages, 3)
+	require.Equal(t, []string{"a@example.com"}, messages[2].To)
+	_, text, _ = digestParts(t, messages[2].Data)
+	require.Contains(t, text, "Новые публичные слушания\n\n25.06.2030 в 10:00 в ГДК")
+	require.Contains(t, text, "по новому проекту")
+}
+
+// flakyMailer fails to send mail to recipients from fail
+type flakyMailer struct {
+	mu   sync.Mutex
+	fail map[string]bool
+	sent []string
+}
+
+func (m *flakyMailer) Send(_ context.Context, mail publisher.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail[mail.To[0]] {
+		return errors.New("mailbox is unavailable")
+	}
+	m.sent = append(m.sent, mail.To[0])
+	return nil
+}
+
+func (m *flakyMailer) setFail(rcpt string, fail bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fail[rcpt] = fail
+}
+
+func (m *flakyMailer) reset() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := m.sent
+	m.sent = nil
+	return sent
+}
+
+func TestService_SendDigest_failedRecipient(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	mailer := &flakyMailer{fail: map[string]bool{"b@example.com": true}}
+	repo := memory.New()
+	s := New(&Config{Database: repo, Logger: &logger, Digest: &DigestConfig{
+		Period:     DigestDaily,
+		Hour:       9,
+		Recipients: []string{"a@example.com", "b@example.com"},
+		Secret:     "secret",
+		URL:        "https://hearings.example.com/",
+		Mailer:     mailer,
+	}})
+
+	now := time.Date(2030, time.June, 5, 10, 0, 0, 0, time.Local)
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1/", Time: now.Add(72 * time.Hour), Place: "ГДК"}))
+
+	sent, err := s.SendDigest(ctx, now)
+	require.NoError(t, err, "digest is sent to one of recipients")
+	require.Equal(t, 1, sent)
+	require.Equal(t, []string{"a@example.com"}, mailer.reset())
+
+	sent, err = s.SendDigest(ctx, now.Add(time.Minute))
+	require.Error(t, err, "digest is not sent to anyone")
+	require.Zero(t, sent)
+	require.Empty(t, mailer.reset(), "digest is not sent again to recipient who received it")
+
+	mailer.setFail("b@example.com", false)
+	sent, err = s.SendDigest(ctx, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, sent)
+	require.Equal(t, []string{"b@example.com"}, mailer.reset(), "failed recipient gets digest on the next attempt")
+
+	sent, err = s.SendDigest(ctx, now.Add(3*time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, sent)
+
+	sent, err = s.SendDigest(ctx, now.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 2, sent)
+	require.Equal(t, []string{"a@example.com", "b@example.com"}, mailer.reset())
+}
+
+// digestParts returns headers, plain text and HTML of multipart email
+func digestParts(t *testing.T, data string) (mail.Header, string, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	parts := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		p, err := mr.NextPart()
+		require.NoError(t, err)
+		b, err := io.ReadAll(p)
+		require.NoError(t, err)
+		parts = append(parts, strings.ReplaceAll(string(b), "\r\n", "\n"))
+	}
+	return msg.Header, parts[0], parts[1]
+}
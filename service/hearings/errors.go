@@ -0,0 +1,87 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"errors"
+)
+
+// Causes of failed parsing of hearing content.
+// Use errors.Is to check the cause of error returned by Parser.
+var (
+	// ErrEmptyContent is returned when hearing has no paragraphs
+	ErrEmptyContent = errors.New("empty content")
+	// ErrNoTopics is returned when topics of hearing cannot be found
+	ErrNoTopics = errors.New("cannot get topics")
+	// ErrNoDate is returned when date and time of hearing cannot be extracted
+	ErrNoDate = errors.New("cannot get date")
+	// ErrDateBeforeEpoch is returned when extracted date is earlier than the beginning time of service
+	ErrDateBeforeEpoch = errors.New("date is earlier than the beginning time")
+	// ErrNoPlace is returned when place of hearing cannot be found
+	ErrNoPlace = errors.New("cannot get place")
+)
+
+// ParseError describes failed parsing of hearing content
+type ParseError struct {
+	// Err is one of causes: ErrEmptyContent, ErrNoTopics, ErrNoDate, ErrDateBeforeEpoch or ErrNoPlace
+	Err error
+	// Reason is details of failure
+	Reason string
+	// Paragraph is the offending paragraph of content
+	Paragraph string
+}
+
+func newParseError(err error, reason, paragraph string) *ParseError {
+	return &ParseError{
+		Err:       err,
+		Reason:    reason,
+		Paragraph: paragraph,
+	}
+}
+
+// Error returns text representation of parse error
+func (e *ParseError) Error() string {
+	msg := "failed parse content. " + e.Err.Error()
+	if e.Reason != "" {
+		msg += ". " + e.Reason
+	}
+	if e.Paragraph != "" {
+		msg += ": " + e.Paragraph
+	}
+	return msg
+}
+
+// Unwrap returns cause of parse error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidIgnoreRule is returned when ignore rule has unknown kind, empty pattern or invalid regular expression
+var ErrInvalidIgnoreRule = errors.New("invalid ignore rule")
+
+// ErrInvalidWebhook is returned when webhook has invalid URL or unknown event
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ErrInvalidUnsubscribe is returned when unsubscribe link of digest has wrong token
+var ErrInvalidUnsubscribe = errors.New("invalid unsubscribe link")
+
+// ErrInvalidSubscription is returned when subscription has unknown kind, district, category or too short keyword
+var ErrInvalidSubscription = errors.New("invalid subscription")
+
+// ErrInvalidReview is returned when edit of hearing by moderator is empty or malformed
+var ErrInvalidReview = errors.New("invalid review")
+
+// ErrReviewDecided is returned when decision is made about hearing which is already approved or rejected
+var ErrReviewDecided = errors.New("review is already decided")
@@ -16,8 +16,12 @@ package hearings
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
+	"time"
 
 	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
 
 	"github.com/brurbanko/mercury/internal/scrapper"
 
@@ -30,57 +34,113 @@ import (
 // Service to manage public hearings
 type Service struct {
 	logger *zerolog.Logger
-	parser *Parser
-	db     *database.Client
+	rules  *atomic.Value
+	db     database.Repository
 
-	scrapper  *scrapper.Scrapper
-	publisher *publisher.Publisher
+	scrapper *scrapper.Scrapper
+	targets  []publisher.Target
+	digest   *DigestConfig
+	bot      ChatPublisher
+	renderer *render.Renderer
+
+	moderation *ModerationConfig
+
+	reminders []reminder
 }
 
 // Config for hearings service
 type Config struct {
-	Database  *database.Client
-	Logger    *zerolog.Logger
-	Scrapper  *scrapper.Scrapper
-	Publisher *publisher.Publisher
+	Database database.Repository
+	Logger   *zerolog.Logger
+	Scrapper *scrapper.Scrapper
+	// Publisher is default telegram channel. Its messages are formatted as requested by caller of publishing.
+	Publisher publisher.Publisher
+	// Format of messages of Publisher. It overrides format requested by caller if set.
+	Format string
+	// Schedule of publishing to Publisher. Messages are published at any time if it is empty.
+	Schedule publisher.Schedule
+	// Targets are additional channels with their own formats. Names of targets must be unique.
+	Targets []publisher.Target
+	// Digest is email digest of hearings. Digest is disabled if nil.
+	Digest *DigestConfig
+	// Bot sends published hearings to subscribed chats. Subscriptions are not notified if nil.
+	Bot ChatPublisher
+	// Reminders are posted before hearings to all channels. Invalid reminders are skipped.
+	Reminders []Reminder
+	// Renderer renders messages of channels. Built-in templates are used if nil.
+	Renderer *render.Renderer
+	// Moderation holds new hearings for approval before publishing. Hearings are published at once if nil.
+	Moderation *ModerationConfig
 }
 
 // New returns an instance of hearing service
 func New(cfg *Config) *Service {
 	l := cfg.Logger.With().Str("service", "hearings").Logger()
-	return &Service{
+	s := &Service{
 		logger: &l,
+		rules:  &atomic.Value{},
 		db:     cfg.Database,
-		parser: NewParser(),
 
-		scrapper:  cfg.Scrapper,
-		publisher: cfg.Publisher,
+		scrapper: cfg.Scrapper,
+		digest:   cfg.Digest,
+		bot:      cfg.Bot,
+		renderer: cfg.Renderer,
+
+		moderation: cfg.Moderation,
+	}
+	if s.renderer == nil {
+		s.renderer = render.New()
 	}
+	if cfg.Publisher != nil {
+		s.targets = append(s.targets, publisher.Target{Name: ChannelTelegram, Format: cfg.Format, Publisher: cfg.Publisher, Schedule: cfg.Schedule})
+	}
+	s.targets = append(s.targets, cfg.Targets...)
+	for _, r := range cfg.Reminders {
+		compiled, err := compileReminders([]Reminder{r})
+		if err != nil {
+			l.Error().Err(err).Msg("reminder is skipped")
+			continue
+		}
+		s.reminders = append(s.reminders, compiled...)
+	}
+	// Default rules are always valid
+	_ = s.SetRules(DefaultRules())
+	return s
 }
 
-// List of hearings
-func (s Service) List(ctx context.Context) ([]domain.Hearing, error) {
-	links, err := s.db.List(ctx)
-
-	// Reverse slice. Older links will be at begin
-	for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
-		links[i], links[j] = links[j], links[i]
+// List of hearings with current statuses filtered, sorted and paginated by options.
+// Hearings pending review or rejected by moderators are not listed.
+func (s Service) List(ctx context.Context, opts database.ListOptions) (database.Page, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	opts.HideUnapproved = true
+	page, err := s.db.Query(ctx, opts)
+	if err != nil {
+		return page, err
 	}
-	return links, err
+	for i, h := range page.Hearings {
+		page.Hearings[i].Status = h.CurrentStatus(opts.Now)
+	}
+	return page, nil
 }
 
-// FetchLinks of public hearings
+// FetchLinks of public hearings from all sources
 func (s Service) FetchLinks(ctx context.Context) ([]string, error) {
-	links, err := s.scrapper.ExtractLinks(ctx,
-		"https://bga32.ru/arxitektura-i-gradostroitelstvo/publichnye-slushaniya/",
-		".thecontent ol li a",
-		true)
+	result := make([]string, 0)
+	for _, src := range s.sources() {
+		links, err := s.scrapper.ExtractLinks(ctx, src.URL, src.LinksSelector, true)
+		if err != nil {
+			return result, err
+		}
 
-	// Reverse slice. Older links will be at begin
-	for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
-		links[i], links[j] = links[j], links[i]
+		// Reverse slice. Older links will be at begin
+		for i, j := 0, len(links)-1; i < j; i, j = i+1, j-1 {
+			links[i], links[j] = links[j], links[i]
+		}
+		result = append(result, links...)
 	}
-	return links, err
+	return result, nil
 }
 
 // ProcessLink and get information about public hearing
@@ -91,14 +151,15 @@ func (s Service) ProcessLink(ctx context.Context, link string) (domain.Hearing,
 		Logger()
 	l.Info().Msg("processing hearing")
 	hearing := domain.Hearing{URL: link}
-	content, err := s.scrapper.ExtractContent(ctx, link, ".thecontent p", false)
+	src := sourceFor(s.sources(), link)
+	content, err := s.scrapper.ExtractContent(ctx, link, src.ContentSelector, false)
 	if err != nil {
 		l.Error().Err(err).Msg("failed to extract content")
 		return hearing, err
 	}
 	hearing.Raw = content
 
-	hp, err := s.parser.Content(hearing)
+	hp, err := src.parser.Content(hearing)
 	if err != nil {
 		l.Error().Err(err).Msg("failed to parse hearing content")
 		return hp, err
@@ -106,9 +167,106 @@ func (s Service) ProcessLink(ctx context.Context, link string) (domain.Hearing,
 	return hp, nil
 }
 
-// Find public hearing by URL
+// Preview of public hearing as it will be published
+type Preview struct {
+	Hearing  domain.Hearing `json:"hearing"`
+	Report   ParseReport    `json:"report"`
+	Text     string         `json:"text"`
+	Markdown string         `json:"markdown"`
+}
+
+// Preview parses passed paragraphs or content of the page by link without saving to database.
+// If paragraphs are empty, the page is fetched by link. Link may be outside the list of hearings.
+// Parsing errors are returned in report of preview.
+func (s Service) Preview(ctx context.Context, link string, paragraphs []string) (Preview, error) {
+	l := s.logger.With().
+		Str("method", "Preview").
+		Str("link", link).
+		Logger()
+	l.Info().Msg("preview hearing")
+	src := sourceFor(s.sources(), link)
+	if len(paragraphs) == 0 {
+		content, err := s.scrapper.ExtractContent(ctx, link, src.ContentSelector, false)
+		if err != nil {
+			l.Error().Err(err).Msg("failed to extract content")
+			return Preview{}, err
+		}
+		paragraphs = content
+	}
+
+	hp, report, err := src.parser.Report(domain.Hearing{URL: link, Raw: paragraphs})
+	if err != nil {
+		l.Debug().Err(err).Msg("failed to parse hearing content")
+	}
+	return Preview{
+		Hearing:  hp,
+		Report:   report,
+		Text:     s.Render(ChannelAPI, publisher.FormatText, hp),
+		Markdown: s.Render(ChannelAPI, publisher.FormatMarkdown, hp),
+	}, nil
+}
+
+// Search hearings by text of topics, proposals, place and paragraphs.
+// Results are ordered by relevance and have current statuses. Hearings pending review or rejected are not found.
+func (s Service) Search(ctx context.Context, query string, limit int) ([]database.SearchResult, error) {
+	res, err := s.db.Search(ctx, query, limit)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "Search").Str("query", query).Msg("failed to search hearings")
+		return res, err
+	}
+	now := time.Now()
+	for i := range res {
+		res[i].Hearing.Status = res[i].Hearing.CurrentStatus(now)
+	}
+	return res, nil
+}
+
+// Find public hearing by URL. Deleted hearings and hearings pending review or rejected are not found.
 func (s Service) Find(ctx context.Context, link string) (domain.Hearing, error) {
-	return s.db.Find(ctx, link)
+	h, err := s.db.Find(ctx, link)
+	return s.visible(ctx, h, err)
+}
+
+// Get public hearing by identifier. Deleted hearings and hearings pending review or rejected are not found.
+func (s Service) Get(ctx context.Context, id string) (domain.Hearing, error) {
+	h, err := s.db.Get(ctx, id)
+	return s.visible(ctx, h, err)
+}
+
+// visible returns database.ErrNotFound for deleted hearing and hearing held for review, and sets current status of other ones
+func (s Service) visible(ctx context.Context, h domain.Hearing, err error) (domain.Hearing, error) {
+	if err != nil {
+		return h, err
+	}
+	if h.Deleted {
+		return domain.Hearing{}, database.ErrNotFound
+	}
+	review, err := s.db.Review(ctx, h.ID)
+	switch {
+	case err == nil && review.State != database.ReviewApproved:
+		return domain.Hearing{}, database.ErrNotFound
+	case err != nil && !errors.Is(err, database.ErrNotFound):
+		return domain.Hearing{}, err
+	}
+	h.Status = h.CurrentStatus(time.Now())
+	return h, nil
+}
+
+// Delete public hearing by identifier. It is hidden from lists, search and publishing,
+// but its link is not processed again. Hearings held for review may be deleted too.
+func (s Service) Delete(ctx context.Context, id string) error {
+	h, err := s.db.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if h.Deleted {
+		return database.ErrNotFound
+	}
+	if err = s.db.Delete(ctx, id); err != nil {
+		s.logger.Error().Err(err).Str("method", "Delete").Str("id", id).Msg("failed to delete hearing")
+		return err
+	}
+	return nil
 }
 
 // NewHearings returns list of new hearings from site
@@ -134,15 +292,28 @@ func (s Service) NewHearings(ctx context.Context) ([]domain.Hearing, error) {
 		processedLinks[hearing.URL] = struct{}{}
 	}
 
+	ignored, err := s.ignoreMatcher(ctx)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get ignore rules")
+		return nil, err
+	}
+
 	newLinks := make([]string, 0)
 	for _, link := range links {
-		if _, ok := processedLinks[link]; !ok {
-			newLinks = append(newLinks, link)
+		if _, ok := processedLinks[link]; ok {
+			continue
+		}
+		if rule, ok := ignored.match(link); ok {
+			l.Debug().Str("link", link).Str("rule", rule.ID).Msg("link is ignored")
+			continue
 		}
+		newLinks = append(newLinks, link)
 	}
 
 	l.Info().Msgf("found %d new hearings", len(newLinks))
 	hearings := make([]domain.Hearing, 0)
+	// Hearings pending review are announced to webhooks when they are approved
+	created := make([]domain.Hearing, 0)
 	for _, link := range newLinks {
 		hearing, err := s.ProcessLink(ctx, link)
 		if err != nil {
@@ -150,37 +321,153 @@ func (s Service) NewHearings(ctx context.Context) ([]domain.Hearing, error) {
 			continue
 		}
 
-		err = s.db.Create(ctx, hearing)
+		state := database.ReviewApproved
+		if s.moderation != nil {
+			state, err = s.moderate(ctx, hearing)
+		} else {
+			err = s.db.Create(ctx, hearing)
+		}
 		if err != nil {
 			l.Error().Err(err).Str("link", link).Msg("failed to save hearing")
 			continue
 		}
+		// Get identifier and detected fields of saved hearing
+		if saved, err := s.db.Find(ctx, link); err == nil {
+			hearing = saved
+		}
+		if s.moderation != nil && hearing.ID != "" {
+			s.sendPreview(ctx, hearing.ID)
+		}
 
 		hearings = append(hearings, hearing)
+		if state == database.ReviewApproved {
+			created = append(created, hearing)
+		}
 	}
+	s.notify(ctx, WebhookCreated, created...)
 
 	return hearings, nil
 }
 
-// ListUnpublished returns list of unpublished hearings
-func (s Service) ListUnpublished(ctx context.Context, mark bool) ([]domain.IHearing, error) {
+// RefreshStatuses checks pages of hearings which are not held yet and detects cancelled or postponed ones.
+// Webhooks are notified about all changes. If publish is true, follow-up messages are enqueued to outbox for changed hearings which were already published.
+func (s Service) RefreshStatuses(ctx context.Context, format string, publish bool) ([]domain.Hearing, error) {
+	l := s.logger.With().Str("method", "RefreshStatuses").Logger()
+	l.Info().Msg("refreshing statuses of hearings")
+	list, err := s.db.List(ctx)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get list of hearings")
+		return nil, err
+	}
+
+	now := time.Now()
+	changed := make([]domain.Hearing, 0)
+	for _, h := range list {
+		if h.Deleted {
+			continue
+		}
+		switch h.CurrentStatus(now) {
+		case domain.StatusHeld, domain.StatusCancelled:
+			continue
+		}
+
+		src := sourceFor(s.sources(), h.URL)
+		content, err := s.scrapper.ExtractContent(ctx, h.URL, src.ContentSelector, true)
+		if err != nil {
+			l.Error().Err(err).Str("link", h.URL).Msg("failed to extract content")
+			continue
+		}
+
+		hp, err := src.parser.Content(domain.Hearing{URL: h.URL, Raw: content})
+		if err != nil {
+			// Page of cancelled hearing may be rewritten and cannot be parsed
+			hp = domain.Hearing{URL: h.URL, Raw: content, Status: src.parser.Status(content)}
+		} else if hp.Status == "" && !hp.Time.Equal(h.Time) {
+			hp.Status = domain.StatusPostponed
+		}
+		if hp.Status == "" || (hp.Status == h.Status && hp.Time.Equal(h.Time)) {
+			continue
+		}
+
+		l.Info().Str("link", h.URL).Str("status", string(hp.Status)).Msg("status of hearing changed")
+		if err = s.db.Update(ctx, hp); err != nil {
+			l.Error().Err(err).Str("link", h.URL).Msg("failed to update hearing")
+			continue
+		}
+		updated, err := s.db.Find(ctx, h.URL)
+		if err != nil {
+			l.Error().Err(err).Str("link", h.URL).Msg("failed to find hearing")
+			continue
+		}
+		changed = append(changed, updated)
+		if updated.Status == domain.StatusCancelled {
+			s.notify(ctx, WebhookCancelled, updated)
+		} else {
+			s.notify(ctx, WebhookUpdated, updated)
+		}
+
+		if !publish || !updated.Published {
+			continue
+		}
+		if _, err = s.enqueue(ctx, []domain.Hearing{updated}, statusEvent, format, false); err != nil {
+			l.Error().Err(err).Str("link", h.URL).Msg("failed to enqueue follow-up")
+			return changed, err
+		}
+		l.Info().Str("link", h.URL).Msg("follow-up enqueued")
+	}
+	if publish {
+		if _, err = s.Deliver(ctx); err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// ListUnpublished returns list of unpublished hearings. Hearings are not marked as published,
+// caller which publishes them by itself confirms it by Acknowledge.
+func (s Service) ListUnpublished(ctx context.Context) ([]domain.Hearing, error) {
 	l := s.logger.With().Str("method", "ListUnpublished").Logger()
 	l.Info().Msg("listing unpublished hearings")
-	unpublished, err := s.db.Unpublished(ctx, mark)
+	unpublished, err := s.db.Unpublished(ctx, false)
 	if err != nil {
 		l.Error().Err(err).Msg("failed to get unpublished hearings")
 		return nil, err
 	}
-	// Cast slice of domain.Hearing to slice of domain.IHearing
-	hearings := make([]domain.IHearing, 0)
+	return unpublished, nil
+}
+
+// Acknowledge marks unpublished hearings as published by caller without enqueueing their messages
+// and returns number of marked ones. Identifiers of published, unknown or not approved hearings are skipped.
+func (s Service) Acknowledge(ctx context.Context, ids []string) (int, error) {
+	l := s.logger.With().Str("method", "Acknowledge").Logger()
+	unpublished, err := s.db.Unpublished(ctx, false)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get unpublished hearings")
+		return 0, err
+	}
+	acknowledged := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		acknowledged[id] = struct{}{}
+	}
+	marked := 0
 	for _, h := range unpublished {
-		hearings = append(hearings, h)
+		if _, ok := acknowledged[h.ID]; !ok {
+			continue
+		}
+		if err = s.db.MarkPublished(ctx, h.URL); err != nil {
+			l.Error().Err(err).Str("id", h.ID).Msg("failed to mark hearing as published")
+			return marked, err
+		}
+		marked++
 	}
-	return hearings, nil
+	l.Info().Msgf("%d hearings are published by caller", marked)
+	return marked, nil
 }
 
-// Publish all unpublished hearings.
-// Get it from DB and publish them one by one to telegram channel.
+// Publish all unpublished hearings. Hearings pending review or rejected by moderators are not published.
+// Hearings are marked as published together with enqueueing their messages to outbox,
+// then due messages are delivered. Messages to channels with schedule wait for their slots in outbox. Failed deliveries are retried by RunOutbox.
+// Number of enqueued hearings is returned.
 func (s Service) Publish(ctx context.Context, format string) (int, error) {
 	l := s.logger.With().Str("method", "Publish").Logger()
 	l.Info().Msg("publishing new hearings")
@@ -190,25 +477,15 @@ func (s Service) Publish(ctx context.Context, format string) (int, error) {
 		return 0, err
 	}
 
-	for i, h := range unpublished {
-		var message string
-		if format == "markdown" {
-			message = h.Markdown()
-		} else {
-			message = h.String()
-		}
-		err = s.publisher.Publish(ctx, message)
-		if err != nil {
-			l.Error().Err(err).Str("link", h.URL).Msg("failed to publish hearing")
-			return i, err
-		}
-		l.Info().Str("link", h.URL).Msg("hearing published")
-		err = s.db.MarkPublished(ctx, h.URL)
-		if err != nil {
-			l.Error().Err(err).Str("link", h.URL).Msg("failed to mark hearing as published")
-			return i, err
-		}
+	_, err = s.enqueue(ctx, unpublished, func(domain.Hearing) string { return EventPublished }, format, true)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to enqueue hearings")
+		return 0, err
+	}
+	l.Info().Msgf("%d hearings enqueued", len(unpublished))
 
+	if _, err = s.Deliver(ctx); err != nil {
+		return len(unpublished), err
 	}
 	return len(unpublished), nil
 }
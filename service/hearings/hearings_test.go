@@ -0,0 +1,266 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/database/databasetest"
+	"github.com/brurbanko/mercury/database/memory"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/scrapper"
+)
+
+// fakeSite serves the list of hearings and pages of hearings
+type fakeSite struct {
+	*httptest.Server
+	mu    sync.Mutex
+	pages map[string][]string
+}
+
+func newFakeSite(t *testing.T) *fakeSite {
+	t.Helper()
+	site := &fakeSite{pages: make(map[string][]string)}
+	site.Server = httptest.NewServer(http.HandlerFunc(site.handle))
+	t.Cleanup(site.Close)
+	return site
+}
+
+func (f *fakeSite) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.URL.Path == "/list/" {
+		paths := make([]string, 0, len(f.pages))
+		for p := range f.pages {
+			paths = append(paths, p)
+		}
+		// Newer hearings are at the top of the list
+		sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+		_, _ = fmt.Fprint(w, `<div class="thecontent"><ol>`)
+		for _, p := range paths {
+			_, _ = fmt.Fprintf(w, `<li><a href="%s%s">hearing</a></li>`, f.URL, p)
+		}
+		_, _ = fmt.Fprint(w, `</ol></div>`)
+		return
+	}
+
+	content, ok := f.pages[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = fmt.Fprintf(w, `<div class="thecontent"><p>%s</p></div>`, strings.Join(content, "</p><p>"))
+}
+
+func (f *fakeSite) setPage(path string, content ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pages[path] = content
+}
+
+func newTestService(t *testing.T, site *fakeSite) (*Service, *memory.Repository) {
+	t.Helper()
+	repo := memory.New()
+	return newTestServiceWith(t, site, repo), repo
+}
+
+// newSQLiteTestService returns service with SQLite database in temporary directory
+func newSQLiteTestService(t *testing.T, site *fakeSite) (*Service, *database.Client) {
+	t.Helper()
+	logger := zerolog.Nop()
+	repo, err := database.New("sqlite://"+filepath.Join(t.TempDir(), "test"), &logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	return newTestServiceWith(t, site, repo), repo
+}
+
+// inMoscow sets local time zone and time zone of parsed hearings to Moscow for one test
+func inMoscow(t *testing.T) {
+	t.Helper()
+	databasetest.SetLocal(t, databasetest.Moscow)
+	loc := serviceTimeLocation
+	serviceTimeLocation = databasetest.Moscow
+	t.Cleanup(func() { serviceTimeLocation = loc })
+}
+
+func newTestServiceWith(t *testing.T, site *fakeSite, repo database.Repository) *Service {
+	t.Helper()
+	logger := zerolog.Nop()
+	// Publisher without token skips publishing
+	pub, err := publisher.NewTelegram(&publisher.TelegramOptions{Logger: &logger})
+	require.NoError(t, err)
+
+	s := New(&Config{
+		Database:  repo,
+		Logger:    &logger,
+		Scrapper:  scrapper.New(&scrapper.Options{CacheDir: t.TempDir()}),
+		Publisher: pub,
+	})
+	src := DefaultSource()
+	src.URL = site.URL + "/list/"
+	require.NoError(t, s.SetRules(Rules{Sources: []Source{src}}))
+	return s
+}
+
+var testHearingContent = []string{
+	"17 марта 2099 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания по следующим вопросам:",
+	"-по проекту планировки территории по ул. Фосфоритной;",
+	"-по проекту межевания территории по ул. Речной;",
+	"Экспозиция проекта будет проводиться с 25 января по 25 февраля 2099 года",
+	"Приём предложений осуществляет оргкомитет до 16 марта 2099 года",
+}
+
+func TestService_NewHearings(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	site.setPage("/2/", "Итоги публичных слушаний")
+	s, _ := newTestService(t, site)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1, "page which cannot be parsed must be skipped")
+	require.Equal(t, site.URL+"/1/", found[0].URL)
+	require.NotEmpty(t, found[0].ID)
+	require.Equal(t, []string{
+		"по проекту планировки территории по ул. Фосфоритной",
+		"по проекту межевания территории по ул. Речной",
+	}, found[0].Topic)
+
+	found, err = s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Empty(t, found, "processed hearings must not be found again")
+
+	page, err := s.List(ctx, database.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Hearings, 1)
+	require.Equal(t, domain.StatusAnnounced, page.Hearings[0].Status)
+	require.Equal(t, domain.CategoryPlanning, page.Hearings[0].Category)
+	require.Equal(t, domain.DistrictSovetsky, page.Hearings[0].District)
+
+	page, err = s.List(ctx, database.ListOptions{Statuses: []domain.Status{domain.StatusHeld}})
+	require.NoError(t, err)
+	require.Empty(t, page.Hearings)
+}
+
+func TestService_Publish(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, _ := newTestService(t, site)
+
+	_, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+
+	unpublished, err := s.ListUnpublished(ctx)
+	require.NoError(t, err)
+	require.Len(t, unpublished, 1)
+
+	cnt, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Equal(t, 1, cnt)
+
+	unpublished, err = s.ListUnpublished(ctx)
+	require.NoError(t, err)
+	require.Empty(t, unpublished)
+
+	page, err := s.List(ctx, database.ListOptions{Statuses: []domain.Status{domain.StatusUpcoming}})
+	require.NoError(t, err)
+	require.Len(t, page.Hearings, 1)
+}
+
+func TestService_RefreshStatuses(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	_, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+
+	changed, err := s.RefreshStatuses(ctx, "markdown", false)
+	require.NoError(t, err)
+	require.Empty(t, changed)
+
+	site.setPage("/1/", append([]string{"Публичные слушания ОТМЕНЕНЫ."}, testHearingContent...)...)
+	changed, err = s.RefreshStatuses(ctx, "markdown", true)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	require.Equal(t, domain.StatusCancelled, changed[0].Status)
+
+	h, err := repo.Find(ctx, site.URL+"/1/")
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusCancelled, h.Status)
+}
+
+func TestService_RefreshStatuses_sqlite(t *testing.T) {
+	inMoscow(t)
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newSQLiteTestService(t, site)
+
+	_, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	_, err = s.Publish(ctx, publisher.FormatMarkdown)
+	require.NoError(t, err)
+
+	changed, err := s.RefreshStatuses(ctx, publisher.FormatMarkdown, true)
+	require.NoError(t, err)
+	require.Empty(t, changed, "unchanged hearing must not be postponed")
+
+	h, err := repo.Find(ctx, site.URL+"/1/")
+	require.NoError(t, err)
+	require.Equal(t, domain.StatusAnnounced, h.Status)
+	require.Equal(t, time.Date(2099, time.March, 17, 11, 0, 0, 0, databasetest.Moscow), h.Time.In(databasetest.Moscow))
+}
+
+func TestService_Preview(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	preview, err := s.Preview(ctx, site.URL+"/1/", nil)
+	require.NoError(t, err)
+	require.Empty(t, preview.Report.Error)
+	require.Len(t, preview.Hearing.Topic, 2)
+	require.NotEmpty(t, preview.Text)
+	require.NotEmpty(t, preview.Markdown)
+
+	preview, err = s.Preview(ctx, "", []string{"Итоги публичных слушаний"})
+	require.NoError(t, err)
+	require.Equal(t, ErrNoTopics.Error(), preview.Report.Cause)
+
+	list, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Empty(t, list, "preview must not save hearings")
+}
@@ -0,0 +1,155 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+)
+
+// ignoreMatcher checks links by compiled ignore rules
+type ignoreMatcher struct {
+	urls     map[string]database.IgnoreRule
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	rule database.IgnoreRule
+	re   *regexp.Regexp
+}
+
+// compileIgnoreRule returns regular expression of pattern rule or nil for URL rule
+func compileIgnoreRule(rule database.IgnoreRule) (*regexp.Regexp, error) {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return nil, fmt.Errorf("%w: empty pattern", ErrInvalidIgnoreRule)
+	}
+	switch rule.Kind {
+	case database.IgnoreURL:
+		return nil, nil
+	case database.IgnorePattern:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidIgnoreRule, err)
+		}
+		return re, nil
+	}
+	return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidIgnoreRule, rule.Kind)
+}
+
+func newIgnoreMatcher(rules []database.IgnoreRule) ignoreMatcher {
+	m := ignoreMatcher{urls: make(map[string]database.IgnoreRule)}
+	for _, rule := range rules {
+		re, err := compileIgnoreRule(rule)
+		if err != nil {
+			continue
+		}
+		if re == nil {
+			m.urls[rule.Pattern] = rule
+		} else {
+			m.patterns = append(m.patterns, ignorePattern{rule: rule, re: re})
+		}
+	}
+	return m
+}
+
+// match returns the first rule ignoring link
+func (m ignoreMatcher) match(link string) (database.IgnoreRule, bool) {
+	if rule, ok := m.urls[link]; ok {
+		return rule, true
+	}
+	for _, p := range m.patterns {
+		if p.re.MatchString(link) {
+			return p.rule, true
+		}
+	}
+	return database.IgnoreRule{}, false
+}
+
+// ignoreMatcher returns matcher of rules saved in database
+func (s Service) ignoreMatcher(ctx context.Context) (ignoreMatcher, error) {
+	rules, err := s.db.IgnoreRules(ctx)
+	if err != nil {
+		return ignoreMatcher{}, err
+	}
+	return newIgnoreMatcher(rules), nil
+}
+
+// IgnoreRules returns all rules of ignored links
+func (s Service) IgnoreRules(ctx context.Context) ([]database.IgnoreRule, error) {
+	rules, err := s.db.IgnoreRules(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "IgnoreRules").Msg("failed to get ignore rules")
+	}
+	return rules, err
+}
+
+// Ignore saves rule of ignored links and deletes saved hearings matched by it.
+// Links matched by rule are not processed by NewHearings.
+// ErrInvalidIgnoreRule is returned for invalid rule and database.ErrAlreadyExists for duplicate one.
+func (s Service) Ignore(ctx context.Context, rule database.IgnoreRule) (database.IgnoreRule, []domain.Hearing, error) {
+	l := s.logger.With().Str("method", "Ignore").Str("kind", rule.Kind).Str("pattern", rule.Pattern).Logger()
+	deleted := make([]domain.Hearing, 0)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if _, err := compileIgnoreRule(rule); err != nil {
+		return rule, deleted, err
+	}
+
+	rule, err := s.db.AddIgnoreRule(ctx, rule)
+	if err != nil {
+		if !errors.Is(err, database.ErrAlreadyExists) {
+			l.Error().Err(err).Msg("failed to save ignore rule")
+		}
+		return rule, deleted, err
+	}
+	l.Info().Str("id", rule.ID).Msg("ignore rule added")
+
+	list, err := s.db.List(ctx)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get list of hearings")
+		return rule, deleted, err
+	}
+	matcher := newIgnoreMatcher([]database.IgnoreRule{rule})
+	for _, h := range list {
+		if h.Deleted {
+			continue
+		}
+		if _, ok := matcher.match(h.URL); !ok {
+			continue
+		}
+		if err = s.db.Delete(ctx, h.ID); err != nil {
+			l.Error().Err(err).Str("link", h.URL).Msg("failed to delete ignored hearing")
+			return rule, deleted, err
+		}
+		l.Info().Str("link", h.URL).Msg("ignored hearing deleted")
+		h.Deleted = true
+		deleted = append(deleted, h)
+	}
+	return rule, deleted, nil
+}
+
+// Unignore deletes rule of ignored links by identifier. Deleted hearings are not restored.
+func (s Service) Unignore(ctx context.Context, id string) error {
+	err := s.db.DeleteIgnoreRule(ctx, id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.logger.Error().Err(err).Str("method", "Unignore").Str("id", id).Msg("failed to delete ignore rule")
+	}
+	return err
+}
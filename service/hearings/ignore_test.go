@@ -0,0 +1,91 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := newIgnoreMatcher([]database.IgnoreRule{
+		{ID: "1", Kind: database.IgnoreURL, Pattern: "https://bga32.ru/notice/"},
+		{ID: "2", Kind: database.IgnorePattern, Pattern: `/itogi-\d+/$`},
+		{ID: "3", Kind: database.IgnorePattern, Pattern: `(`},
+	})
+
+	rule, ok := m.match("https://bga32.ru/notice/")
+	require.True(t, ok)
+	require.Equal(t, "1", rule.ID)
+	rule, ok = m.match("https://bga32.ru/itogi-12/")
+	require.True(t, ok)
+	require.Equal(t, "2", rule.ID)
+	_, ok = m.match("https://bga32.ru/notice/2/")
+	require.False(t, ok, "URL rule must match link exactly")
+}
+
+func TestService_Ignore(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	site.setPage("/2/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	_, _, err := s.Ignore(ctx, database.IgnoreRule{Kind: database.IgnorePattern, Pattern: "("})
+	require.ErrorIs(t, err, ErrInvalidIgnoreRule)
+	_, _, err = s.Ignore(ctx, database.IgnoreRule{Kind: "host", Pattern: "bga32.ru"})
+	require.ErrorIs(t, err, ErrInvalidIgnoreRule)
+	_, _, err = s.Ignore(ctx, database.IgnoreRule{Kind: database.IgnoreURL, Pattern: " "})
+	require.ErrorIs(t, err, ErrInvalidIgnoreRule)
+
+	rule, deleted, err := s.Ignore(ctx, database.IgnoreRule{Kind: database.IgnoreURL, Pattern: site.URL + "/2/", Reason: "notice"})
+	require.NoError(t, err)
+	require.NotEmpty(t, rule.ID)
+	require.Empty(t, deleted)
+	_, _, err = s.Ignore(ctx, database.IgnoreRule{Kind: database.IgnoreURL, Pattern: site.URL + "/2/"})
+	require.ErrorIs(t, err, database.ErrAlreadyExists)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1, "ignored link must be skipped")
+	require.Equal(t, site.URL+"/1/", found[0].URL)
+
+	// Ignoring saved hearing deletes it
+	_, deleted, err = s.Ignore(ctx, database.IgnoreRule{Kind: database.IgnorePattern, Pattern: `/1/$`})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, found[0].ID, deleted[0].ID)
+	_, err = s.Get(ctx, found[0].ID)
+	require.ErrorIs(t, err, database.ErrNotFound)
+	unpublished, err := repo.Unpublished(ctx, false)
+	require.NoError(t, err)
+	require.Empty(t, unpublished)
+
+	// Removed rule lets the link to be processed again
+	require.NoError(t, s.Unignore(ctx, rule.ID))
+	require.ErrorIs(t, s.Unignore(ctx, rule.ID), database.ErrNotFound)
+	found, err = s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, site.URL+"/2/", found[0].URL)
+
+	rules, err := s.IgnoreRules(ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+}
@@ -0,0 +1,359 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
+)
+
+// ModeratorAuto is moderator of reviews approved automatically
+const ModeratorAuto = "auto"
+
+// Actions of buttons of preview. Data of button is action and identifier of hearing, for example approve:12.
+const (
+	ActionApprove = "approve"
+	ActionEdit    = "edit"
+	ActionReject  = "reject"
+)
+
+// Limits of sane hearing. Hearing outside of them is not approved automatically.
+const (
+	reviewHorizon        = 365 * 24 * time.Hour
+	reviewMinPlaceLength = 10
+	reviewMaxPlaceLength = 300
+	reviewMinTopicLength = 10
+	reviewFirstHour      = 8
+	reviewLastHour       = 20
+)
+
+// ModerationConfig of moderation of new hearings before publishing
+type ModerationConfig struct {
+	// AutoApprove approves new hearings without problems of parsing at once
+	AutoApprove bool
+	// Chat of moderators which previews with buttons are sent to. Previews are not sent if empty.
+	Chat string
+	// Sender sends previews to chat of moderators, for example publisher.Telegram
+	Sender ModerationSender
+}
+
+// ModerationSender sends messages with inline buttons. It is implemented by publisher.Telegram.
+type ModerationSender interface {
+	Send(ctx context.Context, chatID string, m publisher.TelegramMessage) error
+}
+
+// ReviewItem is hearing in moderation queue with its preview
+type ReviewItem struct {
+	Review  database.Review `json:"review"`
+	Hearing domain.Hearing  `json:"hearing"`
+	// Problems of parsing which need attention of moderator
+	Problems []string `json:"problems"`
+	// Preview is markdown of hearing as it will be published
+	Preview string `json:"preview"`
+}
+
+// ReviewEdit is correction of hearing by moderator. Empty fields are not changed.
+type ReviewEdit struct {
+	Place     string    `json:"place"`
+	Time      time.Time `json:"time"`
+	Topic     []string  `json:"topic"`
+	Proposals []string  `json:"proposals"`
+}
+
+// empty reports whether edit changes nothing
+func (e ReviewEdit) empty() bool {
+	return e.Place == "" && e.Time.IsZero() && len(e.Topic) == 0 && len(e.Proposals) == 0
+}
+
+// ReviewProblems returns problems of parsed hearing which need attention of moderator.
+// Hearing without problems is parsed with high confidence.
+func ReviewProblems(h domain.Hearing, now time.Time) []string {
+	problems := make([]string, 0)
+	switch {
+	case h.Time.Before(now):
+		problems = append(problems, "дата слушаний уже прошла")
+	case h.Time.After(now.Add(reviewHorizon)):
+		problems = append(problems, "дата слушаний больше чем через год")
+	}
+	if hour := h.Time.Hour(); hour < reviewFirstHour || hour > reviewLastHour {
+		problems = append(problems, "необычное время слушаний "+h.Time.Format("15:04"))
+	}
+	switch n := utf8.RuneCountInString(h.Place); {
+	case n < reviewMinPlaceLength:
+		problems = append(problems, "слишком короткое место проведения")
+	case n > reviewMaxPlaceLength:
+		problems = append(problems, "слишком длинное место проведения")
+	}
+	if len(h.Topic) == 0 {
+		problems = append(problems, "не найдены вопросы слушаний")
+	}
+	for _, topic := range h.Topic {
+		if utf8.RuneCountInString(topic) < reviewMinTopicLength {
+			problems = append(problems, fmt.Sprintf("слишком короткий вопрос %q", topic))
+		}
+	}
+	if len(h.Proposals) == 0 {
+		problems = append(problems, "не найден порядок приёма предложений")
+	}
+	return problems
+}
+
+// moderate saves new hearing together with its review, so it is never published before moderation.
+// Hearing is put to moderation queue or approved automatically.
+func (s Service) moderate(ctx context.Context, h domain.Hearing) (database.ReviewState, error) {
+	l := s.logger.With().Str("method", "moderate").Str("link", h.URL).Logger()
+	review := database.Review{State: database.ReviewPending}
+	if s.moderation.AutoApprove && len(ReviewProblems(h, time.Now())) == 0 {
+		review.State, review.Moderator = database.ReviewApproved, ModeratorAuto
+	}
+	if err := s.db.CreateWithReview(ctx, h, review); err != nil {
+		return "", err
+	}
+	l.Info().Str("state", string(review.State)).Msg("hearing is moderated")
+	return review.State, nil
+}
+
+// sendPreview of pending hearing to chat of moderators with buttons of decisions
+func (s Service) sendPreview(ctx context.Context, id string) {
+	cfg := s.moderation
+	if cfg == nil || cfg.Chat == "" || cfg.Sender == nil {
+		return
+	}
+	l := s.logger.With().Str("method", "sendPreview").Str("id", id).Logger()
+	item, err := s.ReviewItem(ctx, id)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get review")
+		return
+	}
+	if item.Review.State != database.ReviewPending {
+		return
+	}
+	text := domain.EscapeMarkdown("На модерации слушание " + id)
+	if len(item.Problems) > 0 {
+		text += "\n" + domain.EscapeMarkdown("Проверьте: "+strings.Join(item.Problems, "; "))
+	}
+	err = cfg.Sender.Send(ctx, cfg.Chat, publisher.TelegramMessage{
+		Text:      text + "\n\n" + item.Preview,
+		ParseMode: publisher.ParseModeMarkdownV2,
+		Buttons: [][]publisher.Button{{
+			{Text: "Одобрить", Data: ActionApprove + ":" + id},
+			{Text: "Исправить", Data: ActionEdit + ":" + id},
+			{Text: "Отклонить", Data: ActionReject + ":" + id},
+		}},
+	})
+	if err != nil {
+		l.Error().Err(err).Msg("failed to send preview to moderators")
+	}
+}
+
+// item returns review with hearing and its preview
+func (s Service) item(ctx context.Context, review database.Review) (ReviewItem, error) {
+	h, err := s.db.Get(ctx, review.HearingID)
+	if err != nil {
+		return ReviewItem{}, err
+	}
+	h.Status = h.CurrentStatus(time.Now())
+	return ReviewItem{
+		Review:   review,
+		Hearing:  h,
+		Problems: ReviewProblems(h, review.CreatedAt),
+		Preview:  s.message(ChannelTelegram, publisher.FormatMarkdown, EventPublished, render.Data{Hearing: h}),
+	}, nil
+}
+
+// Reviews returns hearings of moderation queue in state in order of their arrival.
+// All moderated hearings are returned for empty state.
+func (s Service) Reviews(ctx context.Context, state database.ReviewState) ([]ReviewItem, error) {
+	l := s.logger.With().Str("method", "Reviews").Str("state", string(state)).Logger()
+	reviews, err := s.db.Reviews(ctx, state)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get reviews")
+		return nil, err
+	}
+	items := make([]ReviewItem, 0, len(reviews))
+	for _, review := range reviews {
+		item, err := s.item(ctx, review)
+		if err != nil {
+			l.Error().Err(err).Str("id", review.HearingID).Msg("failed to get hearing of review")
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ReviewItem returns hearing of moderation queue by identifier.
+// database.ErrNotFound is returned if hearing is not moderated.
+func (s Service) ReviewItem(ctx context.Context, id string) (ReviewItem, error) {
+	review, err := s.db.Review(ctx, id)
+	if err != nil {
+		return ReviewItem{}, err
+	}
+	return s.item(ctx, review)
+}
+
+// Approve pending hearing, so it is published by the next publishing.
+// ErrReviewDecided is returned if hearing is already approved or rejected.
+func (s Service) Approve(ctx context.Context, id, moderator string) (ReviewItem, error) {
+	return s.decide(ctx, id, database.Review{HearingID: id, State: database.ReviewApproved, Moderator: moderator})
+}
+
+// Reject pending hearing, so it is never published.
+// ErrReviewDecided is returned if hearing is already approved or rejected.
+func (s Service) Reject(ctx context.Context, id, moderator, comment string) (ReviewItem, error) {
+	return s.decide(ctx, id, database.Review{HearingID: id, State: database.ReviewRejected, Moderator: moderator, Comment: comment})
+}
+
+// decide saves decision of moderator about pending hearing.
+// Webhooks are notified about approved hearing as about new one, because pending hearings are hidden from them.
+func (s Service) decide(ctx context.Context, id string, review database.Review) (ReviewItem, error) {
+	l := s.logger.With().Str("method", "decide").Str("id", id).Str("state", string(review.State)).Logger()
+	if err := s.updatePendingReview(ctx, review); err != nil {
+		if !errors.Is(err, ErrReviewDecided) && !errors.Is(err, database.ErrNotFound) {
+			l.Error().Err(err).Msg("failed to save review")
+		}
+		return ReviewItem{}, err
+	}
+	l.Info().Str("moderator", review.Moderator).Msg("review is decided")
+	item, err := s.ReviewItem(ctx, id)
+	if err != nil {
+		return item, err
+	}
+	if review.State == database.ReviewApproved {
+		s.notify(ctx, WebhookCreated, item.Hearing)
+	}
+	return item, nil
+}
+
+// EditReview corrects pending hearing and sends its new preview to moderators. Hearing stays pending.
+// ErrInvalidReview is returned for empty edit, ErrReviewDecided is returned if hearing is already approved or rejected.
+func (s Service) EditReview(ctx context.Context, id, moderator string, edit ReviewEdit) (ReviewItem, error) {
+	l := s.logger.With().Str("method", "EditReview").Str("id", id).Logger()
+	edit.Place = strings.TrimSpace(edit.Place)
+	edit.Topic = nonEmpty(edit.Topic)
+	edit.Proposals = nonEmpty(edit.Proposals)
+	if edit.empty() {
+		return ReviewItem{}, fmt.Errorf("%w: nothing to change", ErrInvalidReview)
+	}
+	item, err := s.ReviewItem(ctx, id)
+	if err != nil {
+		return ReviewItem{}, err
+	}
+	// Review is claimed before editing, so hearing approved or rejected meanwhile is not edited
+	item.Review.State = database.ReviewPending
+	item.Review.Moderator = moderator
+	item.Review.Comment = "исправлено"
+	if err = s.updatePendingReview(ctx, item.Review); err != nil {
+		if !errors.Is(err, ErrReviewDecided) && !errors.Is(err, database.ErrNotFound) {
+			l.Error().Err(err).Msg("failed to save review")
+		}
+		return ReviewItem{}, err
+	}
+	err = s.db.Update(ctx, domain.Hearing{
+		URL:       item.Hearing.URL,
+		Place:     edit.Place,
+		Time:      edit.Time,
+		Topic:     edit.Topic,
+		Proposals: edit.Proposals,
+	})
+	if err != nil {
+		l.Error().Err(err).Msg("failed to update hearing")
+		return ReviewItem{}, err
+	}
+	l.Info().Str("moderator", moderator).Msg("hearing is edited")
+	s.sendPreview(ctx, id)
+	return s.ReviewItem(ctx, id)
+}
+
+// updatePendingReview saves review of pending hearing. ErrReviewDecided is returned if hearing is already approved or rejected.
+func (s Service) updatePendingReview(ctx context.Context, review database.Review) error {
+	err := s.db.UpdatePendingReview(ctx, review)
+	if errors.Is(err, database.ErrReviewDecided) {
+		return fmt.Errorf("%w: hearing %s", ErrReviewDecided, review.HearingID)
+	}
+	return err
+}
+
+// nonEmpty returns trimmed strings without empty ones
+func nonEmpty(list []string) []string {
+	res := make([]string, 0, len(list))
+	for _, v := range list {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// ParseReviewEdit parses edit of hearing from lines "field: value" of moderator message.
+// Fields are место, время (02.01.2006 15:04), вопрос and предложения or their english names place, time, topic and proposals.
+// Questions and proposals may be repeated.
+func ParseReviewEdit(text string) (ReviewEdit, error) {
+	var edit ReviewEdit
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return edit, fmt.Errorf("%w: line without field %q", ErrInvalidReview, line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "место", "place":
+			edit.Place = value
+		case "время", "time":
+			t, err := time.ParseInLocation("02.01.2006 15:04", value, serviceTimeLocation)
+			if err != nil {
+				return edit, fmt.Errorf("%w: time must be like 17.03.2022 11:00", ErrInvalidReview)
+			}
+			edit.Time = t
+		case "вопрос", "topic":
+			edit.Topic = append(edit.Topic, value)
+		case "предложения", "proposals":
+			edit.Proposals = append(edit.Proposals, value)
+		default:
+			return edit, fmt.Errorf("%w: unknown field %q", ErrInvalidReview, field)
+		}
+	}
+	if edit.empty() {
+		return edit, fmt.Errorf("%w: nothing to change", ErrInvalidReview)
+	}
+	return edit, nil
+}
+
+// heldForReview returns identifiers of hearings which are pending review or rejected
+func (s Service) heldForReview(ctx context.Context) (map[string]bool, error) {
+	reviews, err := s.db.Reviews(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]bool)
+	for _, r := range reviews {
+		if r.State != database.ReviewApproved {
+			held[r.HearingID] = true
+		}
+	}
+	return held, nil
+}
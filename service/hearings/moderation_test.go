@@ -0,0 +1,209 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+// fakeModerators records previews sent to chat of moderators
+type fakeModerators struct {
+	mu       sync.Mutex
+	messages []publisher.TelegramMessage
+}
+
+func (f *fakeModerators) Send(_ context.Context, chatID string, m publisher.TelegramMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if chatID != "moderators" {
+		return fmt.Errorf("unexpected chat %s", chatID)
+	}
+	f.messages = append(f.messages, m)
+	return nil
+}
+
+// soonHearingContent is content of hearing in two months which has no problems for moderators
+func soonHearingContent() []string {
+	months := []string{"января", "февраля", "марта", "апреля", "мая", "июня",
+		"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+	d := time.Now().AddDate(0, 2, 0)
+	return []string{
+		fmt.Sprintf("%d %s %d года в 11.00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания по проекту планировки территории по ул. Фосфоритной",
+			d.Day(), months[d.Month()-1], d.Year()),
+		"Приём предложений осуществляет оргкомитет до дня проведения слушаний",
+	}
+}
+
+func TestReviewProblems(t *testing.T) {
+	now := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+	h := domain.Hearing{
+		Time:      time.Date(2022, time.March, 17, 11, 0, 0, 0, time.UTC),
+		Place:     "ГДК Советского района (ул. Калинина, д. 66)",
+		Topic:     []string{"по проекту планировки территории"},
+		Proposals: []string{"Приём предложений до 16 марта"},
+	}
+	require.Empty(t, ReviewProblems(h, now))
+
+	bad := h
+	bad.Time = time.Date(2023, time.June, 1, 3, 0, 0, 0, time.UTC)
+	bad.Place = "ГДК"
+	bad.Topic = []string{"по ПП"}
+	bad.Proposals = nil
+	require.Equal(t, []string{
+		"дата слушаний больше чем через год",
+		"необычное время слушаний 03:00",
+		"слишком короткое место проведения",
+		`слишком короткий вопрос "по ПП"`,
+		"не найден порядок приёма предложений",
+	}, ReviewProblems(bad, now))
+
+	bad = h
+	bad.Time = now.Add(-time.Hour)
+	bad.Topic = nil
+	require.Equal(t, []string{"дата слушаний уже прошла", "не найдены вопросы слушаний"}, ReviewProblems(bad, now))
+}
+
+func TestService_moderation(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	site.setPage("/2/", soonHearingContent()...)
+	s, repo := newTestService(t, site)
+	moderators := &fakeModerators{}
+	s.moderation = &ModerationConfig{AutoApprove: true, Chat: "moderators", Sender: moderators}
+	hook, err := s.AddWebhook(ctx, "https://example.com/hook", "", []string{WebhookCreated})
+	require.NoError(t, err)
+	created := func() []string {
+		deliveries, err := s.WebhookDeliveries(ctx, hook.ID)
+		require.NoError(t, err)
+		ids := make([]string, 0, len(deliveries))
+		for _, d := range deliveries {
+			ids = append(ids, d.HearingID)
+		}
+		return ids
+	}
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	ids := map[string]string{}
+	for _, h := range found {
+		ids[strings.TrimPrefix(h.URL, site.URL)] = h.ID
+	}
+	require.Equal(t, []string{ids["/2/"]}, created(), "webhooks must not be notified about pending hearing")
+
+	auto, err := repo.Review(ctx, ids["/2/"])
+	require.NoError(t, err)
+	require.Equal(t, database.ReviewApproved, auto.State, "hearing without problems must be approved automatically")
+	require.Equal(t, ModeratorAuto, auto.Moderator)
+
+	pending, err := s.Reviews(ctx, database.ReviewPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	id := pending[0].Hearing.ID
+	require.Equal(t, ids["/1/"], id)
+	require.Equal(t, []string{"дата слушаний больше чем через год"}, pending[0].Problems)
+	require.Contains(t, pending[0].Preview, "ГДК Советского района")
+
+	require.Len(t, moderators.messages, 1, "preview is sent for pending hearing only")
+	preview := moderators.messages[0]
+	require.Equal(t, publisher.ParseModeMarkdownV2, preview.ParseMode)
+	require.Contains(t, preview.Text, "больше чем через год")
+	require.Equal(t, [][]publisher.Button{{
+		{Text: "Одобрить", Data: "approve:" + id},
+		{Text: "Исправить", Data: "edit:" + id},
+		{Text: "Отклонить", Data: "reject:" + id},
+	}}, preview.Buttons)
+
+	n, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "pending hearing must not be published")
+
+	_, err = s.EditReview(ctx, id, "admin", ReviewEdit{})
+	require.ErrorIs(t, err, ErrInvalidReview)
+	edited, err := s.EditReview(ctx, id, "admin", ReviewEdit{Place: "актовом зале администрации Советского района"})
+	require.NoError(t, err)
+	require.Equal(t, "актовом зале администрации Советского района", edited.Hearing.Place)
+	require.Equal(t, database.ReviewPending, edited.Review.State)
+	require.Len(t, moderators.messages, 2, "new preview must be sent after edit")
+
+	approved, err := s.Approve(ctx, id, "admin")
+	require.NoError(t, err)
+	require.Equal(t, database.ReviewApproved, approved.Review.State)
+	require.Equal(t, "admin", approved.Review.Moderator)
+	require.Equal(t, []string{ids["/2/"], id}, created(), "webhooks must be notified about approved hearing")
+	_, err = s.Reject(ctx, id, "admin", "поздно")
+	require.ErrorIs(t, err, ErrReviewDecided)
+	_, err = s.Approve(ctx, "100500", "admin")
+	require.ErrorIs(t, err, database.ErrNotFound)
+
+	n, err = s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "approved hearing must be published")
+}
+
+func TestService_moderation_reject(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", soonHearingContent()...)
+	s, _ := newTestService(t, site)
+	s.moderation = &ModerationConfig{}
+	_, err := s.AddWebhook(ctx, "https://example.com/hook", "", nil)
+	require.NoError(t, err)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	rejected, err := s.Reject(ctx, found[0].ID, "admin", "дубликат")
+	require.NoError(t, err)
+	require.Equal(t, database.ReviewRejected, rejected.Review.State)
+	require.Equal(t, "дубликат", rejected.Review.Comment)
+	deliveries, err := s.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, deliveries, "rejected hearing is not announced to anyone")
+
+	n, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Zero(t, n, "rejected hearing must not be published")
+	all, err := s.Reviews(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+}
+
+func TestParseReviewEdit(t *testing.T) {
+	edit, err := ParseReviewEdit("Место: ГДК Советского района\nвремя: 17.03.2022 11:00\n\nвопрос: о сносе\ntopic: о застройке\nпредложения: до 16 марта")
+	require.NoError(t, err)
+	require.Equal(t, "ГДК Советского района", edit.Place)
+	require.Equal(t, time.Date(2022, time.March, 17, 11, 0, 0, 0, serviceTimeLocation), edit.Time)
+	require.Equal(t, []string{"о сносе", "о застройке"}, edit.Topic)
+	require.Equal(t, []string{"до 16 марта"}, edit.Proposals)
+
+	for _, text := range []string{"", "просто текст", "время: завтра", "цвет: красный"} {
+		_, err = ParseReviewEdit(text)
+		require.ErrorIs(t, err, ErrInvalidReview, text)
+	}
+}
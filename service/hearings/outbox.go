@@ -0,0 +1,360 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
+)
+
+// Channels of messages. Templates of channel may be overridden in directory of templates.
+const (
+	// ChannelTelegram is channel of deliveries to telegram
+	ChannelTelegram = "telegram"
+	// ChannelAPI is channel of hearings returned by API in text formats
+	ChannelAPI = "api"
+	// ChannelBot is channel of messages of Telegram bot to subscribed chats and of hearings sent on request
+	ChannelBot = "bot"
+)
+
+// Events of deliveries
+const (
+	// EventPublished is announcement of new hearing
+	EventPublished = "published"
+)
+
+// Settings of outbox worker
+const (
+	// outboxBatch is maximum number of deliveries sent by one run of worker
+	outboxBatch = 50
+	// outboxLease is time after which delivery claimed by stopped worker is claimed again.
+	// Deliveries are claimed one by one, so lease covers sending of one message with its retries.
+	outboxLease = 5 * time.Minute
+	// outboxMaxAttempts is number of attempts after which delivery is failed
+	outboxMaxAttempts = 5
+	// outboxRetryDelay is delay before the second attempt. Delay is doubled for every next attempt.
+	outboxRetryDelay = time.Minute
+)
+
+// statusEvent returns event of follow-up about changed status of hearing.
+// Postponed hearing may be postponed again, so time is a part of event.
+func statusEvent(h domain.Hearing) string {
+	if h.Status == domain.StatusPostponed {
+		return fmt.Sprintf("status:%s:%s", h.Status, h.Time.Format("2006-01-02T15:04"))
+	}
+	return "status:" + string(h.Status)
+}
+
+// jsonMessage is message of hearing in JSON format
+type jsonMessage struct {
+	Event   string         `json:"event"`
+	Hearing domain.Hearing `json:"hearing"`
+	// Text of reminder
+	Text string `json:"text,omitempty"`
+}
+
+// templateEvent returns event of templates for event of delivery
+func templateEvent(h domain.Hearing, event string) string {
+	switch {
+	case event == EventPublished:
+		return render.EventNew
+	case strings.HasPrefix(event, eventReminder):
+		return render.EventReminder
+	case h.Status == domain.StatusCancelled:
+		return render.EventCancelled
+	}
+	return render.EventChanged
+}
+
+// message returns text of hearing or reminder in format of channel rendered from templates.
+// Markdown and HTML of Telegram which need too many messages are replaced by summary with link to publication.
+// Entities are rendered from templates of HTML of Telegram.
+// Plain text is returned if template fails.
+func (s Service) message(channel, format, event string, data render.Data) string {
+	h := data.Hearing
+	tmplEvent := templateEvent(h, event)
+	switch format {
+	case publisher.FormatJSON:
+		h.Raw = nil
+		b, err := json.Marshal(jsonMessage{Event: event, Hearing: h, Text: data.Text})
+		if err == nil {
+			return string(b)
+		}
+	case publisher.FormatEntities:
+		// Entities are converted from HTML of Telegram, so they are laid out by the same templates
+		text := domain.ParseTelegramHTML(s.message(channel, publisher.FormatTelegramHTML, event, data))
+		b, err := json.Marshal(text)
+		if err == nil {
+			return string(b)
+		}
+	}
+
+	text, err := s.renderer.Render(channel, format, tmplEvent, data)
+	if err == nil && tmplEvent != render.EventReminder && tooLong(format, text) {
+		text, err = s.renderer.Render(channel, format, render.EventSummary, data)
+	}
+	if err != nil {
+		s.logger.Error().Err(err).Str("channel", channel).Str("format", format).Str("event", event).Msg("failed to render message")
+		if tmplEvent == render.EventReminder {
+			return data.Text
+		}
+		return render.Text(h)
+	}
+	return text
+}
+
+// Render returns full text of hearing in format of channel rendered from templates of new hearing.
+// Plain text of built-in templates is returned if template fails.
+func (s Service) Render(channel, format string, h domain.Hearing) string {
+	text, err := s.renderer.Render(channel, format, render.EventNew, render.Data{Hearing: h})
+	if err != nil {
+		s.logger.Error().Err(err).Str("channel", channel).Str("format", format).Msg("failed to render hearing")
+		return render.Text(h)
+	}
+	return text
+}
+
+// tooLong reports whether message of Telegram needs more than MaxMessageParts parts
+func tooLong(format, text string) bool {
+	switch format {
+	case publisher.FormatMarkdown:
+		return len(domain.SplitMarkdown(text, domain.MaxMessageLength)) > domain.MaxMessageParts
+	case publisher.FormatTelegramHTML:
+		return len(domain.SplitHTML(text, domain.MaxMessageLength)) > domain.MaxMessageParts
+	}
+	return false
+}
+
+// enqueue deliveries of messages about hearings to all channels.
+// Format is used for channels without own format.
+// If publish is true, hearings are marked as published together with saving deliveries
+// and subscribed webhooks and chats are notified.
+func (s Service) enqueue(ctx context.Context, hearings []domain.Hearing, event func(domain.Hearing) string, format string, publish bool) (int, error) {
+	deliveries := make([]database.Delivery, 0, len(hearings)*len(s.targets))
+	slots := make(map[string]time.Time)
+	now := time.Now()
+	for _, h := range hearings {
+		e := event(h)
+		for _, t := range s.targets {
+			f := t.Format
+			if f == "" {
+				f = format
+			}
+			at, err := s.slot(ctx, t, slots, now)
+			if err != nil {
+				return 0, err
+			}
+			deliveries = append(deliveries, database.Delivery{
+				HearingID:     h.ID,
+				Channel:       t.Name,
+				Event:         e,
+				Message:       s.message(t.Name, f, e, render.Data{Hearing: h}),
+				NextAttemptAt: at,
+			})
+		}
+	}
+	if publish {
+		hooks, err := s.webhookDeliveries(ctx, hearings, WebhookPublished)
+		if err != nil {
+			return 0, err
+		}
+		deliveries = append(deliveries, hooks...)
+		chats, err := s.subscriberDeliveries(ctx, hearings)
+		if err != nil {
+			return 0, err
+		}
+		deliveries = append(deliveries, chats...)
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	return s.db.Enqueue(ctx, deliveries, publish)
+}
+
+// slot returns time of sending of the next message to target by its schedule.
+// Slots are kept apart by spacing after the last planned message and moved to the next window.
+// Zero time is returned for target without schedule, so message is sent at once.
+func (s Service) slot(ctx context.Context, t publisher.Target, slots map[string]time.Time, now time.Time) (time.Time, error) {
+	sch := t.Schedule
+	if len(sch.Windows) == 0 && sch.Spacing == 0 {
+		return time.Time{}, nil
+	}
+	last, ok := slots[t.Name]
+	if !ok {
+		var err error
+		if last, err = s.db.LastSlot(ctx, t.Name); err != nil {
+			s.logger.Error().Err(err).Str("channel", t.Name).Msg("failed to get last slot")
+			return time.Time{}, err
+		}
+	}
+	at := now
+	if !last.IsZero() && last.Add(sch.Spacing).After(at) {
+		at = last.Add(sch.Spacing)
+	}
+	if !sch.Silent {
+		at = sch.Next(at)
+	}
+	slots[t.Name] = at
+	return at, nil
+}
+
+// retrySlot returns time of the next attempt of delivery to channel not earlier than at.
+// Attempts to targets keep their schedule. The failed attempt stays the last slot of channel until it is rescheduled,
+// so the retry is kept apart by spacing from it too.
+func (s Service) retrySlot(ctx context.Context, channel string, at time.Time) (time.Time, error) {
+	for _, t := range s.targets {
+		if t.Name != channel {
+			continue
+		}
+		slot, err := s.slot(ctx, t, make(map[string]time.Time), at)
+		if err != nil || slot.IsZero() {
+			return at, err
+		}
+		return slot, nil
+	}
+	return at, nil
+}
+
+// Deliver sends due messages from outbox and returns number of sent ones.
+// Failed deliveries are retried later with growing delay until the limit of attempts.
+// Retries to targets are moved by their schedules like the first attempts.
+// Permanent errors of publisher fail delivery without retries.
+// Error is returned only if outbox cannot be read or updated.
+func (s Service) Deliver(ctx context.Context) (int, error) {
+	l := s.logger.With().Str("method", "Deliver").Logger()
+	sent := 0
+	for i := 0; i < outboxBatch; i++ {
+		// Lease of delivery starts right before sending, so it does not expire while previous deliveries are sent
+		claimed, err := s.db.ClaimDeliveries(ctx, time.Now(), outboxLease, 1)
+		if err != nil {
+			l.Error().Err(err).Msg("failed to claim deliveries")
+			return sent, err
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		d := claimed[0]
+		dl := l.With().Str("delivery", d.ID).Str("hearing", d.HearingID).Str("event", d.Event).Logger()
+		err = s.send(ctx, d)
+		if err == nil {
+			err = s.db.CompleteDelivery(ctx, d.ID, d.Attempts, time.Now())
+			if errors.Is(err, database.ErrNotClaimed) {
+				dl.Warn().Msg("delivery is claimed again after lease")
+				continue
+			}
+			if err != nil {
+				dl.Error().Err(err).Msg("failed to complete delivery")
+				return sent, err
+			}
+			dl.Info().Msg("message delivered")
+			sent++
+			continue
+		}
+		if progress, ok := publisher.SentProgress(err); ok {
+			// The next attempt is resumed from the failed part, so sent parts are not posted twice
+			perr := s.db.SetDeliveryProgress(ctx, d.ID, d.Attempts, progress.Parts, progress.MessageID)
+			if perr != nil && !errors.Is(perr, database.ErrNotClaimed) {
+				dl.Error().Err(perr).Msg("failed to save progress of delivery")
+				return sent, perr
+			}
+		}
+		if ctx.Err() != nil {
+			// Delivery is claimed again after lease
+			return sent, ctx.Err()
+		}
+
+		var next time.Time
+		if d.Attempts < outboxMaxAttempts && !publisher.IsPermanent(err) {
+			delay := outboxRetryDelay << (d.Attempts - 1)
+			if retryAfter := publisher.RetryAfter(err); retryAfter > delay {
+				delay = retryAfter
+			}
+			var serr error
+			if next, serr = s.retrySlot(ctx, d.Channel, time.Now().Add(delay)); serr != nil {
+				return sent, serr
+			}
+			dl.Warn().Err(err).Int("attempts", d.Attempts).Time("next", next).Msg("failed to deliver message")
+		} else {
+			dl.Error().Err(err).Int("attempts", d.Attempts).Msg("delivery failed")
+		}
+		err = s.db.FailDelivery(ctx, d.ID, d.Attempts, err.Error(), next)
+		if errors.Is(err, database.ErrNotClaimed) {
+			dl.Warn().Msg("delivery is claimed again after lease")
+			continue
+		}
+		if err != nil {
+			dl.Error().Err(err).Msg("failed to save result of delivery")
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// send message of delivery to its channel
+func (s Service) send(ctx context.Context, d database.Delivery) error {
+	if strings.HasPrefix(d.Channel, webhookChannel) {
+		return s.sendWebhook(ctx, d)
+	}
+	if strings.HasPrefix(d.Channel, chatChannel) {
+		return s.sendChat(ctx, d)
+	}
+	for _, t := range s.targets {
+		if t.Name != d.Channel {
+			continue
+		}
+		if t.Schedule.Silent && !t.Schedule.Open(time.Now()) {
+			ctx = publisher.WithoutNotification(ctx)
+		}
+		if r, ok := t.Publisher.(publisher.Resumer); ok && d.SentParts > 0 {
+			return r.Resume(ctx, d.Message, publisher.Progress{Parts: d.SentParts, MessageID: d.LastMessageID})
+		}
+		return t.Publisher.Publish(ctx, d.Message)
+	}
+	return fmt.Errorf("unknown channel: %s", d.Channel)
+}
+
+// RunOutbox delivers messages from outbox every interval until context is done
+func (s Service) RunOutbox(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Deliver(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("method", "RunOutbox").Msg("failed to deliver messages")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliveries of hearing by identifier. All deliveries are returned for empty identifier.
+func (s Service) Deliveries(ctx context.Context, hearingID string) ([]database.Delivery, error) {
+	res, err := s.db.Deliveries(ctx, hearingID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "Deliveries").Str("hearing", hearingID).Msg("failed to get deliveries")
+	}
+	return res, err
+}
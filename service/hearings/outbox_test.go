@@ -0,0 +1,513 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
+)
+
+func TestService_Publish_outbox(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	cnt, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Equal(t, 1, cnt)
+	cnt, err = s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Zero(t, cnt)
+
+	deliveries, err := s.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1, "hearing must be delivered once")
+	require.Equal(t, ChannelTelegram, deliveries[0].Channel)
+	require.Equal(t, EventPublished, deliveries[0].Event)
+	require.Equal(t, database.DeliverySent, deliveries[0].Status)
+	require.Equal(t, rendered(t, "markdown", render.EventNew, found[0]), deliveries[0].Message)
+
+	// Follow-up about cancelled hearing is enqueued once
+	site.setPage("/1/", append([]string{"Публичные слушания ОТМЕНЕНЫ."}, testHearingContent...)...)
+	_, err = s.RefreshStatuses(ctx, "text", true)
+	require.NoError(t, err)
+	deliveries, err = repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	require.Equal(t, "status:cancelled", deliveries[1].Event)
+	require.Equal(t, database.DeliverySent, deliveries[1].Status)
+}
+
+func TestService_Acknowledge(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+
+	list, err := s.ListUnpublished(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	list, err = s.ListUnpublished(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1, "listing must not mark hearings as published")
+
+	n, err := s.Acknowledge(ctx, []string{"100500", list[0].ID})
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "unknown hearings must be skipped")
+	n, err = s.Acknowledge(ctx, []string{list[0].ID})
+	require.NoError(t, err)
+	require.Zero(t, n, "published hearing must not be marked again")
+
+	// Acknowledged hearing is published by caller, so nothing is posted to channels
+	deliveries, err := repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, deliveries)
+	list, err = s.ListUnpublished(ctx)
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	n, err = s.Publish(ctx, publisher.FormatMarkdown)
+	require.NoError(t, err)
+	require.Zero(t, n, "acknowledged hearing must not be published again")
+}
+
+func TestService_Deliver_retry(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	_, err = repo.Enqueue(ctx, []database.Delivery{{HearingID: found[0].ID, Channel: "unknown", Event: EventPublished}}, true)
+	require.NoError(t, err)
+
+	sent, err := s.Deliver(ctx)
+	require.NoError(t, err)
+	require.Zero(t, sent)
+	deliveries, err := repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, database.DeliveryPending, deliveries[0].Status)
+	require.Equal(t, "unknown channel: unknown", deliveries[0].LastError)
+	require.True(t, deliveries[0].NextAttemptAt.After(time.Now()))
+
+	// The last attempt fails delivery
+	for i := 1; i < outboxMaxAttempts; i++ {
+		claimed, err := repo.ClaimDeliveries(ctx, time.Now().Add(time.Duration(i)*time.Hour), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.NoError(t, repo.FailDelivery(ctx, claimed[0].ID, claimed[0].Attempts, "failed", time.Now()))
+	}
+	deliveries, err = repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, outboxMaxAttempts, deliveries[0].Attempts)
+
+	_, err = s.Deliver(ctx)
+	require.NoError(t, err)
+	deliveries, err = repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, database.DeliveryFailed, deliveries[0].Status)
+}
+
+func TestService_Deliver_permanent(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`))
+	}))
+	t.Cleanup(api.Close)
+	logger := zerolog.Nop()
+	pub, err := publisher.NewTelegram(&publisher.TelegramOptions{Logger: &logger, Token: "token", ChatID: "@channel", APIURL: api.URL})
+	require.NoError(t, err)
+	s.targets = []publisher.Target{{Name: ChannelTelegram, Publisher: pub}}
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	cnt, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err, "failed delivery must not fail publishing")
+	require.Equal(t, 1, cnt)
+
+	deliveries, err := repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, database.DeliveryFailed, deliveries[0].Status, "permanent error must not be retried")
+	require.Equal(t, 1, deliveries[0].Attempts)
+	require.Equal(t, "api error 400: Bad Request: can't parse entities", deliveries[0].LastError)
+}
+
+func TestService_Deliver_resume(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	var texts []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var m struct {
+			Text             string `json:"text"`
+			ReplyToMessageID int    `json:"reply_to_message_id"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&m))
+		texts = append(texts, m.Text)
+		// The second part fails once
+		if len(texts) == 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(texts) > 2 {
+			require.Equal(t, 1, m.ReplyToMessageID, "resumed part must reply to the sent part")
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":` + strconv.Itoa(len(texts)) + `}}`))
+	}))
+	t.Cleanup(api.Close)
+	logger := zerolog.Nop()
+	pub, err := publisher.NewTelegram(&publisher.TelegramOptions{Logger: &logger, Token: "token", ChatID: "@channel", APIURL: api.URL,
+		MaxLength: 100, Retries: -1, ChatInterval: time.Millisecond})
+	require.NoError(t, err)
+	s.targets = []publisher.Target{{Name: ChannelTelegram, Publisher: pub}}
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	message := strings.Repeat("первая часть ", 6) + "\n\n" + strings.Repeat("вторая часть ", 6)
+	_, err = repo.Enqueue(ctx, []database.Delivery{{HearingID: found[0].ID, Channel: ChannelTelegram, Event: EventPublished, Message: message}}, true)
+	require.NoError(t, err)
+
+	sent, err := s.Deliver(ctx)
+	require.NoError(t, err)
+	require.Zero(t, sent)
+	deliveries, err := repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, database.DeliveryPending, deliveries[0].Status)
+	require.Equal(t, 1, deliveries[0].SentParts)
+	require.Equal(t, 1, deliveries[0].LastMessageID)
+
+	claimed, err := repo.ClaimDeliveries(ctx, time.Now().Add(time.Hour), time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.NoError(t, s.send(ctx, claimed[0]))
+	require.Len(t, texts, 3, "sent part must not be posted again")
+	require.Equal(t, texts[1], texts[2])
+}
+
+func TestService_Publish_targets(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	bodies := make(chan string, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- r.URL.Path + " " + string(body)
+	}))
+	t.Cleanup(hook.Close)
+	for _, name := range []string{"json", "html"} {
+		pub, err := publisher.NewWebhook(&publisher.WebhookOptions{URL: hook.URL + "/" + name})
+		require.NoError(t, err)
+		s.targets = append(s.targets, publisher.Target{Name: "hook-" + name, Format: name, Publisher: pub})
+	}
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	cnt, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Equal(t, 1, cnt)
+
+	deliveries, err := repo.Deliveries(ctx, found[0].ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3, "hearing must be delivered to every target")
+	messages := make(map[string]string)
+	for _, d := range deliveries {
+		require.Equal(t, database.DeliverySent, d.Status, d.Channel)
+		messages[d.Channel] = d.Message
+	}
+	require.Equal(t, rendered(t, "markdown", render.EventNew, found[0]), messages[ChannelTelegram])
+	require.Equal(t, rendered(t, "html", render.EventNew, found[0]), messages["hook-html"])
+	require.Contains(t, messages["hook-json"], `"event":"published"`)
+
+	received := []string{<-bodies, <-bodies}
+	require.ElementsMatch(t, []string{"/json " + messages["hook-json"], `/html {"text":` + jsonString(t, messages["hook-html"]) + "}"}, received)
+
+}
+
+// rendered returns message of event about hearing rendered from built-in templates
+func rendered(t *testing.T, format, event string, h domain.Hearing) string {
+	msg, err := render.New().Render(ChannelTelegram, format, event, render.Data{Hearing: h})
+	require.NoError(t, err)
+	return msg
+}
+
+func jsonString(t *testing.T, s string) string {
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestMessage(t *testing.T) {
+	h := domain.Hearing{
+		Time:  time.Date(2099, time.March, 17, 11, 0, 0, 0, time.UTC),
+		Place: "ГДК Советского района",
+		Topic: []string{"по проекту планировки", "по проекту межевания"},
+		URL:   "https://bga32.ru/1/",
+	}
+	logger := zerolog.Nop()
+	s := New(&Config{Logger: &logger})
+	message := func(h domain.Hearing, event, format string) string {
+		return s.message("telegram", format, event, render.Data{Hearing: h})
+	}
+	require.Equal(t, rendered(t, "markdown", render.EventNew, h), message(h, EventPublished, "markdown"))
+	require.Equal(t, render.Text(h), message(h, EventPublished, "text"))
+	require.Equal(t, rendered(t, "html", render.EventNew, h), message(h, EventPublished, "html"))
+	h.Raw = []string{"raw content is not sent"}
+	require.JSONEq(t, `{"event":"published","hearing":{
+		"id":"","topic":["по проекту планировки","по проекту межевания"],"proposals":null,
+		"place":"ГДК Советского района","url":"https://bga32.ru/1/","time":"2099-03-17T11:00:00Z",
+		"published":false,"status":"","category":"","district":"","raw":null
+	}}`, message(h, EventPublished, "json"))
+
+	for len(domain.SplitMarkdown(rendered(t, "markdown", render.EventNew, h), domain.MaxMessageLength)) <= domain.MaxMessageParts {
+		h.Topic = append(h.Topic, strings.Repeat("по проекту планировки территории ", 20))
+	}
+	require.Equal(t, rendered(t, "markdown", render.EventSummary, h), message(h, EventPublished, "markdown"), "too long hearing must be summarized")
+	require.Equal(t, render.Text(h), message(h, EventPublished, "pdf"), "unknown format must be plain text")
+
+	formatted, err := json.Marshal(domain.ParseTelegramHTML(rendered(t, "telegram_html", render.EventSummary, h)))
+	require.NoError(t, err)
+	require.JSONEq(t, string(formatted), message(h, EventPublished, "entities"), "too long hearing must be summarized")
+	require.True(t, strings.HasPrefix(message(h, EventPublished, "telegram_html"), "<b>17.03.2099 в 11:00 в ГДК Советского района</b> состоятся"))
+	require.Contains(t, message(h, EventPublished, "telegram_html"), "и ещё ", "too long hearing must be summarized")
+
+	h.Topic = h.Topic[:2]
+	var text domain.FormattedText
+	require.NoError(t, json.Unmarshal([]byte(message(h, EventPublished, "entities")), &text))
+	require.Equal(t, "17.03.2099 в 11:00 в ГДК Советского района состоятся публичные слушания:\n\n"+
+		" - по проекту планировки\n\n - по проекту межевания\n\nСсылка на публикацию\n", text.Text)
+	require.Equal(t, []domain.Entity{
+		{Type: domain.EntityBold, Offset: 0, Length: 42},
+		{Type: domain.EntityTextLink, Offset: 125, Length: 20, URL: h.URL},
+	}, text.Entities)
+	require.JSONEq(t, `{"text":"Завтра слушания"}`, s.message("telegram", "entities", eventReminder+"day", render.Data{Hearing: h, Text: "Завтра слушания"}))
+}
+
+func TestMessage_templates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "channels", "vk"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "channels", "vk", "cancelled.tmpl"), []byte("Отменены: {{.Hearing.Place}}"), 0o600))
+	renderer, err := render.Load(dir)
+	require.NoError(t, err)
+	logger := zerolog.Nop()
+	s := New(&Config{Logger: &logger, Renderer: renderer})
+
+	h := domain.Hearing{
+		Time:   time.Date(2099, time.March, 17, 11, 0, 0, 0, time.UTC),
+		Place:  "ГДК Советского района",
+		Topic:  []string{"по проекту планировки"},
+		URL:    "https://bga32.ru/1/",
+		Status: domain.StatusCancelled,
+	}
+	require.Equal(t, "Отменены: ГДК Советского района", s.message("vk", "text", statusEvent(h), render.Data{Hearing: h}))
+	require.Equal(t, render.Text(h), s.message("mail", "text", statusEvent(h), render.Data{Hearing: h}))
+	h.Status = domain.StatusPostponed
+	require.Equal(t, render.Text(h), s.message("vk", "text", statusEvent(h), render.Data{Hearing: h}))
+}
+
+func TestService_Render(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "channels", ChannelBot), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "channels", ChannelBot, "new.tmpl"), []byte("Бот: {{md .Hearing.Place}}"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "channels", ChannelAPI), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "channels", ChannelAPI, "new.tmpl"), []byte("{{.Hearing.Missing}}"), 0o600))
+	renderer, err := render.Load(dir)
+	require.NoError(t, err)
+	logger := zerolog.Nop()
+	s := New(&Config{Logger: &logger, Renderer: renderer})
+
+	h := domain.Hearing{
+		Time:  time.Date(2099, time.March, 17, 11, 0, 0, 0, time.UTC),
+		Place: "ГДК Советского района (ул. Калинина, д. 66)",
+		Topic: []string{"по проекту планировки"},
+		URL:   "https://bga32.ru/1/",
+	}
+	require.Equal(t, "Бот: ГДК Советского района \\(ул\\. Калинина, д\\. 66\\)", s.Render(ChannelBot, "markdown", h))
+	require.Equal(t, rendered(t, "markdown", render.EventNew, h), s.Render(ChannelTelegram, "markdown", h))
+	require.Equal(t, render.Text(h), s.Render(ChannelAPI, "text", h), "plain text must be returned if template fails")
+}
+
+// windowFrom returns window of schedule starting after offset from now and lasting two hours
+func windowFrom(now time.Time, offset time.Duration) publisher.Window {
+	start := now.Add(offset)
+	from := start.Hour()*60 + start.Minute()
+	return publisher.Window{From: from, To: (from + 120) % (24 * 60)}
+}
+
+func TestService_Publish_schedule(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	site.setPage("/2/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(hook.Close)
+	pub, err := publisher.NewWebhook(&publisher.WebhookOptions{URL: hook.URL})
+	require.NoError(t, err)
+	now := time.Now()
+	s.targets = append(s.targets, publisher.Target{
+		Name: "scheduled", Format: "json", Publisher: pub,
+		Schedule: publisher.Schedule{Windows: []publisher.Window{windowFrom(now, 3*time.Hour)}, Spacing: 30 * time.Minute},
+	})
+
+	_, err = s.NewHearings(ctx)
+	require.NoError(t, err)
+	cnt, err := s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+
+	deliveries, err := repo.ChannelDeliveries(ctx, ChannelTelegram)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	require.Equal(t, database.DeliverySent, deliveries[0].Status, "channel without schedule is published at once")
+
+	deliveries, err = repo.ChannelDeliveries(ctx, "scheduled")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	start := now.Add(3 * time.Hour).Truncate(time.Minute)
+	for i, d := range deliveries {
+		require.Equal(t, database.DeliveryPending, d.Status, "message must wait for window")
+		require.Zero(t, d.Attempts)
+		expected := start.Add(time.Duration(i) * 30 * time.Minute)
+		require.WithinDuration(t, expected, d.NextAttemptAt, time.Second, "delivery %d", i)
+	}
+
+	// Slots of the next publishing follow planned ones
+	site.setPage("/3/", testHearingContent...)
+	_, err = s.NewHearings(ctx)
+	require.NoError(t, err)
+	_, err = s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	deliveries, err = repo.ChannelDeliveries(ctx, "scheduled")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	require.WithinDuration(t, start.Add(time.Hour), deliveries[2].NextAttemptAt, time.Second)
+}
+
+func TestService_Deliver_schedule(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(hook.Close)
+	pub, err := publisher.NewWebhook(&publisher.WebhookOptions{URL: hook.URL})
+	require.NoError(t, err)
+	now := time.Now()
+	s.targets = append(s.targets, publisher.Target{
+		Name: "scheduled", Format: "json", Publisher: pub,
+		Schedule: publisher.Schedule{Windows: []publisher.Window{windowFrom(now, 3*time.Hour)}, Spacing: 30 * time.Minute},
+	})
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	// Delivery which is already due, e.g. enqueued in window, fails at its last minute
+	_, err = repo.Enqueue(ctx, []database.Delivery{{HearingID: found[0].ID, Channel: "scheduled", Event: EventPublished}}, true)
+	require.NoError(t, err)
+
+	_, err = s.Deliver(ctx)
+	require.NoError(t, err)
+	deliveries, err := repo.ChannelDeliveries(ctx, "scheduled")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, database.DeliveryPending, deliveries[0].Status)
+	require.Equal(t, 1, deliveries[0].Attempts)
+	require.WithinDuration(t, now.Add(3*time.Hour).Truncate(time.Minute), deliveries[0].NextAttemptAt, time.Second,
+		"retry must wait for window")
+}
+
+func TestService_Publish_silent(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, _ := newTestService(t, site)
+
+	silent := make(chan string, 10)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ChatID              string `json:"chat_id"`
+			DisableNotification bool   `json:"disable_notification"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if msg.DisableNotification {
+			silent <- msg.ChatID
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1}}`))
+	}))
+	t.Cleanup(api.Close)
+	now := time.Now()
+	for name, window := range map[string]publisher.Window{
+		"closed": windowFrom(now, 3*time.Hour),
+		"open":   windowFrom(now, -time.Hour),
+	} {
+		pub, err := publisher.NewTelegram(&publisher.TelegramOptions{Token: "token", ChatID: name, APIURL: api.URL})
+		require.NoError(t, err)
+		s.targets = append(s.targets, publisher.Target{
+			Name: name, Publisher: pub,
+			Schedule: publisher.Schedule{Windows: []publisher.Window{window}, Silent: true},
+		})
+	}
+
+	_, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	_, err = s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+	sent, err := s.Deliveries(ctx, "")
+	require.NoError(t, err)
+	for _, d := range sent {
+		require.Equal(t, database.DeliverySent, d.Status, "silent channel is published at once: %s", d.Channel)
+	}
+	require.Len(t, silent, 1)
+	require.Equal(t, "closed", <-silent, "message outside of window must be sent without notification")
+}
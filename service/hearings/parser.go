@@ -15,6 +15,7 @@
 package hearings
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -33,6 +34,10 @@ var timeAndPlace = `(?P<day>\d+)` + spaces + `+(?P<month>\p{L}+)(?:` + spaces +
 var clearLine = `^[\s\p{Zs}]*[-—]?[\s\p{Zs}]*(?P<line>.*)[\s\p{Zs}]*[\.;]+?[\s\p{Zs}]*$`
 var year = `(?P<year>\d{4})(?:-goda/)?`
 
+// Statuses are announced about hearings themselves, so words in topics (e.g. "отмененного постановления") do not match
+var cancelled = `(?i)слушани\p{L}*.*[^\p{L}]отмен[её]н[ыао]?(?:[^\p{L}]|$)`
+var postponed = `(?i)слушани\p{L}*.*[^\p{L}]перенес[её]н[ыао]?(?:[^\p{L}]|$)`
+
 var serviceTimeLocation = time.Now().Location()
 var beginnigTime = time.Date(2021, time.January, 1, 0, 0, 0, 0, serviceTimeLocation)
 
@@ -72,27 +77,147 @@ type Parser struct {
 	reProposalParagraph *regexp.Regexp
 	reYear              *regexp.Regexp
 	reMissprintTopic    *regexp.Regexp
+	reCancelled         *regexp.Regexp
+	rePostponed         *regexp.Regexp
+}
+
+// Patterns of paragraphs used by parser.
+// Placeholder {spaces} in pattern is replaced by the class of all kinds of spaces.
+type Patterns struct {
+	TopicStart        string `yaml:"topic_start"`
+	TopicFromMisprint string `yaml:"topic_from_misprint"`
+	TopicEnd          string `yaml:"topic_end"`
+	Proposal          string `yaml:"proposal"`
+	TimeAndPlace      string `yaml:"time_and_place"`
+	ClearLine         string `yaml:"clear_line"`
+	Year              string `yaml:"year"`
+	Cancelled         string `yaml:"cancelled"`
+	Postponed         string `yaml:"postponed"`
+}
+
+// DefaultPatterns returns patterns for pages of bga32.ru
+func DefaultPatterns() Patterns {
+	return Patterns{
+		TopicStart:        topicStartParagraph,
+		TopicFromMisprint: topicFromMisprintParagraph,
+		TopicEnd:          topicEndParagraph,
+		Proposal:          proposalParagraph,
+		TimeAndPlace:      timeAndPlace,
+		ClearLine:         clearLine,
+		Year:              year,
+		Cancelled:         cancelled,
+		Postponed:         postponed,
+	}
 }
 
-// NewParser return instance of public hearings parser
+// NewParser return instance of public hearings parser with default patterns
 func NewParser() *Parser {
-	return &Parser{
-		reTopicStart:        regexp.MustCompile(topicStartParagraph),
-		reTopicEnd:          regexp.MustCompile(topicEndParagraph),
-		reTimePlace:         regexp.MustCompile(timeAndPlace),
-		reClearLine:         regexp.MustCompile(clearLine),
-		reProposalParagraph: regexp.MustCompile(proposalParagraph),
-		reYear:              regexp.MustCompile(year),
-		reMissprintTopic:    regexp.MustCompile(topicFromMisprintParagraph),
+	p, err := NewParserWithPatterns(DefaultPatterns())
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// NewParserWithPatterns return instance of public hearings parser with passed patterns.
+// Empty patterns are replaced by default ones.
+func NewParserWithPatterns(patterns Patterns) (*Parser, error) {
+	defaults := DefaultPatterns()
+	compile := func(name, pattern, fallback string, groups ...string) (*regexp.Regexp, error) {
+		if pattern == "" {
+			pattern = fallback
+		}
+		re, err := regexp.Compile(strings.ReplaceAll(pattern, "{spaces}", spaces))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", name, err)
+		}
+		for _, group := range groups {
+			if re.SubexpIndex(group) < 0 {
+				return nil, fmt.Errorf("invalid pattern %s: missing group %q", name, group)
+			}
+		}
+		return re, nil
+	}
+
+	var err error
+	p := &Parser{}
+	if p.reTopicStart, err = compile("topic_start", patterns.TopicStart, defaults.TopicStart); err != nil {
+		return nil, err
+	}
+	if p.reTopicEnd, err = compile("topic_end", patterns.TopicEnd, defaults.TopicEnd); err != nil {
+		return nil, err
+	}
+	p.reTimePlace, err = compile("time_and_place", patterns.TimeAndPlace, defaults.TimeAndPlace,
+		"day", "month", "year", "hours", "minutes", "place")
+	if err != nil {
+		return nil, err
+	}
+	if p.reClearLine, err = compile("clear_line", patterns.ClearLine, defaults.ClearLine, "line"); err != nil {
+		return nil, err
+	}
+	if p.reProposalParagraph, err = compile("proposal", patterns.Proposal, defaults.Proposal); err != nil {
+		return nil, err
 	}
+	if p.reYear, err = compile("year", patterns.Year, defaults.Year, "year"); err != nil {
+		return nil, err
+	}
+	p.reMissprintTopic, err = compile("topic_from_misprint", patterns.TopicFromMisprint, defaults.TopicFromMisprint)
+	if err != nil {
+		return nil, err
+	}
+	if p.reCancelled, err = compile("cancelled", patterns.Cancelled, defaults.Cancelled); err != nil {
+		return nil, err
+	}
+	if p.rePostponed, err = compile("postponed", patterns.Postponed, defaults.Postponed); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-// Content return full data of public hearing
+// Content return full data of public hearing.
+// If content cannot be parsed it returns *ParseError with the cause of failure.
 func (p *Parser) Content(hearing domain.Hearing) (domain.Hearing, error) {
 	return p.prepare(hearing)
 }
 
+// ParseReport describes which paragraphs of content were used by parser.
+// Paragraphs are counted from zero, -1 means that paragraph was not found.
+type ParseReport struct {
+	Paragraphs         int   `json:"paragraphs"`
+	PlaceParagraph     int   `json:"place_paragraph"`
+	TopicParagraphs    []int `json:"topic_paragraphs"`
+	ProposalParagraphs []int `json:"proposal_paragraphs"`
+	// Error of parsing with its cause and the offending paragraph
+	Error     string `json:"error,omitempty"`
+	Cause     string `json:"cause,omitempty"`
+	Paragraph string `json:"paragraph,omitempty"`
+}
+
+// Report return full data of public hearing with report of parsing
+func (p *Parser) Report(hearing domain.Hearing) (domain.Hearing, ParseReport, error) {
+	report := ParseReport{
+		Paragraphs:         len(hearing.Raw),
+		PlaceParagraph:     -1,
+		TopicParagraphs:    make([]int, 0),
+		ProposalParagraphs: make([]int, 0),
+	}
+	ph, err := p.analyze(hearing, &report)
+	if err != nil {
+		report.Error = err.Error()
+		var perr *ParseError
+		if errors.As(err, &perr) {
+			report.Cause = perr.Err.Error()
+			report.Paragraph = perr.Paragraph
+		}
+	}
+	return ph, report, err
+}
+
 func (p *Parser) prepare(hearing domain.Hearing) (domain.Hearing, error) {
+	return p.analyze(hearing, &ParseReport{})
+}
+
+func (p *Parser) analyze(hearing domain.Hearing, report *ParseReport) (domain.Hearing, error) {
 	ph := domain.Hearing{
 		URL: hearing.URL,
 		Raw: hearing.Raw,
@@ -100,7 +225,7 @@ func (p *Parser) prepare(hearing domain.Hearing) (domain.Hearing, error) {
 	content := hearing.Raw
 
 	if len(content) == 0 {
-		return ph, fmt.Errorf("empty content")
+		return ph, newParseError(ErrEmptyContent, "", "")
 	}
 
 	/* DEFINE TOPIC */
@@ -113,7 +238,7 @@ func (p *Parser) prepare(hearing domain.Hearing) (domain.Hearing, error) {
 		if len(parts) != 2 {
 			topics := p.reMissprintTopic.FindAllString(content[start], -1)
 			if len(topics) == 0 {
-				return ph, fmt.Errorf("failed parse content. cannot split to time/place and topic")
+				return ph, newParseError(ErrNoTopics, "cannot split to time/place and topic", content[start])
 			}
 			// Add topic to it position
 			parts = append(parts, topics[0])
@@ -123,8 +248,10 @@ func (p *Parser) prepare(hearing domain.Hearing) (domain.Hearing, error) {
 		top := p.clearString(parts[1])
 		if top != "" {
 			ph.Topic = append(ph.Topic, top)
+			report.TopicParagraphs = append(report.TopicParagraphs, start)
 		}
 		ph.Place = parts[0]
+		report.PlaceParagraph = start
 	} else {
 		// Multiple topics in different paragraphs
 		for i := start; i < next; i++ {
@@ -132,85 +259,124 @@ func (p *Parser) prepare(hearing domain.Hearing) (domain.Hearing, error) {
 			if i == start {
 				parts := p.reTopicStart.Split(content[i], -1)
 				if len(parts) == 0 {
-					return ph, fmt.Errorf("failed parse content. cannot split to time and place")
+					return ph, newParseError(ErrNoPlace, "cannot split to time and place", content[i])
 				}
 				ph.Place = parts[0]
+				report.PlaceParagraph = i
 			} else {
 				//	Next paragraphs with topics
 				top := p.clearString(content[i])
 				if top != "" {
 					ph.Topic = append(ph.Topic, top)
+					report.TopicParagraphs = append(report.TopicParagraphs, i)
 				}
 			}
 		}
 	}
 
 	if len(ph.Topic) == 0 {
-		return ph, fmt.Errorf("failed parse content. cannot get topics")
+		return ph, newParseError(ErrNoTopics, "", content[start])
 	}
 
 	/* DEFINE PLACE AND TIME */
-	if ph.Place != "" {
-		match := p.reTimePlace.FindStringSubmatch(ph.Place)
+	if ph.Place == "" {
+		return ph, newParseError(ErrNoPlace, "empty string", content[start])
+	}
+	date, place, err := p.extractTime(ph.Place, hearing.URL)
+	if err != nil {
+		return ph, err
+	}
+	if place == "" {
+		return ph, newParseError(ErrNoPlace, "result is empty place", ph.Place)
+	}
+	ph.Time = date
+	// Replace place
+	ph.Place = place
 
-		paramsMap := make(map[string]string)
-		for i, name := range p.reTimePlace.SubexpNames() {
-			if i > 0 && i <= len(match) {
-				paramsMap[name] = match[i]
-			}
-		}
+	/* DEFINE PROPOSALS */
+	prop := p.defineProposalParagraphs(content)
+	for _, p := range prop {
+		ph.Proposals = append(ph.Proposals, content[p])
+	}
+	report.ProposalParagraphs = append(report.ProposalParagraphs, prop...)
 
-		year, err := strconv.Atoi(paramsMap["year"])
-		if err != nil {
-			// If year not defined try extract it from url
-			year, err = p.extractYear(hearing.URL)
-			if err != nil {
-				// If year is still not defined, use current year
-				year = time.Now().Year()
-			}
-		}
+	/* DEFINE STATUS */
+	ph.Status = p.Status(content)
 
-		day, err := strconv.Atoi(paramsMap["day"])
-		if err != nil {
-			day = 1
-		}
+	return ph, nil
+}
 
-		timeError := false
-		hours, err := strconv.Atoi(paramsMap["hours"])
-		if err != nil {
-			hours = 0
-			timeError = true
+// extractTime returns time of hearing from the paragraph and the rest of paragraph as place.
+// Link is used to define year if it is missing in the paragraph.
+func (p *Parser) extractTime(paragraph, link string) (time.Time, string, error) {
+	match := p.reTimePlace.FindStringSubmatch(paragraph)
+	if len(match) == 0 {
+		return time.Time{}, "", newParseError(ErrNoDate, "cannot get date and time from place", paragraph)
+	}
+
+	paramsMap := make(map[string]string)
+	for i, name := range p.reTimePlace.SubexpNames() {
+		if i > 0 && i < len(match) {
+			paramsMap[name] = match[i]
 		}
+	}
 
-		minutes, err := strconv.Atoi(paramsMap["minutes"])
+	year, err := strconv.Atoi(paramsMap["year"])
+	if err != nil {
+		// If year not defined try extract it from url
+		year, err = p.extractYear(link)
 		if err != nil {
-			minutes = 0
+			// If year is still not defined, use current year
+			year = time.Now().Year()
 		}
+	}
 
-		ph.Time = time.Date(year, months[paramsMap["month"]], day, hours, minutes, 0, 0, serviceTimeLocation)
-		if ph.Time.Before(beginnigTime) {
-			return ph, fmt.Errorf("failed parse date. the extracted date (%s) is earlier than the beginning time (%s): %s", ph.Time, beginnigTime, ph.Place)
-		}
-		if timeError {
-			return ph, fmt.Errorf("failed parse date. cannot get time from place: %s", ph.Place)
-		}
-		// Replace place
-		ph.Place = paramsMap["place"]
-	} else {
-		return ph, fmt.Errorf("failed parse date and place. empty string")
+	month, ok := months[strings.ToLower(paramsMap["month"])]
+	if !ok {
+		return time.Time{}, "", newParseError(ErrNoDate, fmt.Sprintf("unknown month %q", paramsMap["month"]), paragraph)
 	}
 
-	if ph.Place == "" {
-		return ph, fmt.Errorf("failed parse date and place. result is empty place")
+	day, err := strconv.Atoi(paramsMap["day"])
+	if err != nil {
+		return time.Time{}, "", newParseError(ErrNoDate, "cannot get day from place", paragraph)
 	}
 
-	/* DEFINE PROPOSALS */
-	prop := p.defineProposalParagraphs(content)
-	for _, p := range prop {
-		ph.Proposals = append(ph.Proposals, content[p])
+	hours, err := strconv.Atoi(paramsMap["hours"])
+	if err != nil {
+		return time.Time{}, "", newParseError(ErrNoDate, "cannot get time from place", paragraph)
 	}
 
-	return ph, nil
+	minutes, err := strconv.Atoi(paramsMap["minutes"])
+	if err != nil {
+		return time.Time{}, "", newParseError(ErrNoDate, "cannot get time from place", paragraph)
+	}
+
+	date := time.Date(year, month, day, hours, minutes, 0, 0, serviceTimeLocation)
+	// time.Date normalizes values out of range (e.g. 30 February becomes 2 March), such dates are misprints
+	if date.Year() != year || date.Month() != month || date.Day() != day || date.Hour() != hours || date.Minute() != minutes {
+		return time.Time{}, "", newParseError(ErrNoDate, "the extracted date is out of range", paragraph)
+	}
+	if date.Before(beginnigTime) {
+		return time.Time{}, "", newParseError(ErrDateBeforeEpoch, fmt.Sprintf("the extracted date (%s) is earlier than the beginning time (%s)", date, beginnigTime), paragraph)
+	}
+
+	return date, paramsMap["place"], nil
+}
+
+// Status returns cancelled or postponed status if content announces it.
+// Otherwise, it returns empty status.
+func (p *Parser) Status(content []string) domain.Status {
+	for _, paragraph := range content {
+		if p.reCancelled.MatchString(paragraph) {
+			return domain.StatusCancelled
+		}
+	}
+	for _, paragraph := range content {
+		if p.rePostponed.MatchString(paragraph) {
+			return domain.StatusPostponed
+		}
+	}
+	return ""
 }
 
 func (p *Parser) defineTopicsParagraphs(content []string) (start, next int) {
@@ -246,7 +412,7 @@ func (p *Parser) clearString(str string) string {
 
 	paramsMap := make(map[string]string)
 	for i, name := range p.reClearLine.SubexpNames() {
-		if i > 0 && i <= len(match) {
+		if i > 0 && i < len(match) {
 			paramsMap[name] = match[i]
 		}
 	}
@@ -259,7 +425,7 @@ func (p *Parser) extractYear(link string) (int, error) {
 	if len(match) == 0 {
 		return 0, fmt.Errorf("failed parse year. cannot extract year from url")
 	}
-	year, err := strconv.Atoi(match[1])
+	year, err := strconv.Atoi(match[p.reYear.SubexpIndex("year")])
 	if err != nil {
 		return 0, fmt.Errorf("failed parse year. cannot convert year to int")
 	}
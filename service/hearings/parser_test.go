@@ -15,6 +15,7 @@
 package hearings
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -29,9 +30,9 @@ func TestParser_prepare(t *testing.T) {
 		name    string
 		input   domain.Hearing
 		want    domain.Hearing
-		wantErr bool
+		wantErr error
 	}{
-		{name: "empty", input: domain.Hearing{}, want: domain.Hearing{}, wantErr: true},
+		{name: "empty", input: domain.Hearing{}, want: domain.Hearing{}, wantErr: ErrEmptyContent},
 		{
 			name: "content 2021-02-26: one topic, multi proposals",
 			input: domain.Hearing{
@@ -73,7 +74,6 @@ func TestParser_prepare(t *testing.T) {
 					"информационные материалы к проекту>>>",
 				},
 			},
-			wantErr: false,
 		},
 		{
 			name: "content 2021-03-17. multi topic, multi proposals",
@@ -124,7 +124,6 @@ func TestParser_prepare(t *testing.T) {
 					"скачать экспозицию проектов планировки и межевания >>>",
 				},
 			},
-			wantErr: false,
 		},
 		{
 			name: "content 2021-09-30. without year",
@@ -170,7 +169,6 @@ func TestParser_prepare(t *testing.T) {
 					"скачать постановление Главы >>>",
 				},
 			},
-			wantErr: false,
 		},
 		{
 			name: "content 2022-03-29. new place format",
@@ -215,7 +213,6 @@ func TestParser_prepare(t *testing.T) {
 					"скачать информационный материал >>>",
 				},
 			},
-			wantErr: false,
 		},
 		{
 			name: "content 2022-03-16. strange time, place, topic format",
@@ -260,7 +257,6 @@ func TestParser_prepare(t *testing.T) {
 					"скачать информационный материал >>>",
 				},
 			},
-			wantErr: false,
 		},
 		{
 			name: "content 2022-08-17. different word case and broken content",
@@ -292,7 +288,6 @@ func TestParser_prepare(t *testing.T) {
 					"скачать постановление Главы >>>",
 				},
 			},
-			wantErr: false,
 		},
 		{
 			name: "content 2022-02-24. multi date, multi venue",
@@ -329,14 +324,14 @@ func TestParser_prepare(t *testing.T) {
 				},
 			},
 			want:    domain.Hearing{},
-			wantErr: true,
+			wantErr: ErrNoDate,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got, err := p.prepare(tt.input)
-			if tt.wantErr {
-				require.Error(t, err)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
 			} else {
 				require.NoError(t, err)
 				require.Equal(t, tt.want, got, tt.name)
@@ -344,3 +339,156 @@ func TestParser_prepare(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_Report(t *testing.T) {
+	p := NewParser()
+
+	_, report, err := p.Report(domain.Hearing{
+		URL: "https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-17-marta-2021-goda/",
+		Raw: []string{
+			"Информация о публичных слушаниях",
+			"17 марта 2021 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания по следующим вопросам:",
+			"-по проекту планировки территории по ул. Фосфоритной;",
+			"-по проекту межевания территории по ул. Речной;",
+			"Экспозиция проекта будет проводиться с 25 января по 25 февраля 2021 года",
+			"Приём предложений осуществляет оргкомитет до 16 марта 2021 года",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ParseReport{
+		Paragraphs:         6,
+		PlaceParagraph:     1,
+		TopicParagraphs:    []int{2, 3},
+		ProposalParagraphs: []int{5},
+	}, report)
+
+	_, report, err = p.Report(domain.Hearing{Raw: []string{"17 марта 2021 года в 11.00 в ГДК Советского района"}})
+	require.ErrorIs(t, err, ErrNoTopics)
+	require.Equal(t, ErrNoTopics.Error(), report.Cause)
+	require.Equal(t, "17 марта 2021 года в 11.00 в ГДК Советского района", report.Paragraph)
+}
+
+func TestParser_Status(t *testing.T) {
+	p := NewParser()
+	tests := []struct {
+		name    string
+		content []string
+		want    domain.Status
+	}{
+		{name: "empty", content: nil, want: ""},
+		{name: "regular", content: []string{"17 марта 2021 года в 11.00 в ГДК Советского района состоятся публичные слушания"}, want: ""},
+		{name: "cancelled", content: []string{"Публичные слушания, назначенные на 17 марта 2021 года, ОТМЕНЕНЫ."}, want: domain.StatusCancelled},
+		{name: "postponed", content: []string{"Публичные слушания перенесены на 24 марта 2021 года."}, want: domain.StatusPostponed},
+		{name: "cancelled lowercase", content: []string{"Публичные слушания по проекту планировки отменены"}, want: domain.StatusCancelled},
+		{name: "cancelled in topic", content: []string{
+			"17 марта 2021 года в 11.00 в ГДК Советского района состоятся публичные слушания по следующим вопросам:",
+			"-по проекту решения о признании отмененного постановления утратившим силу;",
+			"-по проекту планировки территории, отмененной в 2020 году;",
+		}, want: ""},
+		{name: "postponed in topic", content: []string{
+			"17 марта 2021 года в 11.00 в ГДК Советского района состоятся публичные слушания по проекту планировки территории перенесенного рынка",
+			"Приём предложений до 16 марта, срок перенесен не будет",
+		}, want: ""},
+		{name: "cancelled in proposals", content: []string{"Приём предложений к отмененному проекту не осуществляется"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, p.Status(tt.content), tt.name)
+		})
+	}
+}
+
+func TestParser_extractTime(t *testing.T) {
+	p := NewParser()
+	tests := []struct {
+		name      string
+		paragraph string
+		link      string
+		wantTime  time.Time
+		wantPlace string
+		wantErr   error
+	}{
+		{name: "empty", wantErr: ErrNoDate, paragraph: ""},
+		{
+			name:      "full date",
+			paragraph: "26 февраля 2021 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66)",
+			wantTime:  time.Date(2021, time.February, 26, 11, 0, 0, 0, serviceTimeLocation),
+			wantPlace: "ГДК Советского района (ул. Калинина, д. 66)",
+		},
+		{
+			name:      "year from link",
+			paragraph: "30 сентября в 11.00 в ГДК Советского района (ул. Калинина, д. 66)",
+			link:      "https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-30-sentyabrya-2021-goda/",
+			wantTime:  time.Date(2021, time.September, 30, 11, 0, 0, 0, serviceTimeLocation),
+			wantPlace: "ГДК Советского района (ул. Калинина, д. 66)",
+		},
+		{name: "unknown month", wantErr: ErrNoDate, paragraph: "30 сентебря 2021 года в 11.00 в ГДК Советского района"},
+		{name: "day out of range", wantErr: ErrNoDate, paragraph: "30 февраля 2022 года в 11.00 в ГДК Советского района"},
+		{name: "time out of range", wantErr: ErrNoDate, paragraph: "3 марта 2022 года в 25.00 в ГДК Советского района"},
+		{name: "before beginning", wantErr: ErrDateBeforeEpoch, paragraph: "3 марта 2020 года в 11.00 в ГДК Советского района"},
+		{name: "huge number", wantErr: ErrNoDate, paragraph: "99999999999999999999 марта 2022 года в 11.00 в ГДК Советского района"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTime, gotPlace, err := p.extractTime(tt.paragraph, tt.link)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tt.wantTime, gotTime, tt.name)
+				require.Equal(t, tt.wantPlace, gotPlace, tt.name)
+			}
+		})
+	}
+}
+
+func FuzzParser_Content(f *testing.F) {
+	f.Add(
+		"https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-29-marta-2022-goda/",
+		"29 марта 2022 года в 11.00 по адресу: г.Брянск, ул. Клинцовская, д. 60 состоятся публичные слушания по проекту Постановления\n"+
+			"Приём предложений осуществляет оргкомитет до 28 марта 2022 года",
+	)
+	f.Add(
+		"https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-17-marta-2021-goda/",
+		"17 марта 2021 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66) состоятся публичные слушания по следующим вопросам:\n"+
+			"-по проекту планировки территории;\n"+
+			"Экспозиция проекта будет проводиться с 25 января по 25 февраля 2021 года",
+	)
+	f.Add(
+		"https://bga32.ru/informaciya-o-publichnyx-slushaniyax-naznachennyx-na-16-marta-2022-goda/",
+		"16 марта 2022 года в 11.00 по адресу: г. Брянск, ул. Дзержинского, д. 2а по проекту Постановления",
+	)
+	f.Add("", "Экспозиция проекта\nсостоятся публичные слушания")
+	f.Add("", "")
+
+	p := NewParser()
+	f.Fuzz(func(t *testing.T, link, text string) {
+		got, err := p.Content(domain.Hearing{URL: link, Raw: strings.Split(text, "\n")})
+		if err != nil {
+			var perr *ParseError
+			require.ErrorAs(t, err, &perr)
+			return
+		}
+		require.NotEmpty(t, got.Topic)
+		require.NotEmpty(t, got.Place)
+		require.False(t, got.Time.Before(beginnigTime))
+	})
+}
+
+func FuzzParser_extractTime(f *testing.F) {
+	f.Add("26 февраля 2021 года в 11.00 в ГДК Советского района (ул. Калинина, д. 66)", "")
+	f.Add("30 сентября в 11.00 в ГДК Советского района", "https://bga32.ru/naznachennyx-na-30-sentyabrya-2021-goda/")
+	f.Add("29 марта 2022 года в 11.00 по адресу: г.Брянск, ул. Клинцовская, д. 60", "")
+	f.Add("31 апреля 2022 года в 24:60 в ГДК", "")
+
+	p := NewParser()
+	f.Fuzz(func(t *testing.T, paragraph, link string) {
+		got, place, err := p.extractTime(paragraph, link)
+		if err != nil {
+			require.True(t, got.IsZero())
+			return
+		}
+		require.False(t, got.Before(beginnigTime))
+		require.Contains(t, paragraph, place)
+	})
+}
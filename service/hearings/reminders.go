@@ -0,0 +1,306 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
+)
+
+// Moments which reminders are scheduled before
+const (
+	// RemindHearing schedules reminder before time of hearing
+	RemindHearing = "hearing"
+	// RemindDeadline schedules reminder before the end of the last day of receiving proposals
+	RemindDeadline = "deadline"
+)
+
+// eventReminder is prefix of events of reminders
+const eventReminder = "reminder:"
+
+// Reminder about hearing posted to all channels once
+type Reminder struct {
+	// Name of reminder is a part of event of deliveries
+	Name string `yaml:"name"`
+	// Of is RemindHearing or RemindDeadline
+	Of string `yaml:"of"`
+	// Before is how long before the moment reminder is posted
+	Before time.Duration `yaml:"before"`
+	// Template of text of reminder, see text/template. Data is reminderData.
+	Template string `yaml:"template"`
+}
+
+type remindersFile struct {
+	Reminders []Reminder `yaml:"reminders"`
+}
+
+// reminder is compiled Reminder
+type reminder struct {
+	Reminder
+	tmpl *template.Template
+}
+
+// reminderData is data of templates of reminders
+type reminderData struct {
+	Hearing domain.Hearing
+	// Deadline is the last day of receiving proposals. It is zero if it is unknown.
+	Deadline time.Time
+}
+
+var reminderFuncs = template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("02.01.2006") },
+	"time": func(t time.Time) string { return t.Format("15:04") },
+	// topic returns the first topic of hearing and number of others
+	"topic": func(h domain.Hearing) string {
+		if len(h.Topic) == 0 {
+			return ""
+		}
+		if others := len(h.Topic) - 1; others > 0 {
+			return fmt.Sprintf("%s и ещё %d", h.Topic[0], others)
+		}
+		return h.Topic[0]
+	},
+}
+
+// DefaultReminders returns reminders one day and one hour before hearing and on the last day of proposals
+func DefaultReminders() []Reminder {
+	return []Reminder{
+		{
+			Name:     "day",
+			Of:       RemindHearing,
+			Before:   24 * time.Hour,
+			Template: "Завтра в {{time .Hearing.Time}} в {{.Hearing.Place}} состоятся публичные слушания {{topic .Hearing}}\n\n{{.Hearing.URL}}",
+		},
+		{
+			Name:     "hour",
+			Of:       RemindHearing,
+			Before:   time.Hour,
+			Template: "Через час, в {{time .Hearing.Time}}, в {{.Hearing.Place}} начнутся публичные слушания {{topic .Hearing}}\n\n{{.Hearing.URL}}",
+		},
+		{
+			Name:     "deadline",
+			Of:       RemindDeadline,
+			Before:   15 * time.Hour,
+			Template: "Сегодня последний день приёма предложений к публичным слушаниям {{date .Hearing.Time}} {{topic .Hearing}}\n\n{{.Hearing.URL}}",
+		},
+	}
+}
+
+// ParseReminders returns validated reminders from YAML document
+func ParseReminders(data []byte) ([]Reminder, error) {
+	var file remindersFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed decode reminders: %w", err)
+	}
+	if _, err := compileReminders(file.Reminders); err != nil {
+		return nil, err
+	}
+	return file.Reminders, nil
+}
+
+// ReadReminders returns validated reminders from YAML file
+func ReadReminders(filename string) ([]Reminder, error) {
+	data, err := os.ReadFile(path.Clean(filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed read reminders: %w", err)
+	}
+	return ParseReminders(data)
+}
+
+func compileReminders(reminders []Reminder) ([]reminder, error) {
+	res := make([]reminder, 0, len(reminders))
+	names := make(map[string]struct{})
+	for i, r := range reminders {
+		switch {
+		case r.Name == "":
+			return nil, fmt.Errorf("invalid reminders: reminder #%d: empty name", i+1)
+		case r.Of != RemindHearing && r.Of != RemindDeadline:
+			return nil, fmt.Errorf("invalid reminders: reminder %s: unknown moment %q", r.Name, r.Of)
+		case r.Before < 0:
+			return nil, fmt.Errorf("invalid reminders: reminder %s: negative time before", r.Name)
+		case strings.TrimSpace(r.Template) == "":
+			return nil, fmt.Errorf("invalid reminders: reminder %s: empty template", r.Name)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("invalid reminders: reminder %s: duplicate name", r.Name)
+		}
+		names[r.Name] = struct{}{}
+		tmpl, err := template.New(r.Name).Funcs(reminderFuncs).Option("missingkey=error").Parse(r.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reminders: reminder %s: %w", r.Name, err)
+		}
+		res = append(res, reminder{Reminder: r, tmpl: tmpl})
+	}
+	return res, nil
+}
+
+var reDeadline = regexp.MustCompile(`(?i)до` + spaces + `+(\d{1,2})` + spaces + `+(\p{L}+)(?:` + spaces + `+(\d{4}))?`)
+
+// ProposalDeadline returns the last day of receiving proposals mentioned in proposals of hearing.
+// Year of hearing is used if year is not mentioned.
+func ProposalDeadline(h domain.Hearing) (time.Time, bool) {
+	for _, p := range h.Proposals {
+		for _, m := range reDeadline.FindAllStringSubmatch(p, -1) {
+			month, ok := months[strings.ToLower(m[2])]
+			if !ok {
+				continue
+			}
+			day, _ := strconv.Atoi(m[1])
+			year := h.Time.Year()
+			if m[3] != "" {
+				year, _ = strconv.Atoi(m[3])
+			}
+			return time.Date(year, month, day, 0, 0, 0, 0, serviceTimeLocation), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// moment returns time which reminder is scheduled before
+func (r reminder) moment(h domain.Hearing) (time.Time, time.Time, bool) {
+	if r.Of == RemindHearing {
+		deadline, _ := ProposalDeadline(h)
+		return h.Time, deadline, true
+	}
+	deadline, ok := ProposalDeadline(h)
+	if !ok {
+		return time.Time{}, deadline, false
+	}
+	return deadline.AddDate(0, 0, 1), deadline, true
+}
+
+// Remind enqueues due reminders about published hearings and returns number of enqueued deliveries.
+// Reminder is due after its time until the moment it is scheduled before, so missed reminders are not posted late.
+// Moment is a part of event, so postponed hearing is reminded again.
+func (s Service) Remind(ctx context.Context, now time.Time) (int, error) {
+	if len(s.reminders) == 0 {
+		return 0, nil
+	}
+	l := s.logger.With().Str("method", "Remind").Logger()
+	published := true
+	page, err := s.db.Query(ctx, database.ListOptions{From: now, Published: &published, Now: now})
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get hearings")
+		return 0, err
+	}
+
+	deliveries := make([]database.Delivery, 0)
+	slots := make(map[string]time.Time)
+	for _, h := range page.Hearings {
+		h.Status = h.CurrentStatus(now)
+		if h.Status == domain.StatusCancelled {
+			continue
+		}
+		// Enqueued reminders are skipped before slots are taken, so they do not delay new ones
+		enqueued, err := s.db.Deliveries(ctx, h.ID)
+		if err != nil {
+			l.Error().Err(err).Str("hearing", h.ID).Msg("failed to get deliveries")
+			return 0, err
+		}
+		for _, r := range s.reminders {
+			moment, deadline, ok := r.moment(h)
+			if !ok || now.Before(moment.Add(-r.Before)) || !now.Before(moment) {
+				continue
+			}
+			var text bytes.Buffer
+			if err = r.tmpl.Execute(&text, reminderData{Hearing: h, Deadline: deadline}); err != nil {
+				l.Error().Err(err).Str("reminder", r.Name).Str("hearing", h.ID).Msg("failed to render reminder")
+				continue
+			}
+			event := eventReminder + r.Name + ":" + moment.Format("2006-01-02T15:04")
+			for _, t := range s.targets {
+				if isEnqueued(enqueued, t.Name, event) {
+					continue
+				}
+				f := t.Format
+				if f == "" {
+					f = publisher.FormatMarkdown
+				}
+				// Reminder keeps schedule of target, but it is not posted after its moment
+				at, err := s.slot(ctx, t, slots, now)
+				if err != nil {
+					return 0, err
+				}
+				if at.After(moment) {
+					at = moment
+				}
+				deliveries = append(deliveries, database.Delivery{
+					HearingID:     h.ID,
+					Channel:       t.Name,
+					Event:         event,
+					Message:       s.message(t.Name, f, event, render.Data{Hearing: h, Text: text.String()}),
+					NextAttemptAt: at,
+				})
+			}
+		}
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	n, err := s.db.Enqueue(ctx, deliveries, false)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to enqueue reminders")
+		return 0, err
+	}
+	if n > 0 {
+		l.Info().Int("deliveries", n).Msg("reminders enqueued")
+	}
+	return n, nil
+}
+
+// RunReminders enqueues due reminders every interval until context is done
+func (s Service) RunReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Remind(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("method", "RunReminders").Msg("failed to enqueue reminders")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isEnqueued reports whether there is delivery of event to channel
+func isEnqueued(deliveries []database.Delivery, channel, event string) bool {
+	for _, d := range deliveries {
+		if d.Channel == channel && d.Event == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/database/memory"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+func TestProposalDeadline(t *testing.T) {
+	h := domain.Hearing{
+		Time:      time.Date(2099, time.March, 17, 11, 0, 0, 0, serviceTimeLocation),
+		Proposals: testHearingContent[4:],
+	}
+	deadline, ok := ProposalDeadline(h)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2099, time.March, 16, 0, 0, 0, 0, serviceTimeLocation), deadline)
+
+	h.Proposals = []string{"Предложения принимаются до 5 марта по адресу ул. Калинина, 66"}
+	deadline, ok = ProposalDeadline(h)
+	require.True(t, ok)
+	require.Equal(t, time.Date(2099, time.March, 5, 0, 0, 0, 0, serviceTimeLocation), deadline)
+
+	h.Proposals = []string{"Предложения принимаются в течение всего срока"}
+	_, ok = ProposalDeadline(h)
+	require.False(t, ok)
+}
+
+func TestParseReminders(t *testing.T) {
+	reminders, err := ParseReminders([]byte(`
+reminders:
+  - name: week
+    of: hearing
+    before: 168h
+    template: "Через неделю: {{topic .Hearing}}"
+`))
+	require.NoError(t, err)
+	require.Equal(t, []Reminder{{Name: "week", Of: RemindHearing, Before: 7 * 24 * time.Hour, Template: "Через неделю: {{topic .Hearing}}"}}, reminders)
+
+	for _, doc := range []string{
+		"reminders: [{of: hearing, before: 1h, template: text}]",
+		"reminders: [{name: a, of: start, before: 1h, template: text}]",
+		"reminders: [{name: a, of: hearing, before: -1h, template: text}]",
+		"reminders: [{name: a, of: hearing, before: 1h}]",
+		"reminders: [{name: a, of: hearing, before: 1h, template: '{{.Unknown'}]",
+		"reminders: [{name: a, of: hearing, before: 1h, template: a}, {name: a, of: deadline, before: 1h, template: b}]",
+		"reminders: [{name: a, when: hearing}]",
+	} {
+		_, err = ParseReminders([]byte(doc))
+		require.Error(t, err, doc)
+	}
+	_, err = compileReminders(DefaultReminders())
+	require.NoError(t, err)
+}
+
+func TestReadReminders_example(t *testing.T) {
+	reminders, err := ReadReminders("../../reminders.example.yml")
+	require.NoError(t, err)
+	require.Equal(t, DefaultReminders(), reminders)
+}
+
+func TestService_Remind(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	repo := memory.New()
+	s := New(&Config{
+		Database:  repo,
+		Logger:    &logger,
+		Targets:   []publisher.Target{{Name: "site", Format: publisher.FormatText, Publisher: nil}},
+		Reminders: DefaultReminders(),
+	})
+
+	now := time.Date(2030, time.June, 5, 10, 0, 0, 0, serviceTimeLocation)
+	hearing := domain.Hearing{
+		URL:       "https://example.com/1",
+		Time:      now.Add(23 * time.Hour),
+		Place:     "ГДК Советского района",
+		Topic:     []string{"по проекту планировки территории по ул. Речной"},
+		Proposals: []string{"Приём предложений осуществляет оргкомитет до 5 июня 2030 года"},
+	}
+	require.NoError(t, repo.Create(ctx, hearing))
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/2", Time: now.Add(time.Hour), Place: "ДК"}))
+
+	n, err := s.Remind(ctx, now)
+	require.NoError(t, err)
+	require.Zero(t, n, "unpublished hearings are not reminded")
+
+	require.NoError(t, repo.MarkPublished(ctx, hearing.URL))
+	n, err = s.Remind(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 2, n, "reminders of the day before and of the last day of proposals")
+	n, err = s.Remind(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, n, "reminder is enqueued once")
+
+	deliveries, err := repo.Deliveries(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	messages := map[string]string{}
+	for _, d := range deliveries {
+		require.Equal(t, "site", d.Channel)
+		messages[d.Event] = d.Message
+	}
+	require.Equal(t, "Завтра в 09:00 в ГДК Советского района состоятся публичные слушания по проекту планировки территории по ул. Речной\n\nhttps://example.com/1",
+		messages["reminder:day:2030-06-06T09:00"])
+	require.Contains(t, messages["reminder:deadline:2030-06-06T00:00"], "Сегодня последний день приёма предложений к публичным слушаниям 06.06.2030")
+
+	n, err = s.Remind(ctx, now.Add(22*time.Hour+30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "reminder an hour before")
+	n, err = s.Remind(ctx, now.Add(23*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n, "reminders are not posted after hearing is started")
+}
+
+func TestService_Remind_schedule(t *testing.T) {
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	repo := memory.New()
+	schedule := publisher.Schedule{
+		Windows:  []publisher.Window{{From: 12 * 60, To: 13 * 60}},
+		Spacing:  30 * time.Minute,
+		Location: serviceTimeLocation,
+	}
+	s := New(&Config{
+		Database:  repo,
+		Logger:    &logger,
+		Targets:   []publisher.Target{{Name: "site", Format: publisher.FormatText, Schedule: schedule}},
+		Reminders: DefaultReminders(),
+	})
+
+	now := time.Date(2030, time.June, 5, 10, 0, 0, 0, serviceTimeLocation)
+	hearing := domain.Hearing{
+		URL:       "https://example.com/1",
+		Time:      now.Add(23 * time.Hour),
+		Place:     "ГДК Советского района",
+		Topic:     []string{"по проекту планировки территории по ул. Речной"},
+		Proposals: []string{"Приём предложений осуществляет оргкомитет до 5 июня 2030 года"},
+	}
+	require.NoError(t, repo.Create(ctx, hearing))
+	require.NoError(t, repo.MarkPublished(ctx, hearing.URL))
+
+	n, err := s.Remind(ctx, now)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	n, err = s.Remind(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, n)
+	deliveries, err := repo.Deliveries(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	slots := []time.Time{deliveries[0].NextAttemptAt, deliveries[1].NextAttemptAt}
+	require.ElementsMatch(t, []time.Time{
+		time.Date(2030, time.June, 5, 12, 0, 0, 0, serviceTimeLocation),
+		time.Date(2030, time.June, 5, 12, 30, 0, 0, serviceTimeLocation),
+	}, []time.Time{slots[0].In(serviceTimeLocation), slots[1].In(serviceTimeLocation)},
+		"reminders wait for window and are kept apart by spacing")
+
+	// The next window is after the hearing is started
+	n, err = s.Remind(ctx, now.Add(22*time.Hour+30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	deliveries, err = repo.Deliveries(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	require.True(t, hearing.Time.Equal(deliveries[2].NextAttemptAt), "reminder must not be posted after its moment")
+}
+
+func TestService_Remind_sqlite(t *testing.T) {
+	inMoscow(t)
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	repo, err := database.New("sqlite://"+filepath.Join(t.TempDir(), "test"), &logger)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = repo.Close() })
+	s := New(&Config{
+		Database:  repo,
+		Logger:    &logger,
+		Targets:   []publisher.Target{{Name: "site", Format: publisher.FormatText, Publisher: nil}},
+		Reminders: DefaultReminders(),
+	})
+
+	at := time.Date(2030, time.June, 6, 11, 0, 0, 0, serviceTimeLocation)
+	hearing := domain.Hearing{
+		URL:   "https://example.com/1",
+		Time:  at,
+		Place: "ГДК Советского района",
+		Topic: []string{"по проекту планировки территории по ул. Речной"},
+	}
+	require.NoError(t, repo.Create(ctx, hearing))
+	require.NoError(t, repo.MarkPublished(ctx, hearing.URL))
+
+	n, err := s.Remind(ctx, at.Add(-24*time.Hour-30*time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, n, "reminder of the day before is not due yet")
+	n, err = s.Remind(ctx, at.Add(-23*time.Hour-30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "reminder of the day before")
+	n, err = s.Remind(ctx, at.Add(-30*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 1, n, "reminder an hour before")
+	n, err = s.Remind(ctx, at.Add(2*time.Hour+30*time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, n, "reminders are not posted after hearing is started")
+
+	deliveries, err := repo.Deliveries(ctx, "1")
+	require.NoError(t, err)
+	events := make([]string, 0, len(deliveries))
+	for _, d := range deliveries {
+		events = append(events, d.Event)
+	}
+	require.ElementsMatch(t, []string{"reminder:day:2030-06-06T11:00", "reminder:hour:2030-06-06T11:00"}, events)
+}
@@ -0,0 +1,282 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"time"
+
+	"github.com/brurbanko/mercury/domain"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source of public hearings with rules of parsing its pages
+type Source struct {
+	// Name of source used in logs and reports
+	Name string `yaml:"name"`
+	// URL of page with list of hearings
+	URL string `yaml:"url"`
+	// LinksSelector selects links to hearings on the list page
+	LinksSelector string `yaml:"links_selector"`
+	// ContentSelector selects paragraphs on the hearing page
+	ContentSelector string `yaml:"content_selector"`
+	// Patterns of paragraphs. Empty patterns are replaced by default ones.
+	Patterns Patterns `yaml:"patterns"`
+}
+
+// Rules of parsing public hearings
+type Rules struct {
+	Sources []Source `yaml:"sources"`
+}
+
+// source is compiled Source
+type source struct {
+	Source
+	host   string
+	parser *Parser
+}
+
+// DefaultSource returns source of public hearings on bga32.ru
+func DefaultSource() Source {
+	return Source{
+		Name:            "bga32",
+		URL:             "https://bga32.ru/arxitektura-i-gradostroitelstvo/publichnye-slushaniya/",
+		LinksSelector:   ".thecontent ol li a",
+		ContentSelector: ".thecontent p",
+		Patterns:        DefaultPatterns(),
+	}
+}
+
+// DefaultRules returns rules with the only default source
+func DefaultRules() Rules {
+	return Rules{Sources: []Source{DefaultSource()}}
+}
+
+// ParseRules returns validated rules from YAML document
+func ParseRules(data []byte) (Rules, error) {
+	var rules Rules
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return rules, fmt.Errorf("failed decode rules: %w", err)
+	}
+	if _, err := rules.compile(); err != nil {
+		return rules, err
+	}
+	return rules, nil
+}
+
+// ReadRules returns validated rules from YAML file
+func ReadRules(filename string) (Rules, error) {
+	data, err := os.ReadFile(path.Clean(filename))
+	if err != nil {
+		return Rules{}, fmt.Errorf("failed read rules: %w", err)
+	}
+	return ParseRules(data)
+}
+
+// Validate rules of all sources
+func (r Rules) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+func (r Rules) compile() ([]source, error) {
+	if len(r.Sources) == 0 {
+		return nil, fmt.Errorf("invalid rules: no sources")
+	}
+	sources := make([]source, 0, len(r.Sources))
+	names := make(map[string]struct{})
+	for i, src := range r.Sources {
+		if src.Name == "" {
+			return nil, fmt.Errorf("invalid rules: source #%d: empty name", i+1)
+		}
+		if _, ok := names[src.Name]; ok {
+			return nil, fmt.Errorf("invalid rules: source %s: duplicate name", src.Name)
+		}
+		names[src.Name] = struct{}{}
+
+		u, err := url.Parse(src.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid rules: source %s: invalid url %q", src.Name, src.URL)
+		}
+		if src.LinksSelector == "" {
+			return nil, fmt.Errorf("invalid rules: source %s: empty links_selector", src.Name)
+		}
+		if src.ContentSelector == "" {
+			return nil, fmt.Errorf("invalid rules: source %s: empty content_selector", src.Name)
+		}
+
+		parser, err := NewParserWithPatterns(src.Patterns)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rules: source %s: %w", src.Name, err)
+		}
+		sources = append(sources, source{
+			Source: src,
+			host:   u.Hostname(),
+			parser: parser,
+		})
+	}
+	return sources, nil
+}
+
+// sourceFor returns source of link by its host.
+// If no one source matches then the first source is returned.
+func sourceFor(sources []source, link string) source {
+	u, err := url.Parse(link)
+	if err == nil {
+		for _, src := range sources {
+			if src.host == u.Hostname() {
+				return src
+			}
+		}
+	}
+	return sources[0]
+}
+
+// sources returns active sources of service
+func (s Service) sources() []source {
+	return s.rules.Load().([]source)
+}
+
+// SetRules validates and activates rules of parsing
+func (s Service) SetRules(rules Rules) error {
+	sources, err := rules.compile()
+	if err != nil {
+		return err
+	}
+	s.rules.Store(sources)
+	return nil
+}
+
+// LoadRules reads rules from file and activates them
+func (s Service) LoadRules(filename string) error {
+	rules, err := ReadRules(filename)
+	if err != nil {
+		return err
+	}
+	return s.SetRules(rules)
+}
+
+// WatchRules reloads rules when the file is changed.
+// Invalid rules are reported to log and the current rules stay active.
+func (s Service) WatchRules(ctx context.Context, filename string, interval time.Duration) {
+	l := s.logger.With().Str("method", "WatchRules").Str("filename", filename).Logger()
+
+	var modTime time.Time
+	if fi, err := os.Stat(filename); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fi, err := os.Stat(filename)
+		if err != nil {
+			l.Error().Err(err).Msg("failed to check rules file")
+			continue
+		}
+		if fi.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = fi.ModTime()
+
+		if err = s.LoadRules(filename); err != nil {
+			l.Error().Err(err).Msg("failed to reload rules. current rules stay active")
+			continue
+		}
+		l.Info().Msg("rules reloaded")
+	}
+}
+
+// RulesReport is result of checking rules against cached pages
+type RulesReport struct {
+	Checked int           `json:"checked"`
+	Parsed  int           `json:"parsed"`
+	Failed  int           `json:"failed"`
+	Changed int           `json:"changed"`
+	Skipped int           `json:"skipped"`
+	Results []RulesResult `json:"results"`
+}
+
+// RulesResult is result of parsing one cached page
+type RulesResult struct {
+	URL     string          `json:"url"`
+	Source  string          `json:"source"`
+	Hearing *domain.Hearing `json:"hearing,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Changed bool            `json:"changed"`
+}
+
+// DryRunRules parses cached pages of stored hearings with passed rules.
+// Pages missing in cache are skipped. Neither database nor active rules are changed.
+func (s Service) DryRunRules(ctx context.Context, rules Rules) (RulesReport, error) {
+	l := s.logger.With().Str("method", "DryRunRules").Logger()
+	report := RulesReport{Results: make([]RulesResult, 0)}
+
+	sources, err := rules.compile()
+	if err != nil {
+		return report, err
+	}
+
+	stored, err := s.db.List(ctx)
+	if err != nil {
+		l.Error().Err(err).Msg("failed to get list of hearings")
+		return report, err
+	}
+
+	for _, h := range stored {
+		src := sourceFor(sources, h.URL)
+		content, err := s.scrapper.ExtractCachedContent(h.URL, src.ContentSelector)
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		report.Checked++
+
+		res := RulesResult{URL: h.URL, Source: src.Name}
+		hp, err := src.parser.Content(domain.Hearing{URL: h.URL, Raw: content})
+		if err != nil {
+			report.Failed++
+			res.Error = err.Error()
+		} else {
+			report.Parsed++
+			res.Hearing = &hp
+			res.Changed = !hp.Time.Equal(h.Time) || hp.Place != h.Place ||
+				!reflect.DeepEqual(hp.Topic, h.Topic) || !reflect.DeepEqual(hp.Proposals, h.Proposals)
+			if res.Changed {
+				report.Changed++
+			}
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	return report, nil
+}
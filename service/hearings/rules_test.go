@@ -0,0 +1,257 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/domain"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "empty", data: "", wantErr: true},
+		{name: "no sources", data: "sources: []", wantErr: true},
+		{
+			name: "default patterns",
+			data: `
+sources:
+  - name: bga32
+    url: https://bga32.ru/arxitektura-i-gradostroitelstvo/publichnye-slushaniya/
+    links_selector: .thecontent ol li a
+    content_selector: .thecontent p
+`,
+		},
+		{
+			name: "unknown field",
+			data: `
+sources:
+  - name: bga32
+    url: https://bga32.ru/
+    link_selector: .thecontent ol li a
+    content_selector: .thecontent p
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid url",
+			data: `
+sources:
+  - name: bga32
+    url: bga32.ru
+    links_selector: a
+    content_selector: p
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate name",
+			data: `
+sources:
+  - {name: bga32, url: "https://bga32.ru/", links_selector: a, content_selector: p}
+  - {name: bga32, url: "https://bga32.ru/", links_selector: a, content_selector: p}
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid pattern",
+			data: `
+sources:
+  - name: bga32
+    url: https://bga32.ru/
+    links_selector: a
+    content_selector: p
+    patterns:
+      topic_start: '(состоятся'
+`,
+			wantErr: true,
+		},
+		{
+			name: "missing group",
+			data: `
+sources:
+  - name: bga32
+    url: https://bga32.ru/
+    links_selector: a
+    content_selector: p
+    patterns:
+      time_and_place: '(?P<day>\d+) (?P<month>\p{L}+) (?P<place>.*)'
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRules([]byte(tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestReadRules_example(t *testing.T) {
+	rules, err := ReadRules("../../rules.example.yml")
+	require.NoError(t, err)
+
+	sources, err := rules.compile()
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+
+	def := NewParser()
+	require.Equal(t, def.reTopicStart.String(), sources[0].parser.reTopicStart.String())
+	require.Equal(t, def.reTopicEnd.String(), sources[0].parser.reTopicEnd.String())
+	require.Equal(t, def.reMissprintTopic.String(), sources[0].parser.reMissprintTopic.String())
+	require.Equal(t, def.reProposalParagraph.String(), sources[0].parser.reProposalParagraph.String())
+	require.Equal(t, def.reTimePlace.String(), sources[0].parser.reTimePlace.String())
+	require.Equal(t, def.reClearLine.String(), sources[0].parser.reClearLine.String())
+	require.Equal(t, def.reYear.String(), sources[0].parser.reYear.String())
+	require.Equal(t, def.reCancelled.String(), sources[0].parser.reCancelled.String())
+	require.Equal(t, def.rePostponed.String(), sources[0].parser.rePostponed.String())
+}
+
+// writeRules writes rules file with passed modification time
+func writeRules(t *testing.T, filename, data string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filename, []byte(data), 0o600))
+	require.NoError(t, os.Chtimes(filename, modTime, modTime))
+}
+
+func testRules(name string) string {
+	return "sources:\n  - {name: " + name + ", url: \"https://bga32.ru/\", links_selector: a, content_selector: p}\n"
+}
+
+func TestService_LoadRules(t *testing.T) {
+	s, _ := newTestService(t, newFakeSite(t))
+	filename := filepath.Join(t.TempDir(), "rules.yml")
+
+	require.Error(t, s.LoadRules(filename), "missing file must not be loaded")
+	require.Equal(t, "bga32", s.sources()[0].Name)
+
+	writeRules(t, filename, testRules("custom"), time.Now())
+	require.NoError(t, s.LoadRules(filename))
+	require.Len(t, s.sources(), 1)
+	require.Equal(t, "custom", s.sources()[0].Name)
+	require.Equal(t, "bga32.ru", s.sources()[0].host)
+
+	writeRules(t, filename, "sources: []", time.Now())
+	require.Error(t, s.LoadRules(filename))
+	require.Equal(t, "custom", s.sources()[0].Name, "invalid rules must not replace active ones")
+}
+
+func TestService_WatchRules(t *testing.T) {
+	s, _ := newTestService(t, newFakeSite(t))
+	filename := filepath.Join(t.TempDir(), "rules.yml")
+	modTime := time.Now().Add(-time.Hour)
+	writeRules(t, filename, testRules("first"), modTime)
+	require.NoError(t, s.LoadRules(filename))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.WatchRules(ctx, filename, 10*time.Millisecond)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	name := func() string { return s.sources()[0].Name }
+
+	// Watcher may start after the first change, so the file is touched until it's reloaded
+	require.Eventually(t, func() bool {
+		modTime = modTime.Add(time.Minute)
+		if os.WriteFile(filename, []byte(testRules("second")), 0o600) != nil ||
+			os.Chtimes(filename, modTime, modTime) != nil {
+			return false
+		}
+		return name() == "second"
+	}, time.Second, 10*time.Millisecond)
+
+	writeRules(t, filename, "sources: [", modTime.Add(time.Minute))
+	require.Never(t, func() bool { return name() != "second" }, 100*time.Millisecond, 10*time.Millisecond,
+		"invalid rules must not replace active ones")
+
+	writeRules(t, filename, testRules("third"), modTime.Add(2*time.Minute))
+	require.Eventually(t, func() bool { return name() == "third" }, time.Second, 10*time.Millisecond,
+		"valid rules must be loaded after invalid ones")
+}
+
+func TestService_DryRunRules(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	_, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+
+	// Page is cached but the stored hearing differs from it
+	site.setPage("/2/", testHearingContent...)
+	_, err = s.scrapper.ExtractContent(ctx, site.URL+"/2/", ".thecontent p", false)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: site.URL + "/2/", Place: "old place"}))
+	// Page is not cached
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: site.URL + "/3/"}))
+
+	src := DefaultSource()
+	src.Name = "dry"
+	src.URL = site.URL + "/list/"
+	report, err := s.DryRunRules(ctx, Rules{Sources: []Source{src}})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Checked)
+	require.Equal(t, 2, report.Parsed)
+	require.Equal(t, 0, report.Failed)
+	require.Equal(t, 1, report.Changed)
+	require.Equal(t, 1, report.Skipped)
+	require.Len(t, report.Results, 2)
+	for _, res := range report.Results {
+		require.Equal(t, "dry", res.Source)
+		require.NotNil(t, res.Hearing)
+		require.Equal(t, res.URL == site.URL+"/2/", res.Changed, res.URL)
+	}
+
+	h, err := repo.Find(ctx, site.URL+"/2/")
+	require.NoError(t, err)
+	require.Equal(t, "old place", h.Place, "dry run must not change database")
+	require.Equal(t, "bga32", s.sources()[0].Name, "dry run must not change active rules")
+
+	src.ContentSelector = ".missing"
+	report, err = s.DryRunRules(ctx, Rules{Sources: []Source{src}})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Checked)
+	require.Equal(t, 2, report.Failed)
+	require.Equal(t, 0, report.Changed)
+	for _, res := range report.Results {
+		require.Nil(t, res.Hearing)
+		require.NotEmpty(t, res.Error)
+	}
+
+	_, err = s.DryRunRules(ctx, Rules{})
+	require.Error(t, err, "invalid rules must not be checked")
+}
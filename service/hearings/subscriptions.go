@@ -0,0 +1,181 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+	"github.com/brurbanko/mercury/internal/render"
+)
+
+// chatChannel is prefix of channel of deliveries to subscribed chat, identifier of chat follows it
+const chatChannel = "chat:"
+
+// minKeywordLength is minimal number of characters of keyword of subscription
+const minKeywordLength = 3
+
+// ChatPublisher sends messages to any chat. It is implemented by publisher.Telegram.
+type ChatPublisher interface {
+	PublishTo(ctx context.Context, chatID, message string) error
+}
+
+// chatResumer resumes partially published message to chat. It is implemented by publisher.Telegram.
+type chatResumer interface {
+	ResumeTo(ctx context.Context, chatID, message string, progress publisher.Progress) error
+}
+
+// Districts are all districts which chats may be subscribed to
+var Districts = []domain.District{
+	domain.DistrictBezhitsky, domain.DistrictVolodarsky, domain.DistrictSovetsky, domain.DistrictFokinsky,
+}
+
+// Categories are all categories which chats may be subscribed to
+var Categories = []domain.Category{
+	domain.CategoryMasterPlan, domain.CategoryZoning, domain.CategoryPermittedUse, domain.CategoryDeviation,
+	domain.CategoryPlanning, domain.CategoryImprovement, domain.CategoryOther,
+}
+
+// normalizeSubscription returns value of subscription in stored form.
+// District may be set by code or russian name, for example sovetsky or советский.
+func normalizeSubscription(kind, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch kind {
+	case database.SubscribeDistrict:
+		for _, d := range Districts {
+			name := strings.ToLower(d.Name())
+			if value == string(d) || value == name || strings.HasPrefix(name, value+" ") {
+				return string(d), nil
+			}
+		}
+		return "", fmt.Errorf("%w: unknown district %q", ErrInvalidSubscription, value)
+	case database.SubscribeCategory:
+		for _, c := range Categories {
+			if value == string(c) {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("%w: unknown category %q", ErrInvalidSubscription, value)
+	case database.SubscribeKeyword:
+		value = strings.Join(strings.Fields(value), " ")
+		if utf8.RuneCountInString(value) < minKeywordLength {
+			return "", fmt.Errorf("%w: keyword is shorter than %d characters", ErrInvalidSubscription, minKeywordLength)
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: unknown kind %q", ErrInvalidSubscription, kind)
+}
+
+// matches returns true if hearing concerns subscription
+func matches(sub database.Subscription, h domain.Hearing) bool {
+	switch sub.Kind {
+	case database.SubscribeDistrict:
+		return string(h.District) == sub.Value
+	case database.SubscribeCategory:
+		return string(h.Category) == sub.Value
+	case database.SubscribeKeyword:
+		for _, text := range append(append([]string{h.Place}, h.Topic...), h.Proposals...) {
+			if strings.Contains(strings.ToLower(text), sub.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Subscribe chat to hearings of district, category or with keyword.
+// ErrInvalidSubscription is returned for unknown kind or value,
+// database.ErrAlreadyExists is returned if chat is already subscribed.
+func (s Service) Subscribe(ctx context.Context, chatID, kind, value string) (database.Subscription, error) {
+	value, err := normalizeSubscription(kind, value)
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	sub, err := s.db.AddSubscription(ctx, database.Subscription{ChatID: chatID, Kind: kind, Value: value})
+	if err != nil {
+		s.logger.Warn().Err(err).Str("method", "Subscribe").Str("chat", chatID).Msg("failed to add subscription")
+	}
+	return sub, err
+}
+
+// Subscriptions of chat in order of creation
+func (s Service) Subscriptions(ctx context.Context, chatID string) ([]database.Subscription, error) {
+	res, err := s.db.Subscriptions(ctx, chatID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "Subscriptions").Str("chat", chatID).Msg("failed to get subscriptions")
+	}
+	return res, err
+}
+
+// DeleteSubscriptions of chat and returns number of deleted ones. All subscriptions are deleted if kind is empty.
+func (s Service) DeleteSubscriptions(ctx context.Context, chatID, kind, value string) (int, error) {
+	if kind != "" {
+		var err error
+		if value, err = normalizeSubscription(kind, value); err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.db.DeleteSubscriptions(ctx, chatID, kind, value)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "DeleteSubscriptions").Str("chat", chatID).Msg("failed to delete subscriptions")
+	}
+	return n, err
+}
+
+// subscriberDeliveries returns deliveries of published hearings to chats subscribed to them.
+// Chat receives one message about hearing even if several subscriptions match it.
+func (s Service) subscriberDeliveries(ctx context.Context, hearings []domain.Hearing) ([]database.Delivery, error) {
+	if s.bot == nil || len(hearings) == 0 {
+		return nil, nil
+	}
+	subs, err := s.db.Subscriptions(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]database.Delivery, 0)
+	for _, h := range hearings {
+		chats := make(map[string]bool)
+		for _, sub := range subs {
+			if chats[sub.ChatID] || !matches(sub, h) {
+				continue
+			}
+			chats[sub.ChatID] = true
+			deliveries = append(deliveries, database.Delivery{
+				HearingID: h.ID,
+				Channel:   chatChannel + sub.ChatID,
+				Event:     EventPublished,
+				Message:   s.message(ChannelBot, publisher.FormatMarkdown, EventPublished, render.Data{Hearing: h}),
+			})
+		}
+	}
+	return deliveries, nil
+}
+
+// sendChat sends delivery to subscribed chat
+func (s Service) sendChat(ctx context.Context, d database.Delivery) error {
+	if s.bot == nil {
+		return fmt.Errorf("%w: bot is disabled", publisher.ErrPermanent)
+	}
+	chatID := strings.TrimPrefix(d.Channel, chatChannel)
+	if r, ok := s.bot.(chatResumer); ok && d.SentParts > 0 {
+		return r.ResumeTo(ctx, chatID, d.Message, publisher.Progress{Parts: d.SentParts, MessageID: d.LastMessageID})
+	}
+	return s.bot.PublishTo(ctx, chatID, d.Message)
+}
@@ -0,0 +1,113 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+)
+
+// fakeChats records messages sent to chats
+type fakeChats struct {
+	mu       sync.Mutex
+	messages map[string][]string
+}
+
+func (f *fakeChats) PublishTo(_ context.Context, chatID, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages[chatID] = append(f.messages[chatID], message)
+	return nil
+}
+
+func TestService_Subscribe(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, newFakeSite(t))
+
+	sub, err := s.Subscribe(ctx, "1", database.SubscribeDistrict, "Советский")
+	require.NoError(t, err)
+	require.Equal(t, "sovetsky", sub.Value)
+	_, err = s.Subscribe(ctx, "1", database.SubscribeDistrict, "sovetsky")
+	require.ErrorIs(t, err, database.ErrAlreadyExists)
+	_, err = s.Subscribe(ctx, "1", database.SubscribeDistrict, "советский район")
+	require.ErrorIs(t, err, database.ErrAlreadyExists)
+
+	for _, tc := range [][2]string{
+		{database.SubscribeDistrict, "центральный"},
+		{database.SubscribeCategory, "roads"},
+		{database.SubscribeKeyword, " ул "},
+		{"street", "Речная"},
+	} {
+		_, err = s.Subscribe(ctx, "1", tc[0], tc[1])
+		require.ErrorIs(t, err, ErrInvalidSubscription, tc)
+	}
+
+	sub, err = s.Subscribe(ctx, "1", database.SubscribeKeyword, "  Улица   Речная ")
+	require.NoError(t, err)
+	require.Equal(t, "улица речная", sub.Value)
+
+	subs, err := s.Subscriptions(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+
+	n, err := s.DeleteSubscriptions(ctx, "1", database.SubscribeDistrict, "советский")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	n, err = s.DeleteSubscriptions(ctx, "1", "", "")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestService_Publish_subscriptions(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, _ := newTestService(t, site)
+	chats := &fakeChats{messages: make(map[string][]string)}
+	s.bot = chats
+
+	for _, sub := range [][3]string{
+		{"1", database.SubscribeDistrict, "sovetsky"},
+		{"1", database.SubscribeKeyword, "речной"},
+		{"2", database.SubscribeCategory, "zoning"},
+		{"3", database.SubscribeKeyword, "Фосфоритной"},
+		{"4", database.SubscribeCategory, "planning"},
+	} {
+		_, err := s.Subscribe(ctx, sub[0], sub[1], sub[2])
+		require.NoError(t, err)
+	}
+
+	_, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	_, err = s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+
+	chats.mu.Lock()
+	defer chats.mu.Unlock()
+	received := make([]string, 0, len(chats.messages))
+	for chat, messages := range chats.messages {
+		received = append(received, chat)
+		require.Len(t, messages, 1, "chat %s receives one message about hearing", chat)
+		require.Contains(t, messages[0], "ГДК Советского района")
+	}
+	sort.Strings(received)
+	require.Equal(t, []string{"1", "3", "4"}, received)
+}
@@ -0,0 +1,218 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+// Events of webhooks
+const (
+	// WebhookCreated is sent when new hearing is found
+	WebhookCreated = "hearing.created"
+	// WebhookUpdated is sent when time of hearing is changed
+	WebhookUpdated = "hearing.updated"
+	// WebhookCancelled is sent when hearing is cancelled
+	WebhookCancelled = "hearing.cancelled"
+	// WebhookPublished is sent when hearing is published to channels
+	WebhookPublished = "hearing.published"
+)
+
+// WebhookEvents are all events which webhooks may be subscribed to
+var WebhookEvents = []string{WebhookCreated, WebhookUpdated, WebhookCancelled, WebhookPublished}
+
+// Headers of webhook requests in addition to publisher.SignatureHeader
+const (
+	// EventHeader contains event of webhook request
+	EventHeader = "X-Mercury-Event"
+	// DeliveryHeader contains identifier of delivery. Retries of delivery have the same identifier.
+	DeliveryHeader = "X-Mercury-Delivery"
+)
+
+// webhookChannel is prefix of channel of deliveries to webhook, identifier of webhook follows it
+const webhookChannel = "webhook:"
+
+// webhookClient sends requests to webhooks
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// AddWebhook subscribes URL to events of hearings. All events are subscribed if events are empty.
+// Random secret is generated if secret is empty. Webhook with secret is returned.
+// ErrInvalidWebhook is returned for invalid URL or unknown event.
+func (s Service) AddWebhook(ctx context.Context, link, secret string, events []string) (database.Webhook, error) {
+	hook := database.Webhook{URL: strings.TrimSpace(link), Secret: secret}
+	u, err := url.Parse(hook.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return hook, fmt.Errorf("%w: invalid url %q", ErrInvalidWebhook, link)
+	}
+	if len(events) == 0 {
+		events = WebhookEvents
+	}
+	for _, e := range events {
+		if !subscribed(WebhookEvents, e) {
+			return hook, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+		if !subscribed(hook.Events, e) {
+			hook.Events = append(hook.Events, e)
+		}
+	}
+	if hook.Secret == "" {
+		b := make([]byte, 32)
+		if _, err = rand.Read(b); err != nil {
+			return hook, err
+		}
+		hook.Secret = hex.EncodeToString(b)
+	}
+
+	hook, err = s.db.AddWebhook(ctx, hook)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "AddWebhook").Str("url", hook.URL).Msg("failed to save webhook")
+		return hook, err
+	}
+	s.logger.Info().Str("method", "AddWebhook").Str("id", hook.ID).Str("url", hook.URL).Msg("webhook added")
+	return hook, nil
+}
+
+// Webhooks returns all webhooks without secrets
+func (s Service) Webhooks(ctx context.Context) ([]database.Webhook, error) {
+	hooks, err := s.db.Webhooks(ctx)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "Webhooks").Msg("failed to get webhooks")
+		return hooks, err
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+// DeleteWebhook unsubscribes webhook by identifier. Pending deliveries to webhook fail.
+func (s Service) DeleteWebhook(ctx context.Context, id string) error {
+	err := s.db.DeleteWebhook(ctx, id)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		s.logger.Error().Err(err).Str("method", "DeleteWebhook").Str("id", id).Msg("failed to delete webhook")
+	}
+	return err
+}
+
+// WebhookDeliveries returns log of deliveries to webhook. database.ErrNotFound is returned if webhook does not exist.
+func (s Service) WebhookDeliveries(ctx context.Context, id string) ([]database.Delivery, error) {
+	if _, err := s.db.Webhook(ctx, id); err != nil {
+		return nil, err
+	}
+	res, err := s.db.ChannelDeliveries(ctx, webhookChannel+id)
+	if err != nil {
+		s.logger.Error().Err(err).Str("method", "WebhookDeliveries").Str("id", id).Msg("failed to get deliveries")
+	}
+	return res, err
+}
+
+// webhookDeliveries returns deliveries of event about hearings to subscribed webhooks.
+// Payload of delivery is JSON of hearing. Hearing may be updated many times,
+// so hash of payload is a part of event of updates.
+func (s Service) webhookDeliveries(ctx context.Context, hearings []domain.Hearing, event string) ([]database.Delivery, error) {
+	if len(hearings) == 0 {
+		return nil, nil
+	}
+	hooks, err := s.db.Webhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]database.Delivery, 0)
+	for _, h := range hearings {
+		h.Raw = nil
+		payload, err := json.Marshal(h)
+		if err != nil {
+			return nil, err
+		}
+		e := event
+		if event == WebhookUpdated {
+			sum := sha256.Sum256(payload)
+			e += ":" + hex.EncodeToString(sum[:8])
+		}
+		for _, hook := range hooks {
+			if !subscribed(hook.Events, event) {
+				continue
+			}
+			deliveries = append(deliveries, database.Delivery{
+				HearingID: h.ID,
+				Channel:   webhookChannel + hook.ID,
+				Event:     e,
+				Message:   string(payload),
+			})
+		}
+	}
+	return deliveries, nil
+}
+
+// notify enqueues event about hearings to subscribed webhooks
+func (s Service) notify(ctx context.Context, event string, hearings ...domain.Hearing) {
+	l := s.logger.With().Str("method", "notify").Str("event", event).Logger()
+	deliveries, err := s.webhookDeliveries(ctx, hearings, event)
+	if err == nil && len(deliveries) > 0 {
+		_, err = s.db.Enqueue(ctx, deliveries, false)
+	}
+	if err != nil {
+		l.Error().Err(err).Msg("failed to enqueue webhook deliveries")
+	}
+}
+
+// sendWebhook sends delivery to webhook with signature
+func (s Service) sendWebhook(ctx context.Context, d database.Delivery) error {
+	hook, err := s.db.Webhook(ctx, strings.TrimPrefix(d.Channel, webhookChannel))
+	if errors.Is(err, database.ErrNotFound) {
+		return fmt.Errorf("%w: webhook is deleted", publisher.ErrPermanent)
+	}
+	if err != nil {
+		return err
+	}
+	p, err := publisher.NewWebhook(&publisher.WebhookOptions{
+		Logger:     s.logger,
+		URL:        hook.URL,
+		Secret:     hook.Secret,
+		HTTPClient: webhookClient,
+		Headers: map[string]string{
+			EventHeader:    strings.SplitN(d.Event, ":", 2)[0],
+			DeliveryHeader: d.ID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", publisher.ErrPermanent, err)
+	}
+	return p.Publish(ctx, d.Message)
+}
+
+// subscribed returns true if events contain event
+func subscribed(events []string, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+//   Copyright 2022 Alexander <sattellite> Groshev
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package hearings
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/brurbanko/mercury/database"
+	"github.com/brurbanko/mercury/domain"
+	"github.com/brurbanko/mercury/internal/publisher"
+)
+
+// webhookRequest is request received by fake webhook
+type webhookRequest struct {
+	event     string
+	delivery  string
+	signature string
+	body      []byte
+}
+
+func TestService_webhooks(t *testing.T) {
+	ctx := context.Background()
+	site := newFakeSite(t)
+	site.setPage("/1/", testHearingContent...)
+	s, repo := newTestService(t, site)
+
+	var mu sync.Mutex
+	var requests []webhookRequest
+	fail := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, webhookRequest{
+			event:     r.Header.Get(EventHeader),
+			delivery:  r.Header.Get(DeliveryHeader),
+			signature: r.Header.Get(publisher.SignatureHeader),
+			body:      body,
+		})
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(receiver.Close)
+
+	_, err := s.AddWebhook(ctx, "ftp://example.com", "", nil)
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+	_, err = s.AddWebhook(ctx, receiver.URL, "", []string{"hearing.deleted"})
+	require.ErrorIs(t, err, ErrInvalidWebhook)
+	all, err := s.AddWebhook(ctx, receiver.URL, "secret", nil)
+	require.NoError(t, err)
+	require.Equal(t, WebhookEvents, all.Events)
+	cancelled, err := s.AddWebhook(ctx, receiver.URL+"/cancelled", "", []string{WebhookCancelled, WebhookCancelled})
+	require.NoError(t, err)
+	require.Equal(t, []string{WebhookCancelled}, cancelled.Events)
+	require.NotEmpty(t, cancelled.Secret)
+
+	found, err := s.NewHearings(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	_, err = s.Publish(ctx, "markdown")
+	require.NoError(t, err)
+
+	// The first request fails and is retried later with the same delivery
+	log, err := s.WebhookDeliveries(ctx, all.ID)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	require.Equal(t, WebhookCreated, log[0].Event)
+	require.Equal(t, database.DeliveryPending, log[0].Status)
+	require.Equal(t, "api error 503", log[0].LastError)
+	require.Equal(t, WebhookPublished, log[1].Event)
+	require.Equal(t, database.DeliverySent, log[1].Status)
+	claimed, err := repo.ClaimDeliveries(ctx, log[0].NextAttemptAt, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.NoError(t, repo.FailDelivery(ctx, claimed[0].ID, claimed[0].Attempts, claimed[0].LastError, log[0].CreatedAt))
+	sent, err := s.Deliver(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, sent)
+
+	mu.Lock()
+	received := append([]webhookRequest(nil), requests...)
+	mu.Unlock()
+	require.Len(t, received, 3)
+	require.Equal(t, WebhookCreated, received[0].event)
+	require.Equal(t, WebhookPublished, received[1].event)
+	require.Equal(t, received[0].delivery, received[2].delivery, "retry must have the same delivery")
+	for _, r := range received {
+		require.Equal(t, publisher.Sign("secret", r.body), r.signature)
+	}
+	var payload domain.Hearing
+	require.NoError(t, json.Unmarshal(received[0].body, &payload))
+	require.Equal(t, found[0].ID, payload.ID)
+	require.Equal(t, found[0].URL, payload.URL)
+	require.Empty(t, payload.Raw)
+
+	log, err = s.WebhookDeliveries(ctx, cancelled.ID)
+	require.NoError(t, err)
+	require.Empty(t, log, "webhook must receive subscribed events only")
+
+	// Cancellation is sent to both webhooks, postponement is update
+	site.setPage("/1/", append([]string{"Публичные слушания ОТМЕНЕНЫ."}, testHearingContent...)...)
+	_, err = s.RefreshStatuses(ctx, "text", false)
+	require.NoError(t, err)
+	log, err = s.WebhookDeliveries(ctx, cancelled.ID)
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	require.Equal(t, WebhookCancelled, log[0].Event)
+	log, err = s.WebhookDeliveries(ctx, all.ID)
+	require.NoError(t, err)
+	require.Len(t, log, 3)
+
+	hooks, err := s.Webhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, hooks, 2)
+	require.Empty(t, hooks[0].Secret)
+
+	// Pending deliveries to deleted webhook fail without retries
+	require.NoError(t, s.DeleteWebhook(ctx, cancelled.ID))
+	_, err = s.Deliver(ctx)
+	require.NoError(t, err)
+	deliveries, err := repo.ChannelDeliveries(ctx, webhookChannel+cancelled.ID)
+	require.NoError(t, err)
+	require.Equal(t, database.DeliveryFailed, deliveries[0].Status)
+	require.Equal(t, "permanent publishing error: webhook is deleted", deliveries[0].LastError)
+	_, err = s.WebhookDeliveries(ctx, cancelled.ID)
+	require.ErrorIs(t, err, database.ErrNotFound)
+}
+
+func TestService_webhookDeliveries_updated(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestService(t, newFakeSite(t))
+	_, err := s.AddWebhook(ctx, "https://example.com/hook", "", []string{WebhookUpdated})
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, domain.Hearing{URL: "https://example.com/1", Place: "ГДК"}))
+	h, err := repo.Find(ctx, "https://example.com/1")
+	require.NoError(t, err)
+
+	s.notify(ctx, WebhookUpdated, h)
+	s.notify(ctx, WebhookUpdated, h)
+	h.Place = "ДК"
+	s.notify(ctx, WebhookUpdated, h)
+	s.notify(ctx, WebhookCreated, h)
+
+	deliveries, err := repo.Deliveries(ctx, h.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2, "the same update must be sent once and every other update must be sent")
+	require.NotEqual(t, deliveries[0].Event, deliveries[1].Event)
+}